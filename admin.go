@@ -0,0 +1,181 @@
+package martini
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Admin is a middleware serving an authenticated admin API under a prefix, to operate a running instance
+// without a deploy. The state it changes lives in the *Config mapped by martini.New, so it also can be
+// set from config files and the environment:
+//
+//	GET  /admin               the maintenance mode, the log level and the feature flags
+//	PUT  /admin/maintenance   {"enabled": true} answers every other request with 503 Service Unavailable
+//	PUT  /admin/log_level     {"level": "warn"} sets the "log.level" config read by Logger
+//	PUT  /admin/flags/:name   {"enabled": true} toggles a feature flag, read with Config.Flag
+//	GET  /admin/routes        the RouteStats, if Stats is set
+//	POST /admin/gc            runs the garbage collector and returns the heap stats
+//	GET  /admin/pprof/:name   the pprof profile, e.g. "heap" or "goroutine"
+//
+// Requests have to send the token in an "Authorization: Bearer" header. Without a token the admin API is
+// disabled. Add it before the other middleware, so maintenance mode stops requests early:
+//
+//	m.Use((&martini.Admin{Stats: stats}).Handler())
+//	m.Get("/checkout", func(config *martini.Config) {
+//	  if config.Flag("new_checkout") {
+//	    ...
+//	  }
+//	})
+type Admin struct {
+	// Prefix is the path the admin API is served under. Defaults to the "admin.prefix" config or "/admin".
+	Prefix string
+	// Token authenticates the requests. Defaults to the "admin.token" config, e.g. MARTINI_ADMIN_TOKEN.
+	Token string
+	// Stats are the route statistics served under /routes.
+	Stats *RouteStats
+
+	once   sync.Once
+	prefix string
+	router Router
+}
+
+// Handler returns the middleware handler serving the admin API and answering requests in maintenance mode.
+func (a *Admin) Handler() Handler {
+	return func(c Context, res http.ResponseWriter, req *http.Request, config *Config) {
+		a.once.Do(func() {
+			a.prefix = strings.TrimSuffix(a.Prefix, "/")
+			if a.Prefix == "" {
+				a.prefix = strings.TrimSuffix(config.String("admin.prefix", "/admin"), "/")
+			}
+			a.router = a.routes()
+		})
+
+		if req.URL.Path != a.prefix && !strings.HasPrefix(req.URL.Path, a.prefix+"/") {
+			if config.Bool("maintenance", false) {
+				res.Header().Set("Retry-After", "120")
+				http.Error(res, "down for maintenance", http.StatusServiceUnavailable)
+			}
+			return
+		}
+		token := a.Token
+		if token == "" {
+			token = config.String("admin.token", "")
+		}
+		if token == "" {
+			return
+		}
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") || subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(token)) != 1 {
+			res.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		a.router.Handle(res, req, c)
+	}
+}
+
+// adminState is the state of the instance rendered by GET /admin.
+type adminState struct {
+	Maintenance bool            `json:"maintenance"`
+	LogLevel    string          `json:"log_level"`
+	Flags       map[string]bool `json:"flags"`
+}
+
+// adminToggle is the body of the requests toggling the maintenance mode and the feature flags.
+type adminToggle struct {
+	Enabled *bool `json:"enabled"`
+}
+
+func (a *Admin) routes() Router {
+	r := NewRouter()
+	r.Group(a.prefix, func(r Router) {
+		r.Get("", func(res http.ResponseWriter, config *Config) {
+			adminJSON(res, adminState{
+				Maintenance: config.Bool("maintenance", false),
+				LogLevel:    config.String("log.level", "info"),
+				Flags:       config.Flags(),
+			})
+		})
+		r.Put("/maintenance", func(res http.ResponseWriter, req *http.Request, config *Config, log *log.Logger) {
+			var body adminToggle
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Enabled == nil {
+				http.Error(res, `expected {"enabled": true|false}`, http.StatusBadRequest)
+				return
+			}
+			config.Set("maintenance", strconv.FormatBool(*body.Enabled))
+			log.Printf("[admin] maintenance set to %v", *body.Enabled)
+			res.WriteHeader(http.StatusNoContent)
+		})
+		r.Put("/log_level", func(res http.ResponseWriter, req *http.Request, config *Config, log *log.Logger) {
+			var body struct {
+				Level string `json:"level"`
+			}
+			json.NewDecoder(req.Body).Decode(&body)
+			if _, ok := logLevels[body.Level]; !ok {
+				http.Error(res, `expected {"level": "info"|"warn"|"error"|"off"}`, http.StatusBadRequest)
+				return
+			}
+			config.Set("log.level", body.Level)
+			log.Printf("[admin] log level set to %s", body.Level)
+			res.WriteHeader(http.StatusNoContent)
+		})
+		r.Put("/flags/:name", func(res http.ResponseWriter, req *http.Request, params Params, config *Config, log *log.Logger) {
+			var body adminToggle
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Enabled == nil {
+				http.Error(res, `expected {"enabled": true|false}`, http.StatusBadRequest)
+				return
+			}
+			config.Set("flags."+params["name"], strconv.FormatBool(*body.Enabled))
+			log.Printf("[admin] flag %s set to %v", params["name"], *body.Enabled)
+			res.WriteHeader(http.StatusNoContent)
+		})
+		if a.Stats != nil {
+			r.Get("/routes", a.Stats.Dashboard())
+		}
+		r.Post("/gc", func(res http.ResponseWriter) {
+			runtime.GC()
+			debug.FreeOSMemory()
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			adminJSON(res, map[string]uint64{
+				"heap_alloc": stats.HeapAlloc,
+				"heap_sys":   stats.HeapSys,
+				"num_gc":     uint64(stats.NumGC),
+			})
+		})
+		r.Get("/pprof/:name", func(res http.ResponseWriter, req *http.Request, params Params) {
+			profile := pprof.Lookup(params["name"])
+			if profile == nil {
+				http.Error(res, "unknown profile "+params["name"], http.StatusNotFound)
+				return
+			}
+			level, _ := strconv.Atoi(req.URL.Query().Get("debug"))
+			if level == 0 {
+				res.Header().Set("Content-Type", "application/octet-stream")
+				res.Header().Set("Content-Disposition", `attachment; filename="`+params["name"]+`.pprof"`)
+			} else {
+				res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			}
+			profile.WriteTo(res, level)
+		})
+	})
+	return r
+}
+
+func adminJSON(res http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.Write(body)
+}
@@ -0,0 +1,91 @@
+package martini
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Admin(t *testing.T) {
+	stats := NewRouteStats()
+	m := Classic()
+	m.Use((&Admin{Token: "secret", Stats: stats}).Handler())
+	m.Use(stats.Handler())
+	m.Get("/checkout", func(config *Config) string {
+		if config.Flag("new_checkout") {
+			return "new"
+		}
+		return "old"
+	})
+
+	serve := func(method, path, token, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		m.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	expect(t, serve("GET", "/admin", "", "").Code, http.StatusUnauthorized)
+	expect(t, serve("GET", "/admin", "wrong", "").Code, http.StatusUnauthorized)
+	expect(t, serve("GET", "/checkout", "", "").Body.String(), "old")
+
+	expect(t, serve("PUT", "/admin/flags/new_checkout", "secret", `{"enabled": true}`).Code, http.StatusNoContent)
+	expect(t, serve("GET", "/checkout", "", "").Body.String(), "new")
+	expect(t, serve("PUT", "/admin/flags/new_checkout", "secret", `{}`).Code, http.StatusBadRequest)
+
+	expect(t, serve("PUT", "/admin/log_level", "secret", `{"level": "warn"}`).Code, http.StatusNoContent)
+	expect(t, serve("PUT", "/admin/log_level", "secret", `{"level": "verbose"}`).Code, http.StatusBadRequest)
+	expect(t, m.Config().String("log.level", ""), "warn")
+
+	expect(t, serve("PUT", "/admin/maintenance", "secret", `{"enabled": true}`).Code, http.StatusNoContent)
+	res := serve("GET", "/checkout", "", "")
+	expect(t, res.Code, http.StatusServiceUnavailable)
+	expect(t, res.Header().Get("Retry-After"), "120")
+
+	// the admin API stays available in maintenance mode
+	res = serve("GET", "/admin", "secret", "")
+	expect(t, res.Code, http.StatusOK)
+	var state adminState
+	expect(t, json.Unmarshal(res.Body.Bytes(), &state), nil)
+	expect(t, state.Maintenance, true)
+	expect(t, state.LogLevel, "warn")
+	expect(t, state.Flags["new_checkout"], true)
+
+	expect(t, serve("PUT", "/admin/maintenance", "secret", `{"enabled": false}`).Code, http.StatusNoContent)
+	expect(t, serve("GET", "/checkout", "", "").Code, http.StatusOK)
+
+	res = serve("GET", "/admin/routes", "secret", "")
+	expect(t, strings.Contains(res.Body.String(), "GET /checkout"), true)
+	expect(t, serve("POST", "/admin/gc", "secret", "").Code, http.StatusOK)
+	res = serve("GET", "/admin/pprof/goroutine?debug=1", "secret", "")
+	expect(t, strings.HasPrefix(res.Body.String(), "goroutine profile"), true)
+	expect(t, serve("GET", "/admin/pprof/nope", "secret", "").Code, http.StatusNotFound)
+}
+
+func Test_AdminConfig(t *testing.T) {
+	m := Classic()
+	m.Use((&Admin{}).Handler())
+	m.Get("/ops", func() string { return "app" })
+
+	// without a token the admin API is disabled
+	req, _ := http.NewRequest("GET", "/ops", nil)
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Body.String(), "app")
+
+	m = Classic()
+	m.Config().Set("admin.prefix", "/ops/")
+	m.Config().Set("admin.token", "secret")
+	m.Use((&Admin{}).Handler())
+	req, _ = http.NewRequest("GET", "/ops", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Code, http.StatusOK)
+	expect(t, strings.Contains(recorder.Body.String(), `"maintenance":false`), true)
+}
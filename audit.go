@@ -0,0 +1,80 @@
+package martini
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/codegangsta/inject"
+)
+
+// auditInjector guards the per-request injector of a Martini instance created in development mode. Mapping services
+// from another goroutine than the one serving the request, or after the request has been served, races
+// with the handler chain. Instead of surfacing as a mysterious data race later on, it is logged together
+// with the stack of the offending call.
+type auditInjector struct {
+	inject.Injector
+	owner    uint64
+	finished int32
+}
+
+func newAuditInjector() *auditInjector {
+	return &auditInjector{Injector: inject.New(), owner: goroutineID()}
+}
+
+func (i *auditInjector) Map(val interface{}) inject.TypeMapper {
+	i.check("Map", reflect.TypeOf(val))
+	i.Injector.Map(val)
+	return i
+}
+
+func (i *auditInjector) MapTo(val interface{}, ifacePtr interface{}) inject.TypeMapper {
+	i.check("MapTo", inject.InterfaceOf(ifacePtr))
+	i.Injector.MapTo(val, ifacePtr)
+	return i
+}
+
+func (i *auditInjector) Set(typ reflect.Type, val reflect.Value) inject.TypeMapper {
+	i.check("Set", typ)
+	i.Injector.Set(typ, val)
+	return i
+}
+
+// finish marks the request as served. Any later mapping is reported.
+func (i *auditInjector) finish() {
+	atomic.StoreInt32(&i.finished, 1)
+}
+
+func (i *auditInjector) check(method string, typ reflect.Type) {
+	var problem string
+	if atomic.LoadInt32(&i.finished) == 1 {
+		problem = "after the request has been served"
+	} else if id := goroutineID(); id != i.owner {
+		problem = "from goroutine " + strconv.FormatUint(id, 10) + " while the request is served by goroutine " + strconv.FormatUint(i.owner, 10)
+	} else {
+		return
+	}
+
+	logger, _ := i.Injector.Get(reflect.TypeOf((*log.Logger)(nil))).Interface().(*log.Logger)
+	if logger == nil {
+		return
+	}
+	logger.Printf("[audit] %s(%v) called on the request context %s. "+
+		"Map services before starting goroutines and hand values to them explicitly (or use Context.Go).\n%s",
+		method, typ, problem, stack(3))
+}
+
+// goroutineID parses the id of the current goroutine from its stack header.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
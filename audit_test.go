@@ -0,0 +1,56 @@
+package martini
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Audit_CrossGoroutineMap(t *testing.T) {
+	buff := bytes.NewBufferString("")
+
+	setENV(Dev)
+	m := New()
+	m.Map(log.New(buff, "[martini] ", 0))
+	m.Use(func(c Context) {
+		c.Map("same goroutine")
+		done := make(chan bool)
+		go func() {
+			c.Map(42)
+			close(done)
+		}()
+		<-done
+	})
+	m.ServeHTTP(httptest.NewRecorder(), (*http.Request)(nil))
+
+	expect(t, strings.Count(buff.String(), "[audit]"), 1)
+	expect(t, strings.Contains(buff.String(), "[audit] Map(int) called on the request context from goroutine"), true)
+}
+
+func Test_Audit_MapAfterServed(t *testing.T) {
+	buff := bytes.NewBufferString("")
+
+	setENV(Dev)
+	m := New()
+	m.Map(log.New(buff, "[martini] ", 0))
+	var ctx Context
+	m.Use(func(c Context) {
+		ctx = c
+	})
+	m.ServeHTTP(httptest.NewRecorder(), (*http.Request)(nil))
+	expect(t, buff.Len(), 0)
+
+	ctx.Map("late")
+	expect(t, strings.Contains(buff.String(), "[audit] Map(string) called on the request context after the request has been served"), true)
+}
+
+func Test_Audit_Production(t *testing.T) {
+	setENV(Prod)
+	defer setENV(Dev)
+	c := New().createContext(httptest.NewRecorder(), (*http.Request)(nil))
+	_, ok := c.Injector.(*auditInjector)
+	expect(t, ok, false)
+}
@@ -0,0 +1,38 @@
+package martini
+
+import (
+	"fmt"
+)
+
+// banner returns the lines Run logs on startup: the address, environment and TLS status, the number of
+// routes and middleware and the build info of the binary. With the "banner.routes" config set it lists the
+// routes too. The "quiet" config turns the banner off.
+func (m *Martini) banner(config *Config, addr string, tls bool) []string {
+	if config.Bool("quiet", false) {
+		return nil
+	}
+	tlsStatus := "off"
+	if tls {
+		tlsStatus = "on"
+	}
+	var routes []RouteInfo
+	if r := m.router(); r != nil {
+		routes = r.All()
+	}
+	lines := []string{
+		fmt.Sprintf("listening on %s env=%s tls=%s", addr, Env, tlsStatus),
+		fmt.Sprintf("routes=%d middleware=%d", len(routes), len(m.handlers)),
+	}
+	info := ReadBuildInfo()
+	lines = append(lines, fmt.Sprintf("build version=%s revision=%s go=%s", info.Version, info.Revision, info.GoVersion))
+	if config.Bool("banner.routes", false) {
+		for _, route := range routes {
+			line := fmt.Sprintf("route %-7s %s%s%s", route.Method, route.Host, m.basePath, route.Pattern)
+			if route.Name != "" {
+				line += " name=" + route.Name
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,29 @@
+package martini
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Banner(t *testing.T) {
+	m := Classic()
+	m.Get("/users/:id", func() {}).Name("user")
+	m.Post("/users", func() {})
+	config := NewConfig()
+
+	lines := m.banner(config, ":3000", false)
+	expect(t, lines[0], "listening on :3000 env="+Env+" tls=off")
+	expect(t, lines[1], "routes=2 middleware=3")
+	for _, line := range lines {
+		refute(t, strings.HasPrefix(line, "route "), true)
+	}
+
+	config.Set("banner.routes", "true")
+	lines = m.banner(config, "localhost:443", true)
+	expect(t, lines[0], "listening on localhost:443 env="+Env+" tls=on")
+	expect(t, lines[len(lines)-2], "route GET     /users/:id name=user")
+	expect(t, lines[len(lines)-1], "route POST    /users name=post_users")
+
+	config.Set("quiet", "true")
+	expect(t, len(m.banner(config, ":3000", false)), 0)
+}
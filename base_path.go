@@ -0,0 +1,52 @@
+package martini
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BasePath is the prefix the application is mounted under, e.g. "/app" when a reverse proxy forwards
+// requests for example.com/app/ to it. An instance of martini.BasePath is available to be injected into
+// any handler, it is empty unless set with SetBasePath.
+type BasePath string
+
+// URL prefixes the root relative path with the base path.
+func (b BasePath) URL(path string) string {
+	return string(b) + path
+}
+
+// SetBasePath sets the prefix the application is mounted under. The prefix is stripped from the path of
+// incoming requests before any handler runs, so routes and static files are declared without it. Requests
+// outside of the base path are served unchanged.
+func (m *Martini) SetBasePath(path string) {
+	m.basePath = normalizeBasePath(path)
+}
+
+// SetBasePath is like Martini.SetBasePath, and additionally prefixes the URLs rendered by the router.
+func (m *ClassicMartini) SetBasePath(path string) {
+	m.Martini.SetBasePath(path)
+	if r, ok := m.Router.(*router); ok {
+		r.basePath = m.basePath
+	}
+}
+
+func normalizeBasePath(path string) string {
+	path = strings.TrimRight(path, "/")
+	if path != "" && path[0] != '/' {
+		path = "/" + path
+	}
+	return path
+}
+
+// stripBasePath removes the base path from the request path, if the request is below it.
+func stripBasePath(req *http.Request, base string) {
+	path := req.URL.Path
+	if !strings.HasPrefix(path, base) || (len(path) > len(base) && path[len(base)] != '/') {
+		return
+	}
+	req.URL.Path = path[len(base):]
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	req.URL.RawPath = ""
+}
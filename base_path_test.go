@@ -0,0 +1,57 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_SetBasePath(t *testing.T) {
+	m := Classic()
+	m.SetBasePath("app/")
+	m.Get("/", func() string { return "root" })
+	m.Get("/users/:id", func(params Params, base BasePath) string {
+		return string(base) + " user " + params["id"]
+	}).Name("user")
+	m.Get("/link", func(routes Routes) string { return routes.URLFor("user", 5) })
+	m.Moved("/people/:id", "user", http.StatusMovedPermanently)
+
+	for path, body := range map[string]string{
+		"/app":         "root",
+		"/app/":        "root",
+		"/app/users/5": "/app user 5",
+		"/app/link":    "/app/users/5",
+		"/users/6":     "/app user 6",
+	} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(recorder, req)
+		expect(t, recorder.Body.String(), body)
+	}
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/app/people/5", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Header().Get("Location"), "/app/users/5")
+
+	// static directories redirect within the base path
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/app/cmd", nil)
+	m.Use(Static("."))
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Header().Get("Location"), "/app/cmd/")
+}
+
+func Test_StripBasePath(t *testing.T) {
+	for path, stripped := range map[string]string{
+		"/app":       "/",
+		"/app/":      "/",
+		"/app/users": "/users",
+		"/apps":      "/apps",
+		"/":          "/",
+	} {
+		req, _ := http.NewRequest("GET", path, nil)
+		stripBasePath(req, "/app")
+		expect(t, req.URL.Path, stripped)
+	}
+}
@@ -0,0 +1,264 @@
+package martini
+
+import (
+	"bufio"
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// BatchRequest is a sub-request of a JSON batch.
+type BatchRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is sent as is if it is a JSON string, encoded as JSON otherwise.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResponse is the response to a sub-request of a JSON batch.
+type BatchResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is embedded as is if it is JSON, as a JSON string otherwise.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchOptions configures the Batch handler.
+type BatchOptions struct {
+	// MaxRequests is the maximum number of sub-requests in a batch. Defaults to 20.
+	MaxRequests int
+	// MaxBodySize is the maximum size of the batch request body. Defaults to 1MB.
+	MaxBodySize int64
+	// Shared are the headers of the batch request passed on to sub-requests that don't set them, so they
+	// share its authentication. Defaults to Authorization and Cookie.
+	Shared []string
+}
+
+// Batch returns a handler executing a batch of sub-requests through h, usually the Martini instance
+// itself, one after another. The batch is either a JSON array of BatchRequest answered with an array of
+// BatchResponse, or a multipart/mixed body of application/http parts answered in kind. Sub-requests
+// share the client address, TLS state, context, request ID, trace context and the Shared headers of the
+// batch request, and can't be batches themselves.
+//
+//	m.Post("/batch", martini.Batch(m, martini.BatchOptions{}))
+func Batch(h http.Handler, opts BatchOptions) Handler {
+	if opts.MaxRequests == 0 {
+		opts.MaxRequests = 20
+	}
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = 1 << 20
+	}
+	if opts.Shared == nil {
+		opts.Shared = []string{"Authorization", "Cookie"}
+	}
+
+	b := &batch{handler: h, opts: opts}
+	return func(c Context, res http.ResponseWriter, req *http.Request) {
+		req.Body = http.MaxBytesReader(res, req.Body, opts.MaxBodySize)
+		mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		var err error
+		switch {
+		case req.Context().Value(batchKey{}) != nil:
+			err = HTTPError{Status: http.StatusBadRequest, Message: "sub-requests can't be batches"}
+		case mediaType == "application/json":
+			err = b.serveJSON(c, res, req)
+		case mediaType == "multipart/mixed":
+			err = b.serveMultipart(c, res, req, params["boundary"])
+		default:
+			err = HTTPError{Status: http.StatusUnsupportedMediaType, Message: "batch must be application/json or multipart/mixed"}
+		}
+		if err != nil {
+			e, ok := asHTTPError(err)
+			if !ok {
+				e = HTTPError{Status: http.StatusBadRequest, Message: err.Error()}
+			}
+			writeHTTPError(res, req, e)
+		}
+	}
+}
+
+// batchKey marks the context of sub-requests, so a batch can't be nested in a batch whatever URL it is
+// served under.
+type batchKey struct{}
+
+type batch struct {
+	handler http.Handler
+	opts    BatchOptions
+}
+
+func (b *batch) serveJSON(c Context, res http.ResponseWriter, req *http.Request) error {
+	var requests []BatchRequest
+	if err := json.NewDecoder(req.Body).Decode(&requests); err != nil {
+		return err
+	}
+	if len(requests) > b.opts.MaxRequests {
+		return b.tooMany()
+	}
+
+	responses := make([]BatchResponse, len(requests))
+	for i, r := range requests {
+		body := []byte(r.Body)
+		var s string
+		if json.Unmarshal(r.Body, &s) == nil {
+			body = []byte(s)
+		}
+		sub, err := http.NewRequest(r.Method, r.URL, bytes.NewReader(body))
+		if err != nil {
+			responses[i] = batchError(http.StatusBadRequest, err.Error())
+			continue
+		}
+		for k, v := range r.Headers {
+			sub.Header.Set(k, v)
+		}
+		if len(body) > 0 && sub.Header.Get("Content-Type") == "" {
+			sub.Header.Set("Content-Type", "application/json")
+		}
+
+		resp := b.serve(c, req, sub).response(sub)
+		data, _ := ioutil.ReadAll(resp.Body)
+		responses[i] = BatchResponse{Status: resp.StatusCode, Headers: make(map[string]string), Body: jsonBody(data)}
+		for k := range resp.Header {
+			responses[i].Headers[k] = resp.Header.Get(k)
+		}
+	}
+
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(res).Encode(responses)
+}
+
+func (b *batch) serveMultipart(c Context, res http.ResponseWriter, req *http.Request, boundary string) error {
+	if boundary == "" {
+		return HTTPError{Status: http.StatusBadRequest, Message: "missing multipart boundary"}
+	}
+	reader := multipart.NewReader(req.Body, boundary)
+	type part struct {
+		id  string
+		sub *http.Request
+		err error
+	}
+	var parts []part
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if len(parts) == b.opts.MaxRequests {
+			return b.tooMany()
+		}
+		sub, err := http.ReadRequest(bufio.NewReader(p))
+		if err == nil {
+			// the body has to be read before the next part
+			var body []byte
+			body, err = ioutil.ReadAll(sub.Body)
+			sub.Body = ioutil.NopCloser(bytes.NewReader(body))
+			sub.RequestURI = ""
+		}
+		parts = append(parts, part{id: p.Header.Get("Content-ID"), sub: sub, err: err})
+	}
+
+	out := multipart.NewWriter(res)
+	res.Header().Set("Content-Type", "multipart/mixed; boundary="+out.Boundary())
+	for _, p := range parts {
+		resp := &http.Response{ProtoMajor: 1, ProtoMinor: 1, Header: make(http.Header)}
+		if p.err != nil {
+			resp.StatusCode = http.StatusBadRequest
+			resp.Header.Set("Content-Type", "text/plain; charset=utf-8")
+			resp.Body = ioutil.NopCloser(strings.NewReader(p.err.Error() + "\n"))
+			resp.ContentLength = int64(len(p.err.Error()) + 1)
+		} else {
+			resp = b.serve(c, req, p.sub).response(p.sub)
+		}
+
+		header := textproto.MIMEHeader{"Content-Type": {"application/http"}}
+		if p.id != "" {
+			header.Set("Content-ID", "response-"+p.id)
+		}
+		w, err := out.CreatePart(header)
+		if err != nil {
+			return nil
+		}
+		if err := resp.Write(w); err != nil {
+			return nil
+		}
+	}
+	out.Close()
+	return nil
+}
+
+func (b *batch) tooMany() error {
+	return HTTPError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("more than %d requests in batch", b.opts.MaxRequests)}
+}
+
+// serve executes the sub-request in the context of the batch request.
+func (b *batch) serve(c Context, req *http.Request, sub *http.Request) *batchRecorder {
+	rec := &batchRecorder{header: make(http.Header)}
+	if sub.URL.IsAbs() || !strings.HasPrefix(sub.URL.Path, "/") {
+		rec.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(&rec.body, "sub-request URL must be a path, not %q\n", sub.URL)
+		return rec
+	}
+	sub = subRequest(c, req, sub, b.opts.Shared)
+	b.handler.ServeHTTP(rec, sub.WithContext(gocontext.WithValue(sub.Context(), batchKey{}, true)))
+	return rec
+}
+
+func batchError(status int, message string) BatchResponse {
+	return BatchResponse{Status: status, Body: jsonBody([]byte(message))}
+}
+
+// jsonBody returns the body as is if it is JSON, as a JSON string otherwise.
+func jsonBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return body
+	}
+	s, _ := json.Marshal(string(body))
+	return s
+}
+
+// batchRecorder records the response of a sub-request.
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *batchRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *batchRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *batchRecorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+// response returns the recorded response to the request.
+func (r *batchRecorder) response(req *http.Request) *http.Response {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return &http.Response{
+		Status: fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)), StatusCode: r.status,
+		Proto: "HTTP/1.1", ProtoMajor: 1, ProtoMinor: 1, Header: r.header, Request: req,
+		Body: ioutil.NopCloser(bytes.NewReader(r.body.Bytes())), ContentLength: int64(r.body.Len()),
+	}
+}
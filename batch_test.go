@@ -0,0 +1,130 @@
+package martini
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func batchApp() *ClassicMartini {
+	m := Classic()
+	m.Use(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			res.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	m.Get("/users/:id", func(params Params, res http.ResponseWriter) {
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"id":"` + params["id"] + `"}`))
+	})
+	m.Post("/echo", func(req *http.Request) string {
+		body, _ := ioutil.ReadAll(req.Body)
+		return req.Header.Get("Content-Type") + " " + string(body)
+	})
+	m.Post("/batch", Batch(m, BatchOptions{MaxRequests: 4}))
+	return m
+}
+
+func Test_Batch_JSON(t *testing.T) {
+	m := batchApp()
+	body := `[
+		{"method": "GET", "url": "/users/1"},
+		{"method": "POST", "url": "/echo", "body": {"name":"gopher"}},
+		{"method": "POST", "url": "/echo", "body": "a=b", "headers": {"Content-Type": "application/x-www-form-urlencoded"}},
+		{"method": "POST", "url": "/batch/", "headers": {"Content-Type": "application/json"}, "body": [{"method": "POST", "url": "/echo"}]}
+	]`
+	req := httptest.NewRequest("POST", "/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	expect(t, res.Code, http.StatusOK)
+
+	var responses []BatchResponse
+	expect(t, json.Unmarshal(res.Body.Bytes(), &responses), nil)
+	expect(t, len(responses), 4)
+	expect(t, responses[0].Status, http.StatusOK)
+	expect(t, string(responses[0].Body), `{"id":"1"}`)
+	expect(t, responses[0].Headers["Content-Type"], "application/json")
+	expect(t, string(responses[1].Body), `"application/json {\"name\":\"gopher\"}"`)
+	expect(t, string(responses[2].Body), `"application/x-www-form-urlencoded a=b"`)
+	// the nested batch is refused under an alias of the batch route as well
+	expect(t, responses[3].Status, http.StatusBadRequest)
+	expect(t, strings.Contains(string(responses[3].Body), "sub-requests can't be batches"), true)
+}
+
+func Test_Batch_Errors(t *testing.T) {
+	m := batchApp()
+	for body, status := range map[string]int{
+		`nope`:                 http.StatusBadRequest,
+		`[{}, {}, {}, {}, {}]`: http.StatusRequestEntityTooLarge,
+	} {
+		req := httptest.NewRequest("POST", "/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		expect(t, res.Code, status)
+	}
+
+	req := httptest.NewRequest("POST", "/batch", strings.NewReader(`[{"method": "GET", "url": "/users/1"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	expect(t, res.Code, http.StatusUnauthorized)
+
+	req = httptest.NewRequest("POST", "/batch", strings.NewReader(`a=b`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "text/plain")
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	expect(t, res.Code, http.StatusUnsupportedMediaType)
+}
+
+func Test_Batch_Multipart(t *testing.T) {
+	m := batchApp()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for id, sub := range []string{
+		"GET /users/7 HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"POST /echo HTTP/1.1\r\nHost: example.com\r\nContent-Type: text/plain\r\nContent-Length: 5\r\n\r\nhello",
+		"GET http://other.com/users/1 HTTP/1.1\r\nHost: other.com\r\n\r\n",
+	} {
+		part, _ := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/http"}, "Content-Id": {string(rune('a' + id))}})
+		part.Write([]byte(sub))
+	}
+	w.Close()
+
+	req := httptest.NewRequest("POST", "/batch", &body)
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+w.Boundary())
+	req.Header.Set("Authorization", "Bearer secret")
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	expect(t, res.Code, http.StatusOK)
+
+	_, params, _ := mime.ParseMediaType(res.Header().Get("Content-Type"))
+	r := multipart.NewReader(res.Body, params["boundary"])
+	var got []string
+	for {
+		part, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(part), nil)
+		expect(t, err, nil)
+		b, _ := ioutil.ReadAll(resp.Body)
+		got = append(got, part.Header.Get("Content-ID")+" "+resp.Status[:3]+" "+string(b))
+	}
+	expect(t, len(got), 3)
+	expect(t, got[0], `response-a 200 {"id":"7"}`)
+	expect(t, got[1], "response-b 200 text/plain hello")
+	expect(t, strings.HasPrefix(got[2], "response-c 400 "), true)
+}
@@ -0,0 +1,157 @@
+package martini
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BrokerMessage is a message published through a Broker.
+type BrokerMessage struct {
+	// Event is the type of the message, sent as the event name of server sent events. Optional.
+	Event string
+	Data  []byte
+}
+
+// Broker distributes messages published to a topic to all subscribers of the topic. The in-memory
+// broker only reaches the subscribers of the same process, implement Broker on top of Redis or NATS
+// to broadcast to all instances of an application.
+type Broker interface {
+	Publish(topic string, msg BrokerMessage) error
+	Subscribe(topic string) (Subscription, error)
+}
+
+// Subscription receives the messages of a topic until it is closed.
+type Subscription interface {
+	Messages() <-chan BrokerMessage
+	Close() error
+}
+
+// NewMemoryBroker creates a Broker for the subscribers in the process. Messages to subscribers whose
+// buffer of 64 messages is full are dropped, so slow clients can't hold up the publishers.
+func NewMemoryBroker() Broker {
+	return &memoryBroker{subs: make(map[string]map[*memorySubscription]bool)}
+}
+
+type memoryBroker struct {
+	mu   sync.RWMutex
+	subs map[string]map[*memorySubscription]bool
+}
+
+type memorySubscription struct {
+	broker   *memoryBroker
+	topic    string
+	messages chan BrokerMessage
+	once     sync.Once
+}
+
+func (b *memoryBroker) Publish(topic string, msg BrokerMessage) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs[topic] {
+		select {
+		case s.messages <- msg:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(topic string) (Subscription, error) {
+	s := &memorySubscription{broker: b, topic: topic, messages: make(chan BrokerMessage, 64)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[*memorySubscription]bool)
+	}
+	b.subs[topic][s] = true
+	return s, nil
+}
+
+func (s *memorySubscription) Messages() <-chan BrokerMessage {
+	return s.messages
+}
+
+func (s *memorySubscription) Close() error {
+	s.once.Do(func() {
+		b := s.broker
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[s.topic], s)
+		if len(b.subs[s.topic]) == 0 {
+			delete(b.subs, s.topic)
+		}
+		close(s.messages)
+	})
+	return nil
+}
+
+// sseHeartbeat is the interval of the comments ServerSentEvents sends to keep idle connections open.
+const sseHeartbeat = 15 * time.Second
+
+// ServerSentEvents returns a handler streaming the messages of the topic returned by topic as server
+// sent events, until the client goes away.
+//
+//	broker := martini.NewMemoryBroker()
+//	m.Get("/events", martini.ServerSentEvents(broker, func(req *http.Request) string { return "news" }))
+//	broker.Publish("news", martini.BrokerMessage{Event: "headline", Data: data})
+func ServerSentEvents(b Broker, topic func(*http.Request) string) Handler {
+	return func(res http.ResponseWriter, req *http.Request) {
+		sub, err := b.Subscribe(topic(req))
+		if err != nil {
+			panic(err)
+		}
+		defer sub.Close()
+
+		unbuffer(res)
+		res.Header().Set("Content-Type", "text/event-stream")
+		res.Header().Set("Cache-Control", "no-cache")
+		res.Header().Set("X-Accel-Buffering", "no")
+		res.WriteHeader(http.StatusOK)
+		flush := func() {
+			if f, ok := res.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		flush()
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case msg, ok := <-sub.Messages():
+				if !ok {
+					return
+				}
+				if _, err := res.Write(formatEvent(msg)); err != nil {
+					return
+				}
+				flush()
+			case <-heartbeat.C:
+				if _, err := res.Write([]byte(": ping\n\n")); err != nil {
+					return
+				}
+				flush()
+			case <-req.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// formatEvent formats the message as a server sent event, with a data line per line of the data.
+func formatEvent(msg BrokerMessage) []byte {
+	var buf bytes.Buffer
+	if msg.Event != "" {
+		buf.WriteString("event: " + strings.NewReplacer("\r", "", "\n", "").Replace(msg.Event) + "\n")
+	}
+	for _, line := range bytes.Split(bytes.Replace(msg.Data, []byte("\r\n"), []byte("\n"), -1), []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
@@ -0,0 +1,71 @@
+package martini
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_MemoryBroker(t *testing.T) {
+	b := NewMemoryBroker()
+	s1, _ := b.Subscribe("a")
+	s2, _ := b.Subscribe("a")
+	other, _ := b.Subscribe("b")
+
+	expect(t, b.Publish("a", BrokerMessage{Data: []byte("hi")}), nil)
+	expect(t, string((<-s1.Messages()).Data), "hi")
+	expect(t, string((<-s2.Messages()).Data), "hi")
+	expect(t, len(other.Messages()), 0)
+
+	s1.Close()
+	s1.Close()
+	_, ok := <-s1.Messages()
+	expect(t, ok, false)
+	b.Publish("a", BrokerMessage{Data: []byte("again")})
+	expect(t, string((<-s2.Messages()).Data), "again")
+
+	// a full subscriber doesn't block publishing
+	for i := 0; i < 100; i++ {
+		b.Publish("a", BrokerMessage{})
+	}
+	expect(t, len(s2.Messages()), 64)
+}
+
+func Test_formatEvent(t *testing.T) {
+	expect(t, string(formatEvent(BrokerMessage{Event: "up\ndate", Data: []byte("line 1\r\nline 2")})), "event: update\ndata: line 1\ndata: line 2\n\n")
+}
+
+func Test_ServerSentEvents(t *testing.T) {
+	b := NewMemoryBroker()
+	m := Classic()
+	m.Get("/events", ServerSentEvents(b, func(req *http.Request) string { return "news" }))
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	res := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		m.ServeHTTP(res, httptest.NewRequest("GET", "/events", nil).WithContext(ctx))
+		close(done)
+	}()
+
+	mb := b.(*memoryBroker)
+	for i := 0; i < 100; i++ {
+		mb.mu.RLock()
+		n := len(mb.subs["news"])
+		mb.mu.RUnlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	b.Publish("news", BrokerMessage{Event: "headline", Data: []byte("hello")})
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	<-done
+
+	expect(t, res.Header().Get("Content-Type"), "text/event-stream")
+	expect(t, res.Body.String(), "event: headline\ndata: hello\n\n")
+	expect(t, len(mb.subs["news"]), 0)
+}
@@ -0,0 +1,63 @@
+package martini
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// BuildInfo describes the build of the running binary, for a version endpoint standardized across
+// services:
+//
+//	info := martini.ReadBuildInfo()
+//	info.Extra = map[string]interface{}{"service": "billing"}
+//	m.Get("/version", info.Handler())
+type BuildInfo struct {
+	// Version is the version of the main module, "(devel)" for builds outside of module mode or from a
+	// checkout. Set it yourself, e.g. from a variable set with -ldflags "-X", to override it.
+	Version string `json:"version"`
+	// Revision is the VCS revision the binary was built from.
+	Revision string `json:"revision,omitempty"`
+	// Time is the commit time of the revision, in RFC 3339 format.
+	Time string `json:"build_time,omitempty"`
+	// Modified is whether the working tree had uncommitted changes.
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	// Extra holds additional fields, e.g. the service name or the deployment region.
+	Extra map[string]interface{} `json:"extra,omitempty"`
+}
+
+// ReadBuildInfo returns the build info embedded in the binary by the go command.
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{GoVersion: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Version = build.Main.Version
+	for _, s := range build.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.Time = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// Handler returns a handler rendering the build info as JSON.
+func (info BuildInfo) Handler() Handler {
+	body, err := json.Marshal(info)
+	return func(res http.ResponseWriter) {
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		res.Write(body)
+	}
+}
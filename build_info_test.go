@@ -0,0 +1,36 @@
+package martini
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func Test_BuildInfo(t *testing.T) {
+	info := ReadBuildInfo()
+	expect(t, info.GoVersion, runtime.Version())
+
+	info.Version = "v1.2.3"
+	info.Revision = "abc123"
+	info.Extra = map[string]interface{}{"service": "billing"}
+
+	m := Classic()
+	m.Get("/version", info.Handler())
+	// the handler renders the info as of its creation
+	info.Version = "v2.0.0"
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/version", nil)
+	m.ServeHTTP(res, req)
+	expect(t, res.Code, http.StatusOK)
+	expect(t, res.Header().Get("Content-Type"), "application/json; charset=utf-8")
+
+	var body map[string]interface{}
+	expect(t, json.Unmarshal(res.Body.Bytes(), &body), nil)
+	expect(t, body["version"], "v1.2.3")
+	expect(t, body["revision"], "abc123")
+	expect(t, body["go_version"], runtime.Version())
+	expect(t, body["extra"].(map[string]interface{})["service"], "billing")
+}
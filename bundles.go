@@ -0,0 +1,194 @@
+package martini
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// bundleManifest is the name of the manifest written by Bundles.Build.
+const bundleManifest = "bundles.json"
+
+// Bundles serves JS and CSS bundles, each the concatenation of a list of source files. In development
+// a bundle is built on every request, so changes to the sources show up on reload. In production the
+// bundles are built once by Build with a fingerprint of their content in the file name and served from
+// there with far future cache headers after Load.
+//
+//	bundles := martini.NewBundles("assets", "/bundles")
+//	bundles.Add("app.js", "js/jquery.js", "js/app.js")
+//	if martini.Env == martini.Prod {
+//	  bundles.Load("public/bundles")
+//	}
+//	m.Use(bundles.Handler())
+//	tmpl := template.New("").Funcs(bundles.FuncMap())
+type Bundles struct {
+	dir     string
+	prefix  string
+	mu      sync.RWMutex
+	bundles map[string][]string
+	out     string
+	built   map[string]string
+}
+
+// NewBundles creates bundles of the files in dir, served under the URL prefix.
+func NewBundles(dir string, prefix string) *Bundles {
+	return &Bundles{dir: dir, prefix: "/" + strings.Trim(prefix, "/"), bundles: make(map[string][]string)}
+}
+
+// Add adds the bundle with the name, e.g. "app.js", consisting of the files relative to the directory.
+func (b *Bundles) Add(name string, files ...string) *Bundles {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bundles[name] = files
+	return b
+}
+
+// concat concatenates and minifies the files of the bundle.
+func (b *Bundles) concat(name string) ([]byte, error) {
+	b.mu.RLock()
+	files, ok := b.bundles[name]
+	b.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("martini: unknown bundle %q", name)
+	}
+	var buf bytes.Buffer
+	for _, file := range files {
+		src, err := ioutil.ReadFile(filepath.Join(b.dir, filepath.FromSlash(file)))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(minifyAsset(path.Ext(name), src))
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// Build writes the bundles to the directory out, named with a fingerprint of their content, along with
+// a manifest mapping the bundle names to the files.
+func (b *Bundles) Build(out string) error {
+	b.mu.RLock()
+	names := make([]string, 0, len(b.bundles))
+	for name := range b.bundles {
+		names = append(names, name)
+	}
+	b.mu.RUnlock()
+
+	manifest := make(map[string]string, len(names))
+	for _, name := range names {
+		content, err := b.concat(name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		ext := path.Ext(name)
+		file := strings.TrimSuffix(name, ext) + "-" + hex.EncodeToString(sum[:])[:12] + ext
+		if err := ioutil.WriteFile(filepath.Join(out, file), content, 0644); err != nil {
+			return err
+		}
+		manifest[name] = file
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(out, bundleManifest), data, 0644)
+}
+
+// Load switches to serving the prebuilt bundles written to the directory out by Build.
+func (b *Bundles) Load(out string) error {
+	data, err := ioutil.ReadFile(filepath.Join(out, bundleManifest))
+	if err != nil {
+		return err
+	}
+	var manifest map[string]string
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.out, b.built = out, manifest
+	return nil
+}
+
+// Path returns the URL path of the bundle, the fingerprinted one after Load.
+func (b *Bundles) Path(name string) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if file, ok := b.built[name]; ok {
+		name = file
+	}
+	return b.prefix + "/" + name
+}
+
+// FuncMap returns the template functions for the bundles: bundle returns the path of a bundle, script
+// and stylesheet the tags including it.
+func (b *Bundles) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"bundle": b.Path,
+		"script": func(name string) template.HTML {
+			return template.HTML(`<script src="` + template.HTMLEscapeString(b.Path(name)) + `"></script>`)
+		},
+		"stylesheet": func(name string) template.HTML {
+			return template.HTML(`<link rel="stylesheet" href="` + template.HTMLEscapeString(b.Path(name)) + `">`)
+		},
+	}
+}
+
+// Handler returns a middleware handler serving the bundles under the prefix.
+func (b *Bundles) Handler() Handler {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if (req.Method != "GET" && req.Method != "HEAD") || !strings.HasPrefix(req.URL.Path, b.prefix+"/") {
+			return
+		}
+		name := req.URL.Path[len(b.prefix)+1:]
+
+		b.mu.RLock()
+		out, built := b.out, b.built
+		b.mu.RUnlock()
+		if built != nil {
+			for _, file := range built {
+				if file == name {
+					res.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+					http.ServeFile(res, req, filepath.Join(out, file))
+					return
+				}
+			}
+			return
+		}
+
+		b.mu.RLock()
+		_, ok := b.bundles[name]
+		b.mu.RUnlock()
+		if !ok {
+			return
+		}
+		content, err := b.concat(name)
+		if err != nil {
+			panic(err)
+		}
+		res.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+		res.Header().Set("Cache-Control", "no-cache")
+		res.Write(content)
+	}
+}
+
+// minifyAsset minifies CSS and JS.
+func minifyAsset(ext string, src []byte) []byte {
+	switch ext {
+	case ".css":
+		return minifyCSS(src)
+	case ".js":
+		return minifyJS(src)
+	}
+	return src
+}
@@ -0,0 +1,72 @@
+package martini
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Bundles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-bundles")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "a.js"), []byte("var a = 1;\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.js"), []byte("var b = 2;\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "site.css"), []byte("/* site */\nbody {\n  color: red;\n}\n\n"), 0644)
+
+	bundles := NewBundles(dir, "/bundles/").Add("app.js", "a.js", "b.js").Add("site.css", "site.css")
+	m := Classic()
+	m.Use(bundles.Handler())
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/bundles/app.js", nil))
+	expect(t, res.Code, http.StatusOK)
+	expect(t, res.Body.String(), "var a=1;\nvar b=2;\n")
+	expect(t, strings.HasPrefix(res.Header().Get("Content-Type"), "text/javascript"), true)
+	expect(t, res.Header().Get("Cache-Control"), "no-cache")
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/bundles/site.css", nil))
+	expect(t, res.Body.String(), "body{color:red}\n")
+
+	// changes show up in development
+	ioutil.WriteFile(filepath.Join(dir, "b.js"), []byte("var b = 3;\n"), 0644)
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/bundles/app.js", nil))
+	expect(t, strings.Contains(res.Body.String(), "var b=3;"), true)
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/bundles/missing.js", nil))
+	expect(t, res.Code, http.StatusNotFound)
+
+	out := filepath.Join(dir, "out")
+	os.Mkdir(out, 0755)
+	expect(t, bundles.Build(out), nil)
+	expect(t, bundles.Load(out), nil)
+
+	path := bundles.Path("app.js")
+	expect(t, strings.HasPrefix(path, "/bundles/app-"), true)
+	expect(t, strings.HasSuffix(path, ".js"), true)
+	expect(t, len(path), len("/bundles/app-")+12+len(".js"))
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", path, nil))
+	expect(t, res.Code, http.StatusOK)
+	expect(t, strings.Contains(res.Body.String(), "var b=3;"), true)
+	expect(t, res.Header().Get("Cache-Control"), "public, max-age=31536000, immutable")
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/bundles/app.js", nil))
+	expect(t, res.Code, http.StatusNotFound)
+
+	tmpl := template.Must(template.New("").Funcs(bundles.FuncMap()).Parse(`{{script "app.js"}}{{stylesheet "site.css"}}`))
+	var buf bytes.Buffer
+	expect(t, tmpl.Execute(&buf, nil), nil)
+	expect(t, buf.String(), `<script src="`+path+`"></script><link rel="stylesheet" href="`+bundles.Path("site.css")+`">`)
+}
@@ -0,0 +1,286 @@
+package martini
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheOptions bounds a Cache.
+type CacheOptions struct {
+	// MaxEntries is the number of entries kept. Zero means no limit.
+	MaxEntries int
+	// MaxBytes is the total size of the entries kept, counting the keys and the values that are strings,
+	// byte slices or have a Size() int method. Zero means no limit.
+	MaxBytes int64
+	// TTL is how long entries are kept unless set with another TTL. Zero keeps them until evicted.
+	TTL time.Duration
+}
+
+// CacheStats are the counters of a Cache.
+type CacheStats struct {
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Loads      uint64 `json:"loads"`
+	LoadErrors uint64 `json:"load_errors"`
+	// Evictions counts the entries dropped to stay within the bounds, not the expired ones.
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// Cache is an in-process LRU cache with expiring entries, bounded in entries and bytes. It is safe for
+// concurrent use. martini.New maps a *Cache service, bounded by the "cache.max_entries" and
+// "cache.max_bytes" configs, which ResponseCache and CachedSessionStore can share with the handlers:
+//
+//	m.Get("/users/:id", func(cache *martini.Cache, params martini.Params) (string, error) {
+//	  user, err := cache.GetOrLoad("user:"+params["id"], func() (interface{}, error) {
+//	    return db.LoadUser(params["id"])
+//	  })
+//	  ...
+//	})
+type Cache struct {
+	mu      sync.Mutex
+	opts    CacheOptions
+	entries map[string]*list.Element
+	// lru holds the entries, the most recently used first
+	lru   *list.List
+	bytes int64
+	loads map[string]*cacheLoad
+	stats CacheStats
+}
+
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	size    int64
+	expires time.Time
+}
+
+// cacheLoad is a load in flight, which concurrent GetOrLoad calls for the same key wait for.
+type cacheLoad struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// NewCache creates an empty Cache.
+func NewCache(opts CacheOptions) *Cache {
+	return &Cache{opts: opts, entries: make(map[string]*list.Element), lru: list.New(), loads: make(map[string]*cacheLoad)}
+}
+
+// Get returns the value stored under the key, if there is one that hasn't expired.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.get(key)
+}
+
+func (c *Cache) get(key string) (interface{}, bool) {
+	el, ok := c.entries[key]
+	if ok && !el.Value.(*cacheEntry).expires.IsZero() && time.Now().After(el.Value.(*cacheEntry).expires) {
+		c.remove(el)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry).value, true
+}
+
+// Set stores the value under the key for the TTL of the cache.
+func (c *Cache) Set(key string, value interface{}) {
+	c.SetWithTTL(key, value, c.opts.TTL)
+}
+
+// SetWithTTL stores the value under the key for the ttl, zero keeps it until evicted. Values larger than
+// MaxBytes aren't stored.
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value, ttl)
+}
+
+func (c *Cache) set(key string, value interface{}, ttl time.Duration) {
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	entry := &cacheEntry{key: key, value: value, size: cacheSize(key, value)}
+	if c.opts.MaxBytes > 0 && entry.size > c.opts.MaxBytes {
+		return
+	}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += entry.size
+	for (c.opts.MaxEntries > 0 && c.lru.Len() > c.opts.MaxEntries) || (c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes) {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// GetOrLoad returns the value stored under the key, or calls load to get it and stores it for the TTL of
+// the cache. Concurrent calls for the same key wait for a single load. Errors and nil values aren't stored.
+func (c *Cache) GetOrLoad(key string, load func() (interface{}, error)) (interface{}, error) {
+	return c.GetOrLoadWithTTL(key, c.opts.TTL, load)
+}
+
+// GetOrLoadWithTTL is like GetOrLoad but stores the loaded value for the ttl.
+func (c *Cache) GetOrLoadWithTTL(key string, ttl time.Duration, load func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if value, ok := c.get(key); ok {
+		c.mu.Unlock()
+		return value, nil
+	}
+	if l, ok := c.loads[key]; ok {
+		c.mu.Unlock()
+		<-l.done
+		return l.value, l.err
+	}
+	l := &cacheLoad{done: make(chan struct{})}
+	c.loads[key] = l
+	c.stats.Loads++
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.loads, key)
+		if l.err != nil {
+			c.stats.LoadErrors++
+		} else if l.value != nil {
+			c.set(key, l.value, ttl)
+		}
+		c.mu.Unlock()
+		close(l.done)
+	}()
+	l.value, l.err = load()
+	return l.value, l.err
+}
+
+// Delete removes the entry stored under the key.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// DeleteFunc removes the entries fn returns true for and returns their number.
+func (c *Cache) DeleteFunc(fn func(key string, value interface{}) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if entry := el.Value.(*cacheEntry); fn(entry.key, entry.value) {
+			c.remove(el)
+			n++
+		}
+		el = next
+	}
+	return n
+}
+
+func (c *Cache) remove(el *list.Element) {
+	entry := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
+
+// Stats returns the counters of the cache.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// cacheSize returns the size an entry is accounted with.
+func cacheSize(key string, value interface{}) int64 {
+	size := int64(len(key))
+	switch v := value.(type) {
+	case string:
+		size += int64(len(v))
+	case []byte:
+		size += int64(len(v))
+	case interface{ Size() int }:
+		size += int64(v.Size())
+	}
+	return size
+}
+
+// Cache returns the cache mapped by martini.New.
+func (m *Martini) Cache() *Cache {
+	return m.cache
+}
+
+// cachedResponse is a response stored by ResponseCache.
+type cachedResponse struct {
+	header http.Header
+	body   []byte
+}
+
+func (r *cachedResponse) Size() int {
+	size := len(r.body)
+	for k, v := range r.header {
+		size += len(k) + len(strings.Join(v, ""))
+	}
+	return size
+}
+
+// ResponseCache returns a middleware handler storing 200 OK responses to GET requests in the mapped
+// *Cache for the ttl, keyed by host and URL, and answering later requests for the same URL from it.
+// Requests with an Authorization or a Cookie header, which may be answered for the user, are never
+// answered from nor stored in the cache. Neither are responses setting cookies, varying on request
+// headers or with a Cache-Control of no-store or private, nor routes with RouteOptions.DisableCaching.
+func ResponseCache(ttl time.Duration) Handler {
+	return func(c Context, cache *Cache, req *http.Request, res http.ResponseWriter) {
+		if req.Method != "GET" || req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+			return
+		}
+		key := "response:" + req.Host + req.URL.RequestURI()
+		if v, ok := cache.Get(key); ok {
+			cached := v.(*cachedResponse)
+			for k, v := range cached.header {
+				res.Header()[k] = append([]string(nil), v...)
+			}
+			res.WriteHeader(http.StatusOK)
+			res.Write(cached.body)
+			return
+		}
+
+		bw, ok := res.(BufferedResponseWriter)
+		if !ok {
+			return
+		}
+		bw.Buffer()
+		c.Next()
+		if !bw.Committed() && bw.Status() == http.StatusOK && !routeOptions(c).DisableCaching && storable(bw.Header()) {
+			header := make(http.Header, len(bw.Header()))
+			for k, v := range bw.Header() {
+				header[k] = append([]string(nil), v...)
+			}
+			cache.SetWithTTL(key, &cachedResponse{header, append([]byte(nil), bw.Body()...)}, ttl)
+		}
+		bw.Commit()
+	}
+}
+
+// storable returns whether a response with the headers may be stored in a shared cache. Responses with a
+// Vary header aren't, as the cache is keyed by URL only.
+func storable(header http.Header) bool {
+	if header.Get("Set-Cookie") != "" || header.Get("Vary") != "" {
+		return false
+	}
+	cc := strings.ToLower(header.Get("Cache-Control"))
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private")
+}
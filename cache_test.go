@@ -0,0 +1,218 @@
+package martini
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_CacheLRU(t *testing.T) {
+	c := NewCache(CacheOptions{MaxEntries: 2})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	_, ok := c.Get("a")
+	expect(t, ok, true)
+	// b is the least recently used
+	c.Set("c", 3)
+	_, ok = c.Get("b")
+	expect(t, ok, false)
+	v, _ := c.Get("a")
+	expect(t, v, 1)
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	expect(t, ok, false)
+
+	stats := c.Stats()
+	expect(t, stats.Hits, uint64(2))
+	expect(t, stats.Misses, uint64(2))
+	expect(t, stats.Evictions, uint64(1))
+	expect(t, stats.Entries, 1)
+}
+
+func Test_CacheMaxBytes(t *testing.T) {
+	c := NewCache(CacheOptions{MaxBytes: 10})
+	c.Set("a", "1234")
+	c.Set("b", []byte("1234"))
+	expect(t, c.Stats().Bytes, int64(10))
+	c.Set("c", "12")
+	expect(t, c.Stats().Bytes, int64(8))
+	_, ok := c.Get("a")
+	expect(t, ok, false)
+
+	// too large to be stored at all
+	c.Set("d", "12345678901")
+	_, ok = c.Get("d")
+	expect(t, ok, false)
+	expect(t, c.Stats().Entries, 2)
+}
+
+func Test_CacheTTL(t *testing.T) {
+	c := NewCache(CacheOptions{TTL: 10 * time.Millisecond})
+	c.Set("a", 1)
+	c.SetWithTTL("b", 2, time.Hour)
+	c.SetWithTTL("c", 3, 0)
+	time.Sleep(20 * time.Millisecond)
+	_, ok := c.Get("a")
+	expect(t, ok, false)
+	_, ok = c.Get("b")
+	expect(t, ok, true)
+	_, ok = c.Get("c")
+	expect(t, ok, true)
+	expect(t, c.Stats().Entries, 2)
+}
+
+func Test_CacheGetOrLoad(t *testing.T) {
+	c := NewCache(CacheOptions{})
+	var loads int32
+	release := make(chan struct{})
+	load := func() (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "user", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = c.GetOrLoad("user:1", load)
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	expect(t, atomic.LoadInt32(&loads), int32(1))
+	for _, r := range results {
+		expect(t, r, "user")
+	}
+	v, _ := c.Get("user:1")
+	expect(t, v, "user")
+
+	// errors and nil values aren't stored
+	errLoad := errors.New("db down")
+	_, err := c.GetOrLoad("user:2", func() (interface{}, error) { return nil, errLoad })
+	expect(t, err, errLoad)
+	v, err = c.GetOrLoad("user:3", func() (interface{}, error) { return nil, nil })
+	expect(t, err, nil)
+	expect(t, v, nil)
+	expect(t, c.Stats().Entries, 1)
+	expect(t, c.Stats().LoadErrors, uint64(1))
+	expect(t, c.Stats().Loads, uint64(3))
+
+	expect(t, c.DeleteFunc(func(key string, value interface{}) bool { return value == "user" }), 1)
+	expect(t, c.Stats().Entries, 0)
+}
+
+func Test_ResponseCache(t *testing.T) {
+	m := Classic()
+	m.Use(ResponseCache(time.Minute))
+	calls := 0
+	m.Get("/posts", func(res http.ResponseWriter) string {
+		calls++
+		res.Header().Set("Content-Type", "text/plain")
+		return "posts"
+	})
+	m.Get("/fresh", WithOptions(RouteOptions{DisableCaching: true}), func() string {
+		calls++
+		return "fresh"
+	})
+	m.Get("/private", func(res http.ResponseWriter) string {
+		calls++
+		res.Header().Set("Cache-Control", "private, max-age=60")
+		return "private"
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(res, req)
+		return res
+	}
+	for _, path := range []string{"/posts", "/posts", "/posts?page=2", "/fresh", "/fresh", "/private", "/private"} {
+		get(path)
+	}
+	expect(t, calls, 6)
+
+	res := get("/posts")
+	expect(t, res.Code, http.StatusOK)
+	expect(t, res.Body.String(), "posts")
+	expect(t, res.Header().Get("Content-Type"), "text/plain")
+	expect(t, get("/fresh").Header().Get("Cache-Control"), "no-store")
+	expect(t, m.Cache().Stats().Entries, 2)
+}
+
+func Test_ResponseCachePerUser(t *testing.T) {
+	m := Classic()
+	m.Use(ResponseCache(time.Minute))
+	m.Get("/me", func(req *http.Request) string {
+		if c, err := req.Cookie("sid"); err == nil {
+			return "hello " + c.Value
+		}
+		return "hello guest"
+	})
+	m.Get("/greeting", func(res http.ResponseWriter, req *http.Request) string {
+		res.Header().Set("Vary", "Accept-Language")
+		return "hello " + req.Header.Get("Accept-Language")
+	})
+
+	get := func(path string, header string, value string) string {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		m.ServeHTTP(res, req)
+		return res.Body.String()
+	}
+	expect(t, get("/me", "Cookie", "sid=alice"), "hello alice")
+	expect(t, get("/me", "", ""), "hello guest")
+	expect(t, get("/me", "Cookie", "sid=bob"), "hello bob")
+
+	expect(t, get("/greeting", "Accept-Language", "de"), "hello de")
+	expect(t, get("/greeting", "Accept-Language", "fr"), "hello fr")
+	expect(t, m.Cache().Stats().Entries, 1)
+}
+
+func Test_CachedSessionStore(t *testing.T) {
+	backing := NewMemorySessionStore()
+	counting := &countingSessionStore{SessionStore: backing}
+	store := CachedSessionStore(counting, NewCache(CacheOptions{}), time.Minute)
+
+	s := &Session{ID: "abc", UserID: "bob", Values: map[string]string{"a": "1"}, Expires: time.Now().Add(time.Hour)}
+	expect(t, store.Save(s), nil)
+	loaded, err := store.Get("abc")
+	expect(t, err, nil)
+	expect(t, loaded.Values["a"], "1")
+	// the cached copy doesn't change with the loaded one
+	loaded.Values["a"] = "2"
+	loaded, _ = store.Get("abc")
+	expect(t, loaded.Values["a"], "1")
+	expect(t, counting.gets, 0)
+
+	missing, err := store.Get("missing")
+	expect(t, err, nil)
+	expect(t, missing == nil, true)
+	expect(t, counting.gets, 1)
+
+	expect(t, store.DeleteUser("bob"), nil)
+	loaded, _ = store.Get("abc")
+	expect(t, loaded == nil, true)
+	expect(t, counting.gets, 2)
+}
+
+type countingSessionStore struct {
+	SessionStore
+	gets int
+}
+
+func (s *countingSessionStore) Get(id string) (*Session, error) {
+	s.gets++
+	return s.SessionStore.Get(id)
+}
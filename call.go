@@ -0,0 +1,94 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// traceparentRegex matches a W3C traceparent header, capturing the trace ID and the flags.
+var traceparentRegex = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-([0-9a-f]{2})$`)
+
+// propagateTrace passes the request ID and the W3C trace context of the request on to an internal
+// sub-request. The sub-request continues the trace as a new span.
+func propagateTrace(c Context, req *http.Request, sub *http.Request) {
+	id := req.Header.Get("X-Request-Id")
+	if c != nil {
+		if v := c.Get(reflect.TypeOf((*RequestLogger)(nil))); v.IsValid() {
+			id = v.Interface().(*RequestLogger).Field("request_id")
+		}
+	}
+	if id != "" {
+		sub.Header.Set("X-Request-Id", id)
+	}
+
+	if m := traceparentRegex.FindStringSubmatch(req.Header.Get("Traceparent")); m != nil {
+		sub.Header.Set("Traceparent", "00-"+m[1]+"-"+randomToken(8)+"-"+m[2])
+		if state := req.Header.Get("Tracestate"); state != "" {
+			sub.Header.Set("Tracestate", state)
+		}
+	}
+}
+
+// subRequest prepares an internal request made while serving req, sharing its client, context and
+// trace, and the given headers unless the sub-request sets them itself.
+func subRequest(c Context, req *http.Request, sub *http.Request, shared []string) *http.Request {
+	sub = sub.WithContext(req.Context())
+	sub.Host, sub.RemoteAddr, sub.TLS = req.Host, req.RemoteAddr, req.TLS
+	for _, name := range shared {
+		name = http.CanonicalHeaderKey(name)
+		if sub.Header.Get(name) == "" && req.Header.Get(name) != "" {
+			sub.Header[name] = req.Header[name]
+		}
+	}
+	propagateTrace(c, req, sub)
+	return sub
+}
+
+func (c *context) Call(method string, routeName string, body interface{}, params ...interface{}) (*http.Response, error) {
+	routes, ok := c.Get(reflect.TypeOf((*Routes)(nil)).Elem()).Interface().(Routes)
+	if !ok {
+		return nil, errors.New("martini: Call needs a router")
+	}
+	url, err := routes.URLForE(routeName, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		reader io.Reader
+		ctype  string
+	)
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	case []byte:
+		reader = bytes.NewReader(b)
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		reader, ctype = bytes.NewReader(data), "application/json"
+	}
+	sub, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if ctype != "" {
+		sub.Header.Set("Content-Type", ctype)
+	}
+
+	req := c.Get(reflect.TypeOf((*http.Request)(nil))).Interface().(*http.Request)
+	rec := &batchRecorder{header: make(http.Header)}
+	c.martini.ServeHTTP(rec, subRequest(c, req, sub, []string{"Authorization", "Cookie"}))
+	return rec.response(sub), nil
+}
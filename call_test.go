@@ -0,0 +1,85 @@
+package martini
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Call(t *testing.T) {
+	m := Classic()
+	m.Get("/users/:id", func(params Params, req *http.Request) string {
+		return params["id"] + " " + req.Header.Get("Authorization") + " " + req.Header.Get("X-Request-Id")
+	}).Name("user")
+	m.Post("/echo", func(req *http.Request) string {
+		body, _ := ioutil.ReadAll(req.Body)
+		return req.Header.Get("Content-Type") + " " + string(body)
+	}).Name("echo")
+	m.Get("/dashboard", func(c Context) string {
+		res, err := c.Call("GET", "user", nil, 5)
+		expect(t, err, nil)
+		expect(t, res.StatusCode, http.StatusOK)
+		user, _ := ioutil.ReadAll(res.Body)
+
+		res, err = c.Call("POST", "echo", map[string]int{"n": 1})
+		expect(t, err, nil)
+		echo, _ := ioutil.ReadAll(res.Body)
+
+		_, err = c.Call("GET", "missing", nil)
+		expect(t, err, ErrRouteNotFound)
+		return string(user) + "|" + string(echo)
+	})
+
+	req := httptest.NewRequest("GET", "/dashboard", nil)
+	req.Header.Set("Authorization", "Bearer x")
+	req.Header.Set("X-Request-Id", "abc")
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	expect(t, res.Code, http.StatusOK)
+	expect(t, res.Body.String(), `5 Bearer x abc|application/json {"n":1}`)
+}
+
+func Test_PropagateTrace(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("Tracestate", "vendor=1")
+	sub := httptest.NewRequest("GET", "/sub", nil)
+	propagateTrace(nil, req, sub)
+
+	expect(t, sub.Header.Get("X-Request-Id"), "abc")
+	parts := strings.Split(sub.Header.Get("Traceparent"), "-")
+	expect(t, len(parts), 4)
+	expect(t, parts[1], "4bf92f3577b34da6a3ce929d0e0e4736")
+	refute(t, parts[2], "00f067aa0ba902b7")
+	expect(t, len(parts[2]), 16)
+	expect(t, parts[3], "01")
+	expect(t, sub.Header.Get("Tracestate"), "vendor=1")
+
+	req.Header.Set("Traceparent", "garbage")
+	sub = httptest.NewRequest("GET", "/sub", nil)
+	propagateTrace(nil, req, sub)
+	expect(t, sub.Header.Get("Traceparent"), "")
+}
+
+func Test_Batch_Trace(t *testing.T) {
+	m := Classic()
+	m.Get("/trace", func(req *http.Request) string {
+		return req.Header.Get("X-Request-Id") + " " + strings.Split(req.Header.Get("Traceparent"), "-")[1]
+	})
+	m.Post("/batch", Batch(m, BatchOptions{}))
+
+	req := httptest.NewRequest("POST", "/batch", strings.NewReader(`[{"method": "GET", "url": "/trace"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-Id", "abc")
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+
+	var responses []BatchResponse
+	expect(t, json.Unmarshal(res.Body.Bytes(), &responses), nil)
+	expect(t, string(responses[0].Body), `"abc 4bf92f3577b34da6a3ce929d0e0e4736"`)
+}
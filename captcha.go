@@ -0,0 +1,116 @@
+package martini
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Verification endpoints of CAPTCHA providers speaking the siteverify protocol.
+const (
+	ReCaptchaVerifyURL = "https://www.google.com/recaptcha/api/siteverify"
+	HCaptchaVerifyURL  = "https://hcaptcha.com/siteverify"
+	TurnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+)
+
+// CaptchaResult is the outcome of a CAPTCHA verification. It is mapped by the Captcha handler.
+type CaptchaResult struct {
+	Success bool `json:"success"`
+	// Score is the likelihood of a human between 0 and 1, for providers scoring requests.
+	Score    float64  `json:"score"`
+	Action   string   `json:"action"`
+	Hostname string   `json:"hostname"`
+	Errors   []string `json:"error-codes"`
+}
+
+// CaptchaVerifier verifies the token a CAPTCHA widget added to a form.
+type CaptchaVerifier interface {
+	Verify(token string, remoteIP string) (CaptchaResult, error)
+}
+
+// SiteVerify returns a CaptchaVerifier for providers speaking the siteverify protocol, like reCAPTCHA,
+// hCaptcha and Turnstile. A nil client uses http.DefaultClient.
+//
+//	martini.SiteVerify(martini.TurnstileVerifyURL, secret, nil)
+func SiteVerify(verifyURL string, secret string, client *http.Client) CaptchaVerifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &siteVerify{verifyURL, secret, client}
+}
+
+type siteVerify struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func (v *siteVerify) Verify(token string, remoteIP string) (CaptchaResult, error) {
+	var result CaptchaResult
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	res, err := v.client.PostForm(v.url, form)
+	if err != nil {
+		return result, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("martini: captcha verification failed with %s", res.Status)
+	}
+	err = json.NewDecoder(res.Body).Decode(&result)
+	return result, err
+}
+
+// CaptchaOptions configures the Captcha handler.
+type CaptchaOptions struct {
+	// Fields are the form fields holding the token. Defaults to the fields of reCAPTCHA, hCaptcha and Turnstile.
+	Fields []string
+	// MinScore is the score a request needs to pass, for providers scoring requests.
+	MinScore float64
+	// Optional maps the result without rejecting failed verifications, so the handler can decide.
+	Optional bool
+}
+
+var defaultCaptchaFields = []string{"g-recaptcha-response", "h-captcha-response", "cf-turnstile-response"}
+
+// Captcha returns a handler verifying the CAPTCHA token of the request. Add it to the routes handling forms
+// with a CAPTCHA widget. Requests failing the verification are answered with 403 Forbidden unless the
+// options make it optional, the CaptchaResult is mapped either way.
+//
+//	m.Post("/signup", martini.Captcha(verifier, martini.CaptchaOptions{}), signup)
+func Captcha(v CaptchaVerifier, opts CaptchaOptions) Handler {
+	if len(opts.Fields) == 0 {
+		opts.Fields = defaultCaptchaFields
+	}
+	return func(c Context, res http.ResponseWriter, req *http.Request) {
+		var token string
+		for _, field := range opts.Fields {
+			if token = strings.TrimSpace(req.FormValue(field)); token != "" {
+				break
+			}
+		}
+
+		var result CaptchaResult
+		if token != "" {
+			var err error
+			if result, err = v.Verify(token, clientIP(req)); err != nil {
+				panic(err)
+			}
+			if result.Success && opts.MinScore > 0 && result.Score < opts.MinScore {
+				result.Success = false
+			}
+		}
+		c.Map(result)
+
+		if !result.Success && !opts.Optional {
+			if l := securityLog(c); l != nil {
+				l.Record(SecurityCaptchaFailure, "", nil)
+			}
+			http.Error(res, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_Captcha(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		expect(t, req.FormValue("secret"), "s3cret")
+		switch req.FormValue("response") {
+		case "human":
+			w.Write([]byte(`{"success": true, "score": 0.9, "hostname": "example.com"}`))
+		case "bot":
+			w.Write([]byte(`{"success": true, "score": 0.1}`))
+		default:
+			w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+		}
+	}))
+	defer provider.Close()
+
+	verifier := SiteVerify(provider.URL, "s3cret", nil)
+	m := Classic()
+	m.Post("/signup", Captcha(verifier, CaptchaOptions{MinScore: 0.5}), func(r CaptchaResult) string {
+		return r.Hostname
+	})
+	m.Post("/comment", Captcha(verifier, CaptchaOptions{Optional: true}), func(r CaptchaResult) string {
+		return strings.Join(r.Errors, ",")
+	})
+
+	post := func(path string, field string, token string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, strings.NewReader(url.Values{field: {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		m.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	expect(t, post("/signup", "cf-turnstile-response", "human").Body.String(), "example.com")
+	expect(t, post("/signup", "g-recaptcha-response", "bot").Code, http.StatusForbidden)
+	expect(t, post("/signup", "other", "human").Code, http.StatusForbidden)
+	expect(t, post("/comment", "h-captcha-response", "forged").Body.String(), "invalid-input-response")
+}
@@ -0,0 +1,253 @@
+// Command martini-gen generates reflection free adapters for martini handlers.
+//
+// Annotate the handlers of a package with a //martini:handler comment and add a go:generate directive:
+//
+//  //go:generate martini-gen
+//
+//  //martini:handler
+//  func ShowUser(params martini.Params, db *sql.DB) string {
+//    ...
+//  }
+//
+// For every annotated func a ShowUserHandler variable is written to martini_handlers.go. Register it
+// in place of the func, martini then calls it without reflect based injection:
+//
+//  m.Get("/users/:id", ShowUserHandler)
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const directive = "//martini:handler"
+
+func main() {
+	output := flag.String("o", "martini_handlers.go", "output file name")
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != *output
+	}, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for name, pkg := range pkgs {
+		var files []*ast.File
+		for _, f := range pkg.Files {
+			files = append(files, f)
+		}
+		src, err := generate(fset, name, files)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if src == nil {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, *output), src, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+type handler struct {
+	name    string
+	params  []string
+	results []string
+}
+
+// generate returns the source of the adapters for the annotated handlers in files, or nil if there are none.
+func generate(fset *token.FileSet, pkg string, files []*ast.File) ([]byte, error) {
+	var handlers []handler
+	imports := map[string]string{"reflect": `"reflect"`, "martini": `"github.com/go-martini/martini"`}
+
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !annotated(fn.Doc) {
+				continue
+			}
+
+			h := handler{name: fn.Name.Name}
+			for _, field := range fn.Type.Params.List {
+				if _, ok := field.Type.(*ast.Ellipsis); ok {
+					return nil, fmt.Errorf("%s: variadic handler %s is not supported", fset.Position(fn.Pos()), h.name)
+				}
+				n := len(field.Names)
+				if n == 0 {
+					n = 1
+				}
+				for i := 0; i < n; i++ {
+					h.params = append(h.params, typeString(fset, field.Type))
+				}
+				if err := addImports(imports, f, field.Type); err != nil {
+					return nil, fmt.Errorf("%s: %v", fset.Position(fn.Pos()), err)
+				}
+			}
+			if fn.Type.Results != nil {
+				for _, field := range fn.Type.Results.List {
+					n := len(field.Names)
+					if n == 0 {
+						n = 1
+					}
+					for i := 0; i < n; i++ {
+						h.results = append(h.results, typeString(fset, field.Type))
+					}
+					if err := addImports(imports, f, field.Type); err != nil {
+						return nil, fmt.Errorf("%s: %v", fset.Position(fn.Pos()), err)
+					}
+				}
+			}
+			if len(h.params) > 0 {
+				// the adapters report the params that can't be injected with fmt.Errorf
+				imports["fmt"] = `"fmt"`
+			}
+			handlers = append(handlers, h)
+		}
+	}
+
+	if len(handlers) == 0 {
+		return nil, nil
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Code generated by martini-gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	var specs []string
+	for _, spec := range imports {
+		specs = append(specs, spec)
+	}
+	sort.Strings(specs)
+	for _, spec := range specs {
+		fmt.Fprintf(buf, "\t%s\n", spec)
+	}
+	fmt.Fprintf(buf, ")\n")
+
+	for _, h := range handlers {
+		writeHandler(buf, h)
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+func writeHandler(buf *bytes.Buffer, h handler) {
+	invoker := "_" + h.name + "Invoker"
+	types := "_" + h.name + "Types"
+
+	fmt.Fprintf(buf, "\nvar %s = [...]reflect.Type{\n", types)
+	for _, p := range h.params {
+		fmt.Fprintf(buf, "\treflect.TypeOf((*%s)(nil)).Elem(),\n", p)
+	}
+	fmt.Fprintf(buf, "}\n\n")
+
+	fmt.Fprintf(buf, "type %s func(%s)", invoker, strings.Join(h.params, ", "))
+	switch len(h.results) {
+	case 0:
+	case 1:
+		fmt.Fprintf(buf, " %s", h.results[0])
+	default:
+		fmt.Fprintf(buf, " (%s)", strings.Join(h.results, ", "))
+	}
+	fmt.Fprintf(buf, "\n\n")
+
+	fmt.Fprintf(buf, "func (h %s) Invoke(c martini.Context) ([]reflect.Value, error) {\n", invoker)
+	var args []string
+	for i, p := range h.params {
+		fmt.Fprintf(buf, "\tv%d := c.Get(%s[%d])\n", i, types, i)
+		fmt.Fprintf(buf, "\tif !v%d.IsValid() {\n\t\treturn nil, fmt.Errorf(\"Value not found for type %%v\", %s[%d])\n\t}\n", i, types, i)
+		args = append(args, fmt.Sprintf("v%d.Interface().(%s)", i, p))
+	}
+	var results, values []string
+	for i := range h.results {
+		results = append(results, "r"+strconv.Itoa(i))
+		values = append(values, fmt.Sprintf("reflect.ValueOf(&r%d).Elem()", i))
+	}
+	if len(results) > 0 {
+		fmt.Fprintf(buf, "\t%s := h(%s)\n", strings.Join(results, ", "), strings.Join(args, ", "))
+	} else {
+		fmt.Fprintf(buf, "\th(%s)\n", strings.Join(args, ", "))
+	}
+	fmt.Fprintf(buf, "\treturn []reflect.Value{%s}, nil\n}\n\n", strings.Join(values, ", "))
+
+	fmt.Fprintf(buf, "// %sHandler calls %s without reflection based injection.\n", h.name, h.name)
+	fmt.Fprintf(buf, "var %sHandler martini.Handler = %s(%s)\n", h.name, invoker, h.name)
+}
+
+func annotated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == directive {
+			return true
+		}
+	}
+	return false
+}
+
+func typeString(fset *token.FileSet, expr ast.Expr) string {
+	buf := new(bytes.Buffer)
+	printer.Fprint(buf, fset, expr)
+	return buf.String()
+}
+
+// addImports records the imports of file that are referenced by the type expression.
+func addImports(imports map[string]string, file *ast.File, expr ast.Expr) error {
+	var err error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		spec := findImport(file, ident.Name)
+		if spec == "" {
+			err = fmt.Errorf("cannot resolve package %s", ident.Name)
+			return false
+		}
+		imports[ident.Name] = spec
+		return false
+	})
+	return err
+}
+
+func findImport(file *ast.File, name string) string {
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return imp.Name.Name + " " + imp.Path.Value
+			}
+			continue
+		}
+		if path == "github.com/go-martini/martini" && name == "martini" {
+			return imp.Path.Value
+		}
+		if filepath.Base(path) == name {
+			return imp.Path.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+const source = `package app
+
+import (
+	"database/sql"
+
+	"github.com/go-martini/martini"
+)
+
+//martini:handler
+func ShowUser(params martini.Params, db *sql.DB) (int, string) {
+	return 200, params["id"]
+}
+
+func notAnnotated(db *sql.DB) {}
+
+// logRequest logs the request.
+//martini:handler
+func logRequest(c martini.Context) {
+	c.Next()
+}
+`
+
+func Test_Generate(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "app.go", source, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := generate(fset, "app", []*ast.File{f})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := string(src)
+	for _, s := range []string{
+		"package app",
+		`"database/sql"`,
+		"type _ShowUserInvoker func(martini.Params, *sql.DB) (int, string)",
+		"r0, r1 := h(v0.Interface().(martini.Params), v1.Interface().(*sql.DB))",
+		"var ShowUserHandler martini.Handler = _ShowUserInvoker(ShowUser)",
+		"type _logRequestInvoker func(martini.Context)",
+		"var logRequestHandler martini.Handler = _logRequestInvoker(logRequest)",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected generated source to contain %q, got:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "notAnnotated") {
+		t.Errorf("generated an adapter for a handler that is not annotated")
+	}
+}
+
+func Test_GenerateWithoutParams(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "app.go", "package app\n\n//martini:handler\nfunc Ping() string {\n\treturn \"pong\"\n}\n", parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(fset, "app", []*ast.File{f})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(src), `"fmt"`) {
+		t.Errorf("expected no fmt import, got:\n%s", src)
+	}
+
+	generated, err := parser.ParseFile(fset, "martini_handlers.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("app", fset, []*ast.File{f, generated}, nil); err != nil {
+		t.Errorf("generated source doesn't compile: %v\n%s", err, src)
+	}
+}
@@ -0,0 +1,179 @@
+package martini
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config is a service holding configuration merged from config files, environment variables and flags.
+// Keys are case insensitive and nested with dots, "static.dir" is set by {"static": {"dir": "public"}}
+// in a JSON file, by MARTINI_STATIC_DIR in the environment and by a -static-dir flag. Sources loaded
+// later override the ones loaded before.
+//
+// martini.New maps a *Config with the MARTINI_ environment variables loaded. Martini itself reads
+// "port", "host", "tls.cert" and "tls.key" in Run and "static.dir" in Classic.
+type Config struct {
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// ConfigDecoder decodes the contents of a config file into nested maps.
+type ConfigDecoder func(data []byte) (map[string]interface{}, error)
+
+var configDecoders = map[string]ConfigDecoder{
+	".json": func(data []byte) (map[string]interface{}, error) {
+		var v map[string]interface{}
+		err := json.Unmarshal(data, &v)
+		return v, err
+	},
+}
+
+// RegisterConfigFormat registers the decoder for config files with the given extension, e.g. ".yaml" or
+// ".toml". JSON is supported out of the box.
+func RegisterConfigFormat(ext string, decode ConfigDecoder) {
+	configDecoders[strings.ToLower(ext)] = decode
+}
+
+// NewConfig creates an empty Config.
+func NewConfig() *Config {
+	return &Config{values: make(map[string]string)}
+}
+
+// configKey normalizes keys, "Static_Dir", "static-dir" and "static.dir" are the same key. Only the
+// "flags." prefix of feature flags is normalized, "FLAGS_NEW_CHECKOUT" is the "flags.new_checkout" key.
+func configKey(key string) string {
+	key = strings.ToLower(key)
+	if len(key) > len("flags.") && strings.HasPrefix(key, "flags") && strings.ContainsRune("._-", rune(key[len("flags")])) {
+		return "flags." + key[len("flags."):]
+	}
+	return strings.NewReplacer("_", ".", "-", ".").Replace(key)
+}
+
+// Set sets the value of a key.
+func (c *Config) Set(key string, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[configKey(key)] = value
+}
+
+// Lookup returns the value of a key and whether it is set.
+func (c *Config) Lookup(key string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.values[configKey(key)]
+	return v, ok
+}
+
+// LoadEnv loads the environment variables starting with prefix, without the prefix. MARTINI_STATIC_DIR
+// is loaded as "static.dir" with the prefix "MARTINI_".
+func (c *Config) LoadEnv(prefix string) {
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 && strings.HasPrefix(parts[0], prefix) && len(parts[0]) > len(prefix) {
+			c.Set(parts[0][len(prefix):], parts[1])
+		}
+	}
+}
+
+// LoadFile loads a config file, its format is chosen by the file extension. Nested objects are
+// flattened into dotted keys.
+func (c *Config) LoadFile(path string) error {
+	decode, ok := configDecoders[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return fmt.Errorf("martini: no config format registered for %s", path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values, err := decode(data)
+	if err != nil {
+		return fmt.Errorf("martini: invalid config file %s: %v", path, err)
+	}
+	c.setAll("", values)
+	return nil
+}
+
+func (c *Config) setAll(prefix string, values map[string]interface{}) {
+	for k, v := range values {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			c.setAll(prefix+k+".", v)
+		case nil:
+		default:
+			c.Set(prefix+k, fmt.Sprint(v))
+		}
+	}
+}
+
+// LoadFlags loads the flags that have been set on the command line. Call it after the flag set has been parsed.
+func (c *Config) LoadFlags(fs *flag.FlagSet) {
+	fs.Visit(func(f *flag.Flag) {
+		c.Set(f.Name, f.Value.String())
+	})
+}
+
+// String returns the value of the key, or def if it is not set.
+func (c *Config) String(key string, def string) string {
+	if v, ok := c.Lookup(key); ok {
+		return v
+	}
+	return def
+}
+
+// Int returns the value of the key as an int, or def if it is not set or not an int.
+func (c *Config) Int(key string, def int) int {
+	if v, err := strconv.Atoi(c.String(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+// Bool returns the value of the key as a bool, or def if it is not set or not a bool.
+func (c *Config) Bool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(c.String(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+// Duration returns the value of the key as a time.Duration like "1m30s", or def if it is not set or
+// not a duration.
+func (c *Config) Duration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(c.String(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+// Flag returns whether the feature flag is on, it is the "flags.<name>" key, off unless set to true. Flag
+// names are case insensitive, but "new_checkout" and "new-checkout" are different flags.
+func (c *Config) Flag(name string) bool {
+	return c.Bool("flags."+name, false)
+}
+
+// Flags returns the feature flags that are set, by name.
+func (c *Config) Flags() map[string]bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	flags := make(map[string]bool)
+	for key, v := range c.values {
+		if strings.HasPrefix(key, "flags.") {
+			flags[key[len("flags."):]], _ = strconv.ParseBool(v)
+		}
+	}
+	return flags
+}
+
+// Config returns the configuration of the Martini instance.
+func (m *Martini) Config() *Config {
+	return m.config
+}
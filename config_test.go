@@ -0,0 +1,82 @@
+package martini
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Config(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-config")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(file, []byte(`{"port": 8080, "debug": true, "static": {"dir": "assets"}, "timeout": "1m30s"}`), 0644)
+
+	os.Setenv("TESTAPP_STATIC_DIR", "www")
+	defer os.Unsetenv("TESTAPP_STATIC_DIR")
+
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.Int("port", 3000, "")
+	fs.String("host", "localhost", "")
+	fs.Parse([]string{"-port", "9090"})
+
+	c := NewConfig()
+	expect(t, c.LoadFile(file), nil)
+	expect(t, c.String("static.dir", "public"), "assets")
+	c.LoadEnv("TESTAPP_")
+	c.LoadFlags(fs)
+
+	expect(t, c.Int("port", 0), 9090)
+	expect(t, c.String("Static_Dir", "public"), "www")
+	expect(t, c.Bool("debug", false), true)
+	expect(t, c.Duration("timeout", 0), 90*time.Second)
+	// flags not given on the command line don't override anything
+	expect(t, c.String("host", "0.0.0.0"), "0.0.0.0")
+	expect(t, c.Int("missing", 7), 7)
+
+	expect(t, c.LoadFile(filepath.Join(dir, "config.ini")).Error(), "martini: no config format registered for "+filepath.Join(dir, "config.ini"))
+}
+
+func Test_Config_Injected(t *testing.T) {
+	m := New()
+	m.Config().Set("greeting", "hello")
+	m.Use(func(c *Config) {
+		expect(t, c.String("greeting", ""), "hello")
+	})
+	m.ServeHTTP(httptest.NewRecorder(), (*http.Request)(nil))
+}
+
+func Test_Config_Flags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-config")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(file, []byte(`{"flags": {"dark_mode": true, "beta-search": false}}`), 0644)
+
+	os.Setenv("TESTAPP_FLAGS_NEW_CHECKOUT", "true")
+	defer os.Unsetenv("TESTAPP_FLAGS_NEW_CHECKOUT")
+
+	c := NewConfig()
+	expect(t, c.LoadFile(file), nil)
+	c.LoadEnv("TESTAPP_")
+	c.Set("flags.v2.api", "true")
+
+	expect(t, c.Flag("new_checkout"), true)
+	expect(t, c.Flag("New_Checkout"), true)
+	expect(t, c.Flag("new.checkout"), false)
+	expect(t, c.Flag("dark_mode"), true)
+	expect(t, c.Flag("v2.api"), true)
+
+	flags := c.Flags()
+	expect(t, len(flags), 4)
+	expect(t, flags["new_checkout"], true)
+	expect(t, flags["dark_mode"], true)
+	expect(t, flags["beta-search"], false)
+	expect(t, flags["v2.api"], true)
+}
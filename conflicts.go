@@ -0,0 +1,189 @@
+package martini
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RouteConflict is a pair of routes that match some of the same requests. The route added first
+// handles them.
+type RouteConflict struct {
+	// First is the route added first, e.g. "GET /users/:id".
+	First string
+	// Second is the route added later, e.g. "GET /users/new".
+	Second string
+	// Shadowed is whether First matches every request Second matches, so Second is never reached.
+	Shadowed bool
+}
+
+func (c RouteConflict) String() string {
+	if c.Shadowed {
+		return fmt.Sprintf("%s is never matched, %s is added before it and matches the same requests", c.Second, c.First)
+	}
+	return fmt.Sprintf("%s matches some requests for %s, which is added before it and takes them", c.Second, c.First)
+}
+
+// segment is a path segment of a route pattern for the conflict analysis.
+type segment struct {
+	literal string
+	param   bool
+	// constraint is the expression a param is constrained to
+	constraint *regexp.Regexp
+	wildcard   bool
+}
+
+// patternSegments splits a pattern into segments, it returns false for patterns using regexp syntax
+// other than whole segment params and a trailing wildcard, named or not, which aren't analyzed.
+func patternSegments(pattern string) ([]segment, bool) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, false
+	}
+	// the trailing slash is optional
+	if pattern != "/" {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	params := routeParams(pattern)
+	var segments []segment
+	start := 1
+	for start <= len(pattern) {
+		end := start + strings.Index(pattern[start:]+"/", "/")
+		// a constraint can contain slashes
+		for _, p := range params {
+			if p.start == start && p.end > end {
+				end = p.end
+			}
+		}
+		s := pattern[start:end]
+		named := len(params) > 0 && params[0].wildcard && params[0].start == start && params[0].end == end
+		switch {
+		case s == "**" || named:
+			if end < len(pattern) {
+				return nil, false
+			}
+			if named {
+				params = params[1:]
+			}
+			segments = append(segments, segment{wildcard: true})
+		case len(params) > 0 && params[0].start == start && params[0].end == end:
+			seg := segment{param: true}
+			if params[0].constraint != "" {
+				regex, err := regexp.Compile(`^(?:` + params[0].constraint + `)$`)
+				if err != nil {
+					return nil, false
+				}
+				seg.constraint = regex
+			}
+			params = params[1:]
+			segments = append(segments, seg)
+		case strings.ContainsAny(s, ":*()\\[]{}?+^$|"):
+			return nil, false
+		default:
+			segments = append(segments, segment{literal: s})
+		}
+		start = end + 1
+	}
+	return segments, true
+}
+
+// covers returns whether the segment matches every value other matches.
+func (s segment) covers(other segment, fold bool) bool {
+	switch {
+	case s.wildcard:
+		return true
+	case other.wildcard:
+		return false
+	case !s.param:
+		return !other.param && equalPath(s.literal, other.literal, fold)
+	case s.constraint == nil:
+		return true
+	case other.param:
+		return other.constraint != nil && other.constraint.String() == s.constraint.String()
+	default:
+		return s.constraint.MatchString(other.literal)
+	}
+}
+
+// overlaps returns whether the segments match a common value.
+func (s segment) overlaps(other segment, fold bool) bool {
+	switch {
+	case s.wildcard || other.wildcard:
+		return true
+	case s.param && other.param:
+		return true
+	case s.param:
+		return s.constraint == nil || s.constraint.MatchString(other.literal)
+	case other.param:
+		return other.overlaps(s, fold)
+	default:
+		return equalPath(s.literal, other.literal, fold)
+	}
+}
+
+// compareSegments returns whether the patterns a and b match a common path, and whether a matches
+// every path b matches.
+func compareSegments(a, b []segment, fold bool) (overlap bool, covers bool) {
+	covers = true
+	for i := 0; i < len(a) || i < len(b); i++ {
+		switch {
+		case i == len(a):
+			// "/files" and "/files/**" both match "/files/"
+			return b[i].wildcard, false
+		case i == len(b):
+			// only a wildcard, which matches the rest of the path, can be longer
+			return a[i].wildcard, false
+		}
+		if !a[i].overlaps(b[i], fold) {
+			return false, false
+		}
+		covers = covers && a[i].covers(b[i], fold)
+		if a[i].wildcard || b[i].wildcard {
+			return true, covers
+		}
+	}
+	return true, covers
+}
+
+// methodsOverlap returns whether requests with some method match both routes, and whether the
+// first route matches every method the second does.
+func methodsOverlap(first, second string) (overlap bool, covers bool) {
+	switch {
+	case first == "*" || first == second || (first == "GET" && second == "HEAD"):
+		return true, true
+	case second == "*" || (first == "HEAD" && second == "GET"):
+		return true, false
+	}
+	return false, false
+}
+
+func (r *router) CheckConflicts() []RouteConflict {
+	routes := r.index().routes
+	segments := make([][]segment, len(routes))
+	analyzed := make([]bool, len(routes))
+	for i, route := range routes {
+		segments[i], analyzed[i] = patternSegments(route.pattern)
+	}
+
+	var conflicts []RouteConflict
+	for j, second := range routes {
+		for i, first := range routes[:j] {
+			if !analyzed[i] || !analyzed[j] {
+				continue
+			}
+			// routes of different hosts don't conflict, a route for any host takes those of every host
+			if first.host != nil && first.host.String() != second.host.String() {
+				continue
+			}
+			methods, allMethods := methodsOverlap(first.method, second.method)
+			if !methods || (!allMethods && first.pattern == second.pattern) {
+				// e.g. Any after Get for the same pattern handles the other methods
+				continue
+			}
+			overlap, covers := compareSegments(segments[i], segments[j], r.fold)
+			if overlap {
+				conflicts = append(conflicts, RouteConflict{first.String(), second.String(), covers && allMethods})
+			}
+		}
+	}
+	return conflicts
+}
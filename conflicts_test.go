@@ -0,0 +1,44 @@
+package martini
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_CheckConflicts(t *testing.T) {
+	r := NewRouter()
+	r.Get("/users/new", func() {})
+	r.Get("/users/:id", func() {})
+	r.Get("/users/:id", func() {})
+	r.Post("/users/:id", func() {})
+	r.Any("/users/:id", func() {})
+	r.Get(`/posts/:id(\d+)`, func() {})
+	r.Get("/posts/latest", func() {})
+	r.Get("/posts/42/", func() {})
+	r.Get("/files/**", func() {})
+	r.Get("/files/a/b", func() {})
+	r.Get(`/raw/\d+`, func() {})
+	r.Resource("/things/:id", Methods{"GET": {func() {}}})
+
+	var found []string
+	for _, c := range r.CheckConflicts() {
+		found = append(found, c.String())
+	}
+	expect(t, strings.Join(found, "\n"), strings.Join([]string{
+		"GET /users/:id matches some requests for GET /users/new, which is added before it and takes them",
+		"GET /users/:id matches some requests for GET /users/new, which is added before it and takes them",
+		"GET /users/:id is never matched, GET /users/:id is added before it and matches the same requests",
+		"* /users/:id matches some requests for GET /users/new, which is added before it and takes them",
+		`GET /posts/42/ is never matched, GET /posts/:id(\d+) is added before it and matches the same requests`,
+		"GET /files/a/b is never matched, GET /files/** is added before it and matches the same requests",
+	}, "\n"))
+}
+
+func Test_Validate_ShadowedRoutes(t *testing.T) {
+	m := Classic()
+	m.Get("/users/:id", func() {})
+	m.Get("/users/new", func() {})
+	err := m.Validate()
+	refute(t, err, nil)
+	expect(t, strings.Contains(err.Error(), "GET /users/new is never matched"), true)
+}
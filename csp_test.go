@@ -0,0 +1,57 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+)
+
+func Test_addCSPNonce(t *testing.T) {
+	expect(t, addCSPNonce("default-src 'self'; script-src 'self' cdn.example.com", "abc"),
+		"default-src 'self'; script-src 'self' cdn.example.com 'nonce-abc'; style-src 'self' 'nonce-abc'")
+	expect(t, addCSPNonce("default-src 'none'; img-src *", "abc"),
+		"default-src 'none'; img-src *; script-src 'nonce-abc'; style-src 'nonce-abc'")
+	expect(t, addCSPNonce("Style-Src 'none'", "abc"), "Style-Src 'nonce-abc'; script-src 'nonce-abc'")
+}
+
+func Test_CSP(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"page.tmpl": `<script nonce="{{csp_nonce}}"></script>`,
+	})
+	defer os.RemoveAll(dir)
+	tmpl, err := NewTemplates(TemplateOptions{Dir: dir})
+	expect(t, err, nil)
+
+	m := Classic()
+	m.Use(tmpl.Handler())
+	m.Use(CSP("default-src 'self'"))
+	m.Get("/", func(r *Render) error {
+		return r.HTML(http.StatusOK, "page", nil)
+	})
+	m.Get("/custom", func(res http.ResponseWriter, nonce CSPNonce) string {
+		res.Header().Set("Content-Security-Policy", "script-src 'self'")
+		return string(nonce)
+	})
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+	header := res.Header().Get("Content-Security-Policy")
+	nonce := regexp.MustCompile(`'nonce-(\w+)'`).FindStringSubmatch(header)[1]
+	expect(t, len(nonce), 32)
+	expect(t, res.Body.String(), `<script nonce="`+nonce+`"></script>`)
+
+	res2 := httptest.NewRecorder()
+	m.ServeHTTP(res2, httptest.NewRequest("GET", "/", nil))
+	refute(t, res2.Body.String(), res.Body.String())
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/custom", nil))
+	nonce = res.Body.String()
+	expect(t, res.Header().Get("Content-Security-Policy"), "script-src 'self' 'nonce-"+nonce+"'; style-src 'nonce-"+nonce+"'")
+
+	out, err := tmpl.Render("page", nil)
+	expect(t, err, nil)
+	expect(t, string(out), `<script nonce=""></script>`)
+}
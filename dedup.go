@@ -0,0 +1,209 @@
+package martini
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DedupResponse is a response stored by Dedup to replay it to duplicates.
+type DedupResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// DedupStore remembers the nonces of submissions.
+type DedupStore interface {
+	// Claim claims the key until expires. It reports false if the key is already claimed, claiming has to
+	// be atomic.
+	Claim(key string, expires time.Time) (bool, error)
+	// Release removes the claim, so the submission can be retried.
+	Release(key string) error
+	// Complete stores the response to the claimed key.
+	Complete(key string, r *DedupResponse) error
+	// Response returns the response stored for the key, nil while there is none.
+	Response(key string) (*DedupResponse, error)
+}
+
+// DedupOptions configures the Dedup middleware.
+type DedupOptions struct {
+	// Header is the request header holding the nonce. Defaults to "X-Request-Nonce".
+	Header string
+	// Field is the form field holding the nonce if the header is missing. Defaults to "_nonce".
+	Field string
+	// Window is how long a nonce is remembered. Defaults to 10 minutes.
+	Window time.Duration
+	// Replay answers duplicates with the response to the first submission instead of 409 Conflict.
+	// The response is buffered to record it.
+	Replay bool
+}
+
+// Dedup returns a middleware handler that prevents double submissions, e.g. of a form posted twice by an
+// impatient click. Requests carrying a nonce already seen within the window, for the same method, path and
+// client, are answered with 409 Conflict, or with the recorded response of the first one with Replay. The
+// client is the *Session if Sessions runs before Dedup, the client IP otherwise, so clients can't replay
+// each other's responses by sending the same nonce. Replayed responses don't set cookies. A
+// submission that panics or fails with a 5xx status releases its nonce, so the client can retry it.
+// Requests without a nonce pass.
+//
+//	m.Post("/orders", martini.Dedup(martini.NewMemoryDedupStore(), martini.DedupOptions{Replay: true}), createOrder)
+func Dedup(store DedupStore, opts DedupOptions) Handler {
+	if opts.Header == "" {
+		opts.Header = "X-Request-Nonce"
+	}
+	if opts.Field == "" {
+		opts.Field = "_nonce"
+	}
+	if opts.Window == 0 {
+		opts.Window = 10 * time.Minute
+	}
+
+	return func(c Context, res http.ResponseWriter, req *http.Request) {
+		nonce := req.Header.Get(opts.Header)
+		if nonce == "" {
+			nonce = req.FormValue(opts.Field)
+		}
+		if nonce == "" {
+			return
+		}
+		key := req.Method + " " + req.URL.Path + " " + dedupClient(c, req) + " " + nonce
+
+		claimed, err := store.Claim(key, time.Now().Add(opts.Window))
+		if err != nil {
+			panic(err)
+		}
+		if !claimed {
+			replayDedup(store, key, opts.Replay, res, req)
+			return
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				store.Release(key)
+			}
+		}()
+
+		rw := res.(ResponseWriter)
+		bw, buffered := res.(BufferedResponseWriter)
+		buffered = buffered && opts.Replay
+		if buffered {
+			bw.Buffer()
+		}
+		c.Next()
+		if buffered {
+			defer bw.Commit()
+		}
+		if rw.Status() >= 500 {
+			return
+		}
+		completed = true
+		if buffered && !bw.Committed() {
+			header := make(http.Header)
+			for k, v := range rw.Header() {
+				if k != "Set-Cookie" {
+					header[k] = v
+				}
+			}
+			status := rw.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if err := store.Complete(key, &DedupResponse{status, header, append([]byte(nil), bw.Body()...)}); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// dedupClient returns the identity of the client a nonce is scoped to.
+func dedupClient(c Context, req *http.Request) string {
+	if v := c.Get(reflect.TypeOf((*Session)(nil))); v.IsValid() {
+		return "session:" + v.Interface().(*Session).ID
+	}
+	return "ip:" + clientIP(req)
+}
+
+// replayDedup answers a duplicate submission.
+func replayDedup(store DedupStore, key string, replay bool, res http.ResponseWriter, req *http.Request) {
+	if replay {
+		r, err := store.Response(key)
+		if err != nil {
+			panic(err)
+		}
+		if r != nil {
+			for k, v := range r.Header {
+				res.Header()[k] = v
+			}
+			res.WriteHeader(r.Status)
+			res.Write(r.Body)
+			return
+		}
+	}
+	writeHTTPError(res, req, HTTPError{Status: http.StatusConflict, Message: "duplicate submission"})
+}
+
+// dedupSweepInterval is how often the memory store drops expired nonces.
+const dedupSweepInterval = time.Minute
+
+// NewMemoryDedupStore creates a DedupStore keeping nonces in memory.
+func NewMemoryDedupStore() DedupStore {
+	return &memoryDedupStore{entries: make(map[string]*dedupEntry), swept: time.Now()}
+}
+
+type dedupEntry struct {
+	expires  time.Time
+	response *DedupResponse
+}
+
+type memoryDedupStore struct {
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	swept   time.Time
+}
+
+func (m *memoryDedupStore) Claim(key string, expires time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if now.Sub(m.swept) >= dedupSweepInterval {
+		m.swept = now
+		for k, e := range m.entries {
+			if now.After(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+	}
+	if e, ok := m.entries[key]; ok && !now.After(e.expires) {
+		return false, nil
+	}
+	m.entries[key] = &dedupEntry{expires: expires}
+	return true, nil
+}
+
+func (m *memoryDedupStore) Release(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *memoryDedupStore) Complete(key string, r *DedupResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok {
+		e.response = r
+	}
+	return nil
+}
+
+func (m *memoryDedupStore) Response(key string) (*DedupResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok && !time.Now().After(e.expires) {
+		return e.response, nil
+	}
+	return nil, nil
+}
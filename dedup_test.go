@@ -0,0 +1,127 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Dedup(t *testing.T) {
+	orders := 0
+	m := Classic()
+	m.Post("/orders", Dedup(NewMemoryDedupStore(), DedupOptions{}), func() string {
+		orders++
+		return "created"
+	})
+
+	post := func(nonce string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", nil)
+		if nonce != "" {
+			req.Header.Set("X-Request-Nonce", nonce)
+		}
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		return res
+	}
+
+	expect(t, post("a").Code, http.StatusOK)
+	expect(t, post("a").Code, http.StatusConflict)
+	expect(t, post("b").Code, http.StatusOK)
+	expect(t, post("").Code, http.StatusOK)
+	expect(t, post("").Code, http.StatusOK)
+	expect(t, orders, 4)
+
+	form := url.Values{"_nonce": {"a"}}
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	expect(t, res.Code, http.StatusConflict)
+	expect(t, orders, 4)
+}
+
+func Test_Dedup_Replay(t *testing.T) {
+	orders, fail := 0, true
+	m := Classic()
+	m.Post("/orders", Dedup(NewMemoryDedupStore(), DedupOptions{Replay: true}), func(res http.ResponseWriter) {
+		if fail {
+			fail = false
+			panic("database down")
+		}
+		orders++
+		res.Header().Set("Location", "/orders/1")
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte("order 1"))
+	})
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", nil)
+		req.Header.Set("X-Request-Nonce", "n")
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		return res
+	}
+
+	// a failed submission can be retried
+	expect(t, post().Code, http.StatusInternalServerError)
+	for i := 0; i < 2; i++ {
+		res := post()
+		expect(t, res.Code, http.StatusCreated)
+		expect(t, res.Header().Get("Location"), "/orders/1")
+		expect(t, res.Body.String(), "order 1")
+	}
+	expect(t, orders, 1)
+}
+
+func Test_Dedup_Clients(t *testing.T) {
+	m := Classic()
+	m.Post("/orders", Dedup(NewMemoryDedupStore(), DedupOptions{Replay: true}), func(req *http.Request, res http.ResponseWriter) string {
+		http.SetCookie(res, &http.Cookie{Name: "sid", Value: req.RemoteAddr})
+		return "order for " + req.RemoteAddr
+	})
+
+	post := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Request-Nonce", "n")
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		return res
+	}
+
+	expect(t, post("203.0.113.9:1234").Body.String(), "order for 203.0.113.9:1234")
+	// another client sending the same nonce gets its own response
+	expect(t, post("198.51.100.1:1234").Body.String(), "order for 198.51.100.1:1234")
+	// the replayed response doesn't set the cookie again
+	res := post("203.0.113.9:1234")
+	expect(t, res.Body.String(), "order for 203.0.113.9:1234")
+	expect(t, res.Header().Get("Set-Cookie"), "")
+}
+
+func Test_MemoryDedupStore_Expires(t *testing.T) {
+	store := NewMemoryDedupStore().(*memoryDedupStore)
+	past := time.Now().Add(-time.Second)
+	future := time.Now().Add(time.Hour)
+
+	for _, key := range []string{"a", "b", "c"} {
+		ok, _ := store.Claim(key, past)
+		expect(t, ok, true)
+	}
+	ok, _ := store.Claim("d", future)
+	expect(t, ok, true)
+	ok, _ = store.Claim("d", future)
+	expect(t, ok, false)
+
+	// expired nonces can be claimed again before they are swept
+	ok, _ = store.Claim("a", future)
+	expect(t, ok, true)
+	expect(t, len(store.entries), 4)
+
+	store.swept = time.Now().Add(-dedupSweepInterval)
+	ok, _ = store.Claim("e", future)
+	expect(t, ok, true)
+	expect(t, len(store.entries), 3)
+}
@@ -0,0 +1,107 @@
+package martini
+
+import (
+	"errors"
+	"net/http"
+	"os"
+)
+
+// ErrorClass classifies errors by the kind of failure, each class maps to a HTTP status.
+type ErrorClass int
+
+const (
+	// Internal is the class of unexpected errors, answered with 500 Internal Server Error.
+	Internal ErrorClass = iota
+	// NotFound is the class of errors for missing resources, answered with 404 Not Found.
+	NotFound
+	// Invalid is the class of errors for invalid input, answered with 422 Unprocessable Entity.
+	Invalid
+	// Conflict is the class of errors for conflicting state, answered with 409 Conflict.
+	Conflict
+)
+
+var errorStatus = map[ErrorClass]int{
+	Internal: http.StatusInternalServerError,
+	NotFound: http.StatusNotFound,
+	Invalid:  http.StatusUnprocessableEntity,
+	Conflict: http.StatusConflict,
+}
+
+// Status returns the HTTP status of the class.
+func (c ErrorClass) Status() int {
+	if status, ok := errorStatus[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+func (c ErrorClass) String() string {
+	return http.StatusText(c.Status())
+}
+
+// Errors is a service that classifies errors, it is mapped by martini.New. Handlers returning a non-nil
+// error as their last value are answered with the status of its class and, unless it is Internal, the
+// message it was wrapped with. Map your own implementation to classify the errors of other packages.
+//
+//	func FindUser(id string) (*User, error) {
+//	  ...
+//	  return nil, martini.Wrap(err, martini.NotFound, "no such user")
+//	}
+type Errors interface {
+	// Wrap returns an error of the given class with a message that is safe to show to clients. The
+	// returned error wraps err, which may be nil.
+	Wrap(err error, class ErrorClass, message string) error
+	// Classify returns the class of the error.
+	Classify(err error) ErrorClass
+	// Message returns the message shown to clients for the error.
+	Message(err error) string
+}
+
+// Wrap returns an error of the given class, see Errors.Wrap.
+func Wrap(err error, class ErrorClass, message string) error {
+	return &classifiedError{err, class, message}
+}
+
+type classifiedError struct {
+	err     error
+	class   ErrorClass
+	message string
+}
+
+func (e *classifiedError) Error() string {
+	if e.err == nil {
+		return e.message
+	}
+	return e.message + ": " + e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+type defaultErrors struct{}
+
+func (defaultErrors) Wrap(err error, class ErrorClass, message string) error {
+	return Wrap(err, class, message)
+}
+
+// Classify returns the class the error was wrapped with. Errors for missing files are NotFound,
+// everything else is Internal.
+func (defaultErrors) Classify(err error) ErrorClass {
+	var ce *classifiedError
+	switch {
+	case errors.As(err, &ce):
+		return ce.class
+	case errors.Is(err, os.ErrNotExist):
+		return NotFound
+	}
+	return Internal
+}
+
+func (e defaultErrors) Message(err error) string {
+	var ce *classifiedError
+	if errors.As(err, &ce) && ce.class != Internal {
+		return ce.message
+	}
+	return e.Classify(err).String()
+}
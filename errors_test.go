@@ -0,0 +1,60 @@
+package martini
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func Test_Errors_Classify(t *testing.T) {
+	var errs Errors = defaultErrors{}
+	cause := errors.New("sql: no rows in result set")
+	err := fmt.Errorf("loading user: %w", errs.Wrap(cause, NotFound, "no such user"))
+
+	expect(t, errs.Classify(err), NotFound)
+	expect(t, errs.Message(err), "no such user")
+	expect(t, errors.Is(err, cause), true)
+	expect(t, err.Error(), "loading user: no such user: sql: no rows in result set")
+
+	expect(t, errs.Classify(os.ErrNotExist), NotFound)
+	expect(t, errs.Classify(cause), Internal)
+	expect(t, errs.Message(cause), "Internal Server Error")
+	expect(t, errs.Message(Wrap(cause, Internal, "database is down")), "Internal Server Error")
+	expect(t, Invalid.Status(), http.StatusUnprocessableEntity)
+	expect(t, Conflict.Status(), http.StatusConflict)
+}
+
+func Test_ReturnHandler_Errors(t *testing.T) {
+	m := Classic()
+	m.Get("/users/:id", func(params Params) (string, error) {
+		switch params["id"] {
+		case "1":
+			return "user 1", nil
+		case "2":
+			return "", Wrap(nil, Invalid, "id must be odd")
+		}
+		return "", errors.New("boom")
+	})
+	m.Get("/save", func() error { return nil })
+	m.Get("/typed", func() error { return HTTPError{Status: http.StatusTeapot} })
+
+	for path, want := range map[string]struct {
+		code int
+		body string
+	}{
+		"/users/1": {http.StatusOK, "user 1"},
+		"/users/2": {http.StatusUnprocessableEntity, "id must be odd\n"},
+		"/users/3": {http.StatusInternalServerError, "Internal Server Error\n"},
+		"/save":    {http.StatusOK, ""},
+		"/typed":   {http.StatusTeapot, "I'm a teapot\n"},
+	} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(recorder, req)
+		expect(t, recorder.Code, want.code)
+		expect(t, recorder.Body.String(), want.body)
+	}
+}
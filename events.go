@@ -0,0 +1,98 @@
+package martini
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of a framework event published on the Events bus.
+type EventKind int
+
+const (
+	// EventPanic is published by Recovery when a handler panicked. Event.Panic holds the panic value.
+	EventPanic EventKind = iota
+	// EventServerError is published after a request has been answered with a 5xx status.
+	EventServerError
+	// EventSlowRequest is published after a request took longer than the slow request threshold.
+	EventSlowRequest
+	// EventLockout is published by LoginThrottle when an account gets locked out. Event.Subject holds the account.
+	EventLockout
+	// EventWatchdog is published by a Watchdog when a runtime stat crosses its threshold. Event.Subject holds
+	// the stat, "goroutine" or "heap", and Event.Value its value.
+	EventWatchdog
+)
+
+// Event is a framework event.
+type Event struct {
+	Kind     EventKind
+	Request  *http.Request
+	Status   int
+	Duration time.Duration
+	Panic    interface{}
+	// Subject is what the event is about, like the account of an EventLockout.
+	Subject string
+	// Value is the measured value of an EventWatchdog.
+	Value uint64
+}
+
+// Events is a bus for framework events, so alerting and metrics integrations can observe panics, server
+// errors and slow requests in one place. martini.New maps an *Events service.
+//
+//	m.Events().Subscribe(martini.EventPanic, func(e martini.Event) {
+//	  alerts.Notify(e.Request.URL.Path, e.Panic)
+//	})
+type Events struct {
+	mu          sync.RWMutex
+	subscribers map[EventKind][]func(Event)
+	slow        time.Duration
+}
+
+// NewEvents creates an event bus without subscribers.
+func NewEvents() *Events {
+	return &Events{subscribers: make(map[EventKind][]func(Event))}
+}
+
+// Subscribe registers fn for events of the given kind. Subscribers are called synchronously on the
+// goroutine serving the request, so they should hand slow work off.
+func (e *Events) Subscribe(kind EventKind, fn func(Event)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.subscribers[kind] = append(e.subscribers[kind], fn)
+}
+
+// SetSlowThreshold sets the duration after which requests are reported as EventSlowRequest. Zero,
+// the default, disables slow request events.
+func (e *Events) SetSlowThreshold(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.slow = d
+}
+
+// Publish calls the subscribers of the event's kind.
+func (e *Events) Publish(event Event) {
+	e.mu.RLock()
+	subscribers := e.subscribers[event.Kind]
+	e.mu.RUnlock()
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
+
+// requestDone publishes the events for a request that has been answered.
+func (e *Events) requestDone(req *http.Request, status int, d time.Duration) {
+	e.mu.RLock()
+	slow := e.slow
+	e.mu.RUnlock()
+	if status >= 500 {
+		e.Publish(Event{Kind: EventServerError, Request: req, Status: status, Duration: d})
+	}
+	if slow > 0 && d > slow {
+		e.Publish(Event{Kind: EventSlowRequest, Request: req, Status: status, Duration: d})
+	}
+}
+
+// Events returns the event bus of the Martini instance.
+func (m *Martini) Events() *Events {
+	return m.events
+}
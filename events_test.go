@@ -0,0 +1,43 @@
+package martini
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Events(t *testing.T) {
+	m := Classic()
+	m.Map(log.New(bytes.NewBuffer(nil), "", 0))
+	m.Get("/panic", func() { panic("boom") })
+	m.Get("/fail", func() (int, string) { return http.StatusBadGateway, "upstream down" })
+	m.Get("/slow", func() { time.Sleep(20 * time.Millisecond) })
+	m.Get("/ok", func() {})
+
+	var events []Event
+	record := func(e Event) { events = append(events, e) }
+	m.Events().Subscribe(EventPanic, record)
+	m.Events().Subscribe(EventServerError, record)
+	m.Events().Subscribe(EventSlowRequest, record)
+	m.Events().SetSlowThreshold(10 * time.Millisecond)
+
+	for _, path := range []string{"/panic", "/fail", "/slow", "/ok"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	expect(t, len(events), 4)
+	expect(t, events[0].Kind, EventPanic)
+	expect(t, events[0].Panic, "boom")
+	expect(t, events[0].Request.URL.Path, "/panic")
+	expect(t, events[1].Kind, EventServerError)
+	expect(t, events[1].Status, http.StatusInternalServerError)
+	expect(t, events[2].Kind, EventServerError)
+	expect(t, events[2].Status, http.StatusBadGateway)
+	expect(t, events[3].Kind, EventSlowRequest)
+	expect(t, events[3].Request.URL.Path, "/slow")
+	expect(t, events[3].Duration > 10*time.Millisecond, true)
+}
@@ -0,0 +1,138 @@
+package martini
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+)
+
+// Feed describes a syndication feed that can be rendered as RSS 2.0 or Atom 1.0.
+type Feed struct {
+	Title       string
+	Link        string
+	Description string
+	Author      string
+	Updated     time.Time
+	Items       []FeedItem
+}
+
+// FeedItem is a single entry of a Feed. Id defaults to Link when empty.
+type FeedItem struct {
+	Id          string
+	Title       string
+	Link        string
+	Description string
+	Author      string
+	Published   time.Time
+	Updated     time.Time
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link,omitempty"`
+	Description string `xml:"description,omitempty"`
+	Author      string `xml:"author,omitempty"`
+	Guid        string `xml:"guid,omitempty"`
+	PubDate     string `xml:"pubDate,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	Id      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	Id        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published,omitempty"`
+	Updated   string      `xml:"updated"`
+	Author    *atomAuthor `xml:"author,omitempty"`
+	Summary   string      `xml:"summary,omitempty"`
+}
+
+// RenderRSS writes the feed to the response as an RSS 2.0 document.
+func RenderRSS(res http.ResponseWriter, feed *Feed) {
+	channel := rssChannel{Title: feed.Title, Link: feed.Link, Description: feed.Description}
+	if !feed.Updated.IsZero() {
+		channel.LastBuildDate = feed.Updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, item := range feed.Items {
+		i := rssItem{Title: item.Title, Link: item.Link, Description: item.Description, Author: item.Author, Guid: item.Id}
+		if i.Guid == "" {
+			i.Guid = item.Link
+		}
+		if !item.Published.IsZero() {
+			i.PubDate = item.Published.UTC().Format(time.RFC1123Z)
+		}
+		channel.Items = append(channel.Items, i)
+	}
+	writeXML(res, "application/rss+xml; charset=utf-8", rssFeed{Version: "2.0", Channel: channel})
+}
+
+// RenderAtom writes the feed to the response as an Atom 1.0 document.
+func RenderAtom(res http.ResponseWriter, feed *Feed) {
+	f := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		Title:   feed.Title,
+		Id:      feed.Link,
+		Link:    atomLink{feed.Link},
+		Updated: atomTime(feed.Updated),
+	}
+	if feed.Author != "" {
+		f.Author = &atomAuthor{feed.Author}
+	}
+	for _, item := range feed.Items {
+		e := atomEntry{Title: item.Title, Id: item.Id, Link: atomLink{item.Link}, Summary: item.Description}
+		if e.Id == "" {
+			e.Id = item.Link
+		}
+		if !item.Published.IsZero() {
+			e.Published = atomTime(item.Published)
+		}
+		e.Updated = atomTime(item.Updated)
+		if item.Updated.IsZero() {
+			e.Updated = atomTime(item.Published)
+		}
+		if item.Author != "" {
+			e.Author = &atomAuthor{item.Author}
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	writeXML(res, "application/atom+xml; charset=utf-8", f)
+}
+
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format(time.RFC3339)
+}
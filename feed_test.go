@@ -0,0 +1,40 @@
+package martini
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testFeed = &Feed{
+	Title:       "Martini",
+	Link:        "http://example.com/",
+	Description: "News",
+	Updated:     time.Date(2014, 4, 2, 0, 0, 0, 0, time.UTC),
+	Items: []FeedItem{
+		{Title: "Hello", Link: "http://example.com/hello", Published: time.Date(2014, 4, 1, 0, 0, 0, 0, time.UTC)},
+	},
+}
+
+func Test_RenderRSS(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	RenderRSS(recorder, testFeed)
+
+	body := recorder.Body.String()
+	expect(t, recorder.Header().Get("Content-Type"), "application/rss+xml; charset=utf-8")
+	expect(t, strings.Contains(body, `<rss version="2.0"><channel><title>Martini</title>`), true)
+	expect(t, strings.Contains(body, "<guid>http://example.com/hello</guid>"), true)
+	expect(t, strings.Contains(body, "<pubDate>Tue, 01 Apr 2014 00:00:00 +0000</pubDate>"), true)
+}
+
+func Test_RenderAtom(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	RenderAtom(recorder, testFeed)
+
+	body := recorder.Body.String()
+	expect(t, recorder.Header().Get("Content-Type"), "application/atom+xml; charset=utf-8")
+	expect(t, strings.Contains(body, `<feed xmlns="http://www.w3.org/2005/Atom"><title>Martini</title>`), true)
+	expect(t, strings.Contains(body, "<updated>2014-04-02T00:00:00Z</updated>"), true)
+	expect(t, strings.Contains(body, `<id>http://example.com/hello</id><link href="http://example.com/hello"></link><published>2014-04-01T00:00:00Z</published><updated>2014-04-01T00:00:00Z</updated>`), true)
+}
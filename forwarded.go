@@ -0,0 +1,179 @@
+package martini
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// defaultTrustedProxies are the networks trusted by Forwarded when none are given: loopback and private addresses.
+var defaultTrustedProxies = []string{"127.0.0.0/8", "::1/128", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
+
+// Forwarded returns a middleware handler that applies the Forwarded, X-Forwarded-For, X-Forwarded-Host,
+// X-Forwarded-Proto and X-Forwarded-Prefix headers of requests coming from trusted proxies, so generated links
+// and redirects point at the externally visible URL and the client address is the client's rather than the
+// proxy's. The host and scheme are set on the request, the prefix is added to the BasePath and to the URLs
+// rendered by the Routes service. The client address is set as the RemoteAddr of the request, with port 0, so
+// LoginThrottle, SecurityLog and CaptchaVerifier see it; it is the rightmost address of the forwarded ones that
+// isn't a trusted proxy. Proxies are given as IP addresses or CIDR networks and default
+// to loopback and private addresses. Headers of requests from other addresses are ignored. Of headers with
+// several comma separated values, only the last one is used: the one appended by the trusted proxy, the ones
+// before it were sent by the client or a proxy in front of it and can be spoofed.
+func Forwarded(trusted ...string) Handler {
+	if len(trusted) == 0 {
+		trusted = defaultTrustedProxies
+	}
+	var networks []*net.IPNet
+	for _, t := range trusted {
+		if !strings.Contains(t, "/") {
+			if strings.Contains(t, ":") {
+				t += "/128"
+			} else {
+				t += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(t)
+		if err != nil {
+			panic(fmt.Sprintf("martini: invalid trusted proxy %q: %v", t, err))
+		}
+		networks = append(networks, network)
+	}
+
+	return func(c Context, req *http.Request, base BasePath) {
+		ip := net.ParseIP(clientIP(req))
+		if ip == nil || !containsIP(networks, ip) {
+			return
+		}
+
+		if client := forwardedClient(networks, req.Header); client != "" {
+			req.RemoteAddr = net.JoinHostPort(client, "0")
+		}
+
+		fwdHost, fwdProto := forwardedHostProto(req.Header.Get("Forwarded"))
+		if h := lastValue(req.Header.Get("X-Forwarded-Host")); h != "" {
+			fwdHost = h
+		}
+		if p := lastValue(req.Header.Get("X-Forwarded-Proto")); p != "" {
+			fwdProto = p
+		}
+		if fwdHost != "" {
+			req.Host = fwdHost
+			req.URL.Host = fwdHost
+		}
+		if fwdProto == "http" || fwdProto == "https" {
+			req.URL.Scheme = fwdProto
+		}
+
+		if prefix := normalizeBasePath(lastValue(req.Header.Get("X-Forwarded-Prefix"))); prefix != "" {
+			c.Map(BasePath(prefix) + base)
+			if routes := c.Get(reflect.TypeOf((*Routes)(nil)).Elem()); routes.IsValid() {
+				c.MapTo(&prefixedRoutes{routes.Interface().(Routes), prefix}, (*Routes)(nil))
+			}
+		}
+	}
+}
+
+// ExternalURL returns the absolute URL of the root relative path on the host the request was sent to.
+// Behind a proxy, use the Forwarded middleware to have it point at the externally visible host.
+func ExternalURL(req *http.Request, path string) string {
+	scheme := req.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+	}
+	return scheme + "://" + req.Host + path
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastValue returns the value added by the proxy closest to the server from a comma separated header.
+func lastValue(header string) string {
+	if i := strings.LastIndexByte(header, ','); i >= 0 {
+		header = header[i+1:]
+	}
+	return strings.TrimSpace(header)
+}
+
+// forwardedHostProto parses the host and proto of the last element of a RFC 7239 Forwarded header.
+func forwardedHostProto(header string) (host string, proto string) {
+	for _, pair := range strings.Split(lastValue(header), ";") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		val := strings.Trim(kv[1], `"`)
+		switch strings.ToLower(kv[0]) {
+		case "host":
+			host = val
+		case "proto":
+			proto = strings.ToLower(val)
+		}
+	}
+	return host, proto
+}
+
+// forwardedClient returns the address of the client from the X-Forwarded-For header, or the for parameters
+// of the Forwarded header, walking from the proxy closest to the server towards the client until an address
+// that isn't a trusted proxy. Addresses before it could have been sent by the client and are ignored.
+func forwardedClient(networks []*net.IPNet, header http.Header) string {
+	var hops []string
+	if xff := header.Get("X-Forwarded-For"); xff != "" {
+		hops = strings.Split(xff, ",")
+	} else {
+		for _, element := range strings.Split(header.Get("Forwarded"), ",") {
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.ToLower(kv[0]) == "for" {
+					hops = append(hops, kv[1])
+				}
+			}
+		}
+	}
+
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.Trim(strings.TrimSpace(hops[i]), `"`)
+		if host, _, err := net.SplitHostPort(hop); err == nil {
+			hop = host
+		}
+		ip := net.ParseIP(strings.Trim(hop, "[]"))
+		if ip == nil {
+			// an obfuscated or unknown address, nothing to the left of it can be trusted
+			break
+		}
+		client = ip.String()
+		if !containsIP(networks, ip) {
+			break
+		}
+	}
+	return client
+}
+
+// prefixedRoutes is the Routes service mapped for requests forwarded with a X-Forwarded-Prefix.
+type prefixedRoutes struct {
+	Routes
+	prefix string
+}
+
+func (r *prefixedRoutes) URLFor(name string, params ...interface{}) string {
+	return r.prefix + r.Routes.URLFor(name, params...)
+}
+
+func (r *prefixedRoutes) URLForE(name string, params ...interface{}) (string, error) {
+	url, err := r.Routes.URLForE(name, params...)
+	if err != nil {
+		return "", err
+	}
+	return r.prefix + url, nil
+}
@@ -0,0 +1,94 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Forwarded(t *testing.T) {
+	m := Classic()
+	m.Use(Forwarded("10.0.0.1", "192.168.0.0/16"))
+	m.Get("/users/:id", func() {}).Name("user")
+	m.Get("/link", func(req *http.Request, routes Routes) string {
+		return ExternalURL(req, routes.URLFor("user", 5))
+	})
+	m.Moved("/people/:id", "user", http.StatusFound)
+
+	tests := []struct {
+		remote string
+		header http.Header
+		link   string
+	}{
+		{"10.0.0.1:1234", http.Header{
+			"X-Forwarded-Host":   {"example.com"},
+			"X-Forwarded-Proto":  {"https"},
+			"X-Forwarded-Prefix": {"/svc/"},
+		}, "https://example.com/svc/users/5"},
+		// the client sent values of its own, the proxy appended the real ones
+		{"10.0.0.1:1234", http.Header{
+			"X-Forwarded-Host":   {"evil.com, example.com"},
+			"X-Forwarded-Proto":  {"http, https"},
+			"X-Forwarded-Prefix": {"/evil, /svc"},
+		}, "https://example.com/svc/users/5"},
+		{"192.168.4.2:1234", http.Header{
+			"Forwarded": {`for=192.0.2.60;host="evil.com", for=192.0.2.61;proto=https;host="example.org"`},
+		}, "https://example.org/users/5"},
+		{"203.0.113.9:1234", http.Header{
+			"X-Forwarded-Host":   {"evil.com"},
+			"X-Forwarded-Prefix": {"/evil"},
+		}, "http://internal/users/5"},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://internal/link", nil)
+		req.RemoteAddr = tt.remote
+		req.Header = tt.header
+		m.ServeHTTP(recorder, req)
+		expect(t, recorder.Body.String(), tt.link)
+	}
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/people/5", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-Prefix", "/svc")
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Header().Get("Location"), "/svc/users/5")
+}
+
+func Test_ForwardedClient(t *testing.T) {
+	m := Classic()
+	m.Use(Forwarded("10.0.0.0/8"))
+	m.Get("/ip", func(req *http.Request) string {
+		return clientIP(req)
+	})
+
+	tests := []struct {
+		remote string
+		header http.Header
+		ip     string
+	}{
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"203.0.113.9"}}, "203.0.113.9"},
+		// the client spoofed the leftmost address, the proxies appended the real one
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"198.51.100.1, 203.0.113.9, 10.0.0.2"}}, "203.0.113.9"},
+		{"10.0.0.1:1234", http.Header{"Forwarded": {`for=198.51.100.1, for="[2001:db8::1]:4711"`}}, "2001:db8::1"},
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"unknown"}}, "10.0.0.1"},
+		// headers of untrusted clients are ignored
+		{"203.0.113.9:1234", http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/ip", nil)
+		req.RemoteAddr = tt.remote
+		req.Header = tt.header
+		m.ServeHTTP(recorder, req)
+		expect(t, recorder.Body.String(), tt.ip)
+	}
+}
+
+func Test_Forwarded_InvalidProxy(t *testing.T) {
+	defer func() {
+		expect(t, recover() != nil, true)
+	}()
+	Forwarded("not an ip")
+}
@@ -0,0 +1,56 @@
+package martini
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/codegangsta/inject"
+)
+
+// frozenInjector is the global injector of a Martini instance. Once frozen, mapping services panics with
+// an error, since request contexts use it as their parent and a handler mapping on it by mistake would
+// change global state for every request in flight.
+type frozenInjector struct {
+	inject.Injector
+	frozen int32
+}
+
+func (i *frozenInjector) Map(val interface{}) inject.TypeMapper {
+	i.check(reflect.TypeOf(val))
+	i.Injector.Map(val)
+	return i
+}
+
+func (i *frozenInjector) MapTo(val interface{}, ifacePtr interface{}) inject.TypeMapper {
+	i.check(inject.InterfaceOf(ifacePtr))
+	i.Injector.MapTo(val, ifacePtr)
+	return i
+}
+
+func (i *frozenInjector) Set(typ reflect.Type, val reflect.Value) inject.TypeMapper {
+	i.check(typ)
+	i.Injector.Set(typ, val)
+	return i
+}
+
+func (i *frozenInjector) freeze() {
+	atomic.StoreInt32(&i.frozen, 1)
+}
+
+func (i *frozenInjector) check(typ reflect.Type) {
+	if atomic.LoadInt32(&i.frozen) == 1 {
+		panic(fmt.Errorf("martini: cannot map %v on the frozen global injector, map it on the request's martini.Context instead", typ))
+	}
+}
+
+// Freeze makes the global injector read-only. Mapping a service on the Martini instance afterwards
+// panics, which Recovery turns into a 500. Call it once all global services are mapped.
+func (m *Martini) Freeze() {
+	m.global.freeze()
+}
+
+// FreezeOnRun sets whether Run freezes the global injector before it starts serving requests.
+func (m *Martini) FreezeOnRun(freeze bool) {
+	m.freezeOnRun = freeze
+}
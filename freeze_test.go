@@ -0,0 +1,31 @@
+package martini
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Freeze(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	recorder := httptest.NewRecorder()
+
+	m := New()
+	m.Map(log.New(buff, "[martini] ", 0))
+	m.Use(Recovery())
+	m.Use(func(c Context) {
+		c.Map("request level")
+	})
+	m.Use(func(s string) {
+		expect(t, s, "request level")
+		m.Map("global")
+	})
+	m.Freeze()
+
+	m.ServeHTTP(recorder, (*http.Request)(nil))
+	expect(t, recorder.Code, http.StatusInternalServerError)
+	expect(t, strings.Contains(buff.String(), "cannot map string on the frozen global injector"), true)
+}
@@ -0,0 +1,205 @@
+//go:build go1.18
+// +build go1.18
+
+package martini
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// H0 wraps a handler without arguments returning a value so it is called without reflection.
+func H0[R any](fn func() R) Handler {
+	return h0[R](fn)
+}
+
+// H1 wraps a handler taking one service so its dependency is resolved by type at compile time and the
+// handler is called without reflection. The return value is handled like any other handler's.
+//
+//	m.Get("/users/:id", martini.H1(func(params martini.Params) string {
+//	  return params["id"]
+//	}))
+func H1[A, R any](fn func(A) R) Handler {
+	return h1[A, R](fn)
+}
+
+// H2 is like H1 for handlers taking two services.
+func H2[A, B, R any](fn func(A, B) R) Handler {
+	return h2[A, B, R](fn)
+}
+
+// H3 is like H1 for handlers taking three services.
+func H3[A, B, C, R any](fn func(A, B, C) R) Handler {
+	return h3[A, B, C, R](fn)
+}
+
+// H4 is like H1 for handlers taking four services.
+func H4[A, B, C, D, R any](fn func(A, B, C, D) R) Handler {
+	return h4[A, B, C, D, R](fn)
+}
+
+// V1 is like H1 for handlers that do not return anything, such as middleware.
+func V1[A any](fn func(A)) Handler {
+	return v1[A](fn)
+}
+
+// V2 is like V1 for handlers taking two services.
+func V2[A, B any](fn func(A, B)) Handler {
+	return v2[A, B](fn)
+}
+
+// V3 is like V1 for handlers taking three services.
+func V3[A, B, C any](fn func(A, B, C)) Handler {
+	return v3[A, B, C](fn)
+}
+
+// V4 is like V1 for handlers taking four services.
+func V4[A, B, C, D any](fn func(A, B, C, D)) Handler {
+	return v4[A, B, C, D](fn)
+}
+
+type h0[R any] func() R
+type h1[A, R any] func(A) R
+type h2[A, B, R any] func(A, B) R
+type h3[A, B, C, R any] func(A, B, C) R
+type h4[A, B, C, D, R any] func(A, B, C, D) R
+type v1[A any] func(A)
+type v2[A, B any] func(A, B)
+type v3[A, B, C any] func(A, B, C)
+type v4[A, B, C, D any] func(A, B, C, D)
+
+func (h h0[R]) Invoke(c Context) ([]reflect.Value, error) {
+	return result(h()), nil
+}
+
+func (h h1[A, R]) Invoke(c Context) ([]reflect.Value, error) {
+	a, err := resolve[A](c)
+	if err != nil {
+		return nil, err
+	}
+	return result(h(a)), nil
+}
+
+func (h h2[A, B, R]) Invoke(c Context) ([]reflect.Value, error) {
+	a, err := resolve[A](c)
+	if err != nil {
+		return nil, err
+	}
+	b, err := resolve[B](c)
+	if err != nil {
+		return nil, err
+	}
+	return result(h(a, b)), nil
+}
+
+func (h h3[A, B, C, R]) Invoke(c Context) ([]reflect.Value, error) {
+	a, err := resolve[A](c)
+	if err != nil {
+		return nil, err
+	}
+	b, err := resolve[B](c)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := resolve[C](c)
+	if err != nil {
+		return nil, err
+	}
+	return result(h(a, b, cc)), nil
+}
+
+func (h h4[A, B, C, D, R]) Invoke(c Context) ([]reflect.Value, error) {
+	a, err := resolve[A](c)
+	if err != nil {
+		return nil, err
+	}
+	b, err := resolve[B](c)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := resolve[C](c)
+	if err != nil {
+		return nil, err
+	}
+	d, err := resolve[D](c)
+	if err != nil {
+		return nil, err
+	}
+	return result(h(a, b, cc, d)), nil
+}
+
+func (h v1[A]) Invoke(c Context) ([]reflect.Value, error) {
+	a, err := resolve[A](c)
+	if err != nil {
+		return nil, err
+	}
+	h(a)
+	return nil, nil
+}
+
+func (h v2[A, B]) Invoke(c Context) ([]reflect.Value, error) {
+	a, err := resolve[A](c)
+	if err != nil {
+		return nil, err
+	}
+	b, err := resolve[B](c)
+	if err != nil {
+		return nil, err
+	}
+	h(a, b)
+	return nil, nil
+}
+
+func (h v3[A, B, C]) Invoke(c Context) ([]reflect.Value, error) {
+	a, err := resolve[A](c)
+	if err != nil {
+		return nil, err
+	}
+	b, err := resolve[B](c)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := resolve[C](c)
+	if err != nil {
+		return nil, err
+	}
+	h(a, b, cc)
+	return nil, nil
+}
+
+func (h v4[A, B, C, D]) Invoke(c Context) ([]reflect.Value, error) {
+	a, err := resolve[A](c)
+	if err != nil {
+		return nil, err
+	}
+	b, err := resolve[B](c)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := resolve[C](c)
+	if err != nil {
+		return nil, err
+	}
+	d, err := resolve[D](c)
+	if err != nil {
+		return nil, err
+	}
+	h(a, b, cc, d)
+	return nil, nil
+}
+
+// resolve looks up the service of type T on the request context.
+func resolve[T any](c Context) (T, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	val := c.Get(t)
+	if !val.IsValid() {
+		var zero T
+		return zero, fmt.Errorf("Value not found for type %v", t)
+	}
+	v, _ := val.Interface().(T)
+	return v, nil
+}
+
+func result[R any](r R) []reflect.Value {
+	return []reflect.Value{reflect.ValueOf(&r).Elem()}
+}
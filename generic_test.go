@@ -0,0 +1,43 @@
+//go:build go1.18
+// +build go1.18
+
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_GenericHandlers(t *testing.T) {
+	result := ""
+	m := Classic()
+	m.Map("world")
+	m.Use(V2(func(c Context, s string) {
+		result += "before "
+		c.Next()
+		result += "after"
+	}))
+	m.Get("/hello/:name", H2(func(params Params, s string) string {
+		return "Hello " + params["name"] + " " + s
+	}))
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:3000/hello/martini", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Body.String(), "Hello martini world")
+	expect(t, result, "before after")
+}
+
+func Test_GenericHandlers_MissingService(t *testing.T) {
+	m := New()
+	m.Use(V1(func(i int) {}))
+
+	defer func() {
+		err := recover()
+		refute(t, err, nil)
+		expect(t, strings.HasPrefix(err.(error).Error(), "Value not found for type int (handler "), true)
+	}()
+	m.ServeHTTP(httptest.NewRecorder(), (*http.Request)(nil))
+}
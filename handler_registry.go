@@ -0,0 +1,72 @@
+package martini
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// handlerRegistry holds the handlers registered with Martini.Name.
+type handlerRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	names    map[uintptr]string
+}
+
+func newHandlerRegistry() *handlerRegistry {
+	return &handlerRegistry{handlers: make(map[string]Handler), names: make(map[uintptr]string)}
+}
+
+// Name registers the handler under the name and returns it. Named handlers can be looked up with Named,
+// e.g. for routes read from a config file, added with m.Use("name") to take part in the ordering
+// constraints of martini.Middleware, and are referred to by their name in validation problems and
+// panics. Registering a name twice panics.
+//
+//	m.Name("auth", auth)
+//	m.Use("auth")
+//	r.Get("/admin", m.Named("auth"), admin)
+func (m *Martini) Name(name string, handler Handler) Handler {
+	validateHandler(handler)
+	m.registry.mu.Lock()
+	defer m.registry.mu.Unlock()
+	if _, ok := m.registry.handlers[name]; ok {
+		panic(fmt.Sprintf("martini: a handler is already named %q", name))
+	}
+	m.registry.handlers[name] = handler
+	m.registry.names[reflect.ValueOf(handler).Pointer()] = name
+	return handler
+}
+
+// Named returns the handler registered under the name. It panics if there is none.
+func (m *Martini) Named(name string) Handler {
+	m.registry.mu.RLock()
+	defer m.registry.mu.RUnlock()
+	handler, ok := m.registry.handlers[name]
+	if !ok {
+		panic(fmt.Sprintf("martini: no handler named %q", name))
+	}
+	return handler
+}
+
+// describe returns the registered name of the handler, or the name of the function backing it. Handlers
+// created by the same function literal share its name.
+func (r *handlerRegistry) describe(h Handler) string {
+	if r != nil {
+		r.mu.RLock()
+		name, ok := r.names[reflect.ValueOf(h).Pointer()]
+		r.mu.RUnlock()
+		if ok {
+			return fmt.Sprintf("%q", name)
+		}
+	}
+	return handlerName(h)
+}
+
+// handlerError adds the handler that could not be invoked to the error.
+func handlerError(c Context, h Handler, err error) error {
+	var registry *handlerRegistry
+	if v := c.Get(reflect.TypeOf(registry)); v.IsValid() {
+		registry = v.Interface().(*handlerRegistry)
+	}
+	return fmt.Errorf("%w (handler %s)", err, registry.describe(h))
+}
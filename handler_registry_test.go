@@ -0,0 +1,52 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_NamedHandlers(t *testing.T) {
+	var order []string
+	m := Classic()
+	m.Name("auth", func() { order = append(order, "auth") })
+	m.Name("audit", func() { order = append(order, "audit") })
+	m.Use("audit")
+	m.Use(Middleware{Name: "auth", Before: []string{"audit"}})
+	m.Get("/", m.Named("auth"), func() string { return "ok" })
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Body.String(), "ok")
+	expect(t, strings.Join(order, ","), "auth,audit,auth")
+
+	func() {
+		defer func() {
+			refute(t, recover(), nil)
+		}()
+		m.Name("auth", func() {})
+	}()
+	func() {
+		defer func() {
+			expect(t, recover(), `martini: no handler named "missing"`)
+		}()
+		m.Use("missing")
+	}()
+}
+
+func Test_NamedHandlers_Messages(t *testing.T) {
+	m := New()
+	m.Use(m.Name("needs-int", func(int) {}))
+	err := m.Validate()
+	refute(t, err, nil)
+	expect(t, strings.Contains(err.Error(), `handler 1 ("needs-int") needs int`), true)
+
+	defer func() {
+		err, _ := recover().(error)
+		refute(t, err, nil)
+		expect(t, err.Error(), `Value not found for type int (handler "needs-int")`)
+	}()
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,48 @@
+package martini
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// hostPattern matches the Host header of requests for the routes of a Router.Host group. A nil
+// *hostPattern matches any host.
+type hostPattern struct {
+	pattern string
+	regex   *regexp.Regexp
+}
+
+// newHostPattern compiles a host pattern like "api.example.com", "*.example.com" or ":tenant.example.com".
+func newHostPattern(pattern string) *hostPattern {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(pattern, ".")), ".")
+	for i, label := range labels {
+		switch {
+		case label == "*":
+			labels[i] = `(?P<subdomain>[^.]+)`
+		case strings.HasPrefix(label, ":") && len(label) > 1:
+			labels[i] = `(?P<` + label[1:] + `>[^.]+)`
+		default:
+			labels[i] = regexp.QuoteMeta(label)
+		}
+	}
+	return &hostPattern{pattern, regexp.MustCompile(`^` + strings.Join(labels, `\.`) + `$`)}
+}
+
+// match matches the host of a request, which may come with a port, returning the params of the pattern.
+func (h *hostPattern) match(host string) (map[string]string, bool) {
+	if h == nil {
+		return nil, true
+	}
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return matchRegex(h.regex, strings.ToLower(strings.TrimSuffix(host, ".")))
+}
+
+func (h *hostPattern) String() string {
+	if h == nil {
+		return ""
+	}
+	return h.pattern
+}
@@ -0,0 +1,143 @@
+package martini
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// ClientOptions configures the outbound HTTP client mapped by HTTPClient.
+type ClientOptions struct {
+	// Timeout limits the whole request including reading the body. Defaults to 30 seconds.
+	Timeout time.Duration
+	// DialTimeout limits establishing a connection. Defaults to 5 seconds.
+	DialTimeout time.Duration
+	// TLSHandshakeTimeout limits the TLS handshake. Defaults to 5 seconds.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout limits waiting for the response headers. Zero means only Timeout applies.
+	ResponseHeaderTimeout time.Duration
+	// IdleConnTimeout is how long idle connections are kept in the pool. Defaults to 90 seconds.
+	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost is the number of idle connections kept per host. Defaults to 10.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections per host, requests beyond it wait. Zero means no limit.
+	MaxConnsPerHost int
+	// Stats records the outbound requests by method and host, e.g. "GET api.example.com". Optional.
+	Stats *RouteStats
+	// Logger logs the outbound requests of requests without a *RequestLogger. Optional.
+	Logger *log.Logger
+	// Guard blocks requests to internal addresses and hosts it doesn't allow. The proxy environment
+	// variables are ignored then, connections have to go to the checked address. Optional.
+	Guard *SSRFGuard
+}
+
+// HTTPClient returns a middleware handler that maps an *http.Client for handlers to make outbound
+// requests with, instead of http.DefaultClient which has no timeout. The clients share a pooled
+// transport that honors the proxy environment variables. Outbound requests carry the request ID and
+// trace context of the request they are made for, are logged with its *RequestLogger and recorded in
+// Stats.
+//
+//	m.Use(martini.HTTPClient(martini.ClientOptions{MaxConnsPerHost: 50}))
+//	m.Get("/weather", func(client *http.Client) (int, string) {
+//	  res, err := client.Get("https://api.example.com/weather")
+//	  ...
+//	})
+func HTTPClient(opts ClientOptions) Handler {
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.TLSHandshakeTimeout == 0 {
+		opts.TLSHandshakeTimeout = 5 * time.Second
+	}
+	if opts.IdleConnTimeout == 0 {
+		opts.IdleConnTimeout = 90 * time.Second
+	}
+	if opts.MaxIdleConnsPerHost == 0 {
+		opts.MaxIdleConnsPerHost = 10
+	}
+
+	dialer := &net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second}
+	proxy := http.ProxyFromEnvironment
+	if opts.Guard != nil {
+		dialer.Control = opts.Guard.Control
+		proxy = nil
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return func(c Context, req *http.Request) {
+		t := &clientTransport{base: transport, c: c, req: req, stats: opts.Stats, logger: opts.Logger, guard: opts.Guard}
+		if v := c.Get(reflect.TypeOf((*RequestLogger)(nil))); v.IsValid() {
+			t.rl = v.Interface().(*RequestLogger)
+		}
+		c.Map(&http.Client{Transport: t, Timeout: opts.Timeout})
+	}
+}
+
+// clientTransport instruments the outbound requests made for a request.
+type clientTransport struct {
+	base   http.RoundTripper
+	c      Context
+	req    *http.Request
+	stats  *RouteStats
+	logger *log.Logger
+	rl     *RequestLogger
+	guard  *SSRFGuard
+}
+
+func (t *clientTransport) RoundTrip(out *http.Request) (*http.Response, error) {
+	if t.guard != nil {
+		if err := t.guard.CheckURL(out.URL); err != nil {
+			if out.Body != nil {
+				out.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	// a RoundTripper must not modify the request
+	out = out.Clone(out.Context())
+	propagateTrace(t.c, t.req, out)
+
+	key := out.Method + " " + out.URL.Host
+	r := &routeStatsRequest{stats: t.stats}
+	if t.stats != nil {
+		r.matched(key, nil)
+	}
+	start := time.Now()
+	res, err := t.base.RoundTrip(out)
+	d := time.Since(start)
+
+	status := http.StatusBadGateway
+	if err == nil {
+		status = res.StatusCode
+	}
+	if t.stats != nil {
+		t.stats.record(r, out, d, status, nil)
+	}
+	var printf func(string, ...interface{})
+	if t.rl != nil {
+		printf = t.rl.Printf
+	} else if t.logger != nil {
+		printf = t.logger.Printf
+	}
+	if printf != nil && err != nil {
+		printf("Outbound %s %s failed in %v: %v", out.Method, out.URL.Redacted(), d, err)
+	} else if printf != nil {
+		printf("Outbound %s %s %d in %v", out.Method, out.URL.Redacted(), status, d)
+	}
+	return res, err
+}
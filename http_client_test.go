@@ -0,0 +1,58 @@
+package martini
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_HTTPClient(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		res.Write([]byte(req.Header.Get("X-Request-Id") + " " + req.Header.Get("Traceparent")[:35]))
+	}))
+	defer upstream.Close()
+
+	var out bytes.Buffer
+	stats := NewRouteStats()
+	m := New()
+	m.Map(log.New(&out, "", 0))
+	m.Use(Logger())
+	m.Use(HTTPClient(ClientOptions{Timeout: 50 * time.Millisecond, Stats: stats}))
+	r := NewRouter()
+	r.Get("/", func(client *http.Client) string {
+		res, err := client.Get(upstream.URL + "/fast")
+		expect(t, err, nil)
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		_, err = client.Get(upstream.URL + "/slow")
+		refute(t, err, nil)
+		return string(body)
+	})
+	m.Action(r.Handle)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	expect(t, res.Body.String(), "abc 00-4bf92f3577b34da6a3ce929d0e0e4736")
+
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	expect(t, strings.Contains(out.String(), "Outbound GET "+upstream.URL+"/fast 200 in "), true)
+	expect(t, strings.Contains(out.String(), "Outbound GET "+upstream.URL+"/slow failed in "), true)
+	snapshot := stats.Snapshot()
+	expect(t, len(snapshot), 1)
+	expect(t, snapshot[0].Route, "GET "+host)
+	expect(t, snapshot[0].Count, int64(2))
+	expect(t, snapshot[0].Errors, int64(1))
+	expect(t, snapshot[0].InFlight, int64(0))
+}
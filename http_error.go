@@ -0,0 +1,90 @@
+package martini
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HTTPError can be used as a panic value to abort a request with the given status from deep within a call
+// stack. Recovery renders it as JSON if the client prefers it, as plain text otherwise, instead of treating
+// it as a 500 Internal Server Error.
+//
+//	panic(martini.HTTPError{Status: http.StatusNotFound, Message: "no such user"})
+type HTTPError struct {
+	// Status is the HTTP status code of the response. Defaults to 500.
+	Status int `json:"status"`
+	// Message is the error message shown to the client. Defaults to the status text.
+	Message string `json:"message"`
+	// Details is additional information encoded in JSON responses, e.g. invalid fields.
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e HTTPError) Error() string {
+	return fmt.Sprintf("%d %s", e.Status, e.Message)
+}
+
+// asHTTPError returns the HTTPError of a panic value, if it is one.
+func asHTTPError(v interface{}) (HTTPError, bool) {
+	switch e := v.(type) {
+	case HTTPError:
+		return e, true
+	case *HTTPError:
+		if e != nil {
+			return *e, true
+		}
+	}
+	return HTTPError{}, false
+}
+
+// writeHTTPError writes the error in the format preferred by the request.
+func writeHTTPError(res http.ResponseWriter, req *http.Request, e HTTPError) {
+	if e.Status == 0 {
+		e.Status = http.StatusInternalServerError
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(e.Status)
+	}
+
+	if req != nil && prefersJSON(req.Header.Get("Accept")) {
+		body, err := json.Marshal(e)
+		if err == nil {
+			res.Header().Set("Content-Type", "application/json; charset=utf-8")
+			res.WriteHeader(e.Status)
+			res.Write(body)
+			return
+		}
+	}
+	http.Error(res, e.Message, e.Status)
+}
+
+// prefersJSON returns whether the Accept header ranks JSON above HTML and plain text.
+func prefersJSON(accept string) bool {
+	var jsonQ, textQ float64 = -1, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			if q > jsonQ {
+				jsonQ = q
+			}
+		case mediaType == "text/html" || mediaType == "text/plain":
+			if q > textQ {
+				textQ = q
+			}
+		}
+	}
+	return jsonQ > 0 && jsonQ > textQ
+}
@@ -0,0 +1,54 @@
+package martini
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Recovery_HTTPError(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	m := New()
+	m.Map(log.New(buff, "[martini] ", 0))
+	m.Use(Recovery())
+	m.Use(func(req *http.Request) {
+		if req.URL.Path == "/ptr" {
+			panic(&HTTPError{Status: http.StatusConflict})
+		}
+		panic(HTTPError{Status: http.StatusUnprocessableEntity, Message: "invalid user", Details: map[string]string{"name": "missing"}})
+	})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "text/html, application/json;q=0.9")
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Code, http.StatusUnprocessableEntity)
+	expect(t, recorder.Body.String(), "invalid user\n")
+
+	recorder = httptest.NewRecorder()
+	req.Header.Set("Accept", "application/json")
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Code, http.StatusUnprocessableEntity)
+	expect(t, recorder.Header().Get("Content-Type"), "application/json; charset=utf-8")
+	expect(t, recorder.Body.String(), `{"status":422,"message":"invalid user","details":{"name":"missing"}}`)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/ptr", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Code, http.StatusConflict)
+	expect(t, recorder.Body.String(), "Conflict\n")
+
+	// HTTPErrors are not logged as panics
+	expect(t, buff.String(), "")
+}
+
+func Test_PrefersJSON(t *testing.T) {
+	expect(t, prefersJSON(""), false)
+	expect(t, prefersJSON("*/*"), false)
+	expect(t, prefersJSON("application/json"), true)
+	expect(t, prefersJSON("application/problem+json, text/plain;q=0.5"), true)
+	expect(t, prefersJSON("text/html,application/xhtml+xml,application/json;q=0.9"), false)
+	expect(t, prefersJSON("application/json;q=0"), false)
+}
@@ -0,0 +1,70 @@
+package martini
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// IsHTMX reports whether the request was made by htmx.
+func IsHTMX(req *http.Request) bool {
+	return req.Header.Get("HX-Request") == "true"
+}
+
+// IsBoosted reports whether the request was made by an htmx boosted link or form, which expects a
+// whole page.
+func IsBoosted(req *http.Request) bool {
+	return req.Header.Get("HX-Boosted") == "true"
+}
+
+// TurboFrame returns the id of the Turbo frame the request was made for, empty for other requests.
+func TurboFrame(req *http.Request) string {
+	return req.Header.Get("Turbo-Frame")
+}
+
+// WantsFragment reports whether the request expects a fragment of a page instead of the whole page.
+func WantsFragment(req *http.Request) bool {
+	return (IsHTMX(req) && !IsBoosted(req)) || TurboFrame(req) != ""
+}
+
+// HXRedirect makes htmx requests load the URL as a new page with the HX-Redirect header and redirects
+// other requests with 303 See Other.
+func HXRedirect(res http.ResponseWriter, req *http.Request, url string) {
+	if IsHTMX(req) {
+		res.Header().Set("HX-Redirect", url)
+		res.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(res, req, url, http.StatusSeeOther)
+}
+
+// HXTrigger triggers the client side events with the HX-Trigger header.
+func HXTrigger(res http.ResponseWriter, events ...string) {
+	for _, e := range events {
+		res.Header().Add("HX-Trigger", e)
+	}
+}
+
+// HXTriggerDetail triggers the client side events, passing each its detail.
+func HXTriggerDetail(res http.ResponseWriter, events map[string]interface{}) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	res.Header().Set("HX-Trigger", string(data))
+	return nil
+}
+
+// Fragment renders only the block of the page when the request wants a fragment, and the whole page
+// otherwise.
+//
+//	m.Get("/users", func(r *martini.Render) error {
+//	  return r.Fragment(http.StatusOK, "users/list", "rows", users)
+//	})
+func (r *Render) Fragment(status int, name string, block string, data interface{}, opts ...RenderOptions) error {
+	r.res.Header().Add("Vary", "HX-Request")
+	r.res.Header().Add("Vary", "Turbo-Frame")
+	if WantsFragment(r.req) {
+		return r.HTML(status, name, data, RenderOptions{Block: block})
+	}
+	return r.HTML(status, name, data, opts...)
+}
@@ -0,0 +1,75 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_WantsFragment(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	expect(t, WantsFragment(req), false)
+	req.Header.Set("HX-Request", "true")
+	expect(t, IsHTMX(req), true)
+	expect(t, WantsFragment(req), true)
+	req.Header.Set("HX-Boosted", "true")
+	expect(t, WantsFragment(req), false)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Turbo-Frame", "users")
+	expect(t, TurboFrame(req), "users")
+	expect(t, WantsFragment(req), true)
+}
+
+func Test_HXRedirect(t *testing.T) {
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/users", nil)
+	HXRedirect(res, req, "/users/1")
+	expect(t, res.Code, http.StatusSeeOther)
+	expect(t, res.Header().Get("Location"), "/users/1")
+
+	res = httptest.NewRecorder()
+	req.Header.Set("HX-Request", "true")
+	HXRedirect(res, req, "/users/1")
+	expect(t, res.Code, http.StatusOK)
+	expect(t, res.Header().Get("HX-Redirect"), "/users/1")
+}
+
+func Test_HXTrigger(t *testing.T) {
+	res := httptest.NewRecorder()
+	HXTrigger(res, "saved", "refresh")
+	expect(t, len(res.Header()["Hx-Trigger"]), 2)
+
+	res = httptest.NewRecorder()
+	expect(t, HXTriggerDetail(res, map[string]interface{}{"saved": map[string]int{"id": 1}}), nil)
+	expect(t, res.Header().Get("HX-Trigger"), `{"saved":{"id":1}}`)
+}
+
+func Test_Render_Fragment(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"layouts/main.tmpl": `<main>{{block "content" .}}{{end}}</main>`,
+		"users.tmpl":        `{{define "content"}}<table>{{block "rows" .}}<tr>{{.}}</tr>{{end}}</table>{{end}}`,
+	})
+	defer os.RemoveAll(dir)
+	tmpl, err := NewTemplates(TemplateOptions{Dir: dir, Layout: "main"})
+	expect(t, err, nil)
+
+	m := Classic()
+	m.Use(tmpl.Handler())
+	m.Get("/users", func(r *Render) error {
+		return r.Fragment(http.StatusOK, "users", "rows", "bob")
+	})
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/users", nil))
+	expect(t, res.Body.String(), "<main><table><tr>bob</tr></table></main>")
+	expect(t, strings.Join(res.Header()["Vary"], ", "), "HX-Request, Turbo-Frame")
+
+	res = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/users", nil)
+	req.Header.Set("HX-Request", "true")
+	m.ServeHTTP(res, req)
+	expect(t, res.Body.String(), "<tr>bob</tr>")
+}
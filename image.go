@@ -0,0 +1,294 @@
+package martini
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImageOptions configures the Images handler.
+type ImageOptions struct {
+	// Secret signs the image URLs, so clients can't request arbitrary transformations. Without a Secret or
+	// SecretRef every request fails with a 500.
+	Secret []byte
+	// SecretRef names the secret signing the image URLs instead of Secret, so it can be rotated. Sign the
+	// URLs with its Current value.
+	SecretRef SecretRef
+	// MaxWidth and MaxHeight limit the size of transformed images. Both default to 4096.
+	MaxWidth  int
+	MaxHeight int
+	// MaxSourcePixels limits the width times the height of the source images, so a small file declaring a
+	// huge canvas isn't decoded. Defaults to 40 million.
+	MaxSourcePixels int64
+	// Cache stores the transformed images, so every variant is only computed once. Optional.
+	Cache Storage
+	// MaxAge is the max-age of the Cache-Control header. Defaults to a year, signed URLs never change.
+	MaxAge time.Duration
+}
+
+// imageSpec is the transformation requested by the query of an image URL.
+type imageSpec struct {
+	width, height int
+	crop          bool
+	format        string
+	quality       int
+}
+
+// Images returns a handler serving the images from the storage transformed by the query params, for
+// a route ending in **. The params are w and h for the size, fit=crop to fill the size instead of fitting
+// into it, format=jpeg, png or gif and q for the JPEG quality. URLs are created with SignImageURL.
+//
+//	m.Get("/images/**", martini.Images(storage, martini.ImageOptions{Secret: secret}))
+//	url := martini.SignImageURL(secret, "/images", "avatars/1.jpg", url.Values{"w": {"64"}, "h": {"64"}, "fit": {"crop"}})
+func Images(source Storage, opts ImageOptions) Handler {
+	if opts.MaxWidth == 0 {
+		opts.MaxWidth = 4096
+	}
+	if opts.MaxHeight == 0 {
+		opts.MaxHeight = 4096
+	}
+	if opts.MaxSourcePixels == 0 {
+		opts.MaxSourcePixels = 40000000
+	}
+	if opts.MaxAge == 0 {
+		opts.MaxAge = 365 * 24 * time.Hour
+	}
+
+	return func(params Params, res http.ResponseWriter, req *http.Request) {
+		key := params["_1"]
+		query := req.URL.Query()
+		signature := query.Get("s")
+		keys, err := opts.SecretRef.keys(opts.Secret)
+		if err != nil {
+			// without a key anyone could sign URLs
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		valid := false
+		for _, secret := range keys {
+			valid = valid || hmac.Equal([]byte(signature), []byte(signImage(secret, key, query)))
+		}
+		if !valid {
+			http.Error(res, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		spec, err := parseImageSpec(query, opts)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		etag := `"` + signature[:32] + `"`
+		res.Header().Set("ETag", etag)
+		res.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(opts.MaxAge/time.Second)))
+		if req.Header.Get("If-None-Match") == etag {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		cacheKey := "images/" + signature
+		if opts.Cache != nil {
+			if r, err := opts.Cache.Get(cacheKey); err == nil {
+				defer r.Close()
+				var head [512]byte
+				n, _ := io.ReadFull(r, head[:])
+				res.Header().Set("Content-Type", http.DetectContentType(head[:n]))
+				res.Write(head[:n])
+				io.Copy(res, r)
+				return
+			}
+		}
+
+		r, err := source.Get(key)
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrInvalidKey) {
+			http.NotFound(res, req)
+			return
+		} else if err != nil {
+			panic(err)
+		}
+		img, format, err := decodeImage(r, opts.MaxSourcePixels)
+		r.Close()
+		if err == errImageTooLarge {
+			http.Error(res, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		} else if err != nil {
+			http.Error(res, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+		if spec.format != "" {
+			format = spec.format
+		}
+
+		var buf bytes.Buffer
+		if err := encodeImage(&buf, transformImage(img, spec), format, spec.quality); err != nil {
+			panic(err)
+		}
+		if opts.Cache != nil {
+			// a failing cache only costs the next request the transformation
+			opts.Cache.Put(cacheKey, bytes.NewReader(buf.Bytes()))
+		}
+		res.Header().Set("Content-Type", "image/"+format)
+		res.Write(buf.Bytes())
+	}
+}
+
+var errImageTooLarge = errors.New("source image too large")
+
+// decodeImage decodes the image unless its header declares more than maxPixels pixels.
+func decodeImage(r io.Reader, maxPixels int64) (image.Image, string, error) {
+	var head bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &head))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(config.Width)*int64(config.Height) > maxPixels {
+		return nil, "", errImageTooLarge
+	}
+	return image.Decode(io.MultiReader(&head, r))
+}
+
+// SignImageURL returns the URL of the transformed image served by the Images handler at base.
+func SignImageURL(secret []byte, base string, key string, params url.Values) string {
+	query := url.Values{}
+	for k, v := range params {
+		query[k] = v
+	}
+	query.Set("s", signImage(secret, key, query))
+	return strings.TrimSuffix(base, "/") + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode()
+}
+
+// signImage signs the key with the query params except s.
+func signImage(secret []byte, key string, query url.Values) string {
+	params := url.Values{}
+	for k, v := range query {
+		if k != "s" {
+			params[k] = v
+		}
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(key + "?" + params.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func parseImageSpec(query url.Values, opts ImageOptions) (imageSpec, error) {
+	var spec imageSpec
+	var err error
+	if w := query.Get("w"); w != "" {
+		if spec.width, err = strconv.Atoi(w); err != nil || spec.width < 1 || spec.width > opts.MaxWidth {
+			return spec, fmt.Errorf("invalid width %q", w)
+		}
+	}
+	if h := query.Get("h"); h != "" {
+		if spec.height, err = strconv.Atoi(h); err != nil || spec.height < 1 || spec.height > opts.MaxHeight {
+			return spec, fmt.Errorf("invalid height %q", h)
+		}
+	}
+	switch fit := query.Get("fit"); fit {
+	case "", "contain":
+	case "crop":
+		spec.crop = true
+	default:
+		return spec, fmt.Errorf("invalid fit %q", fit)
+	}
+	switch format := query.Get("format"); format {
+	case "", "jpeg", "png", "gif":
+		spec.format = format
+	default:
+		return spec, fmt.Errorf("invalid format %q", format)
+	}
+	if q := query.Get("q"); q != "" {
+		if spec.quality, err = strconv.Atoi(q); err != nil || spec.quality < 1 || spec.quality > 100 {
+			return spec, fmt.Errorf("invalid quality %q", q)
+		}
+	}
+	return spec, nil
+}
+
+func encodeImage(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case "jpeg":
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return png.Encode(w, img)
+	}
+}
+
+// transformImage resizes the image to the size of the spec. Without crop it fits into the size keeping the
+// aspect ratio, with crop it fills the size and the overflow is cut off evenly on both sides.
+func transformImage(src image.Image, spec imageSpec) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	w, h := spec.width, spec.height
+	if (w == 0 && h == 0) || sw == 0 || sh == 0 {
+		return src
+	}
+	if w == 0 {
+		w = maxInt(1, sw*h/sh)
+	} else if h == 0 {
+		h = maxInt(1, sh*w/sw)
+	}
+
+	if !spec.crop {
+		if sw*h > sh*w {
+			h = maxInt(1, sh*w/sw)
+		} else {
+			w = maxInt(1, sw*h/sh)
+		}
+		return scaleImage(src, b, w, h)
+	}
+	cw, ch := sw, sw*h/w
+	if ch > sh {
+		cw, ch = sh*w/h, sh
+	}
+	x0, y0 := b.Min.X+(sw-cw)/2, b.Min.Y+(sh-ch)/2
+	return scaleImage(src, image.Rect(x0, y0, x0+cw, y0+ch), w, h)
+}
+
+// scaleImage scales the area r of the image to w by h, averaging the pixels covered by every pixel of the result.
+func scaleImage(src image.Image, r image.Rectangle, w int, h int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := r.Min.Y + y*r.Dy()/h
+		y1 := maxInt(y0+1, r.Min.Y+(y+1)*r.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := r.Min.X + x*r.Dx()/w
+			x1 := maxInt(x0+1, r.Min.X+(x+1)*r.Dx()/w)
+			var sr, sg, sb, sa, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					sr, sg, sb, sa, n = sr+uint64(cr), sg+uint64(cg), sb+uint64(cb), sa+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(sr / n), uint16(sg / n), uint16(sb / n), uint16(sa / n)})
+		}
+	}
+	return dst
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,150 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_transformImage(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+
+	b := transformImage(src, imageSpec{width: 100}).Bounds()
+	expect(t, b.Dx(), 100)
+	expect(t, b.Dy(), 50)
+
+	b = transformImage(src, imageSpec{width: 100, height: 100}).Bounds()
+	expect(t, b.Dx(), 100)
+	expect(t, b.Dy(), 50)
+
+	b = transformImage(src, imageSpec{width: 100, height: 100, crop: true}).Bounds()
+	expect(t, b.Dx(), 100)
+	expect(t, b.Dy(), 100)
+
+	expect(t, transformImage(src, imageSpec{}), image.Image(src))
+}
+
+func Test_scaleImage(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(0, 0, color.NRGBA{255, 255, 255, 255})
+	src.Set(1, 0, color.NRGBA{255, 255, 255, 255})
+	src.Set(0, 1, color.NRGBA{0, 0, 0, 255})
+	src.Set(1, 1, color.NRGBA{0, 0, 0, 255})
+
+	dst := scaleImage(src, src.Bounds(), 1, 1)
+	expect(t, dst.NRGBAAt(0, 0), color.NRGBA{127, 127, 127, 255})
+}
+
+func Test_Images(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-images")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	source := &LocalStorage{Dir: dir}
+	cache := &LocalStorage{Dir: dir + "/cache"}
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 300, 150)))
+	source.Put("photos/a.png", &buf)
+
+	secret := []byte("secret")
+	m := Classic()
+	m.Get("/images/**", Images(source, ImageOptions{Secret: secret, Cache: cache}))
+
+	signed := SignImageURL(secret, "/images", "photos/a.png", url.Values{"w": {"60"}, "format": {"jpeg"}})
+	for i := 0; i < 2; i++ {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, httptest.NewRequest("GET", signed, nil))
+		expect(t, res.Code, http.StatusOK)
+		expect(t, res.Header().Get("Content-Type"), "image/jpeg")
+		expect(t, strings.HasPrefix(res.Header().Get("Cache-Control"), "public, max-age="), true)
+		img, format, err := image.Decode(res.Body)
+		expect(t, err, nil)
+		expect(t, format, "jpeg")
+		expect(t, img.Bounds().Dx(), 60)
+		expect(t, img.Bounds().Dy(), 30)
+	}
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", signed, nil)
+	req.Header.Set("If-None-Match", `"`+mustQuery(signed).Get("s")[:32]+`"`)
+	m.ServeHTTP(res, req)
+	expect(t, res.Code, http.StatusNotModified)
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", strings.Replace(signed, "w=60", "w=4000", 1), nil))
+	expect(t, res.Code, http.StatusForbidden)
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", SignImageURL(secret, "/images", "photos/a.png", url.Values{"w": {"5000"}}), nil))
+	expect(t, res.Code, http.StatusBadRequest)
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", SignImageURL(secret, "/images", "photos/missing.png", nil), nil))
+	expect(t, res.Code, http.StatusNotFound)
+}
+
+func Test_Images_NoSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-images")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	source := &LocalStorage{Dir: dir}
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	source.Put("a.png", &buf)
+
+	m := Classic()
+	m.Get("/images/**", Images(source, ImageOptions{}))
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", SignImageURL(nil, "/images", "a.png", url.Values{"w": {"5"}}), nil))
+	expect(t, res.Code, http.StatusInternalServerError)
+}
+
+func Test_Images_MaxSourcePixels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-images")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	source := &LocalStorage{Dir: dir}
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 300, 150)))
+	source.Put("a.png", bytes.NewReader(buf.Bytes()))
+
+	secret := []byte("secret")
+	m := Classic()
+	m.Get("/small/**", Images(source, ImageOptions{Secret: secret, MaxSourcePixels: 300*150 - 1}))
+	m.Get("/images/**", Images(source, ImageOptions{Secret: secret, MaxSourcePixels: 300 * 150}))
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", SignImageURL(secret, "/small", "a.png", url.Values{"w": {"60"}}), nil))
+	expect(t, res.Code, http.StatusUnsupportedMediaType)
+	expect(t, strings.TrimSpace(res.Body.String()), "source image too large")
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", SignImageURL(secret, "/images", "a.png", url.Values{"w": {"60"}}), nil))
+	expect(t, res.Code, http.StatusOK)
+
+	// a PNG header declaring a 100000x100000 canvas
+	bomb := buf.Bytes()[:33]
+	bomb[16], bomb[17], bomb[18], bomb[19] = 0, 1, 0x86, 0xa0
+	bomb[20], bomb[21], bomb[22], bomb[23] = 0, 1, 0x86, 0xa0
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+	_, _, err = decodeImage(bytes.NewReader(bomb), 40000000)
+	expect(t, err, errImageTooLarge)
+}
+
+func mustQuery(rawurl string) url.Values {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		panic(err)
+	}
+	return u.Query()
+}
@@ -0,0 +1,20 @@
+package martini
+
+import (
+	"reflect"
+)
+
+// FastInvoker is implemented by handlers that inject their own arguments instead of being called through
+// reflection, such as the adapters emitted by cmd/martini-gen. The handler still has to be a func type;
+// Martini calls Invoke with the current request Context in place of Context.Invoke.
+type FastInvoker interface {
+	Invoke(Context) ([]reflect.Value, error)
+}
+
+// invoke calls the handler with its dependencies resolved from the given Context.
+func invoke(c Context, handler Handler) ([]reflect.Value, error) {
+	if fi, ok := handler.(FastInvoker); ok {
+		return fi.Invoke(c)
+	}
+	return c.Invoke(handler)
+}
@@ -0,0 +1,28 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type fastHandler func(Params) string
+
+func (h fastHandler) Invoke(c Context) ([]reflect.Value, error) {
+	params := c.Get(reflect.TypeOf(Params(nil))).Interface().(Params)
+	r0 := h(params)
+	return []reflect.Value{reflect.ValueOf(r0)}, nil
+}
+
+func Test_FastInvoker(t *testing.T) {
+	m := Classic()
+	m.Get("/hello/:name", fastHandler(func(params Params) string {
+		return "Hello " + params["name"]
+	}))
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:3000/hello/world", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Body.String(), "Hello world")
+}
@@ -0,0 +1,130 @@
+package martini
+
+import (
+	gocontext "context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Election elects a single leader among the instances sharing its Locks, e.g. RedisLocks, so scheduled
+// tasks run on exactly one instance of a multi-replica deployment. The leader holds a lock on Key and
+// refreshes it every third of the TTL. Should the leader stop or lose the lock, another instance takes
+// over once the lock expired.
+//
+//	election := &martini.Election{Key: "billing-leader"}
+//	m.Elect(election)
+//
+//	go func() {
+//	  for range time.Tick(time.Hour) {
+//	    election.Do(sendInvoices)
+//	  }
+//	}()
+type Election struct {
+	// Locks holds the leader lock. Defaults to the Locks mapped on the Martini instance with Elect.
+	Locks Locks
+	// Key is the key of the leader lock. Defaults to "leader".
+	Key string
+	// TTL is how long the leader lock is held without being refreshed, and so the time until another
+	// instance takes over from a crashed leader. Defaults to 15s.
+	TTL time.Duration
+	// OnElected is called on a goroutine of its own when this instance becomes the leader. Its context is
+	// canceled when the instance stops being the leader.
+	OnElected func(ctx gocontext.Context)
+
+	mu     sync.Mutex
+	lock   Lock
+	demote gocontext.CancelFunc
+}
+
+// Elect takes part in the election while the Martini instance runs, between the start and the stop hooks.
+func (m *Martini) Elect(e *Election) {
+	m.background(e.Run, func() {
+		if e.Locks == nil {
+			e.Locks = m.Injector.Get(reflect.TypeOf((*Locks)(nil)).Elem()).Interface().(Locks)
+		}
+	})
+}
+
+func (e *Election) ttl() time.Duration {
+	if e.TTL <= 0 {
+		return 15 * time.Second
+	}
+	return e.TTL
+}
+
+// Run campaigns for the leadership until ctx is done, then steps down if this instance is the leader.
+func (e *Election) Run(ctx gocontext.Context) {
+	ticker := time.NewTicker(e.ttl() / 3)
+	defer ticker.Stop()
+	for {
+		e.Campaign()
+		select {
+		case <-ctx.Done():
+			e.Resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Campaign refreshes the leader lock if this instance holds it, or tries to acquire it otherwise. Run
+// calls it periodically.
+func (e *Election) Campaign() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lock != nil {
+		if err := e.lock.Refresh(e.ttl()); err == nil {
+			return
+		}
+		// the lock may have expired, another instance can be the leader by now
+		e.stepDown()
+	}
+
+	key := e.Key
+	if key == "" {
+		key = "leader"
+	}
+	lock, err := e.Locks.Acquire(key, e.ttl())
+	if err != nil {
+		return
+	}
+	e.lock = lock
+	var ctx gocontext.Context
+	ctx, e.demote = gocontext.WithCancel(gocontext.Background())
+	if e.OnElected != nil {
+		go e.OnElected(ctx)
+	}
+}
+
+// Resign releases the leader lock if this instance holds it, so another instance takes over right away.
+func (e *Election) Resign() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lock != nil {
+		e.lock.Release()
+		e.stepDown()
+	}
+}
+
+func (e *Election) stepDown() {
+	e.lock = nil
+	e.demote()
+	e.demote = nil
+}
+
+// IsLeader returns whether this instance is the leader.
+func (e *Election) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lock != nil
+}
+
+// Do calls fn if this instance is the leader and reports whether it did.
+func (e *Election) Do(fn func()) bool {
+	if !e.IsLeader() {
+		return false
+	}
+	fn()
+	return true
+}
@@ -0,0 +1,67 @@
+package martini
+
+import (
+	gocontext "context"
+	"testing"
+	"time"
+)
+
+func Test_Election(t *testing.T) {
+	locks := NewMemoryLocks()
+	elected := make(chan gocontext.Context, 1)
+	a := &Election{Locks: locks, TTL: 20 * time.Millisecond, OnElected: func(ctx gocontext.Context) {
+		elected <- ctx
+	}}
+	b := &Election{Locks: locks, TTL: 20 * time.Millisecond}
+
+	a.Campaign()
+	b.Campaign()
+	expect(t, a.IsLeader(), true)
+	expect(t, b.IsLeader(), false)
+	leadership := <-elected
+
+	ran := 0
+	expect(t, a.Do(func() { ran++ }), true)
+	expect(t, b.Do(func() { ran++ }), false)
+	expect(t, ran, 1)
+
+	// a stops refreshing the lock, b takes over once it expired
+	time.Sleep(30 * time.Millisecond)
+	b.Campaign()
+	expect(t, b.IsLeader(), true)
+	a.Campaign()
+	expect(t, a.IsLeader(), false)
+	select {
+	case <-leadership.Done():
+	default:
+		t.Error("the context of the former leader is not canceled")
+	}
+
+	// a resigning leader hands over right away
+	b.Resign()
+	expect(t, b.IsLeader(), false)
+	a.Campaign()
+	expect(t, a.IsLeader(), true)
+	a.Resign()
+}
+
+func Test_Elect(t *testing.T) {
+	m := New()
+	e := &Election{Key: "jobs", TTL: time.Minute}
+	m.Elect(e)
+	expect(t, m.Start(gocontext.Background()), nil)
+	deadline := time.Now().Add(time.Second)
+	for !e.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	expect(t, e.IsLeader(), true)
+
+	// the lock is released on stop
+	expect(t, m.Stop(gocontext.Background()), nil)
+	expect(t, e.IsLeader(), false)
+	var locks Locks
+	m.Invoke(func(l Locks) { locks = l })
+	lock, err := locks.Acquire("jobs", time.Minute)
+	expect(t, err, nil)
+	lock.Release()
+}
@@ -0,0 +1,192 @@
+package martini
+
+import (
+	gocontext "context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Hook is a start or stop hook of an application. It should return once ctx is done.
+type Hook func(ctx gocontext.Context) error
+
+// LifecycleError collects the errors of the stop hooks, which all run even if some fail.
+type LifecycleError struct {
+	Errors []error
+}
+
+func (e *LifecycleError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return "martini: stopping failed:\n\t" + strings.Join(messages, "\n\t")
+}
+
+// Unwrap returns the errors, for errors.Is and errors.As.
+func (e *LifecycleError) Unwrap() []error {
+	return e.Errors
+}
+
+// lifecycle holds the start and stop hooks of a Martini instance or a router.
+type lifecycle struct {
+	starts  []Hook
+	stops   []Hook
+	timeout time.Duration
+}
+
+// run runs the hooks in order, each limited by the timeout if there is one. Unless all is set, it
+// stops at the first error.
+func (l *lifecycle) run(ctx gocontext.Context, name string, hooks []Hook, all bool) []error {
+	var errs []error
+	for _, hook := range hooks {
+		hookCtx, cancel := ctx, gocontext.CancelFunc(func() {})
+		if l.timeout > 0 {
+			hookCtx, cancel = gocontext.WithTimeout(ctx, l.timeout)
+		}
+		err := hook(hookCtx)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			if !all {
+				break
+			}
+		}
+	}
+	return errs
+}
+
+func (r *router) OnStart(hook Hook) {
+	r.lifecycle.starts = append(r.lifecycle.starts, hook)
+}
+
+func (r *router) OnStop(hook Hook) {
+	r.lifecycle.stops = append(r.lifecycle.stops, hook)
+}
+
+func (r *router) HookTimeout(d time.Duration) {
+	r.lifecycle.timeout = d
+}
+
+// start starts the mounted routers in the order they were mounted, then runs the router's own start hooks.
+func (r *router) start(ctx gocontext.Context, name string) error {
+	for _, m := range r.mounts {
+		if err := m.router.start(ctx, name+m.prefix); err != nil {
+			return err
+		}
+	}
+	if errs := r.lifecycle.run(ctx, name, r.lifecycle.starts, false); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// stop runs the router's own stop hooks, then stops the mounted routers in reverse order.
+func (r *router) stop(ctx gocontext.Context, name string) []error {
+	errs := r.lifecycle.run(ctx, name, r.lifecycle.stops, true)
+	for i := len(r.mounts) - 1; i >= 0; i-- {
+		errs = append(errs, r.mounts[i].router.stop(ctx, name+r.mounts[i].prefix)...)
+	}
+	return errs
+}
+
+// OnStart adds a hook that Start, and so Run, runs before serving requests, e.g. to open connections.
+func (m *Martini) OnStart(hook Hook) {
+	m.lifecycle.starts = append(m.lifecycle.starts, hook)
+}
+
+// OnStop adds a hook that Stop, and so Run after a graceful shutdown, runs, e.g. to flush buffers.
+func (m *Martini) OnStop(hook Hook) {
+	m.lifecycle.stops = append(m.lifecycle.stops, hook)
+}
+
+// HookTimeout limits the time each start and stop hook of the Martini instance may take.
+func (m *Martini) HookTimeout(d time.Duration) {
+	m.lifecycle.timeout = d
+}
+
+// router returns the router mapped as martini.Routes, if it is one created by NewRouter.
+func (m *Martini) router() *router {
+	v := m.Injector.Get(reflect.TypeOf((*Routes)(nil)).Elem())
+	if !v.IsValid() {
+		return nil
+	}
+	r, _ := v.Interface().(*router)
+	return r
+}
+
+// background adds a start hook calling setup and running fn on a goroutine, and a stop hook cancelling the
+// context of fn and waiting for it to return.
+func (m *Martini) background(fn func(ctx gocontext.Context), setup func()) {
+	var stop gocontext.CancelFunc
+	done := make(chan struct{})
+	m.OnStart(func(ctx gocontext.Context) error {
+		setup()
+		var runCtx gocontext.Context
+		runCtx, stop = gocontext.WithCancel(gocontext.Background())
+		go func() {
+			defer close(done)
+			fn(runCtx)
+		}()
+		return nil
+	})
+	m.OnStop(func(ctx gocontext.Context) error {
+		if stop == nil {
+			return nil
+		}
+		stop()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Start runs the start hooks. The hooks of the router mapped as martini.Routes, like the one of
+// ClassicMartini, run first, those of the routers mounted on it before its own. Start stops at the
+// first error and returns it. Then it serves the requests added with Warmup.
+func (m *Martini) Start(ctx gocontext.Context) error {
+	if r := m.router(); r != nil {
+		if err := r.start(ctx, "router"); err != nil {
+			return err
+		}
+	}
+	if errs := m.lifecycle.run(ctx, "martini", m.lifecycle.starts, false); len(errs) > 0 {
+		return errs[0]
+	}
+	m.warmup(ctx)
+	return nil
+}
+
+// Stop runs the stop hooks in the reverse order of Start: the Martini instance's own first, then those
+// of the router, each before those of the routers mounted on it. All hooks run, a *LifecycleError
+// collects their errors.
+func (m *Martini) Stop(ctx gocontext.Context) error {
+	errs := m.lifecycle.run(ctx, "martini", m.lifecycle.stops, true)
+	if r := m.router(); r != nil {
+		errs = append(errs, r.stop(ctx, "router")...)
+	}
+	if len(errs) > 0 {
+		return &LifecycleError{errs}
+	}
+	return nil
+}
+
+// OnStart adds a start hook to the Martini instance, use m.Router.OnStart for the router's.
+func (m *ClassicMartini) OnStart(hook Hook) {
+	m.Martini.OnStart(hook)
+}
+
+// OnStop adds a stop hook to the Martini instance, use m.Router.OnStop for the router's.
+func (m *ClassicMartini) OnStop(hook Hook) {
+	m.Martini.OnStop(hook)
+}
+
+// HookTimeout limits the time each hook of the Martini instance may take, use m.Router.HookTimeout for
+// the router's.
+func (m *ClassicMartini) HookTimeout(d time.Duration) {
+	m.Martini.HookTimeout(d)
+}
@@ -0,0 +1,108 @@
+package martini
+
+import (
+	gocontext "context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Lifecycle(t *testing.T) {
+	var calls []string
+	hook := func(name string) Hook {
+		return func(ctx gocontext.Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	m := Classic()
+	admin := NewRouter()
+	api := NewRouter()
+	users := NewRouter()
+	api.Mount("/users", users)
+	m.Mount("/admin", admin)
+	m.Mount("/api", api)
+
+	m.OnStart(hook("martini start"))
+	m.OnStop(hook("martini stop"))
+	m.Router.OnStart(hook("router start"))
+	m.Router.OnStop(hook("router stop"))
+	admin.OnStart(hook("admin start"))
+	admin.OnStop(hook("admin stop"))
+	api.OnStart(hook("api start"))
+	api.OnStop(hook("api stop"))
+	users.OnStart(hook("users start"))
+	users.OnStop(hook("users stop"))
+
+	expect(t, m.Start(gocontext.Background()), nil)
+	expect(t, strings.Join(calls, ", "), "admin start, users start, api start, router start, martini start")
+
+	calls = nil
+	expect(t, m.Stop(gocontext.Background()), nil)
+	expect(t, strings.Join(calls, ", "), "martini stop, router stop, api stop, users stop, admin stop")
+}
+
+func Test_LifecycleErrors(t *testing.T) {
+	errDB := errors.New("db unreachable")
+	errFlush := errors.New("flush failed")
+	var calls []string
+
+	m := Classic()
+	api := NewRouter()
+	m.Mount("/api", api)
+	api.OnStart(func(ctx gocontext.Context) error {
+		return errDB
+	})
+	m.OnStart(func(ctx gocontext.Context) error {
+		calls = append(calls, "martini start")
+		return nil
+	})
+
+	// start stops at the first error
+	err := m.Start(gocontext.Background())
+	expect(t, errors.Is(err, errDB), true)
+	expect(t, err.Error(), "router/api: db unreachable")
+	expect(t, len(calls), 0)
+
+	// stop runs every hook and collects the errors
+	api.OnStop(func(ctx gocontext.Context) error {
+		return errFlush
+	})
+	m.OnStop(func(ctx gocontext.Context) error {
+		return errDB
+	})
+	m.OnStop(func(ctx gocontext.Context) error {
+		calls = append(calls, "martini stop")
+		return nil
+	})
+	err = m.Stop(gocontext.Background())
+	expect(t, len(calls), 1)
+	expect(t, errors.Is(err, errDB), true)
+	expect(t, errors.Is(err, errFlush), true)
+	var lifecycleErr *LifecycleError
+	expect(t, errors.As(err, &lifecycleErr), true)
+	expect(t, len(lifecycleErr.Errors), 2)
+	expect(t, err.Error(), "martini: stopping failed:\n\tmartini: db unreachable\n\trouter/api: flush failed")
+}
+
+func Test_LifecycleTimeout(t *testing.T) {
+	m := New()
+	r := NewRouter()
+	m.MapTo(r, (*Routes)(nil))
+
+	r.HookTimeout(10 * time.Millisecond)
+	r.OnStop(func(ctx gocontext.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	m.OnStop(func(ctx gocontext.Context) error {
+		_, ok := ctx.Deadline()
+		expect(t, ok, false)
+		return nil
+	})
+
+	err := m.Stop(gocontext.Background())
+	expect(t, errors.Is(err, gocontext.DeadlineExceeded), true)
+}
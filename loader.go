@@ -0,0 +1,74 @@
+package martini
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	boolType  = reflect.TypeOf(false)
+)
+
+// Load returns a handler that converts the named route param into a domain object and maps it into the
+// request context, so handlers receive it directly:
+//
+//	func LoadUser(id string, db *sql.DB) (*User, error) { ... }
+//
+//	m.Get("/users/:id", martini.Load("id", LoadUser), func(user *User) string {
+//	  return user.Name
+//	})
+//
+// The loader receives the param value as its first argument, its other arguments are injected. It
+// returns the object and optionally an error or a bool. A nil object or false responds with a 404 Not Found;
+// a non-nil error panics, which Recovery turns into a 500.
+func Load(param string, loader Handler) Handler {
+	validateHandler(loader)
+	t := reflect.TypeOf(loader)
+	if t.NumIn() < 1 || t.In(0).Kind() != reflect.String {
+		panic("martini loader must take the param value as its first argument")
+	}
+	if t.NumOut() < 1 || t.NumOut() > 2 || (t.NumOut() == 2 && t.Out(1) != errorType && t.Out(1) != boolType) {
+		panic("martini loader must return the object and optionally an error or bool")
+	}
+	fn := reflect.ValueOf(loader)
+
+	return func(c Context, params Params, res http.ResponseWriter) {
+		in := make([]reflect.Value, t.NumIn())
+		in[0] = reflect.ValueOf(params[param]).Convert(t.In(0))
+		for i := 1; i < t.NumIn(); i++ {
+			val := c.Get(t.In(i))
+			if !val.IsValid() {
+				panic(fmt.Sprintf("Value not found for type %v", t.In(i)))
+			}
+			in[i] = val
+		}
+
+		out := fn.Call(in)
+		if len(out) == 2 {
+			if out[1].Type() == boolType {
+				if !out[1].Bool() {
+					http.Error(res, "404 page not found", http.StatusNotFound)
+					return
+				}
+			} else if !out[1].IsNil() {
+				panic(out[1].Interface())
+			}
+		}
+		if isNil(out[0]) {
+			http.Error(res, "404 page not found", http.StatusNotFound)
+			return
+		}
+
+		c.Set(t.Out(0), out[0])
+	}
+}
+
+func isNil(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return val.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package martini
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type loadedUser struct {
+	Name string
+}
+
+func Test_Load(t *testing.T) {
+	users := map[string]*loadedUser{"1": {"jeremy"}}
+
+	m := Classic()
+	m.Map(users)
+	m.Get("/users/:id", Load("id", func(id string, users map[string]*loadedUser) (*loadedUser, error) {
+		if id == "broken" {
+			return nil, errors.New("database is down")
+		}
+		return users[id], nil
+	}), func(user *loadedUser) string {
+		return user.Name
+	})
+	m.Get("/flags/:name", Load("name", func(name string) (int, bool) {
+		return len(name), name != "off"
+	}), func(n int) string {
+		return "flag"
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/1", http.StatusOK, "jeremy"},
+		{"/users/2", http.StatusNotFound, "404 page not found\n"},
+		{"/flags/on", http.StatusOK, "flag"},
+		{"/flags/off", http.StatusNotFound, "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+tt.path, nil)
+		m.ServeHTTP(recorder, req)
+		expect(t, recorder.Code, tt.code)
+		expect(t, recorder.Body.String(), tt.body)
+	}
+
+	setENV(Prod)
+	defer setENV(Dev)
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:3000/users/broken", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Code, http.StatusInternalServerError)
+}
@@ -0,0 +1,233 @@
+package martini
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocaleFormat describes how numbers, currencies and dates are formatted in a locale.
+type LocaleFormat struct {
+	// Decimal and Group are the decimal and digit group separators.
+	Decimal string
+	Group   string
+	// CurrencyPattern places the currency symbol ¤ and the amount #, e.g. "¤#" or "# ¤".
+	CurrencyPattern string
+	// ShortDate and LongDate are time layouts. English month names in LongDate are replaced by Months.
+	ShortDate string
+	LongDate  string
+	// Months are the month names, January first.
+	Months []string
+}
+
+var (
+	englishMonths = []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+
+	localeFormatsMu sync.RWMutex
+	localeFormats   = map[string]LocaleFormat{
+		"en":    {".", ",", "¤#", "01/02/2006", "January 2, 2006", englishMonths},
+		"en-gb": {".", ",", "¤#", "02/01/2006", "2 January 2006", englishMonths},
+		"de": {",", ".", "# ¤", "02.01.2006", "2. January 2006",
+			[]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"}},
+		"fr": {",", "\u202f", "# ¤", "02/01/2006", "2 January 2006",
+			[]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"}},
+		"es": {",", ".", "# ¤", "02/01/2006", "2 de January de 2006",
+			[]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"}},
+		"it": {",", ".", "# ¤", "02/01/2006", "2 January 2006",
+			[]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"}},
+		"nl": {",", ".", "¤ #", "02-01-2006", "2 January 2006",
+			[]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"}},
+		"pt": {",", ".", "¤ #", "02/01/2006", "2 de January de 2006",
+			[]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"}},
+		"ja": {".", ",", "¤#", "2006/01/02", "2006年1月2日", englishMonths},
+	}
+
+	currencySymbols = map[string]string{"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "¥", "INR": "₹", "BRL": "R$"}
+	// currencyDecimals are the minor units of the currencies that don't have 2.
+	currencyDecimals = map[string]int{"JPY": 0, "KRW": 0, "BHD": 3, "KWD": 3}
+)
+
+// RegisterLocaleFormat registers the format of a locale, replacing the built in one if there is one.
+func RegisterLocaleFormat(locale string, f LocaleFormat) {
+	localeFormatsMu.Lock()
+	defer localeFormatsMu.Unlock()
+	localeFormats[strings.ToLower(locale)] = f
+}
+
+// Format returns the format of the locale, falling back to the language without region and then to English.
+func (l Locale) Format() LocaleFormat {
+	localeFormatsMu.RLock()
+	defer localeFormatsMu.RUnlock()
+	tag := strings.ToLower(strings.Replace(string(l), "_", "-", -1))
+	if f, ok := localeFormats[tag]; ok {
+		return f
+	}
+	if i := strings.IndexByte(tag, '-'); i > 0 {
+		if f, ok := localeFormats[tag[:i]]; ok {
+			return f
+		}
+	}
+	return localeFormats["en"]
+}
+
+// Number formats the number with the given number of decimals. Templates call it as {{.Locale.Number 1234.5 2}}.
+func (l Locale) Number(v interface{}, decimals int) string {
+	f, ok := toFloat(v)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	return formatNumber(l.Format(), f, decimals)
+}
+
+// Currency formats the amount in the currency with the ISO 4217 code.
+func (l Locale) Currency(v interface{}, code string) string {
+	f, ok := toFloat(v)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	decimals, ok := currencyDecimals[code]
+	if !ok {
+		decimals = 2
+	}
+	symbol, ok := currencySymbols[code]
+	if !ok {
+		symbol = code
+	}
+	format := l.Format()
+	amount := formatNumber(format, math.Abs(f), decimals)
+	out := strings.Replace(strings.Replace(format.CurrencyPattern, "#", amount, 1), "¤", symbol, 1)
+	if f < 0 {
+		out = "-" + out
+	}
+	return out
+}
+
+// Date formats the date in the short form of the locale, e.g. 01/02/2006 in English.
+func (l Locale) Date(t time.Time) string {
+	return t.Format(l.Format().ShortDate)
+}
+
+// LongDate formats the date with the month name, e.g. January 2, 2006 in English.
+func (l Locale) LongDate(t time.Time) string {
+	format := l.Format()
+	return strings.Replace(t.Format(format.LongDate), englishMonths[t.Month()-1], format.Months[t.Month()-1], 1)
+}
+
+func formatNumber(format LocaleFormat, f float64, decimals int) string {
+	s := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	integer, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		integer, fraction = s[:i], s[i+1:]
+	}
+	var out strings.Builder
+	if f < 0 && strings.Trim(s, "0.") != "" {
+		out.WriteByte('-')
+	}
+	for i, c := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			out.WriteString(format.Group)
+		}
+		out.WriteRune(c)
+	}
+	if fraction != "" {
+		out.WriteString(format.Decimal + fraction)
+	}
+	return out.String()
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// LocaleFuncs returns template functions formatting with the locale passed first, for templates that
+// get the locale passed along with other data: {{currency .Locale .Price "EUR"}}.
+func LocaleFuncs() template.FuncMap {
+	return template.FuncMap{
+		"number":    Locale.Number,
+		"currency":  Locale.Currency,
+		"date":      Locale.Date,
+		"long_date": Locale.LongDate,
+	}
+}
+
+// NegotiateLocale returns a middleware handler mapping the supported locale the client prefers by its
+// Accept-Language header as the Locale, the first one if it accepts none of them. Localized routes map
+// the locale of their pattern instead.
+func NegotiateLocale(supported ...string) Handler {
+	return func(c Context, res http.ResponseWriter, req *http.Request) {
+		locale := negotiateLocale(req.Header.Get("Accept-Language"), supported)
+		c.Map(Locale(locale))
+		res.Header().Add("Vary", "Accept-Language")
+		if locale != "" {
+			res.Header().Set("Content-Language", locale)
+		}
+	}
+}
+
+// negotiateLocale returns the supported locale best matching the Accept-Language header. A language
+// without region matches any region and the other way round.
+func negotiateLocale(header string, supported []string) string {
+	type accepted struct {
+		tag string
+		q   float64
+	}
+	var langs []accepted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range fields[1:] {
+			if p = strings.TrimSpace(p); strings.HasPrefix(p, "q=") {
+				if v, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, accepted{tag, q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	base := func(tag string) string {
+		if i := strings.IndexAny(tag, "-_"); i > 0 {
+			return tag[:i]
+		}
+		return tag
+	}
+	for _, lang := range langs {
+		for _, s := range supported {
+			if strings.EqualFold(s, lang.tag) {
+				return s
+			}
+		}
+		for _, s := range supported {
+			if strings.EqualFold(base(s), base(lang.tag)) {
+				return s
+			}
+		}
+	}
+	if len(supported) > 0 {
+		return supported[0]
+	}
+	return ""
+}
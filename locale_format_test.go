@@ -0,0 +1,63 @@
+package martini
+
+import (
+	"bytes"
+	"html/template"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Locale_Format(t *testing.T) {
+	expect(t, Locale("en").Number(1234567.891, 2), "1,234,567.89")
+	expect(t, Locale("de-AT").Number(1234567.891, 2), "1.234.567,89")
+	expect(t, Locale("fr").Number(-1234, 0), "-1\u202f234")
+	expect(t, Locale("xx").Number(999, 1), "999.0")
+	expect(t, Locale("en").Number(-0.001, 2), "0.00")
+	expect(t, Locale("en").Number("x", 2), "x")
+
+	expect(t, Locale("en-US").Currency(1234.5, "USD"), "$1,234.50")
+	expect(t, Locale("de").Currency(1234.5, "EUR"), "1.234,50 €")
+	expect(t, Locale("nl").Currency(-5, "EUR"), "-€ 5,00")
+	expect(t, Locale("ja").Currency(1500, "JPY"), "¥1,500")
+	expect(t, Locale("en").Currency(3, "CHF"), "CHF3.00")
+
+	date := time.Date(2021, time.March, 4, 0, 0, 0, 0, time.UTC)
+	expect(t, Locale("en").Date(date), "03/04/2021")
+	expect(t, Locale("en_GB").Date(date), "04/03/2021")
+	expect(t, Locale("de").LongDate(date), "4. März 2021")
+	expect(t, Locale("es").LongDate(date), "4 de marzo de 2021")
+	expect(t, Locale("ja").LongDate(date), "2021年3月4日")
+
+	RegisterLocaleFormat("sv", LocaleFormat{",", " ", "# ¤", "2006-01-02", "2 January 2006", englishMonths})
+	expect(t, Locale("sv-SE").Date(date), "2021-03-04")
+}
+
+func Test_Locale_Templates(t *testing.T) {
+	tmpl := template.Must(template.New("").Funcs(LocaleFuncs()).Parse(`{{.Locale.Number .N 1}} {{currency .Locale .N "EUR"}}`))
+	var buf bytes.Buffer
+	expect(t, tmpl.Execute(&buf, map[string]interface{}{"Locale": Locale("de"), "N": 1000}), nil)
+	expect(t, buf.String(), "1.000,0 1.000,00 €")
+}
+
+func Test_NegotiateLocale(t *testing.T) {
+	supported := []string{"en", "de-DE", "fr"}
+	expect(t, negotiateLocale("", supported), "en")
+	expect(t, negotiateLocale("fr-CH, fr;q=0.9, en;q=0.8", supported), "fr")
+	expect(t, negotiateLocale("de", supported), "de-DE")
+	expect(t, negotiateLocale("es, de;q=0.5, fr;q=0.7", supported), "fr")
+	expect(t, negotiateLocale("fr;q=0, it", supported), "en")
+
+	m := Classic()
+	m.Use(NegotiateLocale(supported...))
+	m.Get("/", func(l Locale) string {
+		return l.Number(1.5, 1)
+	})
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Language", "de-AT,de;q=0.9")
+	m.ServeHTTP(res, req)
+	expect(t, res.Body.String(), "1,5")
+	expect(t, res.Header().Get("Content-Language"), "de-DE")
+	expect(t, res.Header().Get("Vary"), "Accept-Language")
+}
@@ -0,0 +1,223 @@
+package martini
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ThrottleState is the state of failed logins for an account and IP.
+type ThrottleState struct {
+	// Failures is the number of failed logins since the last successful one.
+	Failures int
+	// Until is when the next login may be attempted.
+	Until time.Time
+	// Expires is when the failures are forgotten. Stores may drop the state then.
+	Expires time.Time
+}
+
+// ThrottleStore stores the ThrottleState by key.
+type ThrottleStore interface {
+	Get(key string) (ThrottleState, error)
+	Set(key string, s ThrottleState) error
+	Delete(key string) error
+}
+
+// LoginThrottle protects logins against brute force attacks. After every failed login for an account from
+// an IP, the next attempt has to wait, twice as long as after the failure before. After MaxFailures the
+// account is locked out for that IP for the Lockout duration and an EventLockout is published. The IP is the
+// RemoteAddr of the request: behind a load balancer or a reverse proxy, add the Forwarded middleware before
+// so it is the client's, or every client shares the proxy's IP and a single attacker locks an account out
+// for everybody.
+//
+//	throttle := &martini.LoginThrottle{Store: martini.NewMemoryThrottleStore(), Events: m.Events()}
+//	m.Post("/login", throttle.Handler(func(req *http.Request) string {
+//	  return req.FormValue("email")
+//	}), func(attempt *martini.LoginAttempt, s *martini.Session, req *http.Request) {
+//	  if !checkPassword(req) {
+//	    attempt.Fail()
+//	    ...
+//	  }
+//	  attempt.Succeed()
+//	  ...
+//	})
+type LoginThrottle struct {
+	Store ThrottleStore
+	// MaxFailures is the number of failures that lock an account out. Defaults to 5.
+	MaxFailures int
+	// Backoff is the wait after the first failure. Defaults to 1 second.
+	Backoff time.Duration
+	// Lockout is how long an account stays locked out. Defaults to 15 minutes.
+	Lockout time.Duration
+	// Window is how long failures are remembered once the wait after the last one ended. Defaults to the
+	// Lockout duration.
+	Window time.Duration
+	// Events receives an EventLockout when an account is locked out. Optional.
+	Events *Events
+}
+
+// LoginAttempt is mapped by LoginThrottle.Handler for the login handler to report the outcome.
+type LoginAttempt struct {
+	throttle *LoginThrottle
+	req      *http.Request
+	account  string
+	ip       string
+	audit    *SecurityLog
+}
+
+// Fail records a failed login.
+func (a *LoginAttempt) Fail() error {
+	if a.audit != nil {
+		a.audit.Record(SecurityLoginFailure, a.account, nil)
+	}
+	return a.throttle.fail(a.req, a.account, a.ip)
+}
+
+// Succeed records a successful login, which resets the failures.
+func (a *LoginAttempt) Succeed() error {
+	if a.audit != nil {
+		a.audit.Record(SecurityLoginSuccess, a.account, nil)
+	}
+	return a.throttle.Store.Delete(throttleKey(a.account, a.ip))
+}
+
+func throttleKey(account string, ip string) string {
+	return account + "|" + ip
+}
+
+func (l *LoginThrottle) defaults() (int, time.Duration, time.Duration, time.Duration) {
+	max, backoff, lockout, window := l.MaxFailures, l.Backoff, l.Lockout, l.Window
+	if max == 0 {
+		max = 5
+	}
+	if backoff == 0 {
+		backoff = time.Second
+	}
+	if lockout == 0 {
+		lockout = 15 * time.Minute
+	}
+	if window == 0 {
+		window = lockout
+	}
+	return max, backoff, lockout, window
+}
+
+// Wait returns how long the account has to wait before the next login from the IP.
+func (l *LoginThrottle) Wait(account string, ip string) (time.Duration, error) {
+	s, err := l.Store.Get(throttleKey(account, ip))
+	if err != nil {
+		return 0, err
+	}
+	if wait := time.Until(s.Until); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+func (l *LoginThrottle) fail(req *http.Request, account string, ip string) error {
+	max, backoff, lockout, window := l.defaults()
+	key := throttleKey(account, ip)
+	s, err := l.Store.Get(key)
+	if err != nil {
+		return err
+	}
+	if !s.Expires.IsZero() && time.Now().After(s.Expires) {
+		s = ThrottleState{}
+	}
+	s.Failures++
+	wait := backoff << uint(s.Failures-1)
+	if s.Failures >= max || wait > lockout || wait <= 0 {
+		wait = lockout
+	}
+	s.Until = time.Now().Add(wait)
+	s.Expires = s.Until.Add(window)
+	if err := l.Store.Set(key, s); err != nil {
+		return err
+	}
+	if s.Failures == max && l.Events != nil {
+		l.Events.Publish(Event{Kind: EventLockout, Request: req, Subject: account})
+	}
+	return nil
+}
+
+// Handler returns a handler for login routes. It answers with 429 Too Many Requests and a Retry-After
+// header while the account returned by the account func has to wait, and maps a *LoginAttempt otherwise.
+func (l *LoginThrottle) Handler(account func(*http.Request) string) Handler {
+	return func(c Context, res http.ResponseWriter, req *http.Request) {
+		a := &LoginAttempt{throttle: l, req: req, account: account(req), ip: clientIP(req), audit: securityLog(c)}
+		wait, err := l.Wait(a.account, a.ip)
+		if err != nil {
+			panic(err)
+		}
+		if wait > 0 {
+			if a.audit != nil {
+				a.audit.Record(SecurityRateLimited, a.account, map[string]string{"retry_after": wait.String()})
+			}
+			res.Header().Set("Retry-After", strconv.Itoa(int(wait/time.Second)+1))
+			http.Error(res, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		c.Map(a)
+	}
+}
+
+// clientIP returns the IP of the client, without the port. It is the IP of the proxy unless the
+// Forwarded middleware resolved the client behind it.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// throttleSweepInterval is how often the memory store drops expired states.
+const throttleSweepInterval = time.Minute
+
+// NewMemoryThrottleStore creates a ThrottleStore keeping the state in memory. Expired states are dropped,
+// so clients cycling through account names don't grow it without bound.
+func NewMemoryThrottleStore() ThrottleStore {
+	return &memoryThrottleStore{states: make(map[string]ThrottleState), swept: time.Now()}
+}
+
+type memoryThrottleStore struct {
+	mu     sync.Mutex
+	states map[string]ThrottleState
+	swept  time.Time
+}
+
+func (m *memoryThrottleStore) Get(key string) (ThrottleState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.states[key]
+	if !s.Expires.IsZero() && time.Now().After(s.Expires) {
+		delete(m.states, key)
+		return ThrottleState{}, nil
+	}
+	return s, nil
+}
+
+func (m *memoryThrottleStore) Set(key string, s ThrottleState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if now.Sub(m.swept) >= throttleSweepInterval {
+		m.swept = now
+		for k, state := range m.states {
+			if !state.Expires.IsZero() && now.After(state.Expires) {
+				delete(m.states, k)
+			}
+		}
+	}
+	m.states[key] = s
+	return nil
+}
+
+func (m *memoryThrottleStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, key)
+	return nil
+}
@@ -0,0 +1,112 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_LoginThrottle(t *testing.T) {
+	m := Classic()
+	throttle := &LoginThrottle{Store: NewMemoryThrottleStore(), MaxFailures: 3, Backoff: time.Millisecond, Lockout: time.Hour, Events: m.Events()}
+	var lockouts []string
+	m.Events().Subscribe(EventLockout, func(e Event) { lockouts = append(lockouts, e.Subject) })
+	m.Post("/login", throttle.Handler(func(req *http.Request) string {
+		return req.URL.Query().Get("user")
+	}), func(attempt *LoginAttempt, req *http.Request) (int, string) {
+		if req.URL.Query().Get("password") != "secret" {
+			attempt.Fail()
+			return http.StatusUnauthorized, "wrong password"
+		}
+		attempt.Succeed()
+		return http.StatusOK, "welcome"
+	})
+
+	login := func(user string, password string, ip string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/login?user="+user+"&password="+password, nil)
+		req.RemoteAddr = ip + ":1234"
+		m.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	expect(t, login("jane", "guess", "10.0.0.1").Code, http.StatusUnauthorized)
+	// the next attempt has to wait
+	expect(t, login("jane", "secret", "10.0.0.1").Code, http.StatusTooManyRequests)
+	time.Sleep(2 * time.Millisecond)
+	expect(t, login("jane", "secret", "10.0.0.1").Code, http.StatusOK)
+
+	for i := 0; i < 3; i++ {
+		expect(t, login("jane", "guess", "10.0.0.1").Code, http.StatusUnauthorized)
+		time.Sleep(5 * time.Millisecond)
+	}
+	locked := login("jane", "secret", "10.0.0.1")
+	expect(t, locked.Code, http.StatusTooManyRequests)
+	expect(t, locked.Header().Get("Retry-After"), "3600")
+	expect(t, len(lockouts), 1)
+	expect(t, lockouts[0], "jane")
+
+	// other IPs and accounts are not affected
+	expect(t, login("jane", "secret", "10.0.0.2").Code, http.StatusOK)
+	expect(t, login("joe", "secret", "10.0.0.1").Code, http.StatusOK)
+}
+
+func Test_LoginThrottleBehindProxy(t *testing.T) {
+	m := Classic()
+	m.Use(Forwarded())
+	throttle := &LoginThrottle{Store: NewMemoryThrottleStore(), MaxFailures: 1, Lockout: time.Hour}
+	m.Post("/login", throttle.Handler(func(req *http.Request) string {
+		return "jane"
+	}), func(attempt *LoginAttempt) (int, string) {
+		attempt.Fail()
+		return http.StatusUnauthorized, "wrong password"
+	})
+
+	login := func(client string) int {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/login", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", client)
+		m.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+	expect(t, login("203.0.113.9"), http.StatusUnauthorized)
+	expect(t, login("203.0.113.9"), http.StatusTooManyRequests)
+	// the attacker locked the account out for their own IP only
+	expect(t, login("198.51.100.1"), http.StatusUnauthorized)
+}
+
+func Test_MemoryThrottleStore_Expires(t *testing.T) {
+	store := NewMemoryThrottleStore().(*memoryThrottleStore)
+	past := time.Now().Add(-time.Second)
+	for _, account := range []string{"a", "b", "c"} {
+		store.Set(throttleKey(account, "1.2.3.4"), ThrottleState{Failures: 1, Until: past, Expires: past})
+	}
+	store.Set(throttleKey("d", "1.2.3.4"), ThrottleState{Failures: 1, Expires: time.Now().Add(time.Hour)})
+
+	s, _ := store.Get(throttleKey("a", "1.2.3.4"))
+	expect(t, s.Failures, 0)
+	expect(t, len(store.states), 3)
+
+	// the expired states are swept once the interval passed
+	store.swept = time.Now().Add(-throttleSweepInterval)
+	store.Set(throttleKey("e", "1.2.3.4"), ThrottleState{Failures: 1, Expires: time.Now().Add(time.Hour)})
+	expect(t, len(store.states), 2)
+}
+
+func Test_LoginThrottle_Window(t *testing.T) {
+	throttle := &LoginThrottle{Store: NewMemoryThrottleStore(), MaxFailures: 3, Backoff: time.Millisecond, Lockout: time.Hour, Window: time.Millisecond}
+	req, _ := http.NewRequest("POST", "/login", nil)
+
+	expect(t, throttle.fail(req, "jane", "1.2.3.4"), nil)
+	s, _ := throttle.Store.Get(throttleKey("jane", "1.2.3.4"))
+	expect(t, s.Failures, 1)
+	expect(t, s.Expires.Equal(s.Until.Add(time.Millisecond)), true)
+
+	// the failure is forgotten once the window after the wait passed
+	time.Sleep(5 * time.Millisecond)
+	expect(t, throttle.fail(req, "jane", "1.2.3.4"), nil)
+	s, _ = throttle.Store.Get(throttleKey("jane", "1.2.3.4"))
+	expect(t, s.Failures, 1)
+}
@@ -0,0 +1,180 @@
+package martini
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrLockHeld is returned by Locks.Acquire when another holder has the lock.
+var ErrLockHeld = errors.New("martini: lock is held")
+
+// ErrLockLost is returned by Lock.Refresh and Lock.Release when the lock expired and may have been
+// acquired by another holder since.
+var ErrLockLost = errors.New("martini: lock lost")
+
+// Locks hands out locks expiring after a ttl, so a holder that crashed doesn't keep them forever. Use
+// NewMemoryLocks within a single instance and RedisLocks to coordinate across instances. martini.New maps
+// in-memory Locks, map a RedisLocks as martini.Locks to replace them:
+//
+//	m.MapTo(&martini.RedisLocks{Client: client}, (*martini.Locks)(nil))
+//
+//	m.Post("/reports", func(locks martini.Locks) (int, string) {
+//	  lock, err := locks.Acquire("reports", time.Minute)
+//	  if err == martini.ErrLockHeld {
+//	    return http.StatusConflict, "a report is being generated"
+//	  }
+//	  defer lock.Release()
+//	  ...
+//	})
+type Locks interface {
+	// Acquire acquires the lock on the key for the ttl. It returns ErrLockHeld if it is held.
+	Acquire(key string, ttl time.Duration) (Lock, error)
+}
+
+// Lock is a lock acquired from Locks.
+type Lock interface {
+	// Key returns the key the lock is held on.
+	Key() string
+	// Refresh extends the lock to expire after the ttl from now. It returns ErrLockLost if the lock
+	// expired.
+	Refresh(ttl time.Duration) error
+	// Release releases the lock. It returns ErrLockLost if the lock expired.
+	Release() error
+}
+
+// NewMemoryLocks creates Locks held in memory, for a single instance.
+func NewMemoryLocks() Locks {
+	return &memoryLocks{locks: make(map[string]memoryLockEntry)}
+}
+
+type memoryLocks struct {
+	mu    sync.Mutex
+	locks map[string]memoryLockEntry
+}
+
+type memoryLockEntry struct {
+	token   string
+	expires time.Time
+}
+
+func (m *memoryLocks) Acquire(key string, ttl time.Duration) (Lock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.locks[key]; ok && time.Now().Before(e.expires) {
+		return nil, ErrLockHeld
+	}
+	token := randomToken(16)
+	m.locks[key] = memoryLockEntry{token, time.Now().Add(ttl)}
+	return &memoryLock{m, key, token}, nil
+}
+
+type memoryLock struct {
+	locks *memoryLocks
+	key   string
+	token string
+}
+
+func (l *memoryLock) Key() string {
+	return l.key
+}
+
+// held returns whether the lock is still held, the caller holds the mutex.
+func (l *memoryLock) held() bool {
+	e, ok := l.locks.locks[l.key]
+	return ok && e.token == l.token && time.Now().Before(e.expires)
+}
+
+func (l *memoryLock) Refresh(ttl time.Duration) error {
+	l.locks.mu.Lock()
+	defer l.locks.mu.Unlock()
+	if !l.held() {
+		return ErrLockLost
+	}
+	l.locks.locks[l.key] = memoryLockEntry{l.token, time.Now().Add(ttl)}
+	return nil
+}
+
+func (l *memoryLock) Release() error {
+	l.locks.mu.Lock()
+	defer l.locks.mu.Unlock()
+	if !l.held() {
+		return ErrLockLost
+	}
+	delete(l.locks.locks, l.key)
+	return nil
+}
+
+// RedisLockClient is the subset of Redis commands used by RedisLocks. Adapt the client library of your
+// choice to it.
+type RedisLockClient interface {
+	// SetNX sets the key to the value expiring after the ttl, like SET key value NX PX ttl, and reports
+	// whether it was set.
+	SetNX(key string, value string, ttl time.Duration) (bool, error)
+	// Eval runs the Lua script and returns its integer result.
+	Eval(script string, keys []string, args ...string) (int64, error)
+}
+
+// The scripts only touch the key if it still holds the token of the lock.
+const (
+	redisReleaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+	redisRefreshScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+)
+
+// RedisLocks are Locks held in Redis, shared by every instance using the same Redis. A lock is a key
+// holding a random token, which expires with the lock.
+type RedisLocks struct {
+	Client RedisLockClient
+	// Prefix is prepended to the keys, "lock:" by default.
+	Prefix string
+}
+
+func (r *RedisLocks) key(key string) string {
+	if r.Prefix == "" {
+		return "lock:" + key
+	}
+	return r.Prefix + key
+}
+
+func (r *RedisLocks) Acquire(key string, ttl time.Duration) (Lock, error) {
+	token := randomToken(16)
+	ok, err := r.Client.SetNX(r.key(key), token, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("martini: acquiring lock %s: %w", key, err)
+	}
+	if !ok {
+		return nil, ErrLockHeld
+	}
+	return &redisLock{r, key, token}, nil
+}
+
+type redisLock struct {
+	locks *RedisLocks
+	key   string
+	token string
+}
+
+func (l *redisLock) Key() string {
+	return l.key
+}
+
+func (l *redisLock) Refresh(ttl time.Duration) error {
+	return l.eval(redisRefreshScript, strconv.FormatInt(int64(ttl/time.Millisecond), 10))
+}
+
+func (l *redisLock) Release() error {
+	return l.eval(redisReleaseScript)
+}
+
+func (l *redisLock) eval(script string, args ...string) error {
+	n, err := l.locks.Client.Eval(script, []string{l.locks.key(l.key)}, append([]string{l.token}, args...)...)
+	if err != nil {
+		return fmt.Errorf("martini: lock %s: %w", l.key, err)
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package martini
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeRedisLocks implements the lock scripts of RedisLocks against a map.
+type fakeRedisLocks struct {
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+func (r *fakeRedisLocks) live(key string) bool {
+	_, ok := r.values[key]
+	return ok && time.Now().Before(r.expires[key])
+}
+
+func (r *fakeRedisLocks) SetNX(key string, value string, ttl time.Duration) (bool, error) {
+	if r.live(key) {
+		return false, nil
+	}
+	r.values[key], r.expires[key] = value, time.Now().Add(ttl)
+	return true, nil
+}
+
+func (r *fakeRedisLocks) Eval(script string, keys []string, args ...string) (int64, error) {
+	if !r.live(keys[0]) || r.values[keys[0]] != args[0] {
+		return 0, nil
+	}
+	switch script {
+	case redisReleaseScript:
+		delete(r.values, keys[0])
+	case redisRefreshScript:
+		ms, _ := time.ParseDuration(args[1] + "ms")
+		r.expires[keys[0]] = time.Now().Add(ms)
+	default:
+		return 0, errors.New("unknown script")
+	}
+	return 1, nil
+}
+
+func testLocks(t *testing.T, locks Locks) {
+	lock, err := locks.Acquire("reports", 20*time.Millisecond)
+	expect(t, err, nil)
+	expect(t, lock.Key(), "reports")
+	_, err = locks.Acquire("reports", time.Minute)
+	expect(t, err, ErrLockHeld)
+	other, err := locks.Acquire("exports", time.Minute)
+	expect(t, err, nil)
+
+	expect(t, lock.Refresh(40*time.Millisecond), nil)
+	time.Sleep(30 * time.Millisecond)
+	_, err = locks.Acquire("reports", time.Minute)
+	expect(t, err, ErrLockHeld)
+
+	expect(t, lock.Release(), nil)
+	expect(t, lock.Release(), ErrLockLost)
+	again, err := locks.Acquire("reports", 10*time.Millisecond)
+	expect(t, err, nil)
+
+	// an expired lock is lost, even once acquired by another holder
+	time.Sleep(20 * time.Millisecond)
+	expect(t, again.Refresh(time.Minute), ErrLockLost)
+	taken, err := locks.Acquire("reports", time.Minute)
+	expect(t, err, nil)
+	expect(t, again.Release(), ErrLockLost)
+	expect(t, taken.Release(), nil)
+	expect(t, other.Release(), nil)
+}
+
+func Test_MemoryLocks(t *testing.T) {
+	testLocks(t, NewMemoryLocks())
+}
+
+func Test_RedisLocks(t *testing.T) {
+	client := &fakeRedisLocks{make(map[string]string), make(map[string]time.Time)}
+	testLocks(t, &RedisLocks{Client: client})
+
+	locks := &RedisLocks{Client: client, Prefix: "app:"}
+	_, err := locks.Acquire("jobs", time.Minute)
+	expect(t, err, nil)
+	_, ok := client.values["app:jobs"]
+	expect(t, ok, true)
+}
+
+func Test_LocksService(t *testing.T) {
+	m := Classic()
+	m.Post("/reports", func(locks Locks) (int, string) {
+		if _, err := locks.Acquire("reports", time.Minute); err == ErrLockHeld {
+			return http.StatusConflict, "busy"
+		}
+		return http.StatusAccepted, "started"
+	})
+	for _, status := range []int{http.StatusAccepted, http.StatusConflict} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/reports", nil)
+		m.ServeHTTP(res, req)
+		expect(t, res.Code, status)
+	}
+}
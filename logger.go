@@ -3,18 +3,44 @@ package martini
 import (
 	"log"
 	"net/http"
+	"reflect"
 	"time"
 )
 
+// logLevels maps the values of the "log.level" config to the lowest status of the responses logged. Only
+// the "info" level, the default, logs requests as they go in.
+var logLevels = map[string]int{
+	"info":  0,
+	"warn":  400,
+	"error": 500,
+	"off":   1000,
+}
+
 // Logger returns a middleware handler that logs the request as it goes in and the response as it goes out.
+// It maps a *RequestLogger bound to the request ID, which is also sent in the X-Request-Id response header,
+// and logs both lines with the fields bound to it. The "log.level" config, read on each request so it
+// can be adjusted at runtime, limits the lines to the responses with a 4xx or 5xx status with "warn", to
+// those with a 5xx status with "error", and turns them off with "off".
 func Logger() Handler {
 	return func(res http.ResponseWriter, req *http.Request, c Context, log *log.Logger) {
 		start := time.Now()
-		log.Printf("Started %s %s", req.Method, req.URL.Path)
+		level := 0
+		if v := c.Get(reflect.TypeOf((*Config)(nil))); v.IsValid() {
+			level = logLevels[v.Interface().(*Config).String("log.level", "info")]
+		}
+		rl := NewRequestLogger(log)
+		rl.Bind("request_id", requestID(req))
+		res.Header().Set("X-Request-Id", rl.Field("request_id"))
+		c.Map(rl)
+		if level == 0 {
+			rl.Printf("Started %s %s", req.Method, req.URL.Path)
+		}
 
 		rw := res.(ResponseWriter)
 		c.Next()
 
-		log.Printf("Completed %v %s in %v\n", rw.Status(), http.StatusText(rw.Status()), time.Since(start))
+		if rw.Status() >= level {
+			rl.Printf("Completed %v %s in %v", rw.Status(), http.StatusText(rw.Status()), time.Since(start))
+		}
 	}
 }
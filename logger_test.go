@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -29,3 +30,29 @@ func Test_Logger(t *testing.T) {
 	expect(t, recorder.Code, http.StatusNotFound)
 	refute(t, len(buff.String()), 0)
 }
+
+func Test_LoggerLevel(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	m := New()
+	m.Map(log.New(buff, "[martini] ", 0))
+	m.Use(Logger())
+	m.Use(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			res.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	m.Config().Set("log.level", "warn")
+	for _, path := range []string{"/ok", "/missing"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	expect(t, strings.Count(buff.String(), "\n"), 1)
+	expect(t, strings.HasPrefix(buff.String(), "[martini] Completed 404 Not Found"), true)
+
+	buff.Reset()
+	m.Config().Set("log.level", "off")
+	req, _ := http.NewRequest("GET", "/missing", nil)
+	m.ServeHTTP(httptest.NewRecorder(), req)
+	expect(t, buff.String(), "")
+}
@@ -0,0 +1,192 @@
+package martini
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LongPollMessage is a message published to a LongPoll topic. IDs increase by one per topic.
+type LongPollMessage struct {
+	ID   uint64      `json:"id"`
+	Data interface{} `json:"data"`
+}
+
+// LongPoll delivers messages published to topics to clients waiting for them with long polling
+// requests, for clients that can't use server sent events or WebSockets. Each topic keeps the last
+// messages, so clients passing the ID of the last message they got don't miss any between requests.
+//
+//	poll := martini.NewLongPoll()
+//	m.Get("/poll", poll.Handler(func(req *http.Request) string {
+//	  return "room:" + req.URL.Query().Get("room")
+//	}, 30*time.Second))
+//	poll.Publish("room:1", message)
+//
+// Topics are created by Publish. Waiting on a topic nothing was published to doesn't keep it once the
+// request is done.
+type LongPoll struct {
+	// Buffer is the number of messages kept per topic. Defaults to 100.
+	Buffer int
+	// Heartbeat is the interval of the whitespace written while waiting, so proxies don't close idle
+	// connections. Defaults to 15 seconds.
+	Heartbeat time.Duration
+
+	mu     sync.Mutex
+	topics map[string]*pollTopic
+}
+
+type pollTopic struct {
+	messages []LongPollMessage
+	last     uint64
+	// published is closed and replaced on every publish, to wake up the waiting requests.
+	published chan struct{}
+	waiters   int
+}
+
+// NewLongPoll creates a LongPoll, the zero value is ready to use as well.
+func NewLongPoll() *LongPoll {
+	return &LongPoll{}
+}
+
+// topic returns the topic, creating it if needed. The caller holds the mutex.
+func (lp *LongPoll) topic(name string) *pollTopic {
+	if lp.topics == nil {
+		lp.topics = make(map[string]*pollTopic)
+	}
+	t, ok := lp.topics[name]
+	if !ok {
+		t = &pollTopic{published: make(chan struct{})}
+		lp.topics[name] = t
+	}
+	return t
+}
+
+func (lp *LongPoll) buffer() int {
+	if lp.Buffer <= 0 {
+		return 100
+	}
+	return lp.Buffer
+}
+
+func (lp *LongPoll) heartbeat() time.Duration {
+	if lp.Heartbeat <= 0 {
+		return 15 * time.Second
+	}
+	return lp.Heartbeat
+}
+
+// Publish publishes the data to the topic and returns the ID of the message.
+func (lp *LongPoll) Publish(topic string, data interface{}) uint64 {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	t := lp.topic(topic)
+	t.last++
+	t.messages = append(t.messages, LongPollMessage{t.last, data})
+	if len(t.messages) > lp.buffer() {
+		t.messages = t.messages[len(t.messages)-lp.buffer():]
+	}
+	close(t.published)
+	t.published = make(chan struct{})
+	return t.last
+}
+
+// Last returns the ID of the last message published to the topic.
+func (lp *LongPoll) Last(topic string) uint64 {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	if t, ok := lp.topics[topic]; ok {
+		return t.last
+	}
+	return 0
+}
+
+// Wait returns the messages of the topic after since, waiting for the next one if there are none.
+// It returns no messages when the timeout passes or the context is done first.
+func (lp *LongPoll) Wait(ctx gocontext.Context, topic string, since uint64, timeout time.Duration) []LongPollMessage {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	lp.mu.Lock()
+	t := lp.topic(topic)
+	t.waiters++
+	lp.mu.Unlock()
+	defer func() {
+		lp.mu.Lock()
+		t.waiters--
+		if t.waiters == 0 && len(t.messages) == 0 {
+			delete(lp.topics, topic)
+		}
+		lp.mu.Unlock()
+	}()
+
+	for {
+		lp.mu.Lock()
+		var messages []LongPollMessage
+		for _, m := range t.messages {
+			if m.ID > since {
+				messages = append(messages, m)
+			}
+		}
+		published := t.published
+		lp.mu.Unlock()
+		if len(messages) > 0 {
+			return messages
+		}
+
+		select {
+		case <-published:
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Handler returns a handler answering with the messages of the topic returned by topic, waiting up to
+// the timeout for them. The client passes the ID of the last message it got as the since query param,
+// without it only messages published while waiting are returned. The response is a JSON object with
+// the messages and the last ID to pass as since next.
+func (lp *LongPoll) Handler(topic func(*http.Request) string, timeout time.Duration) Handler {
+	return func(res http.ResponseWriter, req *http.Request) {
+		name := topic(req)
+		since, err := strconv.ParseUint(req.URL.Query().Get("since"), 10, 64)
+		if last := lp.Last(name); err != nil || since > last {
+			// IDs start over when the server restarts
+			since = last
+		}
+		res.Header().Set("Content-Type", "application/json")
+		res.Header().Set("Cache-Control", "no-store")
+
+		done := make(chan []LongPollMessage, 1)
+		go func() {
+			done <- lp.Wait(req.Context(), name, since, timeout)
+		}()
+		heartbeat := time.NewTicker(lp.heartbeat())
+		defer heartbeat.Stop()
+		for {
+			select {
+			case messages := <-done:
+				last := since
+				if len(messages) > 0 {
+					last = messages[len(messages)-1].ID
+				} else if messages == nil {
+					messages = []LongPollMessage{}
+				}
+				json.NewEncoder(res).Encode(struct {
+					Messages []LongPollMessage `json:"messages"`
+					Last     uint64            `json:"last"`
+				}{messages, last})
+				return
+			case <-heartbeat.C:
+				// leading whitespace is valid JSON
+				res.Write([]byte(" "))
+				if f, ok := res.(http.Flusher); ok {
+					f.Flush()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package martini
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_LongPoll_Wait(t *testing.T) {
+	lp := NewLongPoll()
+	lp.Buffer = 2
+	lp.Publish("a", 1)
+	lp.Publish("a", 2)
+	lp.Publish("a", 3)
+
+	messages := lp.Wait(gocontext.Background(), "a", 0, time.Second)
+	expect(t, len(messages), 2)
+	expect(t, messages[0].ID, uint64(2))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		lp.Publish("a", 4)
+	}()
+	messages = lp.Wait(gocontext.Background(), "a", 3, time.Second)
+	expect(t, len(messages), 1)
+	expect(t, messages[0].Data, interface{}(4))
+
+	expect(t, len(lp.Wait(gocontext.Background(), "a", 4, 10*time.Millisecond)), 0)
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	start := time.Now()
+	expect(t, len(lp.Wait(ctx, "a", 4, time.Second)), 0)
+	expect(t, time.Since(start) < time.Second, true)
+}
+
+func Test_LongPoll_Handler(t *testing.T) {
+	lp := NewLongPoll()
+	lp.Heartbeat = 5 * time.Millisecond
+	m := Classic()
+	m.Get("/poll", lp.Handler(func(req *http.Request) string {
+		return req.URL.Query().Get("topic")
+	}, time.Second))
+
+	lp.Publish("news", "old")
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		lp.Publish("news", "fresh")
+	}()
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/poll?topic=news", nil))
+	expect(t, strings.HasPrefix(res.Body.String(), " "), true)
+	var body struct {
+		Messages []LongPollMessage
+		Last     uint64
+	}
+	expect(t, json.Unmarshal(res.Body.Bytes(), &body), nil)
+	expect(t, len(body.Messages), 1)
+	expect(t, body.Messages[0].Data, interface{}("fresh"))
+	expect(t, body.Last, uint64(2))
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/poll?topic=news&since=0", nil))
+	expect(t, json.Unmarshal(res.Body.Bytes(), &body), nil)
+	expect(t, len(body.Messages), 2)
+}
+
+func Test_LongPoll_Timeout(t *testing.T) {
+	lp := NewLongPoll()
+	m := Classic()
+	m.Get("/poll", lp.Handler(func(req *http.Request) string { return "t" }, 10*time.Millisecond))
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/poll?since=99", nil))
+	expect(t, res.Body.String(), "{\"messages\":[],\"last\":0}\n")
+}
+
+func Test_LongPoll_Topics(t *testing.T) {
+	var lp LongPoll
+	m := Classic()
+	m.Get("/poll", lp.Handler(func(req *http.Request) string {
+		return req.URL.Query().Get("room")
+	}, 5*time.Millisecond))
+
+	for _, room := range []string{"a", "b", "c"} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, httptest.NewRequest("GET", "/poll?room="+room, nil))
+		expect(t, res.Code, http.StatusOK)
+	}
+	expect(t, lp.Last("d"), uint64(0))
+	expect(t, len(lp.topics), 0)
+
+	lp.Publish("a", 1)
+	expect(t, len(lp.Wait(gocontext.Background(), "a", 0, time.Millisecond)), 1)
+	expect(t, len(lp.topics), 1)
+}
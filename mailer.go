@@ -0,0 +1,271 @@
+package martini
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+// Message is an email message. At least one of Text and HTML should be set.
+type Message struct {
+	From    string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+	Text    string
+	HTML    string
+	// Headers are additional headers, e.g. List-Unsubscribe.
+	Headers map[string]string
+}
+
+// Mailer sends email messages. Map one so handlers can send mail:
+//
+//	m.MapTo(&martini.SMTPMailer{Addr: "smtp.example.com:587", Auth: auth, From: "app@example.com"}, (*martini.Mailer)(nil))
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// NewPageMessage creates a message with the HTML body rendered from the page of the Templates, in the
+// layout selected by opts like the pages rendered as responses, so emails share their layouts and
+// partials. If the page defines a "text" block, it is rendered as the text body, unescaped.
+//
+//	msg, err := martini.NewPageMessage(templates, "Welcome", "mail/welcome", user, martini.RenderOptions{Layout: "mail"})
+func NewPageMessage(t *Templates, subject string, name string, data interface{}, opts ...RenderOptions) (*Message, error) {
+	body, err := t.Render(name, data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{Subject: subject, HTML: string(body)}
+	if t.hasBlock(name, "text") {
+		text, err := t.Render(name, data, RenderOptions{Block: "text"})
+		if err != nil {
+			return nil, err
+		}
+		msg.Text = html.UnescapeString(string(text))
+	}
+	return msg, nil
+}
+
+// NewTemplateMessage creates a message with the bodies rendered from the templates with the data. Either
+// template may be nil. Use NewPageMessage to render pages of Templates.
+func NewTemplateMessage(subject string, text *texttemplate.Template, html *htmltemplate.Template, data interface{}) (*Message, error) {
+	msg := &Message{Subject: subject}
+	var buf bytes.Buffer
+	if text != nil {
+		if err := text.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		msg.Text = buf.String()
+		buf.Reset()
+	}
+	if html != nil {
+		if err := html.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		msg.HTML = buf.String()
+	}
+	return msg, nil
+}
+
+// SMTPMailer is a Mailer sending messages through an SMTP server.
+type SMTPMailer struct {
+	// Addr is the host:port of the server.
+	Addr string
+	// Auth authenticates with the server, e.g. smtp.PlainAuth. Optional.
+	Auth smtp.Auth
+	// From is the sender of messages without a From.
+	From string
+}
+
+func (m *SMTPMailer) Send(msg *Message) error {
+	from := msg.From
+	if from == "" {
+		from = m.From
+	}
+	sender, err := parseAddresses([]string{from})
+	if err != nil {
+		return err
+	}
+	var recipients []string
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		addrs, err := parseAddresses(list)
+		if err != nil {
+			return err
+		}
+		for _, a := range addrs {
+			recipients = append(recipients, a.Address)
+		}
+	}
+	data, err := buildMessage(msg, from, time.Now())
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.Addr, m.Auth, sender[0].Address, recipients, data)
+}
+
+// parseAddresses parses the addresses, e.g. "bob@example.com" or "Bob <bob@example.com>". Addresses
+// with line breaks, which would inject headers, are rejected.
+func parseAddresses(list []string) ([]*mail.Address, error) {
+	addrs := make([]*mail.Address, len(list))
+	for i, s := range list {
+		if strings.ContainsAny(s, "\r\n") {
+			return nil, fmt.Errorf("martini: invalid email address %q", s)
+		}
+		a, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("martini: invalid email address %q: %v", s, err)
+		}
+		addrs[i] = a
+	}
+	return addrs, nil
+}
+
+// formatAddresses formats the addresses for a header, encoding the names as needed.
+func formatAddresses(list []string) (string, error) {
+	addrs, err := parseAddresses(list)
+	if err != nil {
+		return "", err
+	}
+	formatted := make([]string, len(addrs))
+	for i, a := range addrs {
+		if a.Name == "" {
+			formatted[i] = a.Address
+		} else {
+			formatted[i] = a.String()
+		}
+	}
+	return strings.Join(formatted, ", "), nil
+}
+
+// buildMessage renders the message in MIME format, with a multipart/alternative body if it has both
+// a text and a HTML version. It fails for invalid addresses and header names, header values are encoded
+// so line breaks can't inject headers.
+func buildMessage(msg *Message, from string, date time.Time) ([]byte, error) {
+	var b bytes.Buffer
+	header := func(k, v string) {
+		b.WriteString(k + ": " + v + "\r\n")
+	}
+	for _, h := range []struct {
+		name string
+		list []string
+	}{{"From", []string{from}}, {"To", msg.To}, {"Cc", msg.Cc}} {
+		if len(h.list) == 0 {
+			continue
+		}
+		v, err := formatAddresses(h.list)
+		if err != nil {
+			return nil, err
+		}
+		header(h.name, v)
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	keys := make([]string, 0, len(msg.Headers))
+	for k := range msg.Headers {
+		if !headerName.MatchString(k) {
+			return nil, fmt.Errorf("martini: invalid header name %q", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		header(k, mime.QEncoding.Encode("utf-8", msg.Headers[k]))
+	}
+
+	part := func(contentType string, body string) {
+		b.WriteString("Content-Type: " + contentType + "; charset=utf-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		w := quotedprintable.NewWriter(&b)
+		w.Write([]byte(body))
+		w.Close()
+		b.WriteString("\r\n")
+	}
+	if msg.Text == "" || msg.HTML == "" {
+		if msg.HTML != "" {
+			part("text/html", msg.HTML)
+		} else {
+			part("text/plain", msg.Text)
+		}
+		return b.Bytes(), nil
+	}
+
+	boundary := randomToken(16)
+	header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	b.WriteString("\r\n")
+	b.WriteString("--" + boundary + "\r\n")
+	part("text/plain", msg.Text)
+	b.WriteString("--" + boundary + "\r\n")
+	part("text/html", msg.HTML)
+	b.WriteString("--" + boundary + "--\r\n")
+	return b.Bytes(), nil
+}
+
+// headerName matches the names of message headers, printable characters without colons or spaces.
+var headerName = regexp.MustCompile(`^[!-9;-~]+$`)
+
+// ErrMailerClosed is returned by AsyncMailer.Send once the mailer has been closed.
+var ErrMailerClosed = errors.New("mailer closed")
+
+// AsyncMailer is a Mailer queueing messages and sending them in the background through another Mailer,
+// so handlers don't wait for the mail server.
+type AsyncMailer struct {
+	mailer  Mailer
+	queue   chan *Message
+	onError func(*Message, error)
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+}
+
+// NewAsyncMailer starts a background sender with a queue of the given size. Send blocks while the queue
+// is full. onError is called with messages that failed to send and may be nil.
+func NewAsyncMailer(mailer Mailer, queueSize int, onError func(*Message, error)) *AsyncMailer {
+	m := &AsyncMailer{mailer: mailer, queue: make(chan *Message, queueSize), onError: onError}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		for msg := range m.queue {
+			if err := m.mailer.Send(msg); err != nil && m.onError != nil {
+				m.onError(msg, err)
+			}
+		}
+	}()
+	return m
+}
+
+// Send queues the message. It returns ErrMailerClosed once Close has been called.
+func (m *AsyncMailer) Send(msg *Message) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return ErrMailerClosed
+	}
+	m.queue <- msg
+	return nil
+}
+
+// Close stops accepting messages and waits until the queued ones have been sent. Closing a closed mailer
+// does nothing.
+func (m *AsyncMailer) Close() {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.queue)
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
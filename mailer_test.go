@@ -0,0 +1,199 @@
+package martini
+
+import (
+	"bufio"
+	"errors"
+	htmltemplate "html/template"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	texttemplate "text/template"
+	"time"
+)
+
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []*Message
+	err  error
+}
+
+func (m *recordingMailer) Send(msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return m.err
+}
+
+func Test_NewTemplateMessage(t *testing.T) {
+	text := texttemplate.Must(texttemplate.New("text").Parse("Hi {{.}}"))
+	html := htmltemplate.Must(htmltemplate.New("html").Parse("<p>Hi {{.}}</p>"))
+
+	msg, err := NewTemplateMessage("Welcome", text, html, "<Bob>")
+	expect(t, err, nil)
+	expect(t, msg.Subject, "Welcome")
+	expect(t, msg.Text, "Hi <Bob>")
+	expect(t, msg.HTML, "<p>Hi &lt;Bob&gt;</p>")
+
+	msg, err = NewTemplateMessage("Welcome", text, nil, "Bob")
+	expect(t, err, nil)
+	expect(t, msg.HTML, "")
+}
+
+func Test_buildMessage(t *testing.T) {
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := &Message{To: []string{"a@example.com", "b@example.com"}, Bcc: []string{"c@example.com"}, Subject: "Grüße", Text: "plain"}
+	data, err := buildMessage(msg, "app@example.com", date)
+	expect(t, err, nil)
+	out := string(data)
+	expect(t, strings.Contains(out, "From: app@example.com\r\n"), true)
+	expect(t, strings.Contains(out, "To: a@example.com, b@example.com\r\n"), true)
+	expect(t, strings.Contains(out, "c@example.com"), false)
+	expect(t, strings.Contains(out, "Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\n"), true)
+	expect(t, strings.Contains(out, "Content-Type: text/plain; charset=utf-8\r\n"), true)
+	expect(t, strings.Contains(out, "multipart"), false)
+
+	msg.HTML = "<p>html</p>"
+	data, _ = buildMessage(msg, "app@example.com", date)
+	out = string(data)
+	expect(t, strings.Contains(out, "Content-Type: multipart/alternative; boundary="), true)
+	expect(t, strings.Index(out, "text/plain") < strings.Index(out, "text/html"), true)
+}
+
+func Test_buildMessageInjection(t *testing.T) {
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, msg := range []*Message{
+		{To: []string{"a@example.com\r\nBcc: victim@example.com"}},
+		{Cc: []string{"a@example.com\nBcc: victim@example.com"}},
+		{To: []string{"not an address"}},
+		{To: []string{"a@example.com"}, Headers: map[string]string{"X-Tag\r\nBcc": "victim@example.com"}},
+	} {
+		_, err := buildMessage(msg, "app@example.com", date)
+		refute(t, err, nil)
+	}
+	_, err := buildMessage(&Message{To: []string{"a@example.com"}}, "app@example.com\r\nBcc: victim@example.com", date)
+	refute(t, err, nil)
+
+	msg := &Message{To: []string{"Bob <b@example.com>"}, Text: "hi", Headers: map[string]string{"X-Tag": "a\r\nBcc: victim@example.com"}}
+	data, err := buildMessage(msg, "App <app@example.com>", date)
+	expect(t, err, nil)
+	out := string(data)
+	expect(t, strings.Contains(out, "\r\nBcc:"), false)
+	expect(t, strings.Contains(out, "To: \"Bob\" <b@example.com>\r\n"), true)
+	expect(t, strings.Contains(out, "From: \"App\" <app@example.com>\r\n"), true)
+
+	err = (&SMTPMailer{Addr: "127.0.0.1:0"}).Send(&Message{From: "app@example.com", Bcc: []string{"a@example.com\r\nRCPT TO:<b@example.com>"}})
+	refute(t, err, nil)
+}
+
+func Test_NewPageMessage(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"layouts/mail.tmpl": `<body>{{block "content" .}}{{end}}</body>`,
+		"mail/welcome.tmpl": `{{define "content"}}<p>Hi {{.}}</p>{{end}}{{define "text"}}Hi {{.}}{{end}}`,
+		"mail/bye.tmpl":     `<p>Bye {{.}}</p>`,
+	})
+	defer os.RemoveAll(dir)
+	tmpl, err := NewTemplates(TemplateOptions{Dir: dir})
+	expect(t, err, nil)
+
+	msg, err := NewPageMessage(tmpl, "Welcome", "mail/welcome", "<Bob>", RenderOptions{Layout: "mail"})
+	expect(t, err, nil)
+	expect(t, msg.Subject, "Welcome")
+	expect(t, msg.HTML, "<body><p>Hi &lt;Bob&gt;</p></body>")
+	expect(t, msg.Text, "Hi <Bob>")
+
+	msg, err = NewPageMessage(tmpl, "Bye", "mail/bye", "Bob")
+	expect(t, err, nil)
+	expect(t, msg.HTML, "<p>Bye Bob</p>")
+	expect(t, msg.Text, "")
+
+	_, err = NewPageMessage(tmpl, "Nope", "mail/nope", nil)
+	refute(t, err, nil)
+}
+
+func Test_SMTPMailer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	expect(t, err, nil)
+	defer l.Close()
+
+	var commands []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost\r\n"))
+		data := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if data {
+				if line == "." {
+					data = false
+					conn.Write([]byte("250 ok\r\n"))
+				}
+				continue
+			}
+			commands = append(commands, line)
+			switch {
+			case strings.HasPrefix(line, "DATA"):
+				data = true
+				conn.Write([]byte("354 go ahead\r\n"))
+			case strings.HasPrefix(line, "QUIT"):
+				conn.Write([]byte("221 bye\r\n"))
+				return
+			default:
+				conn.Write([]byte("250 ok\r\n"))
+			}
+		}
+	}()
+
+	m := &SMTPMailer{Addr: l.Addr().String(), From: "app@example.com"}
+	err = m.Send(&Message{To: []string{"a@example.com"}, Bcc: []string{"b@example.com"}, Subject: "hi", Text: "hello"})
+	expect(t, err, nil)
+	<-done
+	expect(t, commands[1], "MAIL FROM:<app@example.com>")
+	expect(t, commands[2], "RCPT TO:<a@example.com>")
+	expect(t, commands[3], "RCPT TO:<b@example.com>")
+}
+
+func Test_AsyncMailer(t *testing.T) {
+	rec := &recordingMailer{err: errors.New("down")}
+	var failed []*Message
+	m := NewAsyncMailer(rec, 10, func(msg *Message, err error) {
+		failed = append(failed, msg)
+	})
+	for i := 0; i < 3; i++ {
+		expect(t, m.Send(&Message{Subject: "hi"}), nil)
+	}
+	m.Close()
+	expect(t, len(rec.sent), 3)
+	expect(t, len(failed), 3)
+
+	expect(t, m.Send(&Message{Subject: "late"}), ErrMailerClosed)
+	m.Close()
+	expect(t, len(rec.sent), 3)
+}
+
+func Test_AsyncMailer_SendWhileClosing(t *testing.T) {
+	m := NewAsyncMailer(&recordingMailer{}, 1, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := m.Send(&Message{Subject: "hi"})
+			expect(t, err == nil || err == ErrMailerClosed, true)
+		}()
+	}
+	m.Close()
+	wg.Wait()
+}
@@ -0,0 +1,208 @@
+package martini
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// markdownCacheSize is the number of rendered documents kept by Markdown.
+const markdownCacheSize = 256
+
+// MarkdownRenderer converts Markdown to HTML. Adapt the Markdown library of your choice to it.
+type MarkdownRenderer interface {
+	Render(src []byte) ([]byte, error)
+}
+
+// Markdown renders Markdown to HTML for templates, caching the results by the hash of the source.
+// Register its FuncMap to render Markdown within layouts:
+//
+//	md := martini.NewMarkdown(nil)
+//	tmpl, _ := martini.NewTemplates(martini.TemplateOptions{Layout: "main", Funcs: md.FuncMap()})
+//
+//	{{define "content"}}{{markdown .Body}}{{end}}
+type Markdown struct {
+	renderer  MarkdownRenderer
+	sanitizer *Sanitizer
+	mu        sync.Mutex
+	cache     map[[sha256.Size]byte]template.HTML
+}
+
+// NewMarkdown creates a Markdown using the renderer. With a nil renderer a basic one is used, which
+// supports headings, paragraphs, lists, quotes, code, emphasis and links and escapes any HTML in the source.
+func NewMarkdown(renderer MarkdownRenderer) *Markdown {
+	if renderer == nil {
+		renderer = BasicMarkdown{}
+	}
+	return &Markdown{renderer: renderer, cache: make(map[[sha256.Size]byte]template.HTML)}
+}
+
+// SetSanitizer sets the Sanitizer cleaning the rendered HTML, needed for renderers passing raw HTML in the
+// source through.
+func (m *Markdown) SetSanitizer(s *Sanitizer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sanitizer = s
+	m.cache = make(map[[sha256.Size]byte]template.HTML)
+}
+
+// HTML renders the Markdown source.
+func (m *Markdown) HTML(src string) (template.HTML, error) {
+	key := sha256.Sum256([]byte(src))
+	m.mu.Lock()
+	out, ok := m.cache[key]
+	sanitizer := m.sanitizer
+	m.mu.Unlock()
+	if ok {
+		return out, nil
+	}
+
+	b, err := m.renderer.Render([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	if sanitizer != nil {
+		out = template.HTML(sanitizer.Sanitize(string(b)))
+	} else {
+		out = template.HTML(b)
+	}
+	m.mu.Lock()
+	if len(m.cache) >= markdownCacheSize {
+		m.cache = make(map[[sha256.Size]byte]template.HTML)
+	}
+	m.cache[key] = out
+	m.mu.Unlock()
+	return out, nil
+}
+
+// FuncMap returns the markdown template function.
+func (m *Markdown) FuncMap() template.FuncMap {
+	return template.FuncMap{"markdown": m.HTML}
+}
+
+// BasicMarkdown is a MarkdownRenderer for a basic subset of Markdown. Raw HTML in the source is escaped.
+type BasicMarkdown struct{}
+
+var (
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	markdownOrdered = regexp.MustCompile(`^\d+[.)]\s+`)
+	markdownLink    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownStrong  = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	markdownEm      = regexp.MustCompile(`\*(.+?)\*`)
+)
+
+func (BasicMarkdown) Render(src []byte) ([]byte, error) {
+	var (
+		out       bytes.Buffer
+		paragraph []string
+		list      string
+		code      bool
+	)
+	flush := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + markdownInline(strings.Join(paragraph, " ")) + "</p>\n")
+			paragraph = nil
+		}
+		if list != "" {
+			out.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	openList := func(tag string) {
+		if list != tag {
+			flush()
+			out.WriteString("<" + tag + ">\n")
+			list = tag
+		}
+	}
+
+	for _, line := range strings.Split(strings.Replace(string(src), "\r\n", "\n", -1), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			if code {
+				out.WriteString("</code></pre>\n")
+			} else {
+				flush()
+				out.WriteString("<pre><code>")
+			}
+			code = !code
+			continue
+		}
+		if code {
+			out.WriteString(html.EscapeString(line) + "\n")
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flush()
+		case markdownHeading.MatchString(trimmed):
+			flush()
+			m := markdownHeading.FindStringSubmatch(trimmed)
+			level := string(rune('0' + len(m[1])))
+			out.WriteString("<h" + level + ">" + markdownInline(m[2]) + "</h" + level + ">\n")
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			openList("ul")
+			out.WriteString("<li>" + markdownInline(trimmed[2:]) + "</li>\n")
+		case markdownOrdered.MatchString(trimmed):
+			openList("ol")
+			out.WriteString("<li>" + markdownInline(markdownOrdered.ReplaceAllString(trimmed, "")) + "</li>\n")
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			out.WriteString("<blockquote>" + markdownInline(strings.TrimSpace(trimmed[1:])) + "</blockquote>\n")
+		default:
+			if list != "" {
+				flush()
+			}
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	if code {
+		out.WriteString("</code></pre>\n")
+	}
+	flush()
+	return out.Bytes(), nil
+}
+
+// markdownInline renders code spans, links and emphasis, escaping everything else.
+func markdownInline(s string) string {
+	parts := strings.Split(s, "`")
+	for i, p := range parts {
+		if i%2 == 1 && i < len(parts)-1 {
+			parts[i] = "<code>" + html.EscapeString(p) + "</code>"
+			continue
+		}
+		p = html.EscapeString(p)
+		p = markdownLink.ReplaceAllStringFunc(p, func(m string) string {
+			sub := markdownLink.FindStringSubmatch(m)
+			if !safeURL(html.UnescapeString(sub[2])) {
+				return sub[1]
+			}
+			return `<a href="` + sub[2] + `">` + sub[1] + `</a>`
+		})
+		p = markdownStrong.ReplaceAllString(p, "<strong>$1</strong>")
+		p = markdownEm.ReplaceAllString(p, "<em>$1</em>")
+		if i%2 == 1 {
+			p = "`" + p
+		}
+		parts[i] = p
+	}
+	return strings.Join(parts, "")
+}
+
+// safeURL reports whether the URL is relative or uses a scheme that can't run script.
+func safeURL(u string) bool {
+	i := strings.IndexAny(u, ":/?#")
+	if i < 0 || u[i] != ':' {
+		return true
+	}
+	switch strings.ToLower(u[:i]) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package martini
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+)
+
+func Test_BasicMarkdown(t *testing.T) {
+	src := "# Title\n\nSome **bold** and *em* text\nwith `a<b` and [a link](https://example.com?a=1&b=2).\n\n" +
+		"- one\n- two\n\n1. first\n2. second\n\n> quote\n\n```\n<script>x</script>\n```\n\n<script>alert(1)</script> [bad](javascript:alert)\n"
+	out, err := BasicMarkdown{}.Render([]byte(src))
+	expect(t, err, nil)
+	expect(t, string(out), "<h1>Title</h1>\n"+
+		"<p>Some <strong>bold</strong> and <em>em</em> text with <code>a&lt;b</code> and <a href=\"https://example.com?a=1&amp;b=2\">a link</a>.</p>\n"+
+		"<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n"+
+		"<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n"+
+		"<blockquote>quote</blockquote>\n"+
+		"<pre><code>&lt;script&gt;x&lt;/script&gt;\n</code></pre>\n"+
+		"<p>&lt;script&gt;alert(1)&lt;/script&gt; bad</p>\n")
+}
+
+type countingMarkdown struct {
+	calls int
+}
+
+func (c *countingMarkdown) Render(src []byte) ([]byte, error) {
+	c.calls++
+	return append([]byte("<p>"), append(src, "</p>"...)...), nil
+}
+
+func Test_Markdown(t *testing.T) {
+	renderer := &countingMarkdown{}
+	md := NewMarkdown(renderer)
+
+	for i := 0; i < 3; i++ {
+		out, err := md.HTML("hello")
+		expect(t, err, nil)
+		expect(t, out, template.HTML("<p>hello</p>"))
+	}
+	expect(t, renderer.calls, 1)
+	md.HTML("other")
+	expect(t, renderer.calls, 2)
+
+	tmpl := template.Must(template.New("").Funcs(NewMarkdown(nil).FuncMap()).Parse(`<main>{{markdown .}}</main>`))
+	var buf bytes.Buffer
+	expect(t, tmpl.Execute(&buf, "*hi*"), nil)
+	expect(t, buf.String(), "<main><p><em>hi</em></p>\n</main>")
+}
@@ -18,10 +18,16 @@
 package martini
 
 import (
+	gocontext "context"
+	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"reflect"
+	"syscall"
+	"time"
 
 	"github.com/codegangsta/inject"
 )
@@ -29,16 +35,46 @@ import (
 // Martini represents the top level web application. inject.Injector methods can be invoked to map services on a global level.
 type Martini struct {
 	inject.Injector
-	handlers []Handler
-	action   Handler
-	logger   *log.Logger
+	handlers   []Handler
+	middleware []Middleware
+	action     Handler
+	logger     *log.Logger
+	audit      bool
+	basePath   string
+	events     *Events
+	config     *Config
+	registry   *handlerRegistry
+	cache      *Cache
+
+	plugins       []string
+	templateFuncs template.FuncMap
+	lifecycle     lifecycle
+	warmups       []*http.Request
+
+	global      *frozenInjector
+	freezeOnRun bool
 }
 
 // New creates a bare bones Martini instance. Use this method if you want to have full control over the middleware that is used.
 func New() *Martini {
-	m := &Martini{Injector: inject.New(), action: func() {}, logger: log.New(os.Stdout, "[martini] ", 0)}
+	global := &frozenInjector{Injector: inject.New()}
+	m := &Martini{Injector: global, action: func() {}, logger: log.New(os.Stdout, "[martini] ", 0), audit: Env == Dev, global: global}
 	m.Map(m.logger)
 	m.Map(defaultReturnHandler())
+	m.MapTo(defaultErrors{}, (*Errors)(nil))
+	m.events = NewEvents()
+	m.Map(m.events)
+	m.config = NewConfig()
+	m.config.LoadEnv("MARTINI_")
+	m.Map(m.config)
+	m.registry = newHandlerRegistry()
+	m.Map(m.registry)
+	m.cache = NewCache(CacheOptions{
+		MaxEntries: m.config.Int("cache.max_entries", 10000),
+		MaxBytes:   int64(m.config.Int("cache.max_bytes", 64<<20)),
+	})
+	m.Map(m.cache)
+	m.MapTo(NewMemoryLocks(), (*Locks)(nil))
 	return m
 }
 
@@ -46,6 +82,7 @@ func New() *Martini {
 // Will panic if any of the handlers is not a callable function
 func (m *Martini) Handlers(handlers ...Handler) {
 	m.handlers = make([]Handler, 0)
+	m.middleware = nil
 	for _, handler := range handlers {
 		m.Use(handler)
 	}
@@ -57,39 +94,120 @@ func (m *Martini) Action(handler Handler) {
 	m.action = handler
 }
 
-// Use adds a middleware Handler to the stack. Will panic if the handler is not a callable func. Middleware Handlers are invoked in the order that they are added.
+// Use adds a middleware Handler to the stack. Will panic if the handler is not a callable func. Middleware Handlers are invoked in the order that they are added,
+// unless they are given as a martini.Middleware declaring their place in the stack. Will panic if the declared order has a cycle.
+// A string or a martini.Middleware without Handler adds the handler registered under the name with Name.
 func (m *Martini) Use(handler Handler) {
-	validateHandler(handler)
+	mw, ok := handler.(Middleware)
+	if name, isName := handler.(string); isName {
+		mw, ok = Middleware{Name: name}, true
+	}
+	if !ok {
+		mw = Middleware{Handler: handler}
+	}
+	if mw.Handler == nil && mw.Name != "" {
+		mw.Handler = m.Named(mw.Name)
+	}
+	validateHandler(mw.Handler)
 
-	m.handlers = append(m.handlers, handler)
+	middleware := append(m.middleware, mw)
+	handlers, err := sortMiddleware(middleware)
+	if err != nil {
+		panic(err)
+	}
+	m.middleware, m.handlers = middleware, handlers
 }
 
 // ServeHTTP is the HTTP Entry point for a Martini instance. Useful if you want to control your own HTTP server.
 func (m *Martini) ServeHTTP(res http.ResponseWriter, req *http.Request) {
-	m.createContext(res, req).run()
+	start := time.Now()
+	c := m.createContext(res, req)
+	defer c.done()
+	c.run()
+	if m.events != nil {
+		m.events.requestDone(req, c.rw.Status(), time.Since(start))
+	}
 }
 
-// Run the http server. Listening on os.GetEnv("PORT") or 3000 by default.
+// Run the http server. Listening on the "port" config or os.GetEnv("PORT") or 3000 by default. The server
+// uses TLS if the "tls.cert" and "tls.key" configs are set.
+// The start hooks and the warmup requests run before listening. On SIGINT or SIGTERM the server shuts down gracefully, waiting for
+// the "shutdown.timeout" config, 30s by default, for requests in flight, runs the stop hooks and returns.
+// It logs a startup banner, which the "quiet" config turns off and the "banner.routes" config extends by the route table.
 func (m *Martini) Run() {
-	port := os.Getenv("PORT")
+	config := m.config
+	if config == nil {
+		config = NewConfig()
+	}
+	port := config.String("port", os.Getenv("PORT"))
 	if port == "" {
 		port = "3000"
 	}
 
-	host := os.Getenv("HOST")
+	host := config.String("host", os.Getenv("HOST"))
 
 	logger := m.Injector.Get(reflect.TypeOf(m.logger)).Interface().(*log.Logger)
 
-	logger.Println("listening on " + host + ":" + port)
-	logger.Fatalln(http.ListenAndServe(host+":"+port, m))
+	if problems := missingMiddleware(m.middleware); len(problems) > 0 {
+		logger.Fatalln(&ValidationError{problems})
+	}
+	if m.freezeOnRun {
+		m.Freeze()
+	}
+	if err := m.Start(gocontext.Background()); err != nil {
+		logger.Fatalln(err)
+	}
+
+	server := &http.Server{Addr: host + ":" + port, Handler: m}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		signal.Stop(signals)
+
+		logger.Println("shutting down")
+		ctx, cancel := gocontext.WithTimeout(gocontext.Background(), config.Duration("shutdown.timeout", 30*time.Second))
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Println(err)
+		}
+		if err := m.Stop(ctx); err != nil {
+			logger.Println(err)
+		}
+	}()
+
+	cert, key := config.String("tls.cert", ""), config.String("tls.key", "")
+	for _, line := range m.banner(config, server.Addr, cert != "" && key != "") {
+		logger.Println(line)
+	}
+	var err error
+	if cert != "" && key != "" {
+		err = server.ListenAndServeTLS(cert, key)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		logger.Fatalln(err)
+	}
+	<-stopped
 }
 
 func (m *Martini) createContext(res http.ResponseWriter, req *http.Request) *context {
-	c := &context{inject.New(), m.handlers, m.action, NewResponseWriter(res), 0}
+	var injector inject.Injector = inject.New()
+	if m.audit {
+		injector = newAuditInjector()
+	}
+	c := &context{injector, m, m.handlers, m.action, NewResponseWriter(res), 0, nil, nil}
 	c.SetParent(m)
 	c.MapTo(c, (*Context)(nil))
 	c.MapTo(c.rw, (*http.ResponseWriter)(nil))
 	c.Map(req)
+	c.Map(BasePath(m.basePath))
+	if m.basePath != "" {
+		stripBasePath(req, m.basePath)
+	}
 	return c
 }
 
@@ -100,13 +218,14 @@ type ClassicMartini struct {
 }
 
 // Classic creates a classic Martini with some basic default middleware - martini.Logger, martini.Recovery and martini.Static.
+// Static serves the "static.dir" config, "public" by default.
 // Classic also maps martini.Routes as a service.
 func Classic() *ClassicMartini {
 	r := NewRouter()
 	m := New()
-	m.Use(Logger())
-	m.Use(Recovery())
-	m.Use(Static("public"))
+	m.Use(Middleware{Name: "logger", Handler: Logger()})
+	m.Use(Middleware{Name: "recovery", Handler: Recovery()})
+	m.Use(Middleware{Name: "static", Handler: Static(m.config.String("static.dir", "public"))})
 	m.MapTo(r, (*Routes)(nil))
 	m.Action(r.Handle)
 	return &ClassicMartini{m, r}
@@ -131,14 +250,30 @@ type Context interface {
 	Next()
 	// Written returns whether or not the response for this context has been written.
 	Written() bool
+	// CheckDeadline returns a non-nil error once the request's context is done, either because its
+	// deadline passed (see martini.Timeout) or the client went away. Long running handlers can call it
+	// periodically to abort cooperatively.
+	CheckDeadline() error
+	// Go runs the handler in a new goroutine. Its arguments are resolved from the request context before
+	// Go returns. The *http.Request it receives carries a context that is canceled once the request has
+	// been served, and a panic in the goroutine is logged instead of crashing the process.
+	Go(Handler)
+	// Call serves an internal request for the named route through the whole middleware stack and returns
+	// the response. The params fill the route pattern like for URLFor. The body can be nil, a string,
+	// []byte or io.Reader, anything else is sent as JSON. The request shares the client, the
+	// Authorization and Cookie headers, the request ID and the trace context of the current request.
+	Call(method string, routeName string, body interface{}, params ...interface{}) (*http.Response, error)
 }
 
 type context struct {
 	inject.Injector
+	martini  *Martini
 	handlers []Handler
 	action   Handler
 	rw       ResponseWriter
 	index    int
+	goCtx    gocontext.Context
+	cancel   gocontext.CancelFunc
 }
 
 func (c *context) handler() Handler {
@@ -160,11 +295,81 @@ func (c *context) Written() bool {
 	return c.rw.Written()
 }
 
+func (c *context) CheckDeadline() error {
+	req := c.Get(reflect.TypeOf((*http.Request)(nil))).Interface().(*http.Request)
+	if req == nil {
+		return nil
+	}
+	return req.Context().Err()
+}
+
+func (c *context) Go(handler Handler) {
+	validateHandler(handler)
+
+	t := reflect.TypeOf(handler)
+	in := make([]reflect.Value, t.NumIn())
+	for i := range in {
+		argType := t.In(i)
+		val := c.Get(argType)
+		if !val.IsValid() {
+			panic(fmt.Sprintf("Value not found for type %v", argType))
+		}
+		if req, ok := val.Interface().(*http.Request); ok && req != nil {
+			val = reflect.ValueOf(req.WithContext(c.goContext(req)))
+		}
+		in[i] = val
+	}
+
+	var logger *log.Logger
+	if val := c.Get(reflect.TypeOf(logger)); val.IsValid() {
+		logger, _ = val.Interface().(*log.Logger)
+	}
+	var events *Events
+	if val := c.Get(reflect.TypeOf(events)); val.IsValid() {
+		events, _ = val.Interface().(*Events)
+	}
+	var req *http.Request
+	if val := c.Get(reflect.TypeOf(req)); val.IsValid() {
+		req, _ = val.Interface().(*http.Request)
+	}
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				if logger != nil {
+					logger.Printf("PANIC in goroutine: %s\n%s", err, stack(3))
+				}
+				if events != nil {
+					events.Publish(Event{Kind: EventPanic, Request: req, Panic: err})
+				}
+			}
+		}()
+		reflect.ValueOf(handler).Call(in)
+	}()
+}
+
+// goContext returns the context handed to goroutines started with Go, creating it on first use.
+func (c *context) goContext(req *http.Request) gocontext.Context {
+	if c.goCtx == nil {
+		c.goCtx, c.cancel = gocontext.WithCancel(req.Context())
+	}
+	return c.goCtx
+}
+
+// done releases the resources held by the context once the request has been served.
+func (c *context) done() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	if audit, ok := c.Injector.(*auditInjector); ok {
+		audit.finish()
+	}
+}
+
 func (c *context) run() {
 	for c.index <= len(c.handlers) {
-		_, err := c.Invoke(c.handler())
+		_, err := invoke(c, c.handler())
 		if err != nil {
-			panic(err)
+			panic(handlerError(c, c.handler(), err))
 		}
 		c.index += 1
 
@@ -1,9 +1,12 @@
 package martini
 
 import (
+	"bytes"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -139,3 +142,33 @@ func Test_Martini_Basic_NoRace(t *testing.T) {
 		}()
 	}
 }
+
+func Test_Martini_Go(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	canceled := make(chan bool)
+	panicked := make(chan Event, 1)
+
+	m := New()
+	m.Map(log.New(buff, "[martini] ", 0))
+	m.Events().Subscribe(EventPanic, func(e Event) {
+		panicked <- e
+	})
+	m.Use(func(c Context) {
+		c.Go(func(req *http.Request) {
+			<-req.Context().Done()
+			canceled <- true
+		})
+		c.Go(func() {
+			panic("here is a panic!")
+		})
+	})
+
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	m.ServeHTTP(httptest.NewRecorder(), req)
+
+	expect(t, <-canceled, true)
+	e := <-panicked
+	expect(t, e.Panic, "here is a panic!")
+	expect(t, e.Request.URL.Path, "/")
+	expect(t, strings.Contains(buff.String(), "PANIC in goroutine: here is a panic!"), true)
+}
@@ -0,0 +1,124 @@
+package martini
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Middleware is a named middleware handler declaring where it belongs in the stack relative to other
+// named middleware, so the stack doesn't depend on the order of the Use calls:
+//
+//	m.Use(martini.Middleware{Name: "session", Handler: sessions, Requires: []string{"cookies"}})
+//	m.Use(martini.Middleware{Name: "cookies", Handler: cookies, After: []string{"logger"}})
+//
+// Martini sorts the stack whenever middleware is added. It keeps the order of the Use calls, except that
+// middleware is moved up to run before the middleware that has to run after it. Classic names its
+// middleware "logger", "recovery" and "static".
+type Middleware struct {
+	// Name is how other middleware refers to this one. Names must be unique.
+	Name string
+	// Handler is the middleware handler. Defaults to the handler registered under Name with Martini.Name.
+	Handler Handler
+	// Requires names middleware that has to be used and run before this one.
+	Requires []string
+	// Before names middleware this one runs before, if it is used.
+	Before []string
+	// After names middleware this one runs after, if it is used.
+	After []string
+}
+
+// sortMiddleware orders the middleware so that every constraint holds and returns their handlers. It
+// returns an error if two middleware have the same name or the constraints contradict each other.
+func sortMiddleware(middleware []Middleware) ([]Handler, error) {
+	byName := make(map[string]int)
+	for i, mw := range middleware {
+		if mw.Name == "" {
+			continue
+		}
+		if _, ok := byName[mw.Name]; ok {
+			return nil, fmt.Errorf("martini: middleware %q is used twice", mw.Name)
+		}
+		byName[mw.Name] = i
+	}
+
+	// prev holds the middleware that has to run before each one
+	prev := make([][]int, len(middleware))
+	edge := func(before string, after int, reverse bool) {
+		i, ok := byName[before]
+		if !ok {
+			return
+		}
+		if reverse {
+			i, after = after, i
+		}
+		prev[after] = append(prev[after], i)
+	}
+	for i, mw := range middleware {
+		for _, name := range mw.Requires {
+			edge(name, i, false)
+		}
+		for _, name := range mw.After {
+			edge(name, i, false)
+		}
+		for _, name := range mw.Before {
+			edge(name, i, true)
+		}
+	}
+
+	// middleware is added in the order of the Use calls, pulling the middleware it has to run after forward
+	const (
+		unvisited = iota
+		visiting
+		added
+	)
+	handlers := make([]Handler, 0, len(middleware))
+	state := make([]int, len(middleware))
+	var path []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case added:
+			return nil
+		case visiting:
+			cycle := path
+			for cycle[0] != middleware[i].Name {
+				cycle = cycle[1:]
+			}
+			return fmt.Errorf("martini: middleware ordering has a cycle: %s -> %s", strings.Join(cycle, " -> "), middleware[i].Name)
+		}
+		state[i] = visiting
+		path = append(path, middleware[i].Name)
+		for _, j := range prev[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = added
+		handlers = append(handlers, middleware[i].Handler)
+		return nil
+	}
+	for i := range middleware {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return handlers, nil
+}
+
+// missingMiddleware returns the problems with middleware requiring middleware that isn't used.
+func missingMiddleware(middleware []Middleware) []string {
+	used := make(map[string]bool)
+	for _, mw := range middleware {
+		used[mw.Name] = true
+	}
+	var problems []string
+	for _, mw := range middleware {
+		for _, name := range mw.Requires {
+			if !used[name] {
+				problems = append(problems, fmt.Sprintf("middleware %q requires %q, which is not used", mw.Name, name))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,61 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Middleware_Order(t *testing.T) {
+	var order []string
+	named := func(name string) Handler {
+		return func() { order = append(order, name) }
+	}
+
+	m := New()
+	m.Use(Middleware{Name: "session", Handler: named("session"), Requires: []string{"cookies"}})
+	m.Use(named("unnamed"))
+	m.Use(Middleware{Name: "cookies", Handler: named("cookies"), After: []string{"logger"}})
+	m.Use(Middleware{Name: "logger", Handler: named("logger"), Before: []string{"missing"}})
+	expect(t, m.Validate(), nil)
+
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	expect(t, strings.Join(order, ","), "logger,cookies,session,unnamed")
+}
+
+func Test_Middleware_Errors(t *testing.T) {
+	m := New()
+	m.Use(Middleware{Name: "a", Handler: func() {}, Requires: []string{"b", "c"}})
+	err := m.Validate()
+	refute(t, err, nil)
+	expect(t, strings.Contains(err.Error(), `middleware "a" requires "b", which is not used`), true)
+
+	m.Use(Middleware{Name: "b", Handler: func() {}, After: []string{"c"}})
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			refute(t, err, nil)
+			expect(t, err.Error(), "martini: middleware ordering has a cycle: a -> b -> c -> a")
+		}()
+		m.Use(Middleware{Name: "c", Handler: func() {}, After: []string{"a"}})
+	}()
+	// the stack is left as it was
+	expect(t, len(m.handlers), 2)
+
+	func() {
+		defer func() {
+			refute(t, recover(), nil)
+		}()
+		m.Use(Middleware{Name: "a", Handler: func(res http.ResponseWriter) {}})
+	}()
+}
+
+func Test_Middleware_Classic(t *testing.T) {
+	m := Classic()
+	first := func() {}
+	m.Use(Middleware{Name: "first", Handler: first, Before: []string{"logger"}})
+	expect(t, len(m.handlers), 4)
+	expect(t, reflect.ValueOf(m.handlers[0]).Pointer(), reflect.ValueOf(first).Pointer())
+}
@@ -0,0 +1,284 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// MinifyOptions configures the Minify middleware.
+type MinifyOptions struct {
+	// MinSize is the size below which responses are left alone, as minifying them saves too little.
+	// Defaults to 512 bytes.
+	MinSize int
+	// MaxSize is the size above which responses are left alone, to bound the time spent. Defaults to 4MB.
+	MaxSize int
+}
+
+// minifiers minify the responses by media type.
+var minifiers = map[string]func([]byte) []byte{
+	"text/html":              minifyHTML,
+	"text/css":               minifyCSS,
+	"text/javascript":        minifyJS,
+	"application/javascript": minifyJS,
+	"application/json":       minifyJSON,
+}
+
+// Minify returns a middleware handler buffering the response and minifying HTML, CSS, JS and JSON
+// bodies before they are sent. The media type is taken from the Content-Type header, or detected from
+// the body if there is none. Encoded responses, e.g. gzipped ones, and responses of routes with
+// RouteOptions.DisableCompression are left alone.
+func Minify(options ...MinifyOptions) Handler {
+	var opts MinifyOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.MinSize == 0 {
+		opts.MinSize = 512
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = 4 << 20
+	}
+
+	return func(c Context, res http.ResponseWriter) {
+		bw, ok := res.(BufferedResponseWriter)
+		if !ok {
+			return
+		}
+		bw.Buffer()
+		c.Next()
+
+		body := bw.Body()
+		if bw.Committed() || len(body) < opts.MinSize || len(body) > opts.MaxSize || bw.Header().Get("Content-Encoding") != "" ||
+			routeOptions(c).DisableCompression {
+			bw.Commit()
+			return
+		}
+		ctype := bw.Header().Get("Content-Type")
+		if ctype == "" {
+			ctype = http.DetectContentType(body)
+		}
+		if mediaType, _, err := mime.ParseMediaType(ctype); err == nil {
+			if minify := minifiers[mediaType]; minify != nil || strings.HasSuffix(mediaType, "+json") {
+				if minify == nil {
+					minify = minifyJSON
+				}
+				bw.SetBody(minify(body))
+				bw.Header().Del("Content-Length")
+			}
+		}
+		bw.Commit()
+	}
+}
+
+// minifyJSON removes the insignificant whitespace, invalid JSON is left alone.
+func minifyJSON(src []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, src); err != nil {
+		return src
+	}
+	return buf.Bytes()
+}
+
+var (
+	htmlRawText    = regexp.MustCompile(`(?is)<(pre|textarea|script|style)\b.*?</(pre|textarea|script|style)\s*>`)
+	htmlComment    = regexp.MustCompile(`(?s)<!--([^\[].*?)?-->`)
+	htmlWhitespace = regexp.MustCompile(`\s+`)
+)
+
+// minifyHTML removes comments and collapses whitespace, except in pre, textarea, script and style
+// elements. Whitespace is never removed entirely, as it separates inline elements.
+func minifyHTML(src []byte) []byte {
+	var out bytes.Buffer
+	collapse := func(b []byte) {
+		b = htmlComment.ReplaceAll(b, nil)
+		out.Write(htmlWhitespace.ReplaceAllFunc(b, func(ws []byte) []byte {
+			if bytes.IndexByte(ws, '\n') >= 0 {
+				return []byte("\n")
+			}
+			return []byte(" ")
+		}))
+	}
+	last := 0
+	for _, loc := range htmlRawText.FindAllIndex(src, -1) {
+		collapse(src[last:loc[0]])
+		out.Write(src[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	collapse(src[last:])
+	return out.Bytes()
+}
+
+// minifyCSS removes comments and whitespace, leaving strings alone.
+func minifyCSS(src []byte) []byte {
+	var out bytes.Buffer
+	space := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := stringEnd(src, i)
+			if space && needsSpace(out.Bytes(), c) {
+				out.WriteByte(' ')
+			}
+			space = false
+			out.Write(src[i:end])
+			i = end - 1
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return out.Bytes()
+			}
+			i += end + 3
+			space = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			space = true
+		default:
+			if strings.IndexByte("{};,", c) >= 0 {
+				space = false
+				if c == '}' && out.Len() > 0 && out.Bytes()[out.Len()-1] == ';' {
+					out.Truncate(out.Len() - 1)
+				}
+			} else if space && needsSpace(out.Bytes(), c) {
+				out.WriteByte(' ')
+			}
+			space = false
+			out.WriteByte(c)
+		}
+	}
+	return out.Bytes()
+}
+
+// needsSpace reports whether collapsed whitespace before c has to be kept as a space.
+func needsSpace(out []byte, c byte) bool {
+	if len(out) == 0 {
+		return false
+	}
+	return strings.IndexByte("{};,:", out[len(out)-1]) < 0
+}
+
+// stringEnd returns the index after the string literal starting at i.
+func stringEnd(src []byte, i int) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// minifyJS removes comments and whitespace. Line breaks are kept, one for each run of whitespace with
+// line breaks, so automatic semicolon insertion works as before.
+func minifyJS(src []byte) []byte {
+	var (
+		out bytes.Buffer
+		ws  byte
+	)
+	flushSpace := func(next byte) {
+		prev := byte(0)
+		if out.Len() > 0 {
+			prev = out.Bytes()[out.Len()-1]
+		}
+		switch {
+		case ws == '\n' && prev != 0:
+			out.WriteByte('\n')
+		case ws == ' ' && (identByte(prev) && identByte(next) || (prev == next && (next == '+' || next == '-'))):
+			out.WriteByte(' ')
+		}
+		ws = 0
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			if ws == 0 {
+				ws = ' '
+			}
+		case c == '\n':
+			ws = '\n'
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			ws = '\n'
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return out.Bytes()
+			}
+			if bytes.IndexByte(src[i:i+end+4], '\n') >= 0 {
+				ws = '\n'
+			} else if ws == 0 {
+				ws = ' '
+			}
+			i += end + 3
+		case c == '"' || c == '\'' || c == '`':
+			flushSpace(c)
+			end := stringEnd(src, i)
+			out.Write(src[i:end])
+			i = end - 1
+		case c == '/' && regexAllowed(out.Bytes()):
+			flushSpace(c)
+			end := regexEnd(src, i)
+			out.Write(src[i:end])
+			i = end - 1
+		default:
+			flushSpace(c)
+			out.WriteByte(c)
+		}
+	}
+	return out.Bytes()
+}
+
+func identByte(c byte) bool {
+	return c == '_' || c == '$' || c == '\\' || c >= 0x80 || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// regexAllowed reports whether a / after the output starts a regular expression literal instead of
+// being a division.
+func regexAllowed(out []byte) bool {
+	end := len(bytes.TrimRight(out, " \n"))
+	if end == 0 {
+		return true
+	}
+	if c := out[end-1]; !identByte(c) && c != ')' && c != ']' && c != '}' {
+		return true
+	}
+	start := end
+	for start > 0 && identByte(out[start-1]) {
+		start--
+	}
+	switch string(out[start:end]) {
+	case "return", "typeof", "case", "do", "else", "in", "instanceof", "new", "throw", "void", "delete", "yield", "await":
+		return true
+	}
+	return false
+}
+
+// regexEnd returns the index after the regular expression literal starting at i, without its flags.
+func regexEnd(src []byte, i int) int {
+	class := false
+	for j := i + 1; j < len(src); j++ {
+		switch c := src[j]; {
+		case c == '\\':
+			j++
+		case c == '\n':
+			return j
+		case c == '[':
+			class = true
+		case c == ']':
+			class = false
+		case c == '/' && !class:
+			return j + 1
+		}
+	}
+	return len(src)
+}
@@ -0,0 +1,77 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_minifyHTML(t *testing.T) {
+	src := "<html>\n  <!-- comment -->\n  <!--[if IE]>ie<![endif]-->\n  <p>Hello   <b>world</b></p>\n  <pre>  keep\n    this</pre>\n  <script>\n  var a  =  1;\n  </script>\n</html>\n"
+	expect(t, string(minifyHTML([]byte(src))), "<html>\n<!--[if IE]>ie<![endif]-->\n<p>Hello <b>world</b></p>\n<pre>  keep\n    this</pre>\n<script>\n  var a  =  1;\n  </script>\n</html>\n")
+}
+
+func Test_minifyCSS(t *testing.T) {
+	src := "/* header */\nbody , p {\n  color : red;\n  font-family: \"Open  Sans\";\n}\na :hover { margin: 0 auto; }\n"
+	expect(t, string(minifyCSS([]byte(src))), `body,p{color :red;font-family:"Open  Sans"}a :hover{margin:0 auto}`)
+}
+
+func Test_minifyJS(t *testing.T) {
+	tests := []struct{ in, out string }{
+		{"var a = 1; // one\nvar b = 2;", "var a=1;\nvar b=2;"},
+		{"function f( x ) {\n  /* body */\n  return x / 2;\n}", "function f(x){\nreturn x/2;\n}"},
+		{"var s = 'a  // b', t = `x\n   y`;", "var s='a  // b',t=`x\n   y`;"},
+		{"var r = / +\\/[/ ]/g.test(s);", "var r=/ +\\/[/ ]/g.test(s);"},
+		{"if (a) return /x  y/;", "if(a)return/x  y/;"},
+		{"a = b - -c + +d", "a=b- -c+ +d"},
+		{"a\n++b", "a\n++b"},
+		{"a/**/b", "a b"},
+	}
+	for _, test := range tests {
+		expect(t, string(minifyJS([]byte(test.in))), test.out)
+	}
+}
+
+func Test_minifyJSON(t *testing.T) {
+	expect(t, string(minifyJSON([]byte("{\n  \"a\": [1, 2],\n  \"b\": \"x y\"\n}"))), `{"a":[1,2],"b":"x y"}`)
+	expect(t, string(minifyJSON([]byte("{invalid"))), "{invalid")
+}
+
+func Test_Minify(t *testing.T) {
+	page := "<p>\n    " + strings.Repeat("word    ", 100) + "\n</p>"
+	m := Classic()
+	m.Use(Minify())
+	m.Get("/page", func() string {
+		return page
+	})
+	m.Get("/small", func() string {
+		return "<p>   small   </p>"
+	})
+	m.Get("/text", func(res http.ResponseWriter) string {
+		res.Header().Set("Content-Type", "text/plain")
+		return page
+	})
+	m.Get("/json", func(res http.ResponseWriter) string {
+		res.Header().Set("Content-Type", "application/problem+json")
+		res.Header().Set("Content-Length", "100")
+		return `{"a":    ` + `"` + strings.Repeat("x", 600) + `"}`
+	})
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/page", nil))
+	expect(t, res.Body.String(), "<p>\n"+strings.Repeat("word ", 99)+"word\n</p>")
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/small", nil))
+	expect(t, res.Body.String(), "<p>   small   </p>")
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/text", nil))
+	expect(t, res.Body.String(), page)
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/json", nil))
+	expect(t, strings.HasPrefix(res.Body.String(), `{"a":"xxx`), true)
+	expect(t, res.Header().Get("Content-Length"), "")
+}
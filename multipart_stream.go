@@ -0,0 +1,193 @@
+package martini
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// PartRule restricts the file parts of a form field.
+type PartRule struct {
+	// MaxSize is the maximum size of a part in bytes, 0 for no limit.
+	MaxSize int64
+	// Types are the allowed Content-Types of a part, e.g. "image/png" or "image/*". Empty allows all.
+	Types []string
+	// Inspection checks the content of parts before they are handed to the handler. The parts are
+	// spooled to a temporary file for that. Optional.
+	Inspection *UploadInspection
+}
+
+// PartHandler consumes a file part. r reads the content of the part and fails once it exceeds the
+// MaxSize of the rule.
+type PartHandler func(part *multipart.Part, r io.Reader) error
+
+// MultipartStream parses multipart forms as a stream, handing each file part to the handler of its
+// field as it arrives, so large uploads never have to be held in memory or spooled to disk.
+//
+//	stream := martini.NewMultipartStream().
+//	  Handle("video", martini.PartRule{MaxSize: 2 << 30, Types: []string{"video/*"}},
+//	    martini.StreamToStorage(storage, func(p *multipart.Part) string { return "videos/" + randomName() })).
+//	  OnProgress(func(read, total int64) { ... })
+//	fields, err := stream.Parse(req)
+type MultipartStream struct {
+	// MaxFieldsSize limits the total size of the values of non file fields. Defaults to 1MB.
+	MaxFieldsSize int64
+
+	handlers map[string]PartHandler
+	rules    map[string]PartRule
+	progress func(read int64, total int64)
+}
+
+// NewMultipartStream creates a MultipartStream without part handlers.
+func NewMultipartStream() *MultipartStream {
+	return &MultipartStream{MaxFieldsSize: 1 << 20, handlers: make(map[string]PartHandler), rules: make(map[string]PartRule)}
+}
+
+// Handle registers the handler for the file parts of the field. File parts of fields without handler
+// are skipped.
+func (s *MultipartStream) Handle(field string, rule PartRule, h PartHandler) *MultipartStream {
+	s.handlers[field] = h
+	s.rules[field] = rule
+	return s
+}
+
+// OnProgress registers a function called as the body is read, with the bytes read so far and the
+// Content-Length, -1 if unknown.
+func (s *MultipartStream) OnProgress(fn func(read int64, total int64)) *MultipartStream {
+	s.progress = fn
+	return s
+}
+
+// Parse reads the multipart body of the request, passing the file parts to their handlers. It returns
+// the values of the other fields. Violations of a PartRule are returned as HTTPError with status 413
+// Request Entity Too Large or 415 Unsupported Media Type.
+func (s *MultipartStream) Parse(req *http.Request) (url.Values, error) {
+	if s.progress != nil {
+		req.Body = &progressReader{ReadCloser: req.Body, total: req.ContentLength, fn: s.progress}
+	}
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, HTTPError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	values := url.Values{}
+	fieldsSize := int64(0)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return nil, err
+		}
+		field := part.FormName()
+		if part.FileName() == "" {
+			b, err := ioutil.ReadAll(io.LimitReader(part, s.MaxFieldsSize-fieldsSize+1))
+			if err != nil {
+				return nil, err
+			}
+			if fieldsSize += int64(len(b)); fieldsSize > s.MaxFieldsSize {
+				return nil, HTTPError{Status: http.StatusRequestEntityTooLarge, Message: "form fields too large"}
+			}
+			values.Add(field, string(b))
+			continue
+		}
+
+		h, ok := s.handlers[field]
+		if !ok {
+			continue
+		}
+		rule := s.rules[field]
+		if ctype := part.Header.Get("Content-Type"); !allowedType(ctype, rule.Types) {
+			return nil, HTTPError{Status: http.StatusUnsupportedMediaType, Message: fmt.Sprintf("%s: type %q not allowed", field, ctype)}
+		}
+		var r io.Reader = part
+		if rule.MaxSize > 0 {
+			r = &partLimitReader{r: part, field: field, max: rule.MaxSize, left: rule.MaxSize}
+		}
+		if rule.Inspection != nil {
+			spooled, err := inspectPart(rule.Inspection, r)
+			if err != nil {
+				return nil, err
+			}
+			err = h(part, spooled)
+			spooled.Close()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := h(part, r); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// allowedType reports whether the media type matches one of the types, which may end in /* to match
+// a whole family.
+func allowedType(ctype string, types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+	for _, t := range types {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+type partLimitReader struct {
+	r     io.Reader
+	field string
+	max   int64
+	left  int64
+}
+
+func (l *partLimitReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	if l.left -= int64(n); l.left < 0 {
+		return n + int(l.left), HTTPError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("%s: larger than %d bytes", l.field, l.max)}
+	}
+	return n, err
+}
+
+type progressReader struct {
+	io.ReadCloser
+	read  int64
+	total int64
+	fn    func(int64, int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.fn(atomic.AddInt64(&p.read, int64(n)), p.total)
+	}
+	return n, err
+}
+
+// StreamToStorage returns a PartHandler writing parts to the storage under the key returned by key.
+func StreamToStorage(s Storage, key func(part *multipart.Part) string) PartHandler {
+	return func(part *multipart.Part, r io.Reader) error {
+		k := key(part)
+		if err := s.Put(k, r); err != nil {
+			// don't leave a partial file behind, e.g. when the part was too large
+			s.Delete(k)
+			return err
+		}
+		return nil
+	}
+}
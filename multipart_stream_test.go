@@ -0,0 +1,104 @@
+package martini
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+)
+
+func multipartBody(t *testing.T, fields map[string]string, files map[string][2]string) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	for field, f := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="`+field+`"; filename="`+field+`.bin"`)
+		h.Set("Content-Type", f[0])
+		pw, err := w.CreatePart(h)
+		expect(t, err, nil)
+		pw.Write([]byte(f[1]))
+	}
+	w.Close()
+	return &body, w.FormDataContentType()
+}
+
+func Test_MultipartStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-multipart")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	storage := &LocalStorage{Dir: dir}
+
+	body, ctype := multipartBody(t, map[string]string{"title": "Holiday"}, map[string][2]string{
+		"video": {"video/mp4", strings.Repeat("v", 1000)},
+		"other": {"text/plain", "ignored"},
+	})
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", ctype)
+
+	var progress []int64
+	stream := NewMultipartStream().
+		Handle("video", PartRule{MaxSize: 1000, Types: []string{"video/*"}}, StreamToStorage(storage, func(p *multipart.Part) string {
+			return "videos/" + p.FileName()
+		})).
+		OnProgress(func(read, total int64) {
+			progress = append(progress, read)
+			expect(t, total, req.ContentLength)
+		})
+	fields, err := stream.Parse(req)
+	expect(t, err, nil)
+	expect(t, fields.Get("title"), "Holiday")
+	expect(t, progress[len(progress)-1], req.ContentLength)
+
+	r, err := storage.Get("videos/video.bin")
+	expect(t, err, nil)
+	content, _ := ioutil.ReadAll(r)
+	r.Close()
+	expect(t, len(content), 1000)
+}
+
+func Test_MultipartStream_Rules(t *testing.T) {
+	discard := func(p *multipart.Part, r io.Reader) error {
+		_, err := io.Copy(ioutil.Discard, r)
+		return err
+	}
+	stream := NewMultipartStream().Handle("image", PartRule{MaxSize: 10, Types: []string{"image/png", "image/gif"}}, discard)
+
+	parse := func(ctype string, content string) error {
+		body, formType := multipartBody(t, nil, map[string][2]string{"image": {ctype, content}})
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", formType)
+		_, err := stream.Parse(req)
+		return err
+	}
+
+	expect(t, parse("image/png", "0123456789"), nil)
+	e, ok := asHTTPError(parse("image/png", "0123456789x"))
+	expect(t, ok, true)
+	expect(t, e.Status, http.StatusRequestEntityTooLarge)
+	e, ok = asHTTPError(parse("image/jpeg", "x"))
+	expect(t, ok, true)
+	expect(t, e.Status, http.StatusUnsupportedMediaType)
+
+	stream.MaxFieldsSize = 3
+	body, formType := multipartBody(t, map[string]string{"a": "1234"}, nil)
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", formType)
+	_, err := stream.Parse(req)
+	e, ok = asHTTPError(err)
+	expect(t, e.Status, http.StatusRequestEntityTooLarge)
+
+	req = httptest.NewRequest("POST", "/upload", strings.NewReader("a=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = stream.Parse(req)
+	e, ok = asHTTPError(err)
+	expect(t, e.Status, http.StatusBadRequest)
+}
@@ -0,0 +1,107 @@
+package martini
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the number of near-miss routes listed on a 404 in development.
+const maxSuggestions = 5
+
+// suggestRoutes is the default NotFound handler in development. Besides the 404 it lists the routes
+// with a pattern similar to the path.
+func (r *router) suggestRoutes(res http.ResponseWriter, req *http.Request) {
+	suggestions := r.nearMisses(req.URL.Path)
+	if len(suggestions) == 0 {
+		http.NotFound(res, req)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.WriteHeader(http.StatusNotFound)
+	fmt.Fprintln(res, "404 page not found")
+	fmt.Fprintf(res, "\nNo route matches %s %s, did you mean:\n", req.Method, req.URL.Path)
+	for _, s := range suggestions {
+		fmt.Fprintf(res, "  %s\n", s)
+	}
+}
+
+// nearMisses returns the routes a request for the path most likely meant to reach.
+func (r *router) nearMisses(path string) []string {
+	type candidate struct {
+		route    *route
+		distance int
+	}
+
+	var (
+		suggestions []string
+		candidates  []candidate
+		maxDistance = len(path)/4 + 1
+	)
+	for _, route := range r.index().routes {
+		if _, _, ok := route.matchPath(path); ok {
+			continue
+		}
+		if d := editDistance(path, fillPattern(route.pattern, path)); d <= maxDistance {
+			candidates = append(candidates, candidate{route, d})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	for _, c := range candidates {
+		suggestions = append(suggestions, c.route.String())
+	}
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions
+}
+
+// fillPattern replaces the params of the pattern with the path segments at the same position, so
+// "/user/:id" is compared to "/users/5" as "/user/5".
+func fillPattern(pattern string, path string) string {
+	segments := strings.Split(pattern, "/")
+	values := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") && i < len(values) {
+			segments[i] = values[i]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
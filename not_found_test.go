@@ -0,0 +1,58 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_NotFound_Suggestions(t *testing.T) {
+	r := NewRouter()
+	r.Get("/user/:id", func() {})
+	r.Post("/users/:id", func() {})
+	r.Get("/posts", func() {})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/usr/5", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusNotFound)
+	expect(t, recorder.Body.String(), strings.Join([]string{
+		"404 page not found",
+		"",
+		"No route matches GET /usr/5, did you mean:",
+		"  GET /user/:id",
+		"  POST /users/:id",
+		"",
+	}, "\n"))
+
+	// a route for another method is a 405, not a near miss
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/users/5", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusMethodNotAllowed)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/something/else", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Body.String(), "404 page not found\n")
+}
+
+func Test_NotFound_NoSuggestionsInProduction(t *testing.T) {
+	setENV(Prod)
+	defer setENV(Dev)
+
+	r := NewRouter()
+	r.Post("/users", func() {})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/user", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Body.String(), "404 page not found\n")
+}
+
+func Test_EditDistance(t *testing.T) {
+	expect(t, editDistance("", "abc"), 3)
+	expect(t, editDistance("kitten", "sitting"), 3)
+	expect(t, editDistance("/users", "/users"), 0)
+}
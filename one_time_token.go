@@ -0,0 +1,94 @@
+package martini
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInvalidToken is returned by OneTimeTokens.Consume for unknown, expired, used or foreign tokens.
+var ErrInvalidToken = errors.New("martini: invalid or expired token")
+
+// StoredToken is what a TokenStore keeps for a one-time token.
+type StoredToken struct {
+	// Purpose separates tokens of different features, e.g. "password_reset" or "unsubscribe".
+	Purpose string
+	// Subject is what the token grants access to, usually a user ID.
+	Subject string
+	Expires time.Time
+}
+
+// TokenStore stores one-time tokens by the hash of the token.
+type TokenStore interface {
+	// Put stores the token.
+	Put(hash string, t StoredToken) error
+	// Take removes the token and returns it. It reports false if there is no such token. Taking a token
+	// has to be atomic, so that a token can only be consumed once.
+	Take(hash string) (StoredToken, bool, error)
+}
+
+// OneTimeTokens creates and consumes single use tokens with an expiry, for links like password resets,
+// email verifications and unsubscribes. Only hashes of the tokens are stored.
+//
+//	tokens := &martini.OneTimeTokens{Store: store}
+//	token, _ := tokens.Create("password_reset", user.ID, time.Hour)
+//	...
+//	userID, err := tokens.Consume(req.FormValue("token"), "password_reset")
+type OneTimeTokens struct {
+	Store TokenStore
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create creates a token for the purpose and subject, valid for ttl.
+func (o *OneTimeTokens) Create(purpose string, subject string, ttl time.Duration) (string, error) {
+	token := randomToken(32)
+	err := o.Store.Put(tokenHash(token), StoredToken{purpose, subject, time.Now().Add(ttl)})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Consume verifies the token was created for the purpose and hasn't expired, and returns its subject. The
+// token can't be used again afterwards, not even when it was presented for the wrong purpose.
+func (o *OneTimeTokens) Consume(token string, purpose string) (string, error) {
+	t, ok, err := o.Store.Take(tokenHash(token))
+	if err != nil {
+		return "", err
+	}
+	if !ok || t.Purpose != purpose || time.Now().After(t.Expires) {
+		return "", ErrInvalidToken
+	}
+	return t.Subject, nil
+}
+
+// NewMemoryTokenStore creates a TokenStore keeping tokens in memory.
+func NewMemoryTokenStore() TokenStore {
+	return &memoryTokenStore{tokens: make(map[string]StoredToken)}
+}
+
+type memoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]StoredToken
+}
+
+func (m *memoryTokenStore) Put(hash string, t StoredToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[hash] = t
+	return nil
+}
+
+func (m *memoryTokenStore) Take(hash string) (StoredToken, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokens[hash]
+	delete(m.tokens, hash)
+	return t, ok, nil
+}
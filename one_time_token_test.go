@@ -0,0 +1,32 @@
+package martini
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_OneTimeTokens(t *testing.T) {
+	tokens := &OneTimeTokens{Store: NewMemoryTokenStore()}
+
+	token, err := tokens.Create("password_reset", "jane", time.Hour)
+	expect(t, err, nil)
+	expect(t, len(token), 64)
+
+	subject, err := tokens.Consume(token, "password_reset")
+	expect(t, err, nil)
+	expect(t, subject, "jane")
+
+	// tokens are single use
+	_, err = tokens.Consume(token, "password_reset")
+	expect(t, err, ErrInvalidToken)
+
+	token, _ = tokens.Create("unsubscribe", "jane", time.Hour)
+	_, err = tokens.Consume(token, "password_reset")
+	expect(t, err, ErrInvalidToken)
+	_, err = tokens.Consume(token, "unsubscribe")
+	expect(t, err, ErrInvalidToken)
+
+	token, _ = tokens.Create("verify_email", "jane", -time.Second)
+	_, err = tokens.Consume(token, "verify_email")
+	expect(t, err, ErrInvalidToken)
+}
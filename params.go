@@ -0,0 +1,114 @@
+package martini
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrParamMissing is wrapped by the errors of the Params accessors for params the route doesn't have.
+var ErrParamMissing = errors.New("martini: param missing")
+
+// ParamError is returned by the Params accessors when a param is missing or can't be parsed.
+type ParamError struct {
+	Name  string
+	Value string
+	// Type is the type the value was parsed as, e.g. "int".
+	Type string
+	Err  error
+}
+
+func (e *ParamError) Error() string {
+	if e.Err == ErrParamMissing {
+		return fmt.Sprintf("martini: param %q is missing", e.Name)
+	}
+	return fmt.Sprintf("martini: param %q is not a valid %s: %q", e.Name, e.Type, e.Value)
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// lookup returns the value of the param or a ParamError if it is missing.
+func (p Params) lookup(name string, typ string) (string, error) {
+	value, ok := p[name]
+	if !ok {
+		return "", &ParamError{Name: name, Type: typ, Err: ErrParamMissing}
+	}
+	return value, nil
+}
+
+// Int returns the param parsed as an int.
+func (p Params) Int(name string) (int, error) {
+	i, err := p.parseInt(name, "int", 0)
+	return int(i), err
+}
+
+// Int64 returns the param parsed as an int64.
+func (p Params) Int64(name string) (int64, error) {
+	return p.parseInt(name, "int64", 64)
+}
+
+func (p Params) parseInt(name string, typ string, bitSize int) (int64, error) {
+	value, err := p.lookup(name, typ)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(value, 10, bitSize)
+	if err != nil {
+		return 0, &ParamError{name, value, typ, err}
+	}
+	return i, nil
+}
+
+// Float64 returns the param parsed as a float64.
+func (p Params) Float64(name string) (float64, error) {
+	value, err := p.lookup(name, "float64")
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, &ParamError{name, value, "float64", err}
+	}
+	return f, nil
+}
+
+// Bool returns the param parsed as a bool, accepting the values strconv.ParseBool does.
+func (p Params) Bool(name string) (bool, error) {
+	value, err := p.lookup(name, "bool")
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, &ParamError{name, value, "bool", err}
+	}
+	return b, nil
+}
+
+// UUID is a UUID in binary form. String formats it canonically, e.g. "0b7bd4a4-5c8a-4e36-8d8e-0fb7b0b3c8b6".
+type UUID [16]byte
+
+func (u UUID) String() string {
+	s := hex.EncodeToString(u[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// UUID returns the param parsed as a UUID in the canonical hyphenated form, regardless of case.
+func (p Params) UUID(name string) (UUID, error) {
+	var u UUID
+	value, err := p.lookup(name, "UUID")
+	if err != nil {
+		return u, err
+	}
+	if len(value) != 36 || value[8] != '-' || value[13] != '-' || value[18] != '-' || value[23] != '-' {
+		return u, &ParamError{name, value, "UUID", errors.New("invalid UUID format")}
+	}
+	if _, err := hex.Decode(u[:], []byte(strings.Replace(value, "-", "", -1))); err != nil {
+		return UUID{}, &ParamError{name, value, "UUID", err}
+	}
+	return u, nil
+}
@@ -0,0 +1,51 @@
+package martini
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func Test_ParamsAccessors(t *testing.T) {
+	params := Params{"id": "42", "big": "9000000000", "price": "9.95", "draft": "true", "name": "john",
+		"uuid": "0B7BD4A4-5c8a-4e36-8d8e-0fb7b0b3c8b6"}
+
+	i, err := params.Int("id")
+	expect(t, err, nil)
+	expect(t, i, 42)
+	i64, err := params.Int64("big")
+	expect(t, err, nil)
+	expect(t, i64, int64(9000000000))
+	f, err := params.Float64("price")
+	expect(t, err, nil)
+	expect(t, f, 9.95)
+	b, err := params.Bool("draft")
+	expect(t, err, nil)
+	expect(t, b, true)
+	u, err := params.UUID("uuid")
+	expect(t, err, nil)
+	expect(t, u.String(), "0b7bd4a4-5c8a-4e36-8d8e-0fb7b0b3c8b6")
+	expect(t, u[0], byte(0x0b))
+
+	_, err = params.Int("name")
+	expect(t, err.Error(), `martini: param "name" is not a valid int: "john"`)
+	expect(t, errors.Is(err, strconv.ErrSyntax), true)
+	var paramErr *ParamError
+	expect(t, errors.As(err, &paramErr), true)
+	expect(t, paramErr.Name, "name")
+
+	_, err = params.Bool("name")
+	expect(t, err.Error(), `martini: param "name" is not a valid bool: "john"`)
+	_, err = params.Float64("name")
+	expect(t, err.Error(), `martini: param "name" is not a valid float64: "john"`)
+	_, err = params.UUID("name")
+	expect(t, err.Error(), `martini: param "name" is not a valid UUID: "john"`)
+	_, err = params.UUID("price")
+	refute(t, err, nil)
+	_, err = Params{"u": "0b7bd4a4-5c8a-4e36-8d8e-0fb7b0b3c8bz"}.UUID("u")
+	refute(t, err, nil)
+
+	_, err = params.Int64("missing")
+	expect(t, err.Error(), `martini: param "missing" is missing`)
+	expect(t, errors.Is(err, ErrParamMissing), true)
+}
@@ -0,0 +1,95 @@
+package martini
+
+import (
+	"fmt"
+	"html/template"
+)
+
+// Plugin bundles middleware, routes, template functions and services into a unit a package can ship,
+// installed with Martini.Install. Register sets the plugin up on the Martini instance:
+//
+//	type Auth struct{ Users UserStore }
+//
+//	func (a Auth) Name() string { return "auth" }
+//
+//	func (a Auth) Register(m *martini.Martini) error {
+//	  m.Map(a.Users)
+//	  m.Use(martini.Middleware{Name: "auth", Handler: a.authenticate, After: []string{"logger"}})
+//	  m.TemplateFuncs(template.FuncMap{"current_user": currentUser})
+//	  return nil
+//	}
+//
+// A plugin can implement Name() string to be referred to by other plugins, which defaults to its type,
+// and Requires() []string to name the plugins that have to be installed before it.
+type Plugin interface {
+	Register(m *Martini) error
+}
+
+// PluginFunc adapts a function to a Plugin.
+type PluginFunc func(m *Martini) error
+
+// Register calls f(m).
+func (f PluginFunc) Register(m *Martini) error {
+	return f(m)
+}
+
+// Install registers the plugins in order. It returns an error, without registering the remaining
+// plugins, if a plugin is already installed, requires a plugin that isn't installed before it or fails
+// to register.
+func (m *Martini) Install(plugins ...Plugin) error {
+	for _, p := range plugins {
+		name := pluginName(p)
+		if containsString(m.plugins, name) {
+			return fmt.Errorf("martini: plugin %s is already installed", name)
+		}
+		if r, ok := p.(interface{ Requires() []string }); ok {
+			for _, required := range r.Requires() {
+				if !containsString(m.plugins, required) {
+					return fmt.Errorf("martini: plugin %s requires plugin %s, which has to be installed first", name, required)
+				}
+			}
+		}
+		if err := p.Register(m); err != nil {
+			return fmt.Errorf("martini: installing plugin %s: %w", name, err)
+		}
+		m.plugins = append(m.plugins, name)
+	}
+	return nil
+}
+
+// Plugins returns the names of the installed plugins in the order they were installed.
+func (m *Martini) Plugins() []string {
+	return append([]string(nil), m.plugins...)
+}
+
+func pluginName(p Plugin) string {
+	if n, ok := p.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+// TemplateFuncs adds functions for the templates created with Martini.Templates, so plugins can provide
+// template helpers.
+func (m *Martini) TemplateFuncs(funcs template.FuncMap) {
+	if m.templateFuncs == nil {
+		m.templateFuncs = make(template.FuncMap)
+	}
+	for name, f := range funcs {
+		m.templateFuncs[name] = f
+	}
+}
+
+// Templates is like NewTemplates but the templates can use the functions added with TemplateFuncs as
+// well. Functions in opts.Funcs take precedence.
+func (m *Martini) Templates(opts TemplateOptions) (*Templates, error) {
+	funcs := make(template.FuncMap)
+	for name, f := range m.templateFuncs {
+		funcs[name] = f
+	}
+	for name, f := range opts.Funcs {
+		funcs[name] = f
+	}
+	opts.Funcs = funcs
+	return NewTemplates(opts)
+}
@@ -0,0 +1,66 @@
+package martini
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+type greeterPlugin struct {
+	greeting string
+}
+
+func (p greeterPlugin) Name() string {
+	return "greeter"
+}
+
+func (p greeterPlugin) Register(m *Martini) error {
+	m.Map(p)
+	m.Use(Middleware{Name: "greeter", Handler: func(res http.ResponseWriter) {
+		res.Header().Set("X-Greeting", p.greeting)
+	}})
+	m.TemplateFuncs(template.FuncMap{"greet": func(name string) string { return p.greeting + " " + name }})
+	return nil
+}
+
+type shoutPlugin struct{}
+
+func (shoutPlugin) Requires() []string {
+	return []string{"greeter"}
+}
+
+func (shoutPlugin) Register(m *Martini) error {
+	m.TemplateFuncs(template.FuncMap{"shout": strings.ToUpper})
+	return nil
+}
+
+func Test_Install(t *testing.T) {
+	m := Classic()
+	expect(t, m.Install(shoutPlugin{}).Error(), "martini: plugin martini.shoutPlugin requires plugin greeter, which has to be installed first")
+	expect(t, m.Install(greeterPlugin{"Hello"}, shoutPlugin{}), nil)
+	expect(t, strings.Join(m.Plugins(), ","), "greeter,martini.shoutPlugin")
+	expect(t, m.Install(greeterPlugin{"Hi"}).Error(), "martini: plugin greeter is already installed")
+
+	failure := errors.New("no database")
+	err := m.Install(PluginFunc(func(m *Martini) error { return failure }))
+	expect(t, errors.Is(err, failure), true)
+
+	m.Get("/", func(p greeterPlugin) string { return p.greeting })
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Body.String(), "Hello")
+	expect(t, recorder.Header().Get("X-Greeting"), "Hello")
+
+	dir := writeTemplates(t, map[string]string{"page.tmpl": `{{shout (greet .)}}`})
+	defer os.RemoveAll(dir)
+	tmpl, err := m.Templates(TemplateOptions{Dir: dir})
+	expect(t, err, nil)
+	out, err := tmpl.Render("page", "martini")
+	expect(t, err, nil)
+	expect(t, string(out), "HELLO MARTINI")
+}
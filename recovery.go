@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"reflect"
 	"runtime"
 
 	"github.com/codegangsta/inject"
@@ -112,22 +113,48 @@ func function(pc uintptr) []byte {
 
 // Recovery returns a middleware that recovers from any panics and writes a 500 if there was one.
 // While Martini is in development mode, Recovery will also output the panic as HTML.
+// Panics with a HTTPError are answered with its status and message instead.
+// Use it together with BufferResponse so panics that happen after a handler started writing
+// still produce a clean error response.
 func Recovery() Handler {
 	return func(c Context, log *log.Logger) {
 		defer func() {
 			if err := recover(); err != nil {
-				stack := stack(3)
-				log.Printf("PANIC: %s\n%s", err, stack)
+				httpErr, isHTTPErr := asHTTPError(err)
+				var trace []byte
+				if !isHTTPErr {
+					trace = stack(3)
+					log.Printf("PANIC: %s\n%s", err, trace)
+					if events, ok := c.Get(reflect.TypeOf((*Events)(nil))).Interface().(*Events); ok {
+						req, _ := c.Get(reflect.TypeOf((*http.Request)(nil))).Interface().(*http.Request)
+						events.Publish(Event{Kind: EventPanic, Request: req, Panic: err})
+					}
+				}
 
 				// Lookup the current responsewriter
 				val := c.Get(inject.InterfaceOf((*http.ResponseWriter)(nil)))
 				res := val.Interface().(http.ResponseWriter)
 
+				// discard whatever has been buffered so far. If parts of the response
+				// already reached the client there is no clean way to report the error.
+				if bw, ok := res.(BufferedResponseWriter); ok {
+					if !bw.Reset() {
+						return
+					}
+					defer bw.Commit()
+				}
+
+				if isHTTPErr {
+					req, _ := c.Get(reflect.TypeOf((*http.Request)(nil))).Interface().(*http.Request)
+					writeHTTPError(res, req, httpErr)
+					return
+				}
+
 				// respond with panic message while in development mode
 				var body []byte
 				if Env == Dev {
 					res.Header().Set("Content-Type", "text/html")
-					body = []byte(fmt.Sprintf(panicHtml, err, err, stack))
+					body = []byte(fmt.Sprintf(panicHtml, err, err, trace))
 				}
 
 				res.WriteHeader(http.StatusInternalServerError)
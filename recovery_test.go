@@ -47,3 +47,24 @@ func Test_Recovery_ResponseWriter(t *testing.T) {
 	expect(t, recorder2.HeaderMap.Get("Content-Type"), "text/html")
 	refute(t, recorder2.Body.Len(), 0)
 }
+
+func Test_Recovery_Buffered(t *testing.T) {
+	recorder := httptest.NewRecorder()
+
+	setENV(Prod)
+	defer setENV(Dev)
+	m := New()
+	m.Map(log.New(bytes.NewBufferString(""), "[martini] ", 0))
+	m.Use(Recovery())
+	m.Use(BufferResponse())
+	m.Use(func(res http.ResponseWriter) {
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"partial":`))
+		panic("here is a panic!")
+	})
+	m.ServeHTTP(recorder, (*http.Request)(nil))
+
+	expect(t, recorder.Code, http.StatusInternalServerError)
+	expect(t, recorder.HeaderMap.Get("Content-Type"), "")
+	expect(t, recorder.Body.Len(), 0)
+}
@@ -0,0 +1,225 @@
+package martini
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RememberToken is the server side half of a persistent login token. The cookie holds the selector,
+// used to look the token up, and the verifier, of which only a hash is stored.
+type RememberToken struct {
+	Selector     string
+	VerifierHash []byte
+	UserID       string
+	Expires      time.Time
+	// PreviousHash is the hash of the verifier replaced at Rotated. It stays valid for the rotation grace
+	// period, for the requests a browser sent in parallel with the old cookie.
+	PreviousHash []byte
+	Rotated      time.Time
+}
+
+// RememberStore stores persistent login tokens.
+type RememberStore interface {
+	// Get returns the token with the given selector, or nil if it doesn't exist.
+	Get(selector string) (*RememberToken, error)
+	// Save stores the token.
+	Save(t *RememberToken) error
+	// Delete deletes the token with the given selector.
+	Delete(selector string) error
+	// DeleteUser deletes all tokens of the user, e.g. on logout everywhere or when a token was stolen.
+	DeleteUser(userID string) error
+}
+
+// RememberOptions configures the RememberMe middleware.
+type RememberOptions struct {
+	// CookieName is the name of the cookie. Defaults to "martini_remember".
+	CookieName string
+	// Path is the path of the cookie. Defaults to "/".
+	Path string
+	// Domain is the domain of the cookie.
+	Domain string
+	// Secure restricts the cookie to HTTPS.
+	Secure bool
+	// TTL is how long a token stays valid. Defaults to 30 days.
+	TTL time.Duration
+	// RotationGrace is how long the verifier replaced by a rotation stays valid. Defaults to a minute.
+	RotationGrace time.Duration
+}
+
+// Remember is mapped by RememberMe for every request to issue and revoke persistent logins.
+type Remember struct {
+	store RememberStore
+	opts  RememberOptions
+	res   http.ResponseWriter
+	token *RememberToken
+}
+
+// Remember issues a persistent login for the user, replacing the current one of the client.
+func (r *Remember) Remember(userID string) error {
+	if err := r.Forget(); err != nil {
+		return err
+	}
+	return r.issue(&RememberToken{Selector: randomToken(12), UserID: userID})
+}
+
+// Forget revokes the persistent login of the client, if it has one.
+func (r *Remember) Forget() error {
+	if r.token == nil {
+		return nil
+	}
+	if err := r.store.Delete(r.token.Selector); err != nil {
+		return err
+	}
+	r.token = nil
+	r.setCookie("", -1, time.Time{})
+	return nil
+}
+
+// issue gives the token a new verifier and expiry, stores it and sends the cookie.
+func (r *Remember) issue(t *RememberToken) error {
+	verifier := randomToken(32)
+	hash := sha256.Sum256([]byte(verifier))
+	if t.VerifierHash != nil {
+		t.PreviousHash, t.Rotated = t.VerifierHash, time.Now()
+	}
+	t.VerifierHash = hash[:]
+	t.Expires = time.Now().Add(r.opts.TTL)
+	if err := r.store.Save(t); err != nil {
+		return err
+	}
+	r.token = t
+	r.setCookie(t.Selector+":"+verifier, 0, t.Expires)
+	return nil
+}
+
+func (r *Remember) setCookie(value string, maxAge int, expires time.Time) {
+	http.SetCookie(r.res, &http.Cookie{
+		Name: r.opts.CookieName, Value: value, Path: r.opts.Path, Domain: r.opts.Domain,
+		Secure: r.opts.Secure, HttpOnly: true, SameSite: http.SameSiteLaxMode, MaxAge: maxAge, Expires: expires,
+	})
+}
+
+// RememberMe returns a middleware handler for persistent logins, add it after Sessions. When the session
+// isn't logged in but the client sends a valid token cookie, the session is logged in as the token's user
+// and the token's verifier is rotated. The replaced verifier still logs in during the RotationGrace, as
+// browsers send several requests with the old cookie at once. Otherwise a known selector with a wrong
+// verifier means a token has been stolen and used, all tokens of the user are revoked then. Handlers issue
+// and revoke tokens through the mapped *Remember.
+//
+//	m.Post("/login", func(s *martini.Session, r *martini.Remember, req *http.Request) {
+//	  ...
+//	  s.Login(user.ID)
+//	  if req.FormValue("remember") != "" {
+//	    r.Remember(user.ID)
+//	  }
+//	})
+func RememberMe(store RememberStore, opts RememberOptions) Handler {
+	if opts.CookieName == "" {
+		opts.CookieName = "martini_remember"
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.TTL == 0 {
+		opts.TTL = 30 * 24 * time.Hour
+	}
+	if opts.RotationGrace == 0 {
+		opts.RotationGrace = time.Minute
+	}
+
+	return func(c Context, s *Session, res http.ResponseWriter, req *http.Request) {
+		r := &Remember{store: store, opts: opts, res: res}
+		c.Map(r)
+
+		cookie, err := req.Cookie(opts.CookieName)
+		if err != nil {
+			return
+		}
+		parts := strings.SplitN(cookie.Value, ":", 2)
+		if len(parts) != 2 {
+			r.setCookie("", -1, time.Time{})
+			return
+		}
+		t, err := store.Get(parts[0])
+		if err != nil {
+			panic(err)
+		}
+		if t == nil || time.Now().After(t.Expires) {
+			r.setCookie("", -1, time.Time{})
+			return
+		}
+		hash := sha256.Sum256([]byte(parts[1]))
+		if len(t.PreviousHash) > 0 && subtle.ConstantTimeCompare(hash[:], t.PreviousHash) == 1 && time.Since(t.Rotated) < opts.RotationGrace {
+			// a request sent along the one that rotated the token, which already sent the new cookie
+			r.token = t
+			if s.UserID == "" {
+				s.Login(t.UserID)
+			}
+			return
+		}
+		if subtle.ConstantTimeCompare(hash[:], t.VerifierHash) != 1 {
+			if err := store.DeleteUser(t.UserID); err != nil {
+				panic(err)
+			}
+			r.setCookie("", -1, time.Time{})
+			return
+		}
+
+		r.token = t
+		if s.UserID == "" {
+			s.Login(t.UserID)
+			if err := r.issue(t); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// NewMemoryRememberStore creates a RememberStore keeping tokens in memory, for development and tests.
+func NewMemoryRememberStore() RememberStore {
+	return &memoryRememberStore{tokens: make(map[string]RememberToken)}
+}
+
+type memoryRememberStore struct {
+	mu     sync.Mutex
+	tokens map[string]RememberToken
+}
+
+func (m *memoryRememberStore) Get(selector string) (*RememberToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokens[selector]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (m *memoryRememberStore) Save(t *RememberToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[t.Selector] = *t
+	return nil
+}
+
+func (m *memoryRememberStore) Delete(selector string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tokens, selector)
+	return nil
+}
+
+func (m *memoryRememberStore) DeleteUser(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for selector, t := range m.tokens {
+		if t.UserID == userID {
+			delete(m.tokens, selector)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func rememberRequest(m http.Handler, path string, cookies ...*http.Cookie) map[string]*http.Cookie {
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	for _, c := range cookies {
+		if c != nil {
+			req.AddCookie(c)
+		}
+	}
+	m.ServeHTTP(recorder, req)
+	result := make(map[string]*http.Cookie)
+	for _, c := range recorder.Result().Cookies() {
+		result[c.Name] = c
+	}
+	return result
+}
+
+func Test_RememberMe(t *testing.T) {
+	store := NewMemoryRememberStore()
+	m := Classic()
+	m.Use(Sessions(NewMemorySessionStore(), SessionOptions{}))
+	m.Use(RememberMe(store, RememberOptions{}))
+	m.Get("/login", func(s *Session, r *Remember) {
+		s.Login("jane")
+		r.Remember("jane")
+	})
+	m.Get("/whoami", func(s *Session) string { return s.UserID })
+	m.Get("/logout", func(s *Session, r *Remember) {
+		s.Destroy()
+		r.Forget()
+	})
+
+	token := rememberRequest(m, "/login")["martini_remember"]
+	expect(t, token.HttpOnly, true)
+
+	// a new browser session is logged in by the token, which is rotated
+	cookies := rememberRequest(m, "/whoami", token)
+	s := cookies["martini_session"]
+	rotated := cookies["martini_remember"]
+	refute(t, s, (*http.Cookie)(nil))
+	refute(t, rotated.Value, token.Value)
+
+	// requests sent in parallel with the old cookie are logged in without another rotation
+	cookies = rememberRequest(m, "/whoami", token)
+	refute(t, cookies["martini_session"], (*http.Cookie)(nil))
+	expect(t, cookies["martini_remember"] == nil, true)
+	stored, _ := store.Get(rotated.Value[:24])
+	refute(t, stored, (*RememberToken)(nil))
+
+	// reusing the old verifier after the grace period revokes every token of the user
+	stored.Rotated = stored.Rotated.Add(-time.Hour)
+	store.Save(stored)
+	expect(t, rememberRequest(m, "/whoami", token)["martini_remember"].MaxAge, -1)
+	stored, _ = store.Get(rotated.Value[:24])
+	expect(t, stored == nil, true)
+}
+
+func Test_RememberMe_WrongVerifier(t *testing.T) {
+	store := NewMemoryRememberStore()
+	m := Classic()
+	m.Use(Sessions(NewMemorySessionStore(), SessionOptions{}))
+	m.Use(RememberMe(store, RememberOptions{}))
+	m.Get("/login", func(r *Remember) { r.Remember("jane") })
+	m.Get("/whoami", func(s *Session) string { return s.UserID })
+
+	token := rememberRequest(m, "/login")["martini_remember"]
+	rotated := rememberRequest(m, "/whoami", token)["martini_remember"]
+
+	forged := &http.Cookie{Name: token.Name, Value: token.Value[:25] + "forged"}
+	expect(t, rememberRequest(m, "/whoami", forged)["martini_remember"].MaxAge, -1)
+	stored, _ := store.Get(rotated.Value[:24])
+	expect(t, stored == nil, true)
+}
+
+func Test_RememberMe_Forget(t *testing.T) {
+	store := NewMemoryRememberStore()
+	m := Classic()
+	m.Use(Sessions(NewMemorySessionStore(), SessionOptions{}))
+	m.Use(RememberMe(store, RememberOptions{}))
+	m.Get("/login", func(r *Remember) { r.Remember("jane") })
+	m.Get("/logout", func(r *Remember) { r.Forget() })
+
+	token := rememberRequest(m, "/login")["martini_remember"]
+	session := rememberRequest(m, "/logout", token)
+	expect(t, session["martini_remember"].MaxAge, -1)
+	stored, _ := store.Get(token.Value[:24])
+	expect(t, stored == nil, true)
+}
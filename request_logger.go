@@ -0,0 +1,88 @@
+package martini
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RequestLogger is a request scoped logger whose lines end with the fields bound to the request, so lines
+// logged while handling the same request can be correlated. Logger maps a *RequestLogger bound to the
+// request ID, the router binds the matched route. Bind anything else, like the principal, once it is known.
+type RequestLogger struct {
+	logger *log.Logger
+
+	mu     sync.Mutex
+	keys   []string
+	values map[string]string
+}
+
+// NewRequestLogger creates a RequestLogger writing to the given logger.
+func NewRequestLogger(logger *log.Logger) *RequestLogger {
+	return &RequestLogger{logger: logger, values: make(map[string]string)}
+}
+
+// Bind binds a field to the logger, replacing the value of a field bound before with the same key.
+func (l *RequestLogger) Bind(key string, value string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.values[key]; !ok {
+		l.keys = append(l.keys, key)
+	}
+	l.values[key] = value
+}
+
+// Field returns the value bound to the key.
+func (l *RequestLogger) Field(key string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.values[key]
+}
+
+// Printf logs a line followed by the bound fields.
+func (l *RequestLogger) Printf(format string, v ...interface{}) {
+	l.logger.Print(fmt.Sprintf(format, v...) + l.fields())
+}
+
+// Println logs its operands followed by the bound fields.
+func (l *RequestLogger) Println(v ...interface{}) {
+	l.logger.Print(strings.TrimSuffix(fmt.Sprintln(v...), "\n") + l.fields())
+}
+
+// fields formats the bound fields as " key=value" pairs, quoting values that need it.
+func (l *RequestLogger) fields() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var b strings.Builder
+	for _, key := range l.keys {
+		value := l.values[key]
+		if needsQuoting(value) {
+			value = strconv.Quote(value)
+		}
+		b.WriteString(" " + key + "=" + value)
+	}
+	return b.String()
+}
+
+// needsQuoting reports whether the value has to be quoted to stay a single field on a single line.
+func needsQuoting(value string) bool {
+	if value == "" || !utf8.ValidString(value) {
+		return true
+	}
+	return strings.IndexFunc(value, func(r rune) bool {
+		return r == ' ' || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) >= 0
+}
+
+// requestID returns the X-Request-Id sent by the client or a proxy, or a new random one.
+func requestID(req *http.Request) string {
+	if id := req.Header.Get("X-Request-Id"); id != "" && len(id) <= 128 && strconv.CanBackquote(id) {
+		return id
+	}
+	return randomToken(16)
+}
@@ -0,0 +1,55 @@
+package martini
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_RequestLogger(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	m := Classic()
+	m.Map(log.New(buff, "[martini] ", 0))
+	m.Get("/users/:id", func(l *RequestLogger) {
+		l.Bind("principal", "jane doe")
+		l.Printf("loading user %d", 5)
+	})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/5", nil)
+	req.Header.Set("X-Request-Id", "abc123")
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Header().Get("X-Request-Id"), "abc123")
+
+	lines := bytes.Split(bytes.TrimSpace(buff.Bytes()), []byte("\n"))
+	expect(t, len(lines), 3)
+	expect(t, string(lines[0]), "[martini] Started GET /users/5 request_id=abc123")
+	expect(t, string(lines[1]), `[martini] loading user 5 request_id=abc123 route="GET /users/:id" principal="jane doe"`)
+	expect(t, bytes.HasSuffix(lines[2], []byte(` request_id=abc123 route="GET /users/:id" principal="jane doe"`)), true)
+}
+
+func Test_RequestID(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	expect(t, len(requestID(req)), 32)
+	refute(t, requestID(req), requestID(req))
+
+	req.Header.Set("X-Request-Id", "bad\nid")
+	expect(t, len(requestID(req)), 32)
+}
+
+func Test_RequestLogger_Quoting(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	l := NewRequestLogger(log.New(buff, "", 0))
+	l.Bind("user", "jane\n[martini] forged line")
+	l.Bind("tab", "a\tb")
+	l.Bind("cr", "a\rb")
+	l.Bind("nel", "a\u0085b")
+	l.Bind("invalid", "a\xffb")
+	l.Bind("plain", "jane")
+	l.Printf("hello")
+
+	expect(t, bytes.Count(buff.Bytes(), []byte("\n")), 1)
+	expect(t, buff.String(), `hello user="jane\n[martini] forged line" tab="a\tb" cr="a\rb" nel="a\u0085b" invalid="a\xffb" plain=jane`+"\n")
+}
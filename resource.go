@@ -0,0 +1,218 @@
+package martini
+
+import (
+	"path"
+	"reflect"
+	"strings"
+)
+
+// controllerAction describes a controller method and the route it is mapped to.
+type controllerAction struct {
+	method string // controller method
+	verbs  []string
+	member bool // route on /pattern/:id instead of /pattern
+	suffix string
+}
+
+// controllerActions are the actions discovered on controllers, in registration order.
+var controllerActions = []controllerAction{
+	{"Index", []string{"GET"}, false, ""},
+	{"New", []string{"GET"}, false, "/new"},
+	{"Create", []string{"POST"}, false, ""},
+	{"Show", []string{"GET"}, true, ""},
+	{"Edit", []string{"GET"}, true, "/edit"},
+	{"Update", []string{"PUT", "PATCH"}, true, ""},
+	{"Destroy", []string{"DELETE"}, true, ""},
+}
+
+// Filter is a handler run around the actions of a controller mapped with MapResource. Only and Except
+// restrict the filter to, or exclude it from, the named actions (e.g. "Show").
+type Filter struct {
+	Handler Handler
+	Only    []string
+	Except  []string
+}
+
+// BeforeFilterer is implemented by controllers with filters that run before their actions. A filter
+// writing a response stops the action from running, e.g. to reject unauthorized requests.
+type BeforeFilterer interface {
+	BeforeAction() []Filter
+}
+
+// AfterFilterer is implemented by controllers with filters that run after their actions, even if
+// the action has written the response.
+type AfterFilterer interface {
+	AfterAction() []Filter
+}
+
+func (f Filter) appliesTo(action string) bool {
+	if len(f.Only) > 0 && !containsFold(f.Only, action) {
+		return false
+	}
+	return !containsFold(f.Except, action)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// actionHandlers returns the handlers of a controller action including the controller's filters.
+func actionHandlers(controller interface{}, action string, handler Handler) []Handler {
+	var handlers []Handler
+	if c, ok := controller.(BeforeFilterer); ok {
+		for _, f := range c.BeforeAction() {
+			if f.appliesTo(action) {
+				validateHandler(f.Handler)
+				handlers = append(handlers, f.Handler)
+			}
+		}
+	}
+
+	if c, ok := controller.(AfterFilterer); ok {
+		var after []Handler
+		for _, f := range c.AfterAction() {
+			if f.appliesTo(action) {
+				validateHandler(f.Handler)
+				after = append(after, f.Handler)
+			}
+		}
+		if len(after) > 0 {
+			handlers = append(handlers, func(c Context) {
+				c.Next()
+				for _, h := range after {
+					if _, err := invoke(c, h); err != nil {
+						panic(err)
+					}
+				}
+			})
+		}
+	}
+
+	return append(handlers, handler)
+}
+
+// Nested describes a resource nested within another one, see MapResource.
+type Nested struct {
+	// Pattern of the nested resource relative to the parent member, e.g. "/posts".
+	Pattern    string
+	Controller interface{}
+	// Shallow maps the member routes (Show, Edit, Update and Destroy) without the parent, e.g.
+	// /posts/:id instead of /users/:user_id/posts/:id.
+	Shallow bool
+	Nested  []Nested
+}
+
+// MapResource adds the RESTful routes of a controller to the router. The controller is any value with
+// some of the following methods, each of which is used as a handler with the usual dependency injection:
+//
+//	Index    GET    /users
+//	New      GET    /users/new
+//	Create   POST   /users
+//	Show     GET    /users/:id
+//	Edit     GET    /users/:id/edit
+//	Update   PUT    /users/:id (and PATCH)
+//	Destroy  DELETE /users/:id
+//
+// Routes are named after the last segment of the pattern and the action, e.g. "users.show", and methods
+// that are not implemented are answered with a 405 Method Not Allowed. Controllers implementing
+// BeforeFilterer or AfterFilterer have their filters run around the actions.
+//
+// Nested resources are mapped below the parent's member path with the parent id as a param named after
+// the parent, e.g. /users/:user_id/posts/:id, and their routes are named "users.posts.show". If the
+// parent controller has a Load method, it is used as a loader (see martini.Load) for the parent id, so
+// nested handlers can take the parent object as an argument. Loaders of all ancestors run in order.
+func (r *router) MapResource(pattern string, controller interface{}, nested ...Nested) {
+	r.mapResource(resourceScope{}, Nested{Pattern: pattern, Controller: controller, Nested: nested})
+}
+
+// resourceScope is the part of a nested resource inherited from its parents.
+type resourceScope struct {
+	prefix  string
+	name    string
+	loaders []Handler
+}
+
+func (r *router) mapResource(scope resourceScope, res Nested) {
+	pattern := strings.TrimRight(res.Pattern, "/")
+	name := path.Base(pattern)
+	value := reflect.ValueOf(res.Controller)
+
+	collectionPattern, collectionName := scope.prefix+pattern, scope.name+name
+	memberPattern, memberName, memberLoaders := collectionPattern+"/:id", collectionName, scope.loaders
+	if res.Shallow {
+		memberPattern, memberName, memberLoaders = pattern+"/:id", name, nil
+	}
+
+	collection, member := Methods{}, Methods{}
+	names := map[bool]map[string]string{false: {}, true: {}}
+	for _, action := range controllerActions {
+		m := value.MethodByName(action.method)
+		if !m.IsValid() {
+			continue
+		}
+		p, routeName, loaders := collectionPattern, collectionName, scope.loaders
+		if action.member {
+			p, routeName, loaders = memberPattern, memberName, memberLoaders
+		}
+		routeName += "." + strings.ToLower(action.method)
+		h := append(append([]Handler{}, loaders...), actionHandlers(res.Controller, action.method, m.Interface())...)
+
+		if action.suffix != "" {
+			r.Get(p+action.suffix, h...).Name(routeName)
+			continue
+		}
+
+		methods := collection
+		if action.member {
+			methods = member
+		}
+		for _, verb := range action.verbs {
+			methods[verb] = h
+		}
+		names[action.member][action.verbs[0]] = routeName
+	}
+
+	if len(collection) > 0 {
+		nameRoutes(r.addResource(collectionPattern, collection), names[false])
+	}
+	if len(member) > 0 {
+		nameRoutes(r.addResource(memberPattern, member), names[true])
+	}
+
+	param := singular(name) + "_id"
+	child := resourceScope{
+		prefix:  collectionPattern + "/:" + param,
+		name:    collectionName + ".",
+		loaders: scope.loaders,
+	}
+	if load := value.MethodByName("Load"); load.IsValid() {
+		child.loaders = append(append([]Handler{}, scope.loaders...), Load(param, load.Interface()))
+	}
+	for _, n := range res.Nested {
+		r.mapResource(child, n)
+	}
+}
+
+// singular returns the naive singular form of a resource name.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "s"):
+		return name[:len(name)-1]
+	}
+	return name
+}
+
+func nameRoutes(routes map[string]*route, names map[string]string) {
+	for verb, route := range routes {
+		if name, ok := names[verb]; ok {
+			route.Name(name)
+		}
+	}
+}
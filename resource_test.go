@@ -0,0 +1,156 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type usersController struct {
+	prefix string
+}
+
+func (c *usersController) Index() string {
+	return c.prefix + "index"
+}
+
+func (c *usersController) New() string {
+	return c.prefix + "new"
+}
+
+func (c *usersController) Show(params Params) string {
+	return c.prefix + "show " + params["id"]
+}
+
+func (c *usersController) Update(params Params, req *http.Request) string {
+	return c.prefix + req.Method + " " + params["id"]
+}
+
+func Test_MapResource(t *testing.T) {
+	r := NewRouter()
+	r.MapResource("/users/", &usersController{"users "})
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{"GET", "/users", http.StatusOK, "users index"},
+		{"GET", "/users/new", http.StatusOK, "users new"},
+		{"GET", "/users/42", http.StatusOK, "users show 42"},
+		{"PUT", "/users/42", http.StatusOK, "users PUT 42"},
+		{"PATCH", "/users/42", http.StatusOK, "users PATCH 42"},
+		{"POST", "/users", http.StatusMethodNotAllowed, "Method Not Allowed\n"},
+		{"DELETE", "/users/42", http.StatusMethodNotAllowed, "Method Not Allowed\n"},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest(tt.method, "http://localhost:3000"+tt.path, nil)
+		r.Handle(recorder, req, New().createContext(recorder, req))
+		expect(t, recorder.Code, tt.code)
+		expect(t, recorder.Body.String(), tt.body)
+	}
+
+	expect(t, r.URLFor("users.index"), "/users")
+	expect(t, r.URLFor("users.new"), "/users/new")
+	expect(t, r.URLFor("users.show", 42), "/users/42")
+	expect(t, r.URLFor("users.update", 42), "/users/42")
+}
+
+type filteredController struct {
+	log *string
+}
+
+func (c *filteredController) BeforeAction() []Filter {
+	return []Filter{
+		{Handler: func() { *c.log += "load " }, Except: []string{"index"}},
+		{Handler: func(res http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") == "" {
+				res.WriteHeader(http.StatusUnauthorized)
+			}
+		}, Only: []string{"Destroy"}},
+	}
+}
+
+func (c *filteredController) AfterAction() []Filter {
+	return []Filter{{Handler: func(res http.ResponseWriter) { *c.log += "audit " }}}
+}
+
+func (c *filteredController) Index() string {
+	*c.log += "index "
+	return "index"
+}
+
+func (c *filteredController) Destroy() {
+	*c.log += "destroy "
+}
+
+func Test_MapResource_Filters(t *testing.T) {
+	log := ""
+	r := NewRouter()
+	r.MapResource("/things", &filteredController{&log})
+
+	for _, method := range []string{"GET", "DELETE"} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "http://localhost:3000/things", nil)
+		if method == "DELETE" {
+			req.URL.Path = "/things/1"
+		}
+		r.Handle(recorder, req, New().createContext(recorder, req))
+	}
+	// the unauthorized DELETE is stopped by the before filter
+	expect(t, log, "index audit load ")
+}
+
+type parentController struct{}
+
+func (c parentController) Load(id string) (*loadedUser, bool) {
+	return &loadedUser{"user " + id}, id != "0"
+}
+
+func (c parentController) Show(params Params) string {
+	return "show user " + params["id"]
+}
+
+type postsController struct{}
+
+func (c postsController) Index(user *loadedUser) string {
+	return user.Name + " posts"
+}
+
+func (c postsController) Show(params Params) string {
+	return "post " + params["id"]
+}
+
+func Test_MapResource_Nested(t *testing.T) {
+	r := NewRouter()
+	r.MapResource("/users", parentController{}, Nested{
+		Pattern:    "/posts",
+		Controller: postsController{},
+		Shallow:    true,
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/1", http.StatusOK, "show user 1"},
+		{"/users/1/posts", http.StatusOK, "user 1 posts"},
+		{"/users/0/posts", http.StatusNotFound, "404 page not found\n"},
+		{"/posts/3", http.StatusOK, "post 3"},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+tt.path, nil)
+		r.Handle(recorder, req, New().createContext(recorder, req))
+		expect(t, recorder.Code, tt.code)
+		expect(t, recorder.Body.String(), tt.body)
+	}
+
+	expect(t, r.URLFor("users.show", 1), "/users/1")
+	expect(t, r.URLFor("users.posts.index", 1), "/users/1/posts")
+	expect(t, r.URLFor("posts.show", 3), "/posts/3")
+	expect(t, singular("categories"), "category")
+}
@@ -2,6 +2,7 @@ package martini
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"net"
 	"net/http"
@@ -24,12 +25,48 @@ type ResponseWriter interface {
 	Before(BeforeFunc)
 }
 
+// BufferedResponseWriter is a ResponseWriter that can hold the response in memory until it is committed,
+// so a response that went wrong halfway through can still be replaced. The ResponseWriter created by
+// martini for each request implements it.
+type BufferedResponseWriter interface {
+	ResponseWriter
+	// Buffer starts holding the status, headers and body in memory instead of sending them to the client.
+	Buffer()
+	// Commit sends the buffered response to the client and stops buffering. Flush commits as well.
+	Commit()
+	// Committed returns whether any part of the response has been sent to the client.
+	Committed() bool
+	// Reset discards the buffered status, headers and body. It returns false if the response
+	// has already been committed and can no longer be replaced.
+	Reset() bool
+	// Body returns the buffered body, nil if the response isn't buffered.
+	Body() []byte
+	// SetBody replaces the buffered body, keeping status and headers. It returns false if the response
+	// isn't buffered.
+	SetBody(b []byte) bool
+}
+
+// BufferResponse returns a middleware handler that holds the response in memory until the rest of the
+// handler chain has completed or the response is flushed. Combined with Recovery, a panic before that
+// point replaces the partial response with a clean 500.
+func BufferResponse() Handler {
+	return func(c Context, res http.ResponseWriter) {
+		bw, ok := res.(BufferedResponseWriter)
+		if !ok {
+			return
+		}
+		bw.Buffer()
+		c.Next()
+		bw.Commit()
+	}
+}
+
 // BeforeFunc is a function that is called before the ResponseWriter has been written to.
 type BeforeFunc func(ResponseWriter)
 
 // NewResponseWriter creates a ResponseWriter that wraps an http.ResponseWriter
 func NewResponseWriter(rw http.ResponseWriter) ResponseWriter {
-	return &responseWriter{rw, 0, 0, nil}
+	return &responseWriter{rw, 0, 0, nil, nil, nil, false}
 }
 
 type responseWriter struct {
@@ -37,9 +74,16 @@ type responseWriter struct {
 	status      int
 	size        int
 	beforeFuncs []BeforeFunc
+	buffer      *bytes.Buffer
+	header      http.Header
+	unbuffered  bool
 }
 
 func (rw *responseWriter) WriteHeader(s int) {
+	if rw.buffer != nil {
+		rw.status = s
+		return
+	}
 	rw.callBefore()
 	rw.ResponseWriter.WriteHeader(s)
 	rw.status = s
@@ -50,11 +94,95 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 		// The status will be StatusOK if WriteHeader has not been called yet
 		rw.WriteHeader(http.StatusOK)
 	}
+	if rw.buffer != nil {
+		size, err := rw.buffer.Write(b)
+		rw.size += size
+		return size, err
+	}
 	size, err := rw.ResponseWriter.Write(b)
 	rw.size += size
 	return size, err
 }
 
+func (rw *responseWriter) Buffer() {
+	if rw.buffer != nil || rw.Written() || rw.unbuffered {
+		return
+	}
+	rw.buffer = new(bytes.Buffer)
+	// keep a copy of the headers so Reset can restore them
+	rw.header = make(http.Header)
+	for k, v := range rw.Header() {
+		rw.header[k] = v
+	}
+}
+
+// unbuffer commits the response and ignores further calls to Buffer, for responses that have to reach
+// the client as they are written, like event streams.
+func (rw *responseWriter) unbuffer() {
+	rw.Commit()
+	rw.unbuffered = true
+}
+
+// unbuffer stops the response from being buffered by middleware, see RouteOptions.DisableBuffering.
+func unbuffer(res http.ResponseWriter) {
+	if u, ok := res.(interface{ unbuffer() }); ok {
+		u.unbuffer()
+	}
+}
+
+func (rw *responseWriter) Commit() {
+	if rw.buffer == nil {
+		return
+	}
+	buffer := rw.buffer
+	rw.buffer = nil
+	rw.header = nil
+	if rw.status != 0 {
+		rw.callBefore()
+		rw.ResponseWriter.WriteHeader(rw.status)
+		rw.ResponseWriter.Write(buffer.Bytes())
+	}
+}
+
+func (rw *responseWriter) Committed() bool {
+	return rw.buffer == nil && rw.Written()
+}
+
+func (rw *responseWriter) Reset() bool {
+	if rw.Committed() {
+		return false
+	}
+	if rw.buffer != nil {
+		rw.buffer.Reset()
+		header := rw.Header()
+		for k := range header {
+			delete(header, k)
+		}
+		for k, v := range rw.header {
+			header[k] = v
+		}
+	}
+	rw.status = 0
+	rw.size = 0
+	return true
+}
+
+func (rw *responseWriter) Body() []byte {
+	if rw.buffer == nil {
+		return nil
+	}
+	return rw.buffer.Bytes()
+}
+
+func (rw *responseWriter) SetBody(b []byte) bool {
+	if rw.buffer == nil {
+		return false
+	}
+	rw.buffer = bytes.NewBuffer(b)
+	rw.size = len(b)
+	return true
+}
+
 func (rw *responseWriter) Status() int {
 	return rw.status
 }
@@ -90,6 +218,7 @@ func (rw *responseWriter) callBefore() {
 }
 
 func (rw *responseWriter) Flush() {
+	rw.Commit()
 	flusher, ok := rw.ResponseWriter.(http.Flusher)
 	if ok {
 		flusher.Flush()
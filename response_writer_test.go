@@ -186,3 +186,70 @@ func Test_ResponseWriter_FlusherHandler(t *testing.T) {
 	}
 
 }
+
+func Test_ResponseWriter_Buffered(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(rec).(BufferedResponseWriter)
+	result := ""
+	rw.Before(func(ResponseWriter) {
+		result += "before"
+	})
+
+	rw.Buffer()
+	rw.Header().Set("X-Foo", "bar")
+	rw.WriteHeader(http.StatusCreated)
+	rw.Write([]byte("Hello world"))
+
+	expect(t, rw.Written(), true)
+	expect(t, rw.Committed(), false)
+	expect(t, rw.Size(), 11)
+	expect(t, rec.Body.Len(), 0)
+	expect(t, result, "")
+
+	rw.Commit()
+	expect(t, rw.Committed(), true)
+	expect(t, rec.Code, http.StatusCreated)
+	expect(t, rec.Header().Get("X-Foo"), "bar")
+	expect(t, rec.Body.String(), "Hello world")
+	expect(t, result, "before")
+	expect(t, rw.Reset(), false)
+}
+
+func Test_ResponseWriter_BufferedReset(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("X-Keep", "yes")
+	rw := NewResponseWriter(rec).(BufferedResponseWriter)
+
+	rw.Buffer()
+	rw.Header().Set("X-Foo", "bar")
+	rw.Write([]byte("partial"))
+
+	expect(t, rw.Reset(), true)
+	expect(t, rw.Written(), false)
+	expect(t, rw.Size(), 0)
+	expect(t, rec.Header().Get("X-Foo"), "")
+	expect(t, rec.Header().Get("X-Keep"), "yes")
+
+	rw.WriteHeader(http.StatusInternalServerError)
+	rw.Flush()
+	expect(t, rec.Code, http.StatusInternalServerError)
+	expect(t, rec.Body.Len(), 0)
+}
+
+func Test_ResponseWriter_BufferedBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(rec).(BufferedResponseWriter)
+	expect(t, rw.SetBody([]byte("x")), false)
+	expect(t, len(rw.Body()), 0)
+
+	rw.Buffer()
+	rw.WriteHeader(http.StatusAccepted)
+	rw.Write([]byte("Hello world"))
+	expect(t, string(rw.Body()), "Hello world")
+	expect(t, rw.SetBody([]byte("Hi")), true)
+	expect(t, rw.Size(), 2)
+
+	rw.Commit()
+	expect(t, rec.Code, http.StatusAccepted)
+	expect(t, rec.Body.String(), "Hi")
+}
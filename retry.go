@@ -0,0 +1,283 @@
+package martini
+
+import (
+	gocontext "context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RetryMetrics counts what a Retryer did.
+type RetryMetrics struct {
+	// Calls is the number of operations run.
+	Calls uint64 `json:"calls"`
+	// Retries is the number of attempts after the first.
+	Retries uint64 `json:"retries"`
+	// Failures is the number of operations that failed after their last attempt.
+	Failures uint64 `json:"failures"`
+}
+
+// Retryer runs operations again when they fail with a retryable error, waiting an exponentially
+// growing, jittered backoff between attempts. The zero value is usable, map one as a service to share
+// the policy and its metrics.
+//
+//	m.Map(&martini.Retryer{MaxAttempts: 4})
+//	m.Get("/quote", func(r *martini.Retryer, req *http.Request) {
+//	  err := r.Do(req.Context(), func() error {
+//	    ...
+//	  })
+//	})
+type Retryer struct {
+	// MaxAttempts is the number of attempts including the first. Defaults to 3.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled for every further one. Defaults to 100ms.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between attempts. Defaults to 10 seconds.
+	MaxBackoff time.Duration
+	// RetryOn returns whether an error is worth another attempt. Defaults to RetryableError.
+	RetryOn func(error) bool
+
+	calls, retries, failures uint64
+}
+
+// permanentError marks an error as not retryable.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps the error so the Retryer gives up on it right away.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// RetryableError is the default retry classification. Permanent errors and canceled or expired contexts
+// are not retried. Errors with a StatusCode() int method, like the failure of an outbound request, are
+// retried for 408 Request Timeout, 429 Too Many Requests and 5xx statuses except 501 Not Implemented.
+// Anything else, e.g. a network error, is retried.
+func RetryableError(err error) bool {
+	var permanent permanentError
+	if errors.As(err, &permanent) || errors.Is(err, gocontext.Canceled) || errors.Is(err, gocontext.DeadlineExceeded) {
+		return false
+	}
+	var status interface{ StatusCode() int }
+	if errors.As(err, &status) {
+		return retryableStatus(status.StatusCode())
+	}
+	return true
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests ||
+		(status >= 500 && status != http.StatusNotImplemented)
+}
+
+func (r *Retryer) maxAttempts() int {
+	if r.MaxAttempts == 0 {
+		return 3
+	}
+	return r.MaxAttempts
+}
+
+func (r *Retryer) retryable(err error) bool {
+	if r.RetryOn != nil {
+		return r.RetryOn(err)
+	}
+	return RetryableError(err)
+}
+
+// Retry returns whether an operation that failed with err in its attempt-th attempt, counting from 1,
+// should be attempted again.
+func (r *Retryer) Retry(attempt int, err error) bool {
+	return attempt < r.maxAttempts() && r.retryable(err)
+}
+
+func (r *Retryer) maxBackoff() time.Duration {
+	if r.MaxBackoff == 0 {
+		return 10 * time.Second
+	}
+	return r.MaxBackoff
+}
+
+// Delay returns the wait after the attempt-th attempt, counting from 1. It is picked at random from the
+// upper half of the backoff, so clients that failed together don't retry together.
+func (r *Retryer) Delay(attempt int) time.Duration {
+	backoff, max := r.Backoff, r.maxBackoff()
+	if backoff == 0 {
+		backoff = 100 * time.Millisecond
+	}
+	d := backoff << uint(attempt-1)
+	if d > max || d <= 0 {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Do calls fn until it succeeds, fails with an error that isn't retryable or runs out of attempts, and
+// returns its last error. Waiting between attempts stops when ctx is done.
+func (r *Retryer) Do(ctx gocontext.Context, fn func() error) error {
+	atomic.AddUint64(&r.calls, 1)
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if !r.Retry(attempt, err) || !r.wait(ctx, r.Delay(attempt)) {
+			atomic.AddUint64(&r.failures, 1)
+			return err
+		}
+	}
+}
+
+// wait waits for d and counts the retry, it returns false if ctx is done first.
+func (r *Retryer) wait(ctx gocontext.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		atomic.AddUint64(&r.retries, 1)
+		return true
+	}
+}
+
+// count counts an attempt of an operation retried by someone else, like the deliveries of Webhooks.
+func (r *Retryer) count(attempt int, failed bool) {
+	if attempt == 1 {
+		atomic.AddUint64(&r.calls, 1)
+	} else {
+		atomic.AddUint64(&r.retries, 1)
+	}
+	if failed {
+		atomic.AddUint64(&r.failures, 1)
+	}
+}
+
+// Metrics returns what the Retryer did so far.
+func (r *Retryer) Metrics() RetryMetrics {
+	return RetryMetrics{atomic.LoadUint64(&r.calls), atomic.LoadUint64(&r.retries), atomic.LoadUint64(&r.failures)}
+}
+
+// Transport returns a RoundTripper retrying requests through base, http.DefaultTransport if nil.
+// Only idempotent requests are retried: GET, HEAD, OPTIONS, PUT and DELETE requests and requests with
+// an Idempotency-Key header, whose body can be replayed. Responses with a retryable status are retried
+// as well, honoring their Retry-After header up to MaxBackoff, the last one is returned as is.
+//
+//	client := &http.Client{Transport: retryer.Transport(nil), Timeout: 30 * time.Second}
+func (r *Retryer) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryTransport{r, base}
+}
+
+type retryTransport struct {
+	r    *Retryer
+	base http.RoundTripper
+}
+
+// statusError is the failure of an attempt answered with a retryable status.
+type statusError int
+
+func (e statusError) Error() string {
+	return "answered " + strconv.Itoa(int(e))
+}
+
+func (e statusError) StatusCode() int {
+	return int(e)
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !retryableRequest(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	atomic.AddUint64(&t.r.calls, 1)
+	for attempt := 1; ; attempt++ {
+		out := req
+		if attempt > 1 && req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			out = req.Clone(req.Context())
+			out.Body = body
+		}
+
+		res, err := t.base.RoundTrip(out)
+		if err == nil && !retryableStatus(res.StatusCode) {
+			return res, nil
+		}
+		if err == nil {
+			err = statusError(res.StatusCode)
+		}
+		if !t.r.Retry(attempt, err) {
+			atomic.AddUint64(&t.r.failures, 1)
+			if res != nil {
+				return res, nil
+			}
+			return nil, err
+		}
+
+		delay := t.r.Delay(attempt)
+		if res != nil {
+			if d, ok := retryAfter(res.Header.Get("Retry-After")); ok {
+				delay = d
+				if max := t.r.maxBackoff(); delay > max {
+					delay = max
+				}
+			}
+			io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+			res.Body.Close()
+		}
+		if !t.r.wait(req.Context(), delay) {
+			atomic.AddUint64(&t.r.failures, 1)
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryableRequest returns whether the request is idempotent and can be sent again.
+func retryableRequest(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+	default:
+		if req.Header.Get("Idempotency-Key") == "" {
+			return false
+		}
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryAfter parses a Retry-After header given in seconds or as a date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
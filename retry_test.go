@@ -0,0 +1,106 @@
+package martini
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Retryer_Do(t *testing.T) {
+	r := &Retryer{Backoff: time.Millisecond}
+	calls := 0
+	err := r.Do(gocontext.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	expect(t, err, nil)
+	expect(t, calls, 3)
+
+	calls = 0
+	notFound := Permanent(errors.New("not found"))
+	expect(t, r.Do(gocontext.Background(), func() error {
+		calls++
+		return notFound
+	}), notFound)
+	expect(t, calls, 1)
+
+	calls = 0
+	r.Do(gocontext.Background(), func() error {
+		calls++
+		return webhookStatusError(http.StatusServiceUnavailable)
+	})
+	expect(t, calls, 3)
+	expect(t, r.Metrics(), RetryMetrics{Calls: 3, Retries: 4, Failures: 2})
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	calls = 0
+	(&Retryer{}).Do(ctx, func() error {
+		calls++
+		return errors.New("timeout")
+	})
+	expect(t, calls, 1)
+}
+
+func Test_Retryer_Classification(t *testing.T) {
+	expect(t, RetryableError(errors.New("eof")), true)
+	expect(t, RetryableError(fmt.Errorf("wrapped: %w", Permanent(errors.New("bad")))), false)
+	expect(t, RetryableError(gocontext.Canceled), false)
+	expect(t, RetryableError(statusError(http.StatusTooManyRequests)), true)
+	expect(t, RetryableError(statusError(http.StatusBadGateway)), true)
+	expect(t, RetryableError(statusError(http.StatusNotImplemented)), false)
+	expect(t, RetryableError(webhookStatusError(http.StatusBadRequest)), false)
+}
+
+func Test_Retryer_Delay(t *testing.T) {
+	r := &Retryer{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for i := 0; i < 20; i++ {
+		d := r.Delay(2)
+		expect(t, d >= 100*time.Millisecond && d <= 200*time.Millisecond, true)
+		d = r.Delay(10)
+		expect(t, d >= 500*time.Millisecond && d <= time.Second, true)
+	}
+}
+
+func Test_Retryer_Transport(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			res.Header().Set("Retry-After", "0")
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		res.Write([]byte(req.Method + " " + string(body)))
+	}))
+	defer server.Close()
+
+	r := &Retryer{Backoff: time.Millisecond}
+	client := &http.Client{Transport: r.Transport(nil)}
+
+	res, err := client.Post(server.URL, "text/plain", strings.NewReader("data"))
+	expect(t, err, nil)
+	expect(t, res.StatusCode, http.StatusServiceUnavailable)
+
+	expect(t, atomic.LoadInt32(&hits), int32(1))
+
+	// idempotent requests are retried with their body
+	atomic.StoreInt32(&hits, 0)
+	req, _ := http.NewRequest("PUT", server.URL, strings.NewReader("data"))
+	res, err = client.Do(req)
+	expect(t, err, nil)
+	expect(t, res.StatusCode, http.StatusOK)
+	body, _ := ioutil.ReadAll(res.Body)
+	expect(t, string(body), "PUT data")
+	expect(t, r.Metrics(), RetryMetrics{Calls: 1, Retries: 1, Failures: 0})
+}
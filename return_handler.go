@@ -10,12 +10,24 @@ import (
 // when a route handler returns something. The ReturnHandler is
 // responsible for writing to the ResponseWriter based on the values
 // that are passed into this function.
+//
+// The default ReturnHandler answers a non-nil error returned as the
+// last value according to the Errors service, a nil error is ignored.
 type ReturnHandler func(Context, []reflect.Value)
 
 func defaultReturnHandler() ReturnHandler {
 	return func(ctx Context, vals []reflect.Value) {
 		rv := ctx.Get(inject.InterfaceOf((*http.ResponseWriter)(nil)))
 		res := rv.Interface().(http.ResponseWriter)
+		if last := vals[len(vals)-1]; last.Type().Implements(errorType) {
+			if !isNil(last) {
+				writeError(ctx, res, last.Interface().(error))
+				return
+			}
+			if vals = vals[:len(vals)-1]; len(vals) == 0 {
+				return
+			}
+		}
 		var responseVal reflect.Value
 		if len(vals) > 1 && vals[0].Kind() == reflect.Int {
 			res.WriteHeader(int(vals[0].Int()))
@@ -34,6 +46,20 @@ func defaultReturnHandler() ReturnHandler {
 	}
 }
 
+// writeError answers the request with the status and message of the error's class.
+func writeError(ctx Context, res http.ResponseWriter, err error) {
+	var errs Errors = defaultErrors{}
+	if v := ctx.Get(inject.InterfaceOf((*Errors)(nil))); v.IsValid() {
+		errs = v.Interface().(Errors)
+	}
+	req, _ := ctx.Get(reflect.TypeOf((*http.Request)(nil))).Interface().(*http.Request)
+	if e, ok := asHTTPError(err); ok {
+		writeHTTPError(res, req, e)
+		return
+	}
+	writeHTTPError(res, req, HTTPError{Status: errs.Classify(err).Status(), Message: errs.Message(err)})
+}
+
 func isByteSlice(val reflect.Value) bool {
 	return val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8
 }
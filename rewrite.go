@@ -0,0 +1,27 @@
+package martini
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// Rewrite returns a middleware handler that rewrites the request path before it is routed. The pattern is
+// a regular expression that has to match the whole path, the replacement can refer to its captures with
+// $1 or ${name}. Paths not matching the pattern are left alone. An empty result is rewritten to "/".
+//
+//	// deployed under /service-name/ by the proxy
+//	m.Use(martini.Rewrite(`/service-name(/.*)?`, "$1"))
+func Rewrite(pattern string, replacement string) Handler {
+	regex := regexp.MustCompile(`^(?:` + pattern + `)$`)
+	return func(req *http.Request) {
+		if !regex.MatchString(req.URL.Path) {
+			return
+		}
+		path := regex.ReplaceAllString(req.URL.Path, replacement)
+		if path == "" {
+			path = "/"
+		}
+		req.URL.Path = path
+		req.URL.RawPath = ""
+	}
+}
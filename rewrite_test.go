@@ -0,0 +1,29 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Rewrite(t *testing.T) {
+	m := Classic()
+	m.Use(Rewrite(`/service-name(/.*)?`, "$1"))
+	m.Use(Rewrite(`/people/(?P<id>\d+)`, "/users/${id}"))
+	m.Get("/", func() string { return "root" })
+	m.Get("/users/:id", func(params Params) string { return "user " + params["id"] })
+
+	for path, body := range map[string]string{
+		"/service-name":          "root",
+		"/service-name/":         "root",
+		"/service-name/users/5":  "user 5",
+		"/service-name/people/7": "user 7",
+		"/users/8":               "user 8",
+		"/service-names/users/5": "404 page not found\n",
+	} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(recorder, req)
+		expect(t, recorder.Body.String(), body)
+	}
+}
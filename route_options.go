@@ -0,0 +1,85 @@
+package martini
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// RouteOptions holds policies that differ between routes, like an upload endpoint accepting large bodies
+// next to a JSON API with tight timeouts. Attach them to a route or a group with WithOptions.
+type RouteOptions struct {
+	// Timeout sets a deadline on the request's context, see martini.Timeout.
+	Timeout time.Duration
+	// MaxBodySize limits the number of bytes that can be read from the request body.
+	MaxBodySize int64
+	// DisableCompression tells response compressing middleware, like Minify, to leave the response as is.
+	DisableCompression bool
+	// DisableBuffering sends the response to the client as it is written, even if middleware like
+	// BufferResponse or Minify buffers it. Needed for streaming responses that aren't flushed.
+	DisableBuffering bool
+	// DisableCaching tells response caching middleware not to store the response and sets a
+	// Cache-Control: no-store header unless the handlers set Cache-Control themselves.
+	DisableCaching bool
+}
+
+// WithOptions returns a handler applying the options to the rest of the handler chain. Add it in front
+// of a route's handlers or to a group:
+//
+//	m.Group("/uploads", func(r martini.Router) {
+//	  r.Post("/", upload)
+//	}, martini.WithOptions(martini.RouteOptions{MaxBodySize: 100 << 20, DisableCompression: true}))
+//
+// The effective RouteOptions are mapped into the request context, where middleware running before the
+// router finds them once the rest of the chain returned. When groups and routes both set options, the
+// smaller Timeout and MaxBodySize are in effect and compression, buffering and caching stay disabled
+// once a group disabled them.
+func WithOptions(opts RouteOptions) Handler {
+	return func(c Context, req *http.Request, res http.ResponseWriter) {
+		o := opts
+		if prev := c.Get(reflect.TypeOf(o)); prev.IsValid() {
+			o = prev.Interface().(RouteOptions).merge(o)
+		}
+		c.Map(o)
+
+		if opts.DisableBuffering {
+			unbuffer(res)
+		}
+		if rw, ok := res.(ResponseWriter); ok && opts.DisableCaching {
+			rw.Before(func(rw ResponseWriter) {
+				if rw.Header().Get("Cache-Control") == "" {
+					rw.Header().Set("Cache-Control", "no-store")
+				}
+			})
+		}
+
+		if opts.MaxBodySize > 0 && req != nil && req.Body != nil {
+			req.Body = http.MaxBytesReader(res, req.Body, opts.MaxBodySize)
+		}
+		if opts.Timeout > 0 && req != nil {
+			withTimeout(c, req, res, opts.Timeout)
+		}
+	}
+}
+
+// merge returns the options in effect when next is applied within o.
+func (o RouteOptions) merge(next RouteOptions) RouteOptions {
+	if next.Timeout == 0 || (o.Timeout > 0 && o.Timeout < next.Timeout) {
+		next.Timeout = o.Timeout
+	}
+	if next.MaxBodySize == 0 || (o.MaxBodySize > 0 && o.MaxBodySize < next.MaxBodySize) {
+		next.MaxBodySize = o.MaxBodySize
+	}
+	next.DisableCompression = next.DisableCompression || o.DisableCompression
+	next.DisableBuffering = next.DisableBuffering || o.DisableBuffering
+	next.DisableCaching = next.DisableCaching || o.DisableCaching
+	return next
+}
+
+// routeOptions returns the RouteOptions of the matched route, the zero value if there are none.
+func routeOptions(c Context) RouteOptions {
+	if v := c.Get(reflect.TypeOf(RouteOptions{})); v.IsValid() {
+		return v.Interface().(RouteOptions)
+	}
+	return RouteOptions{}
+}
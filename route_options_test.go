@@ -0,0 +1,78 @@
+package martini
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_WithOptions(t *testing.T) {
+	m := Classic()
+	m.Group("/uploads", func(r Router) {
+		r.Post("/small", WithOptions(RouteOptions{MaxBodySize: 4}), func(req *http.Request, opts RouteOptions) (int, string) {
+			expect(t, opts.MaxBodySize, int64(4))
+			expect(t, opts.DisableCompression, true)
+			_, deadline := req.Context().Deadline()
+			expect(t, deadline, true)
+
+			if _, err := ioutil.ReadAll(req.Body); err != nil {
+				return http.StatusRequestEntityTooLarge, "too large"
+			}
+			return http.StatusOK, "ok"
+		})
+	}, WithOptions(RouteOptions{Timeout: time.Second, MaxBodySize: 1024, DisableCompression: true}))
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://localhost:3000/uploads/small", strings.NewReader("12345"))
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Code, http.StatusRequestEntityTooLarge)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "http://localhost:3000/uploads/small", strings.NewReader("1234"))
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Code, http.StatusOK)
+}
+
+func Test_RouteOptions_Merge(t *testing.T) {
+	group := RouteOptions{Timeout: time.Second, MaxBodySize: 10}
+	o := group.merge(RouteOptions{Timeout: time.Minute})
+	expect(t, o.Timeout, time.Second)
+	expect(t, o.MaxBodySize, int64(10))
+	expect(t, o.DisableCompression, false)
+
+	o = group.merge(RouteOptions{Timeout: time.Millisecond, DisableCompression: true})
+	expect(t, o.Timeout, time.Millisecond)
+	expect(t, o.DisableCompression, true)
+
+	o = RouteOptions{DisableBuffering: true}.merge(RouteOptions{DisableCaching: true})
+	expect(t, o.DisableBuffering, true)
+	expect(t, o.DisableCaching, true)
+}
+
+func Test_WithOptions_DisableBuffering(t *testing.T) {
+	m := Classic()
+	m.Use(Minify(MinifyOptions{MinSize: 1}))
+	m.Get("/stream", WithOptions(RouteOptions{DisableBuffering: true}), func(res http.ResponseWriter) {
+		res.Write([]byte("chunk"))
+		// with buffering disabled the chunk reaches the client before the handler returns
+		expect(t, res.(BufferedResponseWriter).Committed(), true)
+	})
+	m.Get("/json", WithOptions(RouteOptions{DisableCompression: true, DisableCaching: true}), func(res http.ResponseWriter) {
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{ "a": 1 }`))
+	})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:3000/stream", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Body.String(), "chunk")
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "http://localhost:3000/json", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Body.String(), `{ "a": 1 }`)
+	expect(t, recorder.Header().Get("Cache-Control"), "no-store")
+}
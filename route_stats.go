@@ -0,0 +1,258 @@
+package martini
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// latencySamples is the number of recent latencies kept per route to compute percentiles.
+const latencySamples = 1024
+
+// RouteStats collects live per-route statistics: requests in flight, responses per status class, latency
+// percentiles and error rate. Add its Handler as middleware and serve its Dashboard on an internal
+// endpoint, or query it with Get and observe responses with OnClass. Requests to routes annotated with an
+// *SLO under the "slo" meta key are recorded against it, see SLO.
+//
+//	stats := martini.NewRouteStats()
+//	m.Use(stats.Handler())
+//	m.Get("/debug/routes", stats.Dashboard())
+//
+//	stats.OnClass("5xx", func(route string, status int, req *http.Request) {
+//	  alerts.Notify(route, status)
+//	})
+type RouteStats struct {
+	mu     sync.Mutex
+	routes map[string]*routeStats
+	hooks  map[string][]ClassHook
+}
+
+// ClassHook is called by RouteStats after a request to the route has been answered with the status.
+type ClassHook func(route string, status int, req *http.Request)
+
+// RouteStat is a snapshot of the statistics of a route.
+type RouteStat struct {
+	Route    string `json:"route"`
+	InFlight int64  `json:"in_flight"`
+	Count    int64  `json:"count"`
+	Errors   int64  `json:"errors"`
+	// Classes counts the responses per status class, "2xx" to "5xx".
+	Classes   map[string]int64 `json:"classes"`
+	ErrorRate float64          `json:"error_rate"`
+	P50       time.Duration    `json:"p50"`
+	P95       time.Duration    `json:"p95"`
+	P99       time.Duration    `json:"p99"`
+	// BurnRate is the burn rate of the route's SLO, if it has one.
+	BurnRate float64 `json:"burn_rate,omitempty"`
+}
+
+type routeStats struct {
+	inFlight  int64
+	count     int64
+	errors    int64
+	classes   [6]int64
+	latencies []time.Duration
+	next      int
+	slo       *SLO
+}
+
+// routeStatsRequest is mapped for each request, the router reports the matched route to it.
+type routeStatsRequest struct {
+	stats *RouteStats
+	route string
+	slo   *SLO
+}
+
+// unmatchedRoute is the key requests matching no route are recorded under.
+const unmatchedRoute = "(unmatched)"
+
+// NewRouteStats creates an empty RouteStats.
+func NewRouteStats() *RouteStats {
+	return &RouteStats{routes: make(map[string]*routeStats), hooks: make(map[string][]ClassHook)}
+}
+
+// statusClass returns the class of the status, "1xx" to "5xx", and its index.
+func statusClass(status int) (string, int) {
+	i := status / 100
+	if i < 1 || i > 5 {
+		// treat invalid statuses like server errors
+		i = 5
+	}
+	return strconv.Itoa(i) + "xx", i
+}
+
+// OnClass registers fn to be called after a request has been answered with a status of the class, "1xx"
+// to "5xx". Hooks are called synchronously on the goroutine serving the request, so they should hand slow
+// work off.
+func (s *RouteStats) OnClass(class string, fn ClassHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks[class] = append(s.hooks[class], fn)
+}
+
+// Handler returns the middleware handler recording requests. Requests whose handlers panicked are
+// recorded with a 500 status before the panic is passed on, add it after Recovery.
+func (s *RouteStats) Handler() Handler {
+	return func(c Context, res http.ResponseWriter, req *http.Request) {
+		r := &routeStatsRequest{stats: s}
+		c.Map(r)
+		start := time.Now()
+		rw := res.(ResponseWriter)
+		var logger *log.Logger
+		if v := c.Get(reflect.TypeOf(logger)); v.IsValid() {
+			logger, _ = v.Interface().(*log.Logger)
+		}
+		defer func() {
+			if err := recover(); err != nil {
+				s.record(r, req, time.Since(start), http.StatusInternalServerError, logger)
+				panic(err)
+			}
+			s.record(r, req, time.Since(start), rw.Status(), logger)
+		}()
+		c.Next()
+	}
+}
+
+// matched is called by the router once the route of the request is known.
+func (r *routeStatsRequest) matched(route string, meta map[string]interface{}) {
+	r.route = route
+	r.slo, _ = meta[sloMeta].(*SLO)
+	r.stats.mu.Lock()
+	rs := r.stats.get(route)
+	rs.inFlight++
+	rs.slo = r.slo
+	r.stats.mu.Unlock()
+}
+
+func (s *RouteStats) get(route string) *routeStats {
+	rs, ok := s.routes[route]
+	if !ok {
+		rs = &routeStats{}
+		s.routes[route] = rs
+	}
+	return rs
+}
+
+func (s *RouteStats) record(r *routeStatsRequest, req *http.Request, d time.Duration, status int, logger *log.Logger) {
+	if r.slo != nil {
+		r.slo.observe(req, d, status, logger)
+	}
+
+	route := r.route
+	s.mu.Lock()
+	if route == "" {
+		route = unmatchedRoute
+	} else {
+		s.get(route).inFlight--
+	}
+	rs := s.get(route)
+	rs.count++
+	if status >= 500 {
+		rs.errors++
+	}
+	class, i := statusClass(status)
+	rs.classes[i]++
+	if len(rs.latencies) < latencySamples {
+		rs.latencies = append(rs.latencies, d)
+	} else {
+		rs.latencies[rs.next] = d
+		rs.next = (rs.next + 1) % latencySamples
+	}
+	hooks := s.hooks[class]
+	s.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn(route, status, req)
+	}
+}
+
+// Snapshot returns the current statistics of all routes, ordered by route.
+func (s *RouteStats) Snapshot() []RouteStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]RouteStat, 0, len(s.routes))
+	for route, rs := range s.routes {
+		stats = append(stats, rs.stat(route))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Route < stats[j].Route })
+	return stats
+}
+
+// Get returns the current statistics of the route, e.g. "GET /users/:id", if it has been requested.
+func (s *RouteStats) Get(route string) (RouteStat, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs, ok := s.routes[route]
+	if !ok {
+		return RouteStat{}, false
+	}
+	return rs.stat(route), true
+}
+
+// stat returns a snapshot of the statistics, the caller holds the mutex.
+func (rs *routeStats) stat(route string) RouteStat {
+	stat := RouteStat{Route: route, InFlight: rs.inFlight, Count: rs.count, Errors: rs.errors, Classes: make(map[string]int64)}
+	if rs.count > 0 {
+		stat.ErrorRate = float64(rs.errors) / float64(rs.count)
+	}
+	for i := 1; i < len(rs.classes); i++ {
+		if rs.classes[i] > 0 {
+			stat.Classes[strconv.Itoa(i)+"xx"] = rs.classes[i]
+		}
+	}
+	latencies := append([]time.Duration(nil), rs.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stat.P50, stat.P95, stat.P99 = percentile(latencies, 0.5), percentile(latencies, 0.95), percentile(latencies, 0.99)
+	if rs.slo != nil {
+		stat.BurnRate = rs.slo.BurnRate()
+	}
+	return stat
+}
+
+// percentile returns the p-th percentile of the sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1)+0.5)]
+}
+
+var routeStatsTemplate = template.Must(template.New("stats").Funcs(template.FuncMap{
+	"percent": func(f float64) float64 { return f * 100 },
+}).Parse(`<html>
+<head><title>Routes</title><meta http-equiv="refresh" content="5"></head>
+<body>
+<table>
+<tr><th>Route</th><th>In flight</th><th>Requests</th><th>Error rate</th><th>4xx</th><th>5xx</th><th>p50</th><th>p95</th><th>p99</th><th>Burn rate</th></tr>
+{{range .}}<tr><td>{{.Route}}</td><td>{{.InFlight}}</td><td>{{.Count}}</td><td>{{printf "%.2f%%" (percent .ErrorRate)}}</td><td>{{index .Classes "4xx"}}</td><td>{{index .Classes "5xx"}}</td><td>{{.P50}}</td><td>{{.P95}}</td><td>{{.P99}}</td><td>{{if .BurnRate}}{{printf "%.2f" .BurnRate}}{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>`))
+
+// Dashboard returns a handler rendering the statistics as a self-refreshing HTML table, or as JSON if the
+// client prefers it. Durations are given in nanoseconds in JSON.
+func (s *RouteStats) Dashboard() Handler {
+	return func(res http.ResponseWriter, req *http.Request) {
+		stats := s.Snapshot()
+		if prefersJSON(req.Header.Get("Accept")) {
+			body, err := json.Marshal(stats)
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res.Header().Set("Content-Type", "application/json; charset=utf-8")
+			res.Write(body)
+			return
+		}
+		res.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := routeStatsTemplate.Execute(res, stats); err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
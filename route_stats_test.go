@@ -0,0 +1,125 @@
+package martini
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_RouteStats(t *testing.T) {
+	stats := NewRouteStats()
+	m := Classic()
+	m.Use(stats.Handler())
+	m.Get("/users/:id", func(params Params) (int, string) {
+		if params["id"] == "0" {
+			return http.StatusInternalServerError, "boom"
+		}
+		return http.StatusOK, "ok"
+	})
+	m.Get("/debug/routes", stats.Dashboard())
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/3", "/users/0", "/missing"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	snapshot := stats.Snapshot()
+	expect(t, len(snapshot), 2)
+	expect(t, snapshot[0].Route, unmatchedRoute)
+	expect(t, snapshot[0].Count, int64(1))
+	expect(t, snapshot[1].Route, "GET /users/:id")
+	expect(t, snapshot[1].Count, int64(4))
+	expect(t, snapshot[1].InFlight, int64(0))
+	expect(t, snapshot[1].ErrorRate, 0.25)
+	expect(t, snapshot[1].P99 >= snapshot[1].P50, true)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/debug/routes", nil)
+	req.Header.Set("Accept", "application/json")
+	m.ServeHTTP(recorder, req)
+	var decoded []RouteStat
+	expect(t, json.Unmarshal(recorder.Body.Bytes(), &decoded), nil)
+	expect(t, decoded[1].Route, "GET /debug/routes")
+	expect(t, decoded[1].InFlight, int64(1))
+	expect(t, decoded[2].Route, "GET /users/:id")
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/debug/routes", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, strings.Contains(recorder.Body.String(), "<td>GET /users/:id</td><td>0</td><td>4</td><td>25.00%</td>"), true)
+}
+
+func Test_RouteStatsClasses(t *testing.T) {
+	stats := NewRouteStats()
+	m := Classic()
+	m.Use(stats.Handler())
+	m.Get("/users/:id", func(params Params) (int, string) {
+		switch params["id"] {
+		case "0":
+			return http.StatusInternalServerError, "boom"
+		case "1":
+			return http.StatusNotFound, "not found"
+		}
+		return http.StatusOK, "ok"
+	})
+
+	var failures []string
+	stats.OnClass("5xx", func(route string, status int, req *http.Request) {
+		failures = append(failures, fmt.Sprintf("%s %d %s", route, status, req.URL.Path))
+	})
+	for _, path := range []string{"/users/0", "/users/1", "/users/1", "/users/2", "/missing"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	expect(t, strings.Join(failures, ", "), "GET /users/:id 500 /users/0")
+
+	stat, ok := stats.Get("GET /users/:id")
+	expect(t, ok, true)
+	expect(t, stat.Classes["2xx"], int64(1))
+	expect(t, stat.Classes["4xx"], int64(2))
+	expect(t, stat.Classes["5xx"], int64(1))
+	_, ok = stat.Classes["3xx"]
+	expect(t, ok, false)
+	stat, _ = stats.Get(unmatchedRoute)
+	expect(t, stat.Classes["4xx"], int64(1))
+	_, ok = stats.Get("GET /orders")
+	expect(t, ok, false)
+}
+
+func Test_RouteStatsPanic(t *testing.T) {
+	stats := NewRouteStats()
+	m := Classic()
+	m.Use(stats.Handler())
+	m.Get("/boom", func() {
+		panic("boom")
+	})
+	var failures []int
+	stats.OnClass("5xx", func(route string, status int, req *http.Request) {
+		failures = append(failures, status)
+	})
+
+	req, _ := http.NewRequest("GET", "/boom", nil)
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Code, http.StatusInternalServerError)
+
+	stat, _ := stats.Get("GET /boom")
+	expect(t, stat.InFlight, int64(0))
+	expect(t, stat.Errors, int64(1))
+	expect(t, stat.Classes["5xx"], int64(1))
+	expect(t, len(failures), 1)
+}
+
+func Test_Percentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i))
+	}
+	expect(t, percentile(sorted, 0.5), time.Duration(51))
+	expect(t, percentile(sorted, 0.99), time.Duration(99))
+	expect(t, percentile(nil, 0.5), time.Duration(0))
+}
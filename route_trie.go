@@ -0,0 +1,185 @@
+package martini
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// routeTrie matches the routes whose patterns are made of whole path segments, like "/users/:id/posts",
+// "/feed.xml" or "/files/**", by walking the segments of the path instead of running the regexp of every
+// route. Patterns using other regexp syntax stay with the linear scan of the routeIndex.
+//
+// The trie mirrors the regexps exactly: a param matches a non-empty segment without '#' or '?', a
+// trailing ** or **name matches the rest of the path including slashes, a '.' in a literal matches any
+// character and a single trailing slash is optional.
+type routeTrie struct {
+	root *trieNode
+	// fold makes literals match regardless of case, they are stored lowercased
+	fold bool
+}
+
+type trieNode struct {
+	literals map[string]*trieNode
+	// dotted are the children for literals containing '.', which matches any character.
+	dotted []*trieNode
+	// segment is the literal of a dotted child.
+	segment  string
+	param    *trieNode
+	wildcard []trieRoute
+	routes   []trieRoute
+}
+
+// trieRoute is a route ending at a node, with the names of the params in path order.
+type trieRoute struct {
+	pos   int
+	route *route
+	names []string
+}
+
+var trieParamName = regexp.MustCompile(`^\w+$`)
+
+func newTrieNode() *trieNode {
+	return &trieNode{literals: make(map[string]*trieNode)}
+}
+
+// add adds the route at position pos. It returns false if the pattern can't be matched by the trie.
+func (t *routeTrie) add(pos int, r *route) bool {
+	if !strings.HasPrefix(r.pattern, "/") || len(r.locales) > 0 {
+		return false
+	}
+	segments := strings.Split(r.pattern[1:], "/")
+	var names []string
+	node := t.root
+	for i, s := range segments {
+		if t.fold && !strings.HasPrefix(s, ":") && !strings.HasPrefix(s, "**") {
+			s = strings.ToLower(s)
+		}
+		switch {
+		case s == "**" && i == len(segments)-1:
+			node.wildcard = append(node.wildcard, trieRoute{pos, r, append(names, "_1")})
+			return true
+		case strings.HasPrefix(s, "**") && i == len(segments)-1:
+			if !trieParamName.MatchString(s[2:]) || containsString(names, s[2:]) {
+				return false
+			}
+			node.wildcard = append(node.wildcard, trieRoute{pos, r, append(names, s[2:])})
+			return true
+		case strings.HasPrefix(s, ":"):
+			if !trieParamName.MatchString(s[1:]) || containsString(names, s[1:]) {
+				return false
+			}
+			names = append(names, s[1:])
+			if node.param == nil {
+				node.param = newTrieNode()
+			}
+			node = node.param
+		case strings.ContainsAny(s, ":*()\\[]{}?+^$|"):
+			return false
+		case strings.Contains(s, "."):
+			var child *trieNode
+			for _, d := range node.dotted {
+				if d.segment == s {
+					child = d
+				}
+			}
+			if child == nil {
+				child = newTrieNode()
+				child.segment = s
+				node.dotted = append(node.dotted, child)
+			}
+			node = child
+		default:
+			child := node.literals[s]
+			if child == nil {
+				child = newTrieNode()
+				node.literals[s] = child
+			}
+			node = child
+		}
+	}
+	node.routes = append(node.routes, trieRoute{pos, r, names})
+	return true
+}
+
+// match returns the position and params of the first route matching the method and path, or -1.
+func (t *routeTrie) match(method string, path string) (int, map[string]string) {
+	if !strings.HasPrefix(path, "/") {
+		return -1, nil
+	}
+	m := &trieMatch{method: method, pos: -1, fold: t.fold}
+	m.walk(t.root, strings.Split(path[1:], "/"), nil)
+	return m.pos, m.params
+}
+
+type trieMatch struct {
+	method string
+	pos    int
+	params map[string]string
+	fold   bool
+}
+
+func (m *trieMatch) walk(node *trieNode, segments []string, values []string) {
+	if len(node.wildcard) > 0 && len(segments) > 0 {
+		if rest := strings.Join(segments, "/"); !strings.ContainsAny(rest, "#?") {
+			m.found(node.wildcard, append(values, rest))
+		}
+	}
+	// the pattern is followed by an optional slash
+	if len(segments) == 0 || (len(segments) == 1 && segments[0] == "") {
+		m.found(node.routes, values)
+	}
+	if len(segments) == 0 {
+		return
+	}
+
+	s, literal := segments[0], segments[0]
+	if m.fold {
+		literal = strings.ToLower(s)
+	}
+	if child := node.literals[literal]; child != nil {
+		m.walk(child, segments[1:], values)
+	}
+	for _, child := range node.dotted {
+		if dottedMatch(child.segment, literal) {
+			m.walk(child, segments[1:], values)
+		}
+	}
+	if node.param != nil && s != "" && !strings.ContainsAny(s, "#?") {
+		m.walk(node.param, segments[1:], append(values[:len(values):len(values)], s))
+	}
+}
+
+// found records the first of the routes matching the method, if it comes before the current match.
+func (m *trieMatch) found(routes []trieRoute, values []string) {
+	for _, r := range routes {
+		if m.pos >= 0 && r.pos > m.pos {
+			return
+		}
+		if !r.route.MatchMethod(m.method) {
+			continue
+		}
+		m.pos = r.pos
+		m.params = make(map[string]string, len(values))
+		for i, name := range r.names {
+			m.params[name] = values[i]
+		}
+		return
+	}
+}
+
+// dottedMatch returns whether the segment matches the literal, in which '.' matches any character
+// but a newline like in the regexp.
+func dottedMatch(literal string, s string) bool {
+	for _, c := range literal {
+		if s == "" {
+			return false
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		if c != r && (c != '.' || r == '\n') {
+			return false
+		}
+		s = s[size:]
+	}
+	return s == ""
+}
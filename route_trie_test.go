@@ -0,0 +1,115 @@
+package martini
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func Test_RouteTrie_MatchesRegexp(t *testing.T) {
+	patterns := []string{
+		"/", "/users", "/users/", "/users/:id", "/users/new", "/users/:id/posts/:post",
+		"/users/:name/edit", "/files/**", "/**", "/feed.xml", "/a.:b", "/:id(\\d+)", "/x/**/y",
+		"/static/**filepath",
+	}
+	paths := []string{
+		"/", "//", "", "/users", "/users/", "/users//", "/users/5", "/users/5/", "/users/new",
+		"/users/5/posts/7", "/users/5/posts/", "/users/bob/edit", "/users/bob/edit/", "/files", "/files/",
+		"/files/a/b", "/files/a/", "/feed.xml", "/feedXxml", "/feed.xm", "/feedéxml", "/users/a?b", "/42",
+		"/static/css/app.css", "/static/",
+	}
+
+	idx := &routeIndex{}
+	trie := routeTrie{root: newTrieNode()}
+	for i, p := range patterns {
+		route := newRoute("GET", p, nil)
+		idx.routes = append(idx.routes, route)
+		if !trie.add(i, route) {
+			idx.dynamic = append(idx.dynamic, i)
+		}
+	}
+	expect(t, len(idx.dynamic), 3)
+
+	for _, path := range paths {
+		for i, route := range idx.routes {
+			if containsInt(idx.dynamic, i) {
+				continue
+			}
+			// a trie holding just this route has to agree with its regexp
+			single := routeTrie{root: newTrieNode()}
+			single.add(i, route)
+			pos, params := single.match("GET", path)
+			want, ok := matchRegex(route.compiled(), path)
+			if ok != (pos == i) || (ok && !reflect.DeepEqual(params, want)) {
+				t.Errorf("%s on %q: trie %v %v, regexp %v %v", route.pattern, path, pos == i, params, ok, want)
+			}
+		}
+	}
+
+	pos, params := trie.match("GET", "/users/new")
+	expect(t, pos, 3)
+	expect(t, params["id"], "new")
+	pos, _ = trie.match("POST", "/users/new")
+	expect(t, pos, -1)
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_RouteTrie_Order(t *testing.T) {
+	r := NewRouter()
+	result := ""
+	r.Get("/users/:id", func(params Params) { result += "id:" + params["id"] + " " })
+	r.Get("/users/:name/edit", func(params Params) { result += "edit:" + params["name"] + " " })
+	r.Get("/users/(me)", func() { result += "regexp " })
+	r.Get("/users/me", func() { result += "shadowed " })
+	r.Get("/files/**", func(params Params) { result += "files:" + params["_1"] + " " })
+	r.Post("/files/upload", func() { result += "upload " })
+
+	for _, req := range []string{"GET /users/me", "GET /users/me/edit", "GET /files/a/b", "POST /files/upload", "HEAD /users/5"} {
+		var method, path string
+		fmt.Sscan(req, &method, &path)
+		recorder := httptest.NewRecorder()
+		r2, _ := http.NewRequest(method, "http://localhost:3000"+path, nil)
+		r.Handle(recorder, r2, New().createContext(recorder, r2))
+	}
+	expect(t, result, "id:me edit:me files:a/b upload id:5 ")
+}
+
+// benchmarkRouter routes a request matching the last of 500 routes with params.
+func benchmarkRouter(b *testing.B, pattern string) {
+	r := NewRouter()
+	for i := 0; i < 500; i++ {
+		r.Get(fmt.Sprintf(pattern, i), func() {})
+	}
+	req, _ := http.NewRequest("GET", "/r499/users/5/posts/7", nil)
+	res := httptest.NewRecorder()
+	m := New()
+	// the development audit of the injector would dominate
+	m.audit = false
+	r.(*router).index()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Handle(res, req, m.createContext(res, req))
+	}
+}
+
+// BenchmarkRouter_Trie routes through the trie.
+func BenchmarkRouter_Trie(b *testing.B) {
+	benchmarkRouter(b, "/r%d/users/:id/posts/:post")
+}
+
+// BenchmarkRouter_Regexp routes equivalent patterns the trie can't hold through the linear regexp scan.
+func BenchmarkRouter_Regexp(b *testing.B) {
+	benchmarkRouter(b, "/r%d/users/:id/posts/(?P<post>[^/]+)")
+}
@@ -1,22 +1,62 @@
 package martini
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"reflect"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codegangsta/inject"
 )
 
 // Params is a map of name/value pairs for named routes. An instance of martini.Params is available to be injected into any route handler.
 type Params map[string]string
 
 // Router is Martini's de-facto routing interface. Supports HTTP verbs, stacked handlers, and dependency injection.
+// Routes can safely be added while the router is serving requests, each request is routed against a
+// consistent snapshot of the routes. Everything else, like naming routes or setting the NotFound
+// handlers, has to happen before serving starts.
 type Router interface {
 	Routes
 
 	// Group adds a group where related routes can be added.
 	Group(string, func(Router), ...Handler)
+	// Mount adds the routes of a router built separately, e.g. by another package, below the pattern,
+	// preceded by the middleware. Named routes keep their names, NotFound handlers set on the sub-router
+	// are used for requests below the pattern and the services provided by the sub-router are only
+	// mapped for its routes. Routes added to the sub-router afterwards are not mounted.
+	Mount(pattern string, sub Router, middleware ...Handler)
+	// Provide maps a service for the routes of the router only, in front of the services of the
+	// global injector. Mounted routers can provide services of the same type without colliding.
+	Provide(val interface{})
+	// ProvideAs is like Provide but maps the service as the interface ifacePtr points to, like MapTo.
+	ProvideAs(val interface{}, ifacePtr interface{})
+	// After adds handlers running once the handler chain of a route completed, even if a handler wrote the
+	// response early or panicked, e.g. for audit logging and cleanup. Called within a Group or Host, they
+	// run for the routes of the group, after the routes' own after handlers and before those of enclosing
+	// groups. Otherwise they run for every route of the router, last.
+	After(h ...Handler)
+	// Host adds a group of routes that only match requests for the host, e.g. "api.example.com". A "*"
+	// label matches any subdomain, which is available as the "subdomain" param, and a ":name" label
+	// matches any label, available as the "name" param:
+	//
+	//	r.Host("*.example.com", func(r martini.Router) {
+	//	  r.Get("/", func(params martini.Params) string {
+	//	    return "Welcome to " + params["subdomain"]
+	//	  })
+	//	})
+	//
+	// NotFound handlers set within the group are used for requests for the host only.
+	Host(host string, fn func(Router), h ...Handler)
 	// Get adds a route for a HTTP GET request to the specified matching pattern.
 	Get(string, ...Handler) Route
 	// Patch adds a route for a HTTP PATCH request to the specified matching pattern.
@@ -33,23 +73,117 @@ type Router interface {
 	Head(string, ...Handler) Route
 	// Any adds a route for any HTTP method request to the specified matching pattern.
 	Any(string, ...Handler) Route
+	// Method adds a route for requests with the HTTP method, e.g. "PURGE" or the WebDAV "PROPFIND", to the
+	// specified matching pattern. The method is upper-cased, it panics if it isn't a valid method token.
+	Method(method string, pattern string, h ...Handler) Route
+	// Match adds a route for each of the HTTP methods to the specified matching pattern, like Method, and
+	// returns them in the same order. Each route is named on its own.
+	Match(methods []string, pattern string, h ...Handler) []Route
+	// Moved adds a route redirecting requests for the pattern to the named route with the given redirect
+	// status. Params of the named route are filled with the params of the same name captured by the pattern.
+	//
+	//	r.Get("/users/:id", showUser).Name("user")
+	//	r.Moved("/people/:id", "user", http.StatusMovedPermanently)
+	Moved(pattern string, name string, status int) Route
+	// Resource adds a route for each HTTP method of the resource. Requests with any other method
+	// are answered with a 405 Method Not Allowed listing the resource's methods in the Allow header.
+	// Unless given explicitly, HEAD is answered by the GET handlers without a body and OPTIONS with
+	// the Allow header.
+	Resource(string, Methods)
+	// MapResource adds the RESTful routes of a controller, see martini.MapResource.
+	MapResource(string, interface{}, ...Nested)
 
 	// NotFound sets the handlers that are called when a no route matches a request. Throws a basic 404 by default.
+	// Called within a Group, the handlers are used for requests below the group pattern instead, after the
+	// group handlers. The group with the longest matching pattern wins.
 	NotFound(...Handler)
 
+	// MethodNotAllowed sets the handlers that are called when routes match the path of a request but not
+	// its method. The Allow header listing the methods of those routes is set before they run. Throws a
+	// basic 405 by default.
+	MethodNotAllowed(...Handler)
+
+	// AutoOptions sets whether OPTIONS requests for paths without an OPTIONS route are answered with the
+	// methods of the routes matching the path in the Allow header. Off by default.
+	AutoOptions(bool)
+
+	// CaseInsensitive sets whether routes match paths regardless of their case, e.g. "/Users/42" for
+	// "/users/:id". Param values keep the case of the request. Off by default.
+	CaseInsensitive(bool)
+
+	// RedirectCase sets whether requests matching a route only regardless of case are redirected to the
+	// path in the casing of the route's pattern, permanently for GET and HEAD requests. Off by default.
+	RedirectCase(bool)
+
+	// Fallback sets a http.Handler, e.g. another mux, that is given requests no route matches before the
+	// NotFound handlers. Should it answer with a 404 the response is dropped and the NotFound handlers run.
+	Fallback(http.Handler)
+
+	// OnStart adds a hook run by Martini.Start if the router is mapped as martini.Routes or mounted on such
+	// a router, after the start hooks of the routers mounted on it.
+	OnStart(Hook)
+	// OnStop adds a hook run by Martini.Stop, before the stop hooks of the routers mounted on the router.
+	OnStop(Hook)
+	// HookTimeout limits the time each start and stop hook of the router may take.
+	HookTimeout(time.Duration)
+
+	// CheckConflicts returns the pairs of routes that match some of the same requests, like
+	// "/users/:id" and "/users/new", so shadowed routes are caught at startup. Patterns using regexp
+	// syntax other than params constrained as a whole segment are not checked. ClassicMartini.Validate
+	// reports the routes that are never matched.
+	CheckConflicts() []RouteConflict
+
 	// Handle is the entry point for routing. This is used as a martini.Handler
 	Handle(http.ResponseWriter, *http.Request, Context)
 }
 
+// Methods maps HTTP methods to the handlers of a resource registered with Router.Resource.
+//
+//	r.Resource("/things/:id", martini.Methods{
+//	  "GET":    {showThing},
+//	  "PUT":    {auth, updateThing},
+//	  "DELETE": {auth, deleteThing},
+//	})
+type Methods map[string][]Handler
+
 type router struct {
-	routes    []*route
-	notFounds []Handler
-	groups    []group
+	routes         []*route
+	notFounds      []Handler
+	notAlloweds    []Handler
+	notFoundSet    bool
+	groupNotFounds []groupNotFound
+	fallback       http.Handler
+	autoOptions    bool
+	fold           bool
+	redirectCase   bool
+	groups         []group
+	basePath       string
+	services       []scopedService
+	mounts         []mount
+	lifecycle      lifecycle
+	// after holds the handlers added with After outside of groups
+	after []Handler
+
+	// mu guards routes and names. Requests read the routes through idx, an immutable
+	// *routeIndex snapshot that is rebuilt after routes have been added.
+	mu    sync.Mutex
+	idx   atomic.Value
+	names map[string]*route
 }
 
 type group struct {
 	pattern  string
 	handlers []Handler
+	host     *hostPattern
+	after    []Handler
+}
+
+// groupNotFound holds the NotFound handlers set within a group.
+type groupNotFound struct {
+	pattern  string
+	regex    *regexp.Regexp
+	handlers []Handler
+	host     *hostPattern
 }
 
 // NewRouter creates a new Router instance.
@@ -61,14 +195,122 @@ type group struct {
 //	m.MapTo(r, (*martini.Routes)(nil))
 //
 // If you are using ClassicMartini, then this is done for you.
+//
+// In development the default NotFound handler lists the routes a request likely meant to reach.
 func NewRouter() Router {
-	return &router{notFounds: []Handler{http.NotFound}, groups: make([]group, 0)}
+	r := &router{notFounds: []Handler{http.NotFound}, notAlloweds: []Handler{methodNotAllowed(nil)}, groups: make([]group, 0), names: make(map[string]*route)}
+	if Env == Dev {
+		r.notFounds = []Handler{r.suggestRoutes}
+	}
+	return r
 }
 
 func (r *router) Group(pattern string, fn func(Router), h ...Handler) {
-	r.groups = append(r.groups, group{pattern, h})
+	r.withGroup(group{pattern: pattern, handlers: h}, fn)
+}
+
+func (r *router) Host(host string, fn func(Router), h ...Handler) {
+	r.withGroup(group{handlers: h, host: newHostPattern(host)}, fn)
+}
+
+// withGroup runs fn within the group and adds the group's after handlers to the routes added by fn.
+func (r *router) withGroup(g group, fn func(Router)) {
+	r.mu.Lock()
+	start := len(r.routes)
+	r.mu.Unlock()
+
+	r.groups = append(r.groups, g)
 	fn(r)
+	after := r.groups[len(r.groups)-1].after
 	r.groups = r.groups[:len(r.groups)-1]
+
+	if len(after) > 0 {
+		r.mu.Lock()
+		for _, route := range r.routes[start:] {
+			route.after = append(route.after, after...)
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *router) After(h ...Handler) {
+	for _, handler := range h {
+		validateHandler(handler)
+	}
+	if len(r.groups) == 0 {
+		r.after = append(r.after, h...)
+		return
+	}
+	g := &r.groups[len(r.groups)-1]
+	g.after = append(g.after, h...)
+}
+
+// groupHost returns the host pattern of the innermost Host group, or nil outside of one.
+func (r *router) groupHost() *hostPattern {
+	for i := len(r.groups) - 1; i >= 0; i-- {
+		if r.groups[i].host != nil {
+			return r.groups[i].host
+		}
+	}
+	return nil
+}
+
+// onHost runs fn within a Host group for the host pattern, or directly if it is nil.
+func (r *router) onHost(host *hostPattern, fn func(Router)) {
+	if host == nil {
+		fn(r)
+		return
+	}
+	r.Host(host.pattern, fn)
+}
+
+func (r *router) Mount(pattern string, sub Router, middleware ...Handler) {
+	s, ok := sub.(*router)
+	if !ok {
+		panic(fmt.Sprintf("martini: cannot mount %T, only routers created with NewRouter", sub))
+	}
+	s.mu.Lock()
+	routes := append([]*route(nil), s.routes...)
+	s.mu.Unlock()
+
+	prefix := strings.TrimSuffix(pattern, "/")
+	r.mounts = append(r.mounts, mount{prefix, s})
+	middleware = append([]Handler{s.provide}, middleware...)
+	r.Group(prefix, func(Router) {
+		for _, route := range routes {
+			p := route.pattern
+			if p == "/" && prefix != "" {
+				// "/api" answers for the root of the sub-router, like "/api/"
+				p = ""
+			}
+			route := route
+			r.onHost(route.host, func(Router) {
+				mounted := r.addRoute(route.method, p, route.handlers)
+				mounted.after = append(append(mounted.after, route.after...), s.after...)
+				for key, value := range route.meta {
+					mounted.Meta(key, value)
+				}
+				for _, l := range route.locales {
+					localized, _ := r.grouped(l.pattern, nil)
+					mounted.Localize(l.locale, localized)
+				}
+				if !route.autoNamed && route.name != "" {
+					r.setName(mounted, route.name)
+				}
+			})
+		}
+		for _, nf := range s.groupNotFounds {
+			nf := nf
+			r.onHost(nf.host, func(Router) {
+				r.Group(nf.pattern, func(Router) {
+					r.NotFound(nf.handlers...)
+				})
+			})
+		}
+		if s.notFoundSet {
+			r.NotFound(s.notFounds...)
+		}
+	}, middleware...)
 }
 
 func (r *router) Get(pattern string, h ...Handler) Route {
@@ -103,110 +345,984 @@ func (r *router) Any(pattern string, h ...Handler) Route {
 	return r.addRoute("*", pattern, h)
 }
 
+// methodToken matches the characters allowed in an HTTP method.
+var methodToken = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
+func (r *router) Method(method string, pattern string, h ...Handler) Route {
+	if !methodToken.MatchString(method) {
+		panic(fmt.Sprintf("martini: invalid HTTP method %q for route %s", method, pattern))
+	}
+	return r.addRoute(strings.ToUpper(method), pattern, h)
+}
+
+func (r *router) Match(methods []string, pattern string, h ...Handler) []Route {
+	routes := make([]Route, len(methods))
+	for i, method := range methods {
+		routes[i] = r.Method(method, pattern, h...)
+	}
+	return routes
+}
+
+func (r *router) Moved(pattern string, name string, status int) Route {
+	if status < 300 || status > 399 {
+		panic(fmt.Sprintf("martini: cannot move %s to %q with status %d, a redirect status is required", pattern, name, status))
+	}
+	return r.addRoute("*", pattern, []Handler{func(res http.ResponseWriter, req *http.Request, params Params, base BasePath) {
+		target := r.findRoute(name)
+		if target == nil {
+			panic(fmt.Sprintf("martini: route %q for moved route %s not found", name, pattern))
+		}
+		var args []string
+		for _, param := range routeParams(target.pattern) {
+			val, ok := params[param.name]
+			if !ok {
+				panic(fmt.Sprintf("martini: moved route %s has no param :%s for route %q", pattern, param.name, name))
+			}
+			args = append(args, val)
+		}
+		url := base.URL(urlWith(target.pattern, args))
+		if req.URL.RawQuery != "" {
+			url += "?" + req.URL.RawQuery
+		}
+		http.Redirect(res, req, url, status)
+	}})
+}
+
+func (r *router) Resource(pattern string, methods Methods) {
+	r.addResource(pattern, methods)
+}
+
+// addResource adds the routes of a resource and returns them by method.
+func (r *router) addResource(pattern string, methods Methods) map[string]*route {
+	handlers := make(map[string][]Handler)
+	for method, h := range methods {
+		handlers[strings.ToUpper(method)] = h
+	}
+	if _, ok := handlers["HEAD"]; !ok && handlers["GET"] != nil {
+		handlers["HEAD"] = append([]Handler{discardBody}, handlers["GET"]...)
+	}
+	_, options := handlers["OPTIONS"]
+	if !options {
+		handlers["OPTIONS"] = nil
+	}
+
+	var allow []string
+	for method := range handlers {
+		allow = append(allow, method)
+	}
+	sort.Strings(allow)
+	if !options {
+		handlers["OPTIONS"] = []Handler{allowMethods(allow)}
+	}
+
+	// HEAD goes first, GET routes would match HEAD requests as well
+	routes := make(map[string]*route)
+	if h, ok := handlers["HEAD"]; ok {
+		routes["HEAD"] = r.addRoute("HEAD", pattern, h)
+	}
+	for _, method := range allow {
+		if method != "HEAD" {
+			routes[method] = r.addRoute(method, pattern, handlers[method])
+		}
+	}
+	r.addRoute("*", pattern, []Handler{methodNotAllowed(allow)})
+	return routes
+}
+
+// allowMethods returns a handler answering OPTIONS requests with the allowed methods and an empty body.
+func allowMethods(allow []string) Handler {
+	header := strings.Join(allow, ", ")
+	return func(res http.ResponseWriter) {
+		res.Header().Set("Allow", header)
+		res.Header().Set("Content-Length", "0")
+		res.WriteHeader(http.StatusOK)
+	}
+}
+
+// discardBody maps a ResponseWriter that drops the response body, so GET handlers can answer HEAD requests.
+func discardBody(c Context, res http.ResponseWriter) {
+	c.MapTo(headResponseWriter{res}, (*http.ResponseWriter)(nil))
+}
+
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	if rw, ok := w.ResponseWriter.(ResponseWriter); ok && !rw.Written() {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return len(b), nil
+}
+
+// methodNotAllowed returns a handler responding with a 405 for the allowed methods. Without methods the
+// Allow header is left alone.
+func methodNotAllowed(allow []string) Handler {
+	header := strings.Join(allow, ", ")
+	return func(res http.ResponseWriter) {
+		if header != "" {
+			res.Header().Set("Allow", header)
+		}
+		http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// mount is a router mounted on another one.
+type mount struct {
+	prefix string
+	router *router
+}
+
+// scopedService is a service provided for the routes of a router.
+type scopedService struct {
+	typ reflect.Type
+	val reflect.Value
+}
+
+func (r *router) Provide(val interface{}) {
+	r.services = append(r.services, scopedService{reflect.TypeOf(val), reflect.ValueOf(val)})
+}
+
+func (r *router) ProvideAs(val interface{}, ifacePtr interface{}) {
+	r.services = append(r.services, scopedService{inject.InterfaceOf(ifacePtr), reflect.ValueOf(val)})
+}
+
+// providedTypes returns the types of the services provided by the router and the routers mounted on it.
+func (r *router) providedTypes() []reflect.Type {
+	var types []reflect.Type
+	for _, s := range r.services {
+		types = append(types, s.typ)
+	}
+	for _, m := range r.mounts {
+		types = append(types, m.router.providedTypes()...)
+	}
+	return types
+}
+
+// provide maps the provided services on the request context.
+func (r *router) provide(c Context) {
+	for _, s := range r.services {
+		c.Set(s.typ, s.val)
+	}
+}
+
 func (r *router) Handle(res http.ResponseWriter, req *http.Request, context Context) {
-	for _, route := range r.routes {
-		ok, vals := route.Match(req.Method, req.URL.Path)
+	r.provide(context)
+	idx := r.index()
+	pos, vals := idx.lookup(req.Method, req.URL.Path), map[string]string(nil)
+	if i, params := idx.trie.match(req.Method, req.URL.Path); i >= 0 && (pos < 0 || i < pos) {
+		pos, vals = i, params
+	}
+
+	// routes matched through their regexp take precedence if they were added before the other match
+	for _, i := range idx.dynamic {
+		if pos >= 0 && i > pos {
+			break
+		}
+		hostVals, ok := idx.routes[i].host.match(req.Host)
+		if !ok {
+			continue
+		}
+		ok, vals, locale := idx.routes[i].match(req.Method, req.URL.Path)
 		if ok {
-			params := Params(vals)
-			context.Map(params)
-			route.Handle(context, res)
+			for k, v := range hostVals {
+				vals[k] = v
+			}
+			if !r.redirectToCase(idx.routes[i], locale, res, req, context) {
+				r.serveRoute(idx.routes[i], vals, locale, context, res)
+			}
+			return
+		}
+	}
+	if pos >= 0 {
+		if vals == nil {
+			vals = make(map[string]string)
+		}
+		if !r.redirectToCase(idx.routes[pos], "", res, req, context) {
+			r.serveRoute(idx.routes[pos], vals, "", context, res)
+		}
+		return
+	}
+
+	if r.fallback != nil {
+		fw := &fallbackWriter{res: res, header: make(http.Header)}
+		r.fallback.ServeHTTP(fw, req)
+		if !fw.notFound {
 			return
 		}
 	}
 
+	if allow := r.allowedMethods(idx.methodsFor(req.Host, req.URL.Path)); len(allow) > 0 {
+		handlers := r.notAlloweds
+		if req.Method == "OPTIONS" && r.autoOptions {
+			handlers = []Handler{allowMethods(allow)}
+		}
+		res.Header().Set("Allow", strings.Join(allow, ", "))
+		c := &routeContext{context, 0, handlers}
+		context.MapTo(c, (*Context)(nil))
+		c.run()
+		return
+	}
+
 	// no routes exist, 404
-	c := &routeContext{context, 0, r.notFounds}
+	c := &routeContext{context, 0, r.notFoundsFor(req.Host, req.URL.Path)}
 	context.MapTo(c, (*Context)(nil))
 	c.run()
 }
 
+func (r *router) serveRoute(route *route, vals map[string]string, locale string, context Context, res http.ResponseWriter) {
+	params := Params(vals)
+	context.Map(params)
+	context.MapTo(route, (*Route)(nil))
+	context.Map(route.routeInfo())
+	if v := context.Get(reflect.TypeOf((*RequestLogger)(nil))); v.IsValid() {
+		v.Interface().(*RequestLogger).Bind("route", route.String())
+	}
+	if v := context.Get(reflect.TypeOf((*routeStatsRequest)(nil))); v.IsValid() {
+		v.Interface().(*routeStatsRequest).matched(route.String(), route.meta)
+	}
+	if locale != "" {
+		context.Map(Locale(locale))
+		var routes Routes = &localizedRoutes{r, locale}
+		if v := context.Get(reflect.TypeOf((*Routes)(nil)).Elem()); v.IsValid() {
+			if p, ok := v.Interface().(*prefixedRoutes); ok {
+				routes = &prefixedRoutes{routes, p.prefix}
+			}
+		}
+		context.MapTo(routes, (*Routes)(nil))
+	}
+	route.handle(context, r.after)
+}
+
 func (r *router) NotFound(handler ...Handler) {
-	r.notFounds = handler
+	if len(r.groups) == 0 {
+		r.notFounds = handler
+		r.notFoundSet = true
+		return
+	}
+
+	pattern, handlers := r.grouped("", handler)
+	host := r.groupHost()
+	nf := groupNotFound{pattern, mustCompilePattern(strings.TrimSuffix(pattern, "/") + "/**"), handlers, host}
+	for i, other := range r.groupNotFounds {
+		if other.pattern == pattern && other.host.String() == host.String() {
+			r.groupNotFounds[i] = nf
+			return
+		}
+	}
+	r.groupNotFounds = append(r.groupNotFounds, nf)
 }
 
-func (r *router) addRoute(method string, pattern string, handlers []Handler) *route {
-	if len(r.groups) > 0 {
-		groupPattern := ""
-		h := make([]Handler, 0)
-		for _, g := range r.groups {
-			groupPattern += g.pattern
-			h = append(h, g.handlers...)
+func (r *router) MethodNotAllowed(handler ...Handler) {
+	r.notAlloweds = handler
+}
+
+func (r *router) AutoOptions(enabled bool) {
+	r.autoOptions = enabled
+}
+
+func (r *router) CaseInsensitive(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fold = enabled
+	// recompile the patterns of the routes added so far
+	for _, route := range r.routes {
+		route.regex, route.err, route.once = nil, nil, sync.Once{}
+		for i, l := range route.locales {
+			route.locales[i].regex = mustCompileRoutePattern(l.pattern, enabled)
+		}
+	}
+	r.idx.Store((*routeIndex)(nil))
+}
+
+func (r *router) RedirectCase(enabled bool) {
+	r.redirectCase = enabled
+}
+
+// redirectToCase redirects the request to the path in the casing of the route's pattern if it differs. It
+// returns whether it did.
+func (r *router) redirectToCase(route *route, locale string, res http.ResponseWriter, req *http.Request, context Context) bool {
+	if !r.redirectCase || locale != "" {
+		return false
+	}
+	path := canonicalCase(route.pattern, req.URL.Path)
+	if path == req.URL.Path {
+		return false
+	}
+	var base BasePath
+	if v := context.Get(reflect.TypeOf(base)); v.IsValid() {
+		base = v.Interface().(BasePath)
+	}
+	url := base.URL(path)
+	if req.URL.RawQuery != "" {
+		url += "?" + req.URL.RawQuery
+	}
+	status := http.StatusPermanentRedirect
+	if req.Method == "GET" || req.Method == "HEAD" {
+		status = http.StatusMovedPermanently
+	}
+	http.Redirect(res, req, url, status)
+	return true
+}
+
+// canonicalCase returns the path with the segments matching literal segments of the pattern in the
+// casing of the pattern.
+func canonicalCase(pattern string, path string) string {
+	literals := strings.Split(pattern, "/")
+	segments := strings.Split(path, "/")
+	for i := range segments {
+		if i == len(literals) || strings.HasPrefix(literals[i], "**") {
+			break
+		}
+		if !strings.ContainsAny(literals[i], ":*().\\[]{}?+^$|") && strings.EqualFold(literals[i], segments[i]) {
+			segments[i] = literals[i]
 		}
+	}
+	return strings.Join(segments, "/")
+}
 
-		pattern = groupPattern + pattern
-		h = append(h, handlers...)
-		handlers = h
+// allowedMethods returns the sorted methods for the Allow header, adding HEAD for GET and OPTIONS if
+// it is answered automatically.
+func (r *router) allowedMethods(methods []string) []string {
+	if len(methods) == 0 {
+		return nil
+	}
+	allow := append([]string{}, methods...)
+	if hasMethod(allow, "GET") && !hasMethod(allow, "HEAD") {
+		allow = append(allow, "HEAD")
 	}
+	if r.autoOptions && !hasMethod(allow, "OPTIONS") {
+		allow = append(allow, "OPTIONS")
+	}
+	sort.Strings(allow)
+	return allow
+}
+
+func (r *router) Fallback(h http.Handler) {
+	r.fallback = h
+}
+
+// fallbackWriter passes the response of the Fallback handler through unless it is a 404.
+type fallbackWriter struct {
+	res      http.ResponseWriter
+	header   http.Header
+	written  bool
+	notFound bool
+}
+
+func (w *fallbackWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *fallbackWriter) WriteHeader(status int) {
+	if w.written {
+		return
+	}
+	w.written = true
+	if status == http.StatusNotFound {
+		w.notFound = true
+		return
+	}
+	for k, v := range w.header {
+		w.res.Header()[k] = v
+	}
+	w.res.WriteHeader(status)
+}
+
+func (w *fallbackWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.notFound {
+		return len(b), nil
+	}
+	return w.res.Write(b)
+}
+
+func (w *fallbackWriter) Flush() {
+	if f, ok := w.res.(http.Flusher); ok && !w.notFound {
+		w.WriteHeader(http.StatusOK)
+		f.Flush()
+	}
+}
+
+// notFoundsFor returns the NotFound handlers of the group with the longest pattern matching the host
+// and path, or the router's own NotFound handlers. Among groups with the same pattern, a Host group wins.
+func (r *router) notFoundsFor(host string, path string) []Handler {
+	handlers, longest, hosted := r.notFounds, -1, false
+	// "/api" covers "/api" and "/api/users" but not "/apis"
+	path = strings.TrimSuffix(path, "/") + "/"
+	for _, nf := range r.groupNotFounds {
+		if len(nf.pattern) < longest || (len(nf.pattern) == longest && (hosted || nf.host == nil)) {
+			continue
+		}
+		if _, ok := nf.host.match(host); !ok {
+			continue
+		}
+		if _, ok := matchRegex(nf.regex, path); ok {
+			handlers, longest, hosted = nf.handlers, len(nf.pattern), nf.host != nil
+		}
+	}
+	return handlers
+}
+
+// grouped prefixes the pattern and handlers with those of the current groups.
+func (r *router) grouped(pattern string, handlers []Handler) (string, []Handler) {
+	if len(r.groups) == 0 {
+		return pattern, handlers
+	}
+
+	groupPattern := ""
+	h := make([]Handler, 0)
+	for _, g := range r.groups {
+		groupPattern += g.pattern
+		h = append(h, g.handlers...)
+	}
+	return groupPattern + pattern, append(h, handlers...)
+}
+
+func (r *router) addRoute(method string, pattern string, handlers []Handler) *route {
+	own := len(handlers)
+	pattern, handlers = r.grouped(pattern, handlers)
 
 	route := newRoute(method, pattern, handlers)
 	route.Validate()
+	route.router = r
+	route.own = len(handlers) - own
+	route.host = r.groupHost()
+	r.mu.Lock()
 	r.routes = append(r.routes, route)
+	r.idx.Store((*routeIndex)(nil))
+	if name := autoName(method, route.host.String()+pattern); r.names[name] == nil {
+		route.name = name
+		route.autoNamed = true
+		r.names[name] = route
+	}
+	r.mu.Unlock()
 	return route
 }
 
-func (r *router) findRoute(name string) *route {
-	for _, route := range r.routes {
-		if route.name == name {
-			return route
+var nonAlphanumeric = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// autoName returns the name of a route that has not been named explicitly, e.g. "get_users_id"
+// for GET /users/:id.
+func autoName(method string, pattern string) string {
+	if method == "*" {
+		method = "any"
+	}
+	name := strings.ToLower(method)
+	if p := strings.Trim(nonAlphanumeric.ReplaceAllString(pattern, "_"), "_"); p != "" {
+		name += "_" + p
+	}
+	return name
+}
+
+// setName names the route. An explicit name can only be used once, while an automatic name
+// is given up in favor of a route explicitly named the same.
+func (r *router) setName(route *route, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if other := r.names[name]; other != nil && other != route {
+		if !other.autoNamed {
+			panic(fmt.Sprintf("martini: route name %q is already used by %s %s", name, other.method, other.pattern))
 		}
+		other.name = ""
+	}
+	if r.names[route.name] == route {
+		delete(r.names, route.name)
+	}
+	route.name = name
+	route.autoNamed = false
+	r.names[name] = route
+}
+
+// routeIndex speeds up route lookups. Routes with a static pattern, i.e. without params, wildcards or
+// other regexp syntax, are found with a single map lookup by method and path. Routes with params and
+// wildcards in whole segments are found by walking a trie. The positions of all other routes, including
+// those bound to a host, are kept in order so they can be checked against their regexp.
+//
+// The methods available for a path are cached, the cache goes away with the index when routes are added.
+type routeIndex struct {
+	routes  []*route
+	static  map[string]int
+	trie    routeTrie
+	dynamic []int
+	hosts   bool
+	// fold is set for case-insensitive routers, whose static paths are lowercased
+	fold bool
+
+	mu      sync.RWMutex
+	methods map[string][]string
+}
+
+// methodsCacheSize bounds the number of paths whose methods are cached, requests can come with
+// arbitrary paths.
+const methodsCacheSize = 1024
+
+// index returns the lookup index for the current routes, building it after routes have been added.
+func (r *router) index() *routeIndex {
+	if idx, _ := r.idx.Load().(*routeIndex); idx != nil {
+		return idx
 	}
 
-	return nil
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	idx, _ := r.idx.Load().(*routeIndex)
+	if idx == nil {
+		idx = newRouteIndex(r.routes, r.fold)
+		r.idx.Store(idx)
+	}
+	return idx
+}
+
+func newRouteIndex(routes []*route, fold bool) *routeIndex {
+	idx := &routeIndex{routes: routes, static: make(map[string]int), trie: routeTrie{newTrieNode(), fold}, fold: fold, methods: make(map[string][]string)}
+	for i, route := range routes {
+		if route.host != nil {
+			idx.dynamic = append(idx.dynamic, i)
+			idx.hosts = true
+			continue
+		}
+		if !route.static() || len(route.locales) > 0 {
+			if !idx.trie.add(i, route) {
+				idx.dynamic = append(idx.dynamic, i)
+			}
+			continue
+		}
+		// patterns match with an optional trailing slash
+		for _, path := range []string{route.pattern, route.pattern + "/"} {
+			key := route.method + " " + path
+			if fold {
+				key = route.method + " " + strings.ToLower(path)
+			}
+			if _, ok := idx.static[key]; !ok {
+				idx.static[key] = i
+			}
+		}
+	}
+	return idx
+}
+
+// lookup returns the position of the first static route matching the method and path, or -1.
+func (idx *routeIndex) lookup(method string, path string) int {
+	pos := -1
+	if idx.fold {
+		path = strings.ToLower(path)
+	}
+	candidates := []string{method, "*"}
+	if method == "HEAD" {
+		candidates = append(candidates, "GET")
+	}
+	for _, m := range candidates {
+		if i, ok := idx.static[m+" "+path]; ok && (pos < 0 || i < pos) {
+			pos = i
+		}
+	}
+	return pos
+}
+
+// methodsFor returns the methods of all routes matching the host and path, regardless of their host if
+// it is empty. The result is shared and must not be modified.
+func (idx *routeIndex) methodsFor(host string, path string) []string {
+	key := path
+	if idx.hosts {
+		key = host + " " + path
+	}
+	idx.mu.RLock()
+	methods, ok := idx.methods[key]
+	idx.mu.RUnlock()
+	if ok {
+		return methods
+	}
+
+	methods = []string{}
+	for _, route := range idx.routes {
+		if hasMethod(methods, route.method) {
+			continue
+		}
+		if _, ok := route.host.match(host); host != "" && !ok {
+			continue
+		}
+		if route.static() && len(route.locales) == 0 {
+			ok = equalPath(path, route.pattern, idx.fold) || equalPath(path, route.pattern+"/", idx.fold)
+		} else {
+			_, _, ok = route.matchPath(path)
+		}
+		if ok {
+			methods = append(methods, route.method)
+		}
+	}
+
+	idx.mu.Lock()
+	if len(idx.methods) >= methodsCacheSize {
+		idx.methods = make(map[string][]string)
+	}
+	idx.methods[key] = methods
+	idx.mu.Unlock()
+	return methods
+}
+
+// compile compiles the patterns of all routes concurrently and returns the errors for invalid ones.
+func (r *router) compile() []error {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   []error
+		routes = make(chan *route)
+	)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for route := range routes {
+				if err := route.compile(); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, route := range r.index().routes {
+		routes <- route
+	}
+	close(routes)
+	wg.Wait()
+	return errs
+}
+
+func (r *router) findRoute(name string) *route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.names[name]
 }
 
 // Route is an interface representing a Route in Martini's routing layer.
+// The methods configuring a route return it, so they can be chained:
+//
+//	r.Get("/users/:id", showUser).Name("user").Constraint("id", `\d+`).Use(auth).Meta("scope", "admin")
+//
+// Like everything but adding routes, routes have to be configured before serving starts.
 type Route interface {
 	// URLWith returns a rendering of the Route's url with the given string params.
 	URLWith([]string) string
-	Name(string)
+	// Pattern returns the pattern of the route, e.g. "/users/:id".
+	Pattern() string
+	// Method returns the method of the route, "*" for routes added with Any.
+	Method() string
+	// GetName returns the name of the route.
+	GetName() string
+	// Name sets the name used to refer to the route in URLFor. Routes are named automatically after
+	// their method and pattern, e.g. "get_users_id" for GET /users/:id, until Name is called.
+	// Naming two routes the same panics.
+	Name(string) Route
+	// Localize adds an alternative pattern for the route in the given locale, e.g. "/de/ueber-uns" for "/en/about".
+	// Requests matching it are handled by the route with the locale mapped as a martini.Locale, and URLFor
+	// renders the localized pattern while that locale is active.
+	Localize(locale string, pattern string) Route
+	// Use adds middleware running after the middleware of the route's groups and before its handlers.
+	Use(...Handler) Route
+	// Constraint constrains a param to match the regular expression, like ":id(expr)" in the pattern
+	// does. It panics if the pattern has no such param.
+	Constraint(param string, expr string) Route
+	// Constraints returns the regular expressions the params are constrained to, e.g. "id": `\d+`
+	// for "/users/:id(\d+)".
+	Constraints() map[string]string
+	// Meta attaches a value to the route under the key, for middleware that treats routes differently
+	// based on annotations rather than their paths. The values are mapped with the martini.RouteInfo
+	// of the matched route.
+	Meta(key string, value interface{}) Route
+	// After adds handlers running once the route's handler chain completed, even if a handler wrote the
+	// response early or panicked. Their return values are ignored.
+	After(...Handler) Route
 }
 
+// Locale is the locale of a localized route pattern. It is mapped into the request context when a request
+// matches a pattern added with Route.Localize.
+type Locale string
+
 type route struct {
 	method   string
 	regex    *regexp.Regexp
 	handlers []Handler
 	pattern  string
 	name     string
+	locales  []localePattern
+	once     sync.Once
+	err      error
+
+	router    *router
+	autoNamed bool
+	host      *hostPattern
+	// own is the position of the route's own handlers after the group handlers and the middleware added with Use
+	own  int
+	meta map[string]interface{}
+	// after holds the handlers added with After, those of the route's groups included
+	after []Handler
+
+	infoOnce sync.Once
+	info     RouteInfo
+}
+
+type localePattern struct {
+	locale  string
+	pattern string
+	regex   *regexp.Regexp
 }
 
+var (
+	paramRegex         = regexp.MustCompile(`:[^/#?()\.\\]+`)
+	wildcardRegex      = regexp.MustCompile(`\*\*`)
+	namedWildcardRegex = regexp.MustCompile(`\*\*[A-Za-z_]\w*`)
+)
+
+// patternCache holds the compiled regular expressions by route pattern, so identical patterns
+// registered for several methods share a single regexp.
+var patternCache = struct {
+	sync.Mutex
+	regexps map[string]*regexp.Regexp
+}{regexps: make(map[string]*regexp.Regexp)}
+
+// newRoute creates a route for the pattern. The pattern is compiled on the first match, which keeps
+// startup cheap for applications registering thousands of routes.
 func newRoute(method string, pattern string, handlers []Handler) *route {
-	route := route{method, nil, handlers, pattern, ""}
-	r := regexp.MustCompile(`:[^/#?()\.\\]+`)
-	pattern = r.ReplaceAllStringFunc(pattern, func(m string) string {
-		return fmt.Sprintf(`(?P<%s>[^/#?]+)`, m[1:])
+	return &route{method: method, handlers: handlers, pattern: pattern}
+}
+
+// String returns the method and pattern of the route, e.g. "GET /users/:id", with the host pattern of
+// routes bound to one in front of the pattern.
+func (r *route) String() string {
+	return r.method + " " + r.host.String() + r.pattern
+}
+
+func (r *route) Pattern() string {
+	return r.pattern
+}
+
+func (r *route) Method() string {
+	return r.method
+}
+
+func (r *route) GetName() string {
+	return r.name
+}
+
+// static returns whether the pattern only matches the literal path, possibly with a trailing slash.
+func (r *route) static() bool {
+	return staticPattern(r.pattern)
+}
+
+func staticPattern(pattern string) bool {
+	return !strings.ContainsAny(pattern, ":*().\\[]{}?+^$|")
+}
+
+// compile compiles the route pattern unless that already happened.
+func (r *route) compile() error {
+	r.once.Do(func() {
+		r.regex, r.err = compileRoutePattern(r.pattern, r.folded())
+	})
+	return r.err
+}
+
+// compiled returns the regular expression of the route pattern, compiling it on first use.
+func (r *route) compiled() *regexp.Regexp {
+	if err := r.compile(); err != nil {
+		panic(err)
+	}
+	return r.regex
+}
+
+// routeParam is a param in a route pattern, like ":id" or ":id(\d+)" with a constraint.
+type routeParam struct {
+	name       string
+	constraint string
+	start, end int
+	// wildcard is set for named wildcards like "**filepath", which match the rest of the path
+	wildcard bool
+}
+
+// routeParams returns the params of the pattern, including named wildcards. A param is followed by a
+// constraint if the parenthesis right after its name is balanced, other parentheses are regexp syntax
+// of the pattern.
+func routeParams(pattern string) []routeParam {
+	locs := paramRegex.FindAllStringIndex(pattern, -1)
+	locs = append(locs, namedWildcardRegex.FindAllStringIndex(pattern, -1)...)
+	sort.Slice(locs, func(i, j int) bool { return locs[i][0] < locs[j][0] })
+
+	var params []routeParam
+	end := 0
+	for _, loc := range locs {
+		if loc[0] < end {
+			// inside the constraint of the previous param
+			continue
+		}
+		if pattern[loc[0]] == '*' {
+			params = append(params, routeParam{name: pattern[loc[0]+2 : loc[1]], start: loc[0], end: loc[1], wildcard: true})
+			end = loc[1]
+			continue
+		}
+		p := routeParam{name: pattern[loc[0]+1 : loc[1]], start: loc[0], end: loc[1]}
+		if close := closingParen(pattern, loc[1]); close > 0 {
+			p.constraint, p.end = pattern[loc[1]+1:close], close+1
+		}
+		params = append(params, p)
+		end = p.end
+	}
+	return params
+}
+
+// closingParen returns the position of the parenthesis closing the one at i, skipping escaped
+// characters and character classes, or -1.
+func closingParen(pattern string, i int) int {
+	if i >= len(pattern) || pattern[i] != '(' {
+		return -1
+	}
+	depth, class := 0, false
+	for ; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// replaceParams replaces the params of the pattern, including their constraints, with the result of fn.
+func replaceParams(pattern string, fn func(routeParam) string) string {
+	var b strings.Builder
+	last := 0
+	for _, p := range routeParams(pattern) {
+		b.WriteString(pattern[last:p.start])
+		b.WriteString(fn(p))
+		last = p.end
+	}
+	b.WriteString(pattern[last:])
+	return b.String()
+}
+
+// folded returns whether the route belongs to a case-insensitive router.
+func (r *route) folded() bool {
+	return r.router != nil && r.router.fold
+}
+
+// equalPath compares paths, regardless of case if fold is set.
+func equalPath(a string, b string, fold bool) bool {
+	if fold {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// compilePattern converts a route pattern into the regular expression used for matching.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return compileRoutePattern(pattern, false)
+}
+
+// compileRoutePattern is like compilePattern but the expression ignores case if fold is set.
+func compileRoutePattern(pattern string, fold bool) (*regexp.Regexp, error) {
+	key, flags := pattern, ""
+	if fold {
+		key, flags = "(?i)"+pattern, "(?i)"
+	}
+	patternCache.Lock()
+	regex, ok := patternCache.regexps[key]
+	patternCache.Unlock()
+	if ok {
+		return regex, nil
+	}
+
+	expr := replaceParams(pattern, func(p routeParam) string {
+		if p.wildcard {
+			return fmt.Sprintf(`(?P<%s>[^#?]*)`, p.name)
+		}
+		if p.constraint != "" {
+			return fmt.Sprintf(`(?P<%s>%s)`, p.name, p.constraint)
+		}
+		return fmt.Sprintf(`(?P<%s>[^/#?]+)`, p.name)
 	})
-	r2 := regexp.MustCompile(`\*\*`)
 	var index int
-	pattern = r2.ReplaceAllStringFunc(pattern, func(m string) string {
+	expr = wildcardRegex.ReplaceAllStringFunc(expr, func(m string) string {
 		index++
 		return fmt.Sprintf(`(?P<_%d>[^#?]*)`, index)
 	})
-	pattern += `\/?`
-	route.regex = regexp.MustCompile(pattern)
-	return &route
+	expr += `\/?`
+	regex, err := regexp.Compile(flags + expr)
+	if err != nil {
+		return nil, fmt.Errorf("martini: invalid route pattern %q: %v", pattern, err)
+	}
+
+	patternCache.Lock()
+	patternCache.regexps[key] = regex
+	patternCache.Unlock()
+	return regex, nil
+}
+
+func mustCompilePattern(pattern string) *regexp.Regexp {
+	return mustCompileRoutePattern(pattern, false)
 }
 
-func (r route) MatchMethod(method string) bool {
+func mustCompileRoutePattern(pattern string, fold bool) *regexp.Regexp {
+	regex, err := compileRoutePattern(pattern, fold)
+	if err != nil {
+		panic(err)
+	}
+	return regex
+}
+
+func (r *route) MatchMethod(method string) bool {
 	return r.method == "*" || method == r.method || (method == "HEAD" && r.method == "GET")
 }
 
-func (r route) Match(method string, path string) (bool, map[string]string) {
+func (r *route) Match(method string, path string) (bool, map[string]string) {
+	ok, params, _ := r.match(method, path)
+	return ok, params
+}
+
+// match is like Match but also returns the locale of the localized pattern that matched, if any.
+func (r *route) match(method string, path string) (bool, map[string]string, string) {
 	// add Any method matching support
 	if !r.MatchMethod(method) {
-		return false, nil
+		return false, nil, ""
+	}
+
+	params, locale, ok := r.matchPath(path)
+	return ok, params, locale
+}
+
+// matchPath matches the path against the route pattern and its localized patterns, regardless of the method.
+func (r *route) matchPath(path string) (map[string]string, string, bool) {
+	if params, ok := matchRegex(r.compiled(), path); ok {
+		return params, "", true
 	}
+	for _, l := range r.locales {
+		if params, ok := matchRegex(l.regex, path); ok {
+			return params, l.locale, true
+		}
+	}
+	return nil, "", false
+}
 
-	matches := r.regex.FindStringSubmatch(path)
+func matchRegex(regex *regexp.Regexp, path string) (map[string]string, bool) {
+	matches := regex.FindStringSubmatch(path)
 	if len(matches) > 0 && matches[0] == path {
 		params := make(map[string]string)
-		for i, name := range r.regex.SubexpNames() {
+		for i, name := range regex.SubexpNames() {
 			if len(name) > 0 {
 				params[name] = matches[i]
 			}
 		}
-		return true, params
+		return params, true
 	}
-	return false, nil
+	return nil, false
 }
 
 func (r *route) Validate() {
@@ -216,23 +1332,57 @@ func (r *route) Validate() {
 }
 
 func (r *route) Handle(c Context, res http.ResponseWriter) {
+	r.handle(c, nil)
+}
+
+// handle runs the route's handlers, then its after handlers followed by the router's.
+func (r *route) handle(c Context, after []Handler) {
 	context := &routeContext{c, 0, r.handlers}
 	c.MapTo(context, (*Context)(nil))
+	if len(r.after) > 0 || len(after) > 0 {
+		defer context.runAfter(append(append([]Handler(nil), r.after...), after...))
+	}
 	context.run()
 }
 
+func (r *route) After(handlers ...Handler) Route {
+	for _, h := range handlers {
+		validateHandler(h)
+	}
+	r.after = append(r.after, handlers...)
+	return r
+}
+
 // URLWith returns the url pattern replacing the parameters for its values
 func (r *route) URLWith(args []string) string {
+	return urlWith(r.pattern, args)
+}
+
+// urlWithLocale is like URLWith but renders the localized pattern for locale if the route has one.
+func (r *route) urlWithLocale(locale string, args []string) string {
+	return urlWith(r.patternFor(locale), args)
+}
+
+// patternFor returns the pattern of the route in the locale, or its pattern if it isn't localized.
+func (r *route) patternFor(locale string) string {
+	for _, l := range r.locales {
+		if l.locale == locale {
+			return l.pattern
+		}
+	}
+	return r.pattern
+}
+
+func urlWith(pattern string, args []string) string {
 	if len(args) > 0 {
-		reg := regexp.MustCompile(`:[^/#?()\.\\]+`)
 		argCount := len(args)
 		i := 0
-		url := reg.ReplaceAllStringFunc(r.pattern, func(m string) string {
+		url := replaceParams(pattern, func(p routeParam) string {
 			var val interface{}
 			if i < argCount {
 				val = args[i]
 			} else {
-				val = m
+				val = pattern[p.start:p.end]
 			}
 			i += 1
 			return fmt.Sprintf(`%v`, val)
@@ -240,44 +1390,250 @@ func (r *route) URLWith(args []string) string {
 
 		return url
 	}
-	return r.pattern
+	return pattern
 }
 
-func (r *route) Name(name string) {
+func (r *route) Name(name string) Route {
+	if r.router != nil {
+		r.router.setName(r, name)
+		return r
+	}
 	r.name = name
+	return r
+}
+
+func (r *route) Use(handlers ...Handler) Route {
+	for _, h := range handlers {
+		validateHandler(h)
+	}
+	combined := make([]Handler, 0, len(r.handlers)+len(handlers))
+	combined = append(combined, r.handlers[:r.own]...)
+	combined = append(combined, handlers...)
+	r.handlers = append(combined, r.handlers[r.own:]...)
+	r.own += len(handlers)
+	return r
+}
+
+func (r *route) Constraint(param string, expr string) Route {
+	found := false
+	constrain := func(pattern string) string {
+		return replaceParams(pattern, func(p routeParam) string {
+			if p.name != param || p.wildcard {
+				return pattern[p.start:p.end]
+			}
+			found = true
+			return ":" + p.name + "(" + expr + ")"
+		})
+	}
+
+	pattern := constrain(r.pattern)
+	if !found {
+		panic(fmt.Sprintf("martini: route %s has no param %q to constrain", r, param))
+	}
+	if _, err := compilePattern(pattern); err != nil {
+		panic(err)
+	}
+	r.pattern, r.regex, r.err, r.once = pattern, nil, nil, sync.Once{}
+	for i, l := range r.locales {
+		r.locales[i].pattern = constrain(l.pattern)
+		r.locales[i].regex = mustCompileRoutePattern(r.locales[i].pattern, r.folded())
+	}
+	if r.router != nil {
+		// the route may have to move from the trie to the regexp routes
+		r.router.mu.Lock()
+		r.router.idx.Store((*routeIndex)(nil))
+		r.router.mu.Unlock()
+	}
+	return r
+}
+
+func (r *route) Constraints() map[string]string {
+	constraints := make(map[string]string)
+	for _, p := range routeParams(r.pattern) {
+		if p.constraint != "" {
+			constraints[p.name] = p.constraint
+		}
+	}
+	return constraints
+}
+
+func (r *route) Meta(key string, value interface{}) Route {
+	if r.meta == nil {
+		r.meta = make(map[string]interface{})
+	}
+	r.meta[key] = value
+	return r
+}
+
+func (r *route) Localize(locale string, pattern string) Route {
+	r.locales = append(r.locales, localePattern{locale, pattern, mustCompileRoutePattern(pattern, r.folded())})
+	return r
 }
 
 // Routes is a helper service for Martini's routing layer.
 type Routes interface {
-	// URLFor returns a rendered URL for the given route. Optional params can be passed to fulfill named parameters in the route.
+	// URLFor returns a rendered URL for the given route. Optional params can be passed to fulfill named parameters in the route,
+	// either in order or as a single map[string]interface{} by name, in which case the remaining keys are added as query
+	// string parameters:
+	//
+	//	routes.URLFor("user", 5)                                         // "/users/5"
+	//	routes.URLFor("user", map[string]interface{}{"id": 5, "tab": 2}) // "/users/5?tab=2"
+	//
+	// Values are escaped. It panics if the route does not exist, a param is of an unsupported type or missing from the map.
 	URLFor(name string, params ...interface{}) string
+	// URLForE is like URLFor but returns an error instead of panicking.
+	URLForE(name string, params ...interface{}) (string, error)
 	// MethodsFor returns an array of methods available for the path
 	MethodsFor(path string) []string
+	// All returns the registered routes in the order they were added, e.g. to print a route table.
+	All() []RouteInfo
 }
 
+// RouteInfo describes a registered route. The RouteInfo of the matched route is mapped into the request
+// context, so middleware can decide based on the annotations added with Route.Meta:
+//
+//	r.Get("/admin/users", listUsers).Meta("scope", "admin")
+//
+//	func authorize(route martini.RouteInfo, user *User) {
+//	  if route.Meta["scope"] == "admin" && !user.Admin {
+//	    panic(martini.HTTPError{Status: http.StatusForbidden})
+//	  }
+//	}
+type RouteInfo struct {
+	Method  string
+	Pattern string
+	// Host is the host pattern of routes added with Router.Host, empty for routes of any host.
+	Host string
+	Name string
+	// Handlers are the names of the functions handling the route, including group handlers and
+	// middleware added with Route.Use.
+	Handlers []string
+	// Meta holds the values attached with Route.Meta. It is shared by all requests and must not be modified.
+	Meta map[string]interface{}
+}
+
+// ErrRouteNotFound is returned by URLForE when no route has the given name.
+var ErrRouteNotFound = errors.New("route not found")
+
 // URLFor returns the url for the given route name.
 func (r *router) URLFor(name string, params ...interface{}) string {
+	return mustURL(r.urlFor(name, "", params))
+}
+
+// URLForE returns the url for the given route name, or an error if the route doesn't exist or the
+// params can't be rendered. Params can be strings, bools, any integer type or a fmt.Stringer.
+func (r *router) URLForE(name string, params ...interface{}) (string, error) {
+	return r.urlFor(name, "", params)
+}
+
+func mustURL(url string, err error) string {
+	if err != nil {
+		panic(err.Error())
+	}
+	return url
+}
+
+func (r *router) urlFor(name string, locale string, params []interface{}) (string, error) {
 	route := r.findRoute(name)
 
 	if route == nil {
-		panic("route not found")
+		return "", ErrRouteNotFound
+	}
+
+	if len(params) == 1 {
+		if named, ok := params[0].(map[string]interface{}); ok {
+			return route.urlWithNamed(r.basePath, locale, named)
+		}
 	}
 
 	var args []string
+	patternParams := routeParams(route.patternFor(locale))
 	for _, param := range params {
-		switch v := param.(type) {
-		case int:
-			args = append(args, strconv.FormatInt(int64(v), 10))
-		case string:
-			args = append(args, v)
-		default:
-			if v != nil {
-				panic("Arguments passed to URLFor must be integers or strings")
+		if param == nil {
+			continue
+		}
+		arg, err := urlParam(param)
+		if err != nil {
+			return "", err
+		}
+		wildcard := len(args) < len(patternParams) && patternParams[len(args)].wildcard
+		args = append(args, escapeParam(arg, wildcard))
+	}
+
+	return r.basePath + route.urlWithLocale(locale, args), nil
+}
+
+// escapeParam escapes a param value for a path. The slashes of a wildcard value separate segments.
+func escapeParam(value string, wildcard bool) string {
+	if !wildcard {
+		return url.PathEscape(value)
+	}
+	segments := strings.Split(value, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// urlWithNamed renders the url filling the params by name. Values not used by the pattern are added as
+// query string parameters.
+func (r *route) urlWithNamed(basePath string, locale string, named map[string]interface{}) (string, error) {
+	used := make(map[string]bool)
+	var err error
+	path := replaceParams(r.patternFor(locale), func(p routeParam) string {
+		val, ok := named[p.name]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("martini: param %s of route %s is missing", p.name, r.name)
 			}
+			return ""
+		}
+		used[p.name] = true
+		arg, paramErr := urlParam(val)
+		if paramErr != nil && err == nil {
+			err = paramErr
 		}
+		return escapeParam(arg, p.wildcard)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	query := make(url.Values)
+	for name, val := range named {
+		if used[name] || val == nil {
+			continue
+		}
+		arg, err := urlParam(val)
+		if err != nil {
+			return "", err
+		}
+		query.Set(name, arg)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return basePath + path, nil
+}
+
+// urlParam renders a param passed to URLFor.
+func urlParam(param interface{}) (string, error) {
+	switch v := param.(type) {
+	case string:
+		return v, nil
+	case fmt.Stringer:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
 	}
 
-	return route.URLWith(args)
+	switch v := reflect.ValueOf(param); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("Arguments passed to URLFor must be integers, strings, bools or fmt.Stringers, got %T", param)
 }
 
 func hasMethod(methods []string, method string) bool {
@@ -291,14 +1647,49 @@ func hasMethod(methods []string, method string) bool {
 
 // MethodsFor returns all methods available for path
 func (r *router) MethodsFor(path string) []string {
-	methods := []string{}
-	for _, route := range r.routes {
-		matches := route.regex.FindStringSubmatch(path)
-		if len(matches) > 0 && matches[0] == path && !hasMethod(methods, route.method) {
-			methods = append(methods, route.method)
-		}
+	return append([]string{}, r.index().methodsFor("", path)...)
+}
+
+// All returns the registered routes
+func (r *router) All() []RouteInfo {
+	routes := r.index().routes
+	infos := make([]RouteInfo, len(routes))
+	for i, route := range routes {
+		infos[i] = route.describe()
 	}
-	return methods
+	return infos
+}
+
+// describe returns the RouteInfo of the route.
+func (r *route) describe() RouteInfo {
+	info := RouteInfo{Method: r.method, Pattern: r.pattern, Host: r.host.String(), Name: r.name, Meta: r.meta}
+	for _, h := range r.handlers {
+		info.Handlers = append(info.Handlers, handlerName(h))
+	}
+	return info
+}
+
+// routeInfo returns the RouteInfo mapped for requests matching the route, built once.
+func (r *route) routeInfo() RouteInfo {
+	r.infoOnce.Do(func() {
+		r.info = r.describe()
+	})
+	return r.info
+}
+
+// localizedRoutes is the Routes service mapped for requests that matched a localized pattern.
+// It renders URLs in the locale of the request.
+type localizedRoutes struct {
+	*router
+	locale string
+}
+
+func (r *localizedRoutes) URLFor(name string, params ...interface{}) string {
+	return mustURL(r.urlFor(name, r.locale, params))
+}
+
+func (r *localizedRoutes) URLForE(name string, params ...interface{}) (string, error) {
+	return r.urlFor(name, r.locale, params)
 }
 
 type routeContext struct {
@@ -312,12 +1703,21 @@ func (r *routeContext) Next() {
 	r.run()
 }
 
+// runAfter invokes the after handlers of a route.
+func (r *routeContext) runAfter(handlers []Handler) {
+	for _, handler := range handlers {
+		if _, err := invoke(r, handler); err != nil {
+			panic(handlerError(r, handler, err))
+		}
+	}
+}
+
 func (r *routeContext) run() {
 	for r.index < len(r.handlers) {
 		handler := r.handlers[r.index]
-		vals, err := r.Invoke(handler)
+		vals, err := invoke(r, handler)
 		if err != nil {
-			panic(err)
+			panic(handlerError(r, handler, err))
 		}
 		r.index += 1
 
@@ -1,8 +1,14 @@
 package martini
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -270,7 +276,7 @@ func Test_MethodsFor(t *testing.T) {
 	router.Put("/foo", func() {
 	})
 
-	router.NotFound(func(routes Routes, w http.ResponseWriter, r *http.Request) {
+	router.MethodNotAllowed(func(routes Routes, w http.ResponseWriter, r *http.Request) {
 		methods := routes.MethodsFor(r.URL.Path)
 		if len(methods) != 0 {
 			w.Header().Set("Allow", strings.Join(methods, ","))
@@ -282,6 +288,51 @@ func Test_MethodsFor(t *testing.T) {
 	expect(t, recorder.Header().Get("Allow"), "GET,PUT")
 }
 
+func Test_MethodsFor_Cache(t *testing.T) {
+	r := NewRouter()
+	r.Get("/users/:id", func() {})
+	expect(t, strings.Join(r.MethodsFor("/users/1"), ","), "GET")
+
+	// the caller's copy doesn't leak into the cache
+	r.MethodsFor("/users/1")[0] = "POST"
+	expect(t, strings.Join(r.MethodsFor("/users/1"), ","), "GET")
+
+	// adding routes invalidates the cache
+	r.Delete("/users/:id", func() {})
+	r.Any("/users/1", func() {})
+	expect(t, strings.Join(r.MethodsFor("/users/1"), ","), "GET,DELETE,*")
+	expect(t, strings.Join(r.MethodsFor("/users/1/"), ","), "GET,DELETE,*")
+	expect(t, len(r.MethodsFor("/posts")), 0)
+}
+
+func listUsers() {}
+
+func Test_RoutesAll(t *testing.T) {
+	r := NewRouter()
+	auth := func() {}
+	r.Get("/users", listUsers).Name("users")
+	r.Group("/admin", func(r Router) {
+		r.Post("/users/:id", func() {})
+	}, auth)
+	r.Host("api.example.com", func(r Router) {
+		r.Get("/status", func() {})
+	})
+
+	routes := r.All()
+	expect(t, len(routes), 3)
+	expect(t, routes[0].Method, "GET")
+	expect(t, routes[0].Pattern, "/users")
+	expect(t, routes[0].Host, "")
+	expect(t, routes[0].Name, "users")
+	expect(t, strings.Join(routes[0].Handlers, ","), "github.com/go-martini/martini.listUsers")
+	expect(t, routes[1].Method, "POST")
+	expect(t, routes[1].Pattern, "/admin/users/:id")
+	expect(t, routes[1].Name, "post_admin_users_id")
+	expect(t, len(routes[1].Handlers), 2)
+	expect(t, routes[2].Host, "api.example.com")
+	expect(t, routes[2].Pattern, "/status")
+}
+
 func Test_NotFound(t *testing.T) {
 	router := NewRouter()
 	recorder := httptest.NewRecorder()
@@ -373,6 +424,78 @@ func Test_NotFoundStacking(t *testing.T) {
 	expect(t, recorder.Body.String(), "Not Found")
 }
 
+func Test_NotFoundPerGroup(t *testing.T) {
+	r := NewRouter()
+	r.NotFound(func() string { return "html" })
+	r.Group("/api", func(r Router) {
+		r.NotFound(func() (int, string) { return 404, "api" })
+		r.Group("/v2/:org", func(r Router) {
+			r.NotFound(func(p Params) (int, string) { return 404, "v2 " + p["org"] })
+		}, func(c Context) { c.Map(Params{"org": "acme"}) })
+	})
+
+	for path, body := range map[string]string{
+		"/foo":             "html",
+		"/apis":            "html",
+		"/api":             "api",
+		"/api/users":       "api",
+		"/api/v2":          "api",
+		"/api/v2/acme/foo": "v2 acme",
+	} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.Handle(recorder, req, New().createContext(recorder, req))
+		expect(t, recorder.Body.String(), body)
+	}
+}
+
+func Test_Fallback(t *testing.T) {
+	r := NewRouter()
+	r.Get("/new", func() string { return "martini" })
+	legacy := http.NewServeMux()
+	legacy.HandleFunc("/old", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Legacy", "true")
+		w.Write([]byte("legacy"))
+	})
+	r.Fallback(legacy)
+	r.NotFound(func() (int, string) { return 404, "martini 404" })
+
+	for path, body := range map[string]string{"/new": "martini", "/old": "legacy", "/missing": "martini 404"} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.Handle(recorder, req, New().createContext(recorder, req))
+		expect(t, recorder.Body.String(), body)
+		expect(t, recorder.Header().Get("X-Legacy") == "true", path == "/old")
+		// the legacy 404 headers are dropped along with its body
+		expect(t, recorder.Header().Get("X-Content-Type-Options"), "")
+	}
+}
+
+func Test_Moved(t *testing.T) {
+	r := NewRouter()
+	r.Moved("/people/:user/posts/:id", "post", http.StatusMovedPermanently)
+	r.Get("/users/:user/posts/:id", func() {}).Name("post")
+	r.Moved("/gone", "missing", http.StatusFound)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/people/5/posts/7?page=2", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusMovedPermanently)
+	expect(t, recorder.Header().Get("Location"), "/users/5/posts/7?page=2")
+
+	r.Moved("/members/:user/posts/:id", "post", http.StatusPermanentRedirect)
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/members/5/posts/7", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusPermanentRedirect)
+	expect(t, recorder.Header().Get("Location"), "/users/5/posts/7")
+
+	defer func() {
+		expect(t, recover(), "martini: cannot move /old to \"post\" with status 200, a redirect status is required")
+	}()
+	r.Moved("/old", "post", http.StatusOK)
+}
+
 func Test_Any(t *testing.T) {
 	router := NewRouter()
 	router.Any("/foo", func(res http.ResponseWriter) {
@@ -420,3 +543,684 @@ func Test_URLFor(t *testing.T) {
 	context.MapTo(router, (*Routes)(nil))
 	router.Handle(recorder, req, context)
 }
+
+func Test_LocalizedRoutes(t *testing.T) {
+	router := NewRouter()
+
+	router.Get("/en/contact", func() {}).Name("contact")
+	about := router.Get("/en/about", func(routes Routes, locale Locale, res http.ResponseWriter) {
+		expect(t, string(locale), "de")
+		expect(t, routes.URLFor("about"), "/de/ueber-uns")
+		expect(t, routes.URLFor("contact"), "/en/contact")
+		res.WriteHeader(http.StatusAccepted)
+	})
+	about.Name("about")
+	about.Localize("de", "/de/ueber-uns")
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:3000/de/ueber-uns", nil)
+	context := New().createContext(recorder, req)
+	context.MapTo(router, (*Routes)(nil))
+	router.Handle(recorder, req, context)
+	expect(t, recorder.Code, http.StatusAccepted)
+	expect(t, router.URLFor("about"), "/en/about")
+}
+
+func Test_RoutePatternCache(t *testing.T) {
+	get := newRoute("GET", "/cache/:id", nil)
+	post := newRoute("POST", "/cache/:id", nil)
+	expect(t, get.regex == nil, true)
+
+	ok, params := get.Match("GET", "/cache/42")
+	expect(t, ok, true)
+	expect(t, params["id"], "42")
+	expect(t, post.compiled(), get.regex)
+}
+
+func Test_RouterCompile(t *testing.T) {
+	router := NewRouter().(*router)
+	router.Get("/foo/:id", func() {})
+	router.Get("/bar/(", func() {})
+
+	errs := router.compile()
+	expect(t, len(errs), 1)
+	expect(t, strings.HasPrefix(errs[0].Error(), `martini: invalid route pattern "/bar/("`), true)
+}
+
+func Test_StaticRoutes(t *testing.T) {
+	r := NewRouter()
+	result := ""
+	r.Get("/users/:id", func(params Params) {
+		result += "dynamic:" + params["id"] + " "
+	})
+	r.Get("/users/new", func() {
+		result += "shadowed "
+	})
+	r.Get("/about", func(params Params) {
+		expect(t, len(params), 0)
+		result += "about "
+	})
+	r.Any("/any", func() {
+		result += "any "
+	})
+	r.Get("/any", func() {
+		result += "get any "
+	})
+
+	idx := r.(*router).index()
+	expect(t, len(idx.dynamic), 0)
+	pos, _ := idx.trie.match("GET", "/users/new")
+	expect(t, pos, 0)
+	expect(t, idx.lookup("GET", "/about/"), 2)
+	expect(t, idx.lookup("HEAD", "/about"), 2)
+	expect(t, idx.lookup("POST", "/about"), -1)
+
+	for _, path := range []string{"/users/new", "/about", "/about/", "/any"} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+path, nil)
+		context := New().createContext(recorder, req)
+		r.Handle(recorder, req, context)
+	}
+	expect(t, result, "dynamic:new about about any ")
+}
+
+func Test_AddRoutesWhileServing(t *testing.T) {
+	r := NewRouter()
+	r.Get("/foo", func() {})
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			r.Get("/bar/"+strconv.Itoa(i), func() {})
+		}
+		close(done)
+	}()
+
+	for i := 0; i < 100; i++ {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000/foo", nil)
+		context := New().createContext(recorder, req)
+		r.Handle(recorder, req, context)
+		expect(t, recorder.Code, http.StatusOK)
+	}
+	<-done
+	expect(t, len(r.(*router).index().routes), 101)
+}
+
+func Test_Resource(t *testing.T) {
+	r := NewRouter()
+	r.Resource("/things/:id", Methods{
+		"GET": {func(params Params) string {
+			return "thing " + params["id"]
+		}},
+		"delete": {func(c Context) {}, func() (int, string) {
+			return http.StatusNoContent, ""
+		}},
+	})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:3000/things/42", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Body.String(), "thing 42")
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "http://localhost:3000/things/42", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusNoContent)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "http://localhost:3000/things/42", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusMethodNotAllowed)
+	expect(t, recorder.Header().Get("Allow"), "DELETE, GET, HEAD, OPTIONS")
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("HEAD", "http://localhost:3000/things/42", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusOK)
+	expect(t, recorder.Body.Len(), 0)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("OPTIONS", "http://localhost:3000/things/42", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusOK)
+	expect(t, recorder.Header().Get("Allow"), "DELETE, GET, HEAD, OPTIONS")
+	expect(t, recorder.Body.Len(), 0)
+}
+
+func Test_RouteAutoNames(t *testing.T) {
+	r := NewRouter()
+	r.Get("/users/:id", func() {})
+	r.Any("/", func() {})
+	r.Post("/users/:id/posts/**", func() {})
+	auto := r.Put("/things", func() {})
+	r.Patch("/things", func() {}).Name("put_things")
+
+	expect(t, autoName("GET", "/users/:id"), "get_users_id")
+	expect(t, r.URLFor("get_users_id", 42), "/users/42")
+	expect(t, r.URLFor("any"), "/")
+	expect(t, r.URLFor("post_users_id_posts", 1), "/users/1/posts/**")
+
+	// the explicit name wins over the automatic one
+	expect(t, r.(*router).findRoute("put_things").method, "PATCH")
+	expect(t, auto.(*route).name, "")
+}
+
+func Test_RouteNameCollision(t *testing.T) {
+	r := NewRouter()
+	r.Get("/foo", func() {}).Name("foo")
+
+	defer func() {
+		expect(t, recover(), `martini: route name "foo" is already used by GET /foo`)
+	}()
+	r.Get("/bar", func() {}).Name("foo")
+}
+
+type urlSlug string
+
+func (s urlSlug) String() string {
+	return "slug-" + string(s)
+}
+
+func Test_URLForE(t *testing.T) {
+	r := NewRouter()
+	r.Get("/posts/:id/:slug/:draft", func() {}).Name("post")
+
+	url, err := r.URLForE("post", int64(5), urlSlug("hello"), true)
+	expect(t, err, nil)
+	expect(t, url, "/posts/5/slug-hello/true")
+
+	url, err = r.URLForE("post", uint(7), "a", false)
+	expect(t, err, nil)
+	expect(t, url, "/posts/7/a/false")
+
+	_, err = r.URLForE("missing")
+	expect(t, err, ErrRouteNotFound)
+
+	_, err = r.URLForE("post", 1.5)
+	expect(t, err.Error(), "Arguments passed to URLFor must be integers, strings, bools or fmt.Stringers, got float64")
+
+	defer func() {
+		expect(t, recover(), "route not found")
+	}()
+	r.URLFor("missing")
+}
+
+func Test_RouteInfo(t *testing.T) {
+	r := NewRouter()
+	authorize := func(res http.ResponseWriter, route RouteInfo, req *http.Request) {
+		if route.Meta["scope"] == "admin" && req.Header.Get("X-Admin") == "" {
+			res.WriteHeader(http.StatusForbidden)
+		}
+	}
+	r.Group("/admin", func(r Router) {
+		r.Get("/users", func(route RouteInfo) string {
+			return route.Name + " " + route.Pattern
+		}).Meta("scope", "admin").Name("admin_users")
+	}, authorize)
+	r.Get("/public", authorize, func(route RouteInfo) string {
+		return route.Method + " " + route.Pattern
+	})
+	sub := NewRouter()
+	sub.Get("/report", authorize, func() string { return "report" }).Meta("scope", "admin")
+	r.Mount("/reports", sub)
+
+	serve := func(path string, admin bool) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+path, nil)
+		if admin {
+			req.Header.Set("X-Admin", "1")
+		}
+		r.Handle(recorder, req, New().createContext(recorder, req))
+		return recorder
+	}
+	expect(t, serve("/admin/users", false).Code, http.StatusForbidden)
+	expect(t, serve("/admin/users", true).Body.String(), "admin_users /admin/users")
+	expect(t, serve("/public", false).Body.String(), "GET /public")
+	expect(t, serve("/reports/report", false).Code, http.StatusForbidden)
+
+	expect(t, r.All()[0].Meta["scope"], "admin")
+	expect(t, len(r.All()[1].Meta), 0)
+}
+
+func Test_MatchedRoute(t *testing.T) {
+	m := Classic()
+	var tags []string
+	m.Use(func(c Context) {
+		c.Next()
+		if v := c.Get(reflect.TypeOf((*Route)(nil)).Elem()); v.IsValid() {
+			route := v.Interface().(Route)
+			tags = append(tags, route.Method()+" "+route.Pattern()+" "+route.GetName())
+		}
+	})
+	m.Get("/users/:id", func(route Route) string {
+		return route.Pattern()
+	}).Name("user")
+	m.Any("/any", func() {})
+
+	for _, path := range []string{"/users/1", "/users/2", "/any", "/missing"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	expect(t, strings.Join(tags, ", "), "GET /users/:id user, GET /users/:id user, * /any any_any")
+	expect(t, m.Validate(), nil)
+}
+
+func Test_URLForNamed(t *testing.T) {
+	r := NewRouter()
+	r.Get(`/users/:id(\d+)/posts/:slug`, func() {}).Name("post").Localize("de", "/benutzer/:id/beitraege/:slug")
+
+	expect(t, r.URLFor("post", map[string]interface{}{"slug": "hello", "id": 5}), "/users/5/posts/hello")
+	expect(t, r.URLFor("post", map[string]interface{}{"id": 5, "slug": "a b/c", "page": 2, "q": "x&y", "skip": nil}),
+		"/users/5/posts/a%20b%2Fc?page=2&q=x%26y")
+
+	// positional values are escaped as well
+	expect(t, r.URLFor("post", 5, "a b/c"), "/users/5/posts/a%20b%2Fc")
+
+	// the localized pattern is filled by name too
+	localized := &localizedRoutes{r.(*router), "de"}
+	expect(t, localized.URLFor("post", map[string]interface{}{"id": 1, "slug": "x", "lang": "de"}), "/benutzer/1/beitraege/x?lang=de")
+
+	_, err := r.URLForE("post", map[string]interface{}{"id": 5})
+	expect(t, err.Error(), "martini: param slug of route post is missing")
+	_, err = r.URLForE("post", map[string]interface{}{"id": 5, "slug": "a", "page": 1.5})
+	expect(t, err.Error(), "Arguments passed to URLFor must be integers, strings, bools or fmt.Stringers, got float64")
+}
+
+func Test_CustomMethods(t *testing.T) {
+	r := NewRouter()
+	result := ""
+	r.Method("PURGE", "/cache/:key", func(params Params) {
+		result += "purge:" + params["key"] + " "
+	})
+	r.Method("propfind", "/dav/**path", func(params Params) {
+		result += "propfind:" + params["path"] + " "
+	}).Name("propfind")
+	routes := r.Match([]string{"GET", "POST"}, "/form", func(req *http.Request) {
+		result += "form:" + req.Method + " "
+	})
+	expect(t, len(routes), 2)
+	routes[1].Name("submit")
+
+	for _, req := range []string{"PURGE /cache/home", "PROPFIND /dav/a/b", "GET /form", "POST /form", "HEAD /form"} {
+		var method, path string
+		fmt.Sscan(req, &method, &path)
+		recorder := httptest.NewRecorder()
+		r2, _ := http.NewRequest(method, "http://localhost:3000"+path, nil)
+		r.Handle(recorder, r2, New().createContext(recorder, r2))
+	}
+	expect(t, result, "purge:home propfind:a/b form:GET form:POST form:HEAD ")
+
+	expect(t, strings.Join(r.MethodsFor("/cache/home"), ","), "PURGE")
+	expect(t, strings.Join(r.MethodsFor("/form"), ","), "GET,POST")
+	expect(t, r.URLFor("get_form"), "/form")
+	expect(t, r.URLFor("submit"), "/form")
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://localhost:3000/cache/home", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusMethodNotAllowed)
+	expect(t, recorder.Header().Get("Allow"), "PURGE")
+
+	defer func() {
+		expect(t, recover(), `martini: invalid HTTP method "GET POST" for route /form`)
+	}()
+	r.Method("GET POST", "/form")
+}
+
+func Test_After(t *testing.T) {
+	r := NewRouter()
+	result := ""
+	step := func(name string) Handler {
+		return func() { result += name + " " }
+	}
+	r.After(step("router"))
+	r.Group("/admin", func(r Router) {
+		r.Get("/users", func(res http.ResponseWriter) {
+			result += "users "
+			// the rest of the chain is skipped, the after handlers still run
+			res.WriteHeader(http.StatusNoContent)
+		}, step("skipped")).After(step("route"))
+		r.After(step("admin"))
+		r.Group("/audit", func(r Router) {
+			r.After(step("audit"))
+			r.Get("/log", func() {
+				result += "log "
+				panic("boom")
+			})
+		})
+	})
+	r.Get("/public", step("public"))
+
+	sub := NewRouter()
+	sub.Get("/", step("sub")).After(step("sub-route"))
+	sub.After(step("sub-router"))
+	r.Mount("/sub", sub)
+
+	serve := func(path string) {
+		result = ""
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+path, nil)
+		r.Handle(recorder, req, New().createContext(recorder, req))
+	}
+	serve("/admin/users")
+	expect(t, result, "users route admin router ")
+	serve("/public")
+	expect(t, result, "public router ")
+	serve("/sub")
+	expect(t, result, "sub sub-route sub-router router ")
+
+	func() {
+		defer func() {
+			expect(t, recover(), "boom")
+		}()
+		serve("/admin/audit/log")
+	}()
+	expect(t, result, "log audit admin router ")
+}
+
+func Test_NamedWildcard(t *testing.T) {
+	r := NewRouter()
+	result := ""
+	r.Get("/static/**filepath", func(params Params) {
+		result += "static:" + params["filepath"] + " "
+	}).Name("static")
+	// the regexp routes capture it as well
+	r.Get(`/v(\d)/docs/**page`, func(params Params) {
+		result += "docs:" + params["page"] + " "
+	}).Name("docs")
+	r.Get("/x/**/y/**rest", func(params Params) {
+		result += "x:" + params["_1"] + ":" + params["rest"] + " "
+	})
+
+	for _, path := range []string{"/static/css/app.css", "/v2/docs/guide/intro", "/x/a/b/y/c/d"} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+path, nil)
+		r.Handle(recorder, req, New().createContext(recorder, req))
+	}
+	expect(t, result, "static:css/app.css docs:guide/intro x:a/b:c/d ")
+
+	expect(t, r.URLFor("static", "css/my app.css"), "/static/css/my%20app.css")
+	expect(t, r.URLFor("static", map[string]interface{}{"filepath": "js/app.js", "v": 3}), "/static/js/app.js?v=3")
+
+	defer func() {
+		expect(t, recover(), `martini: route GET /static/**filepath has no param "filepath" to constrain`)
+	}()
+	r.Get("/static/**filepath", func() {}).Constraint("filepath", `\w+`)
+}
+
+func Test_RouteConstraints(t *testing.T) {
+	r := NewRouter()
+	result := ""
+	id := r.Get(`/users/:id(\d+)`, func(params Params) {
+		result += "id:" + params["id"] + " "
+	})
+	r.Get("/users/:name", func(params Params) {
+		result += "name:" + params["name"] + " "
+	})
+	r.Get(`/files/:year([0-9]{4})/:path([^#?]+)`, func(params Params) {
+		result += "file:" + params["year"] + ":" + params["path"] + " "
+	})
+	r.Get(`/tags/:tag((?:a|b)c?)`, func(params Params) {
+		result += "tag:" + params["tag"] + " "
+	})
+
+	for _, path := range []string{"/users/42", "/users/bob", "/users/42/", "/files/2024/a/b.txt", "/files/24/a", "/tags/ac", "/tags/c"} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+path, nil)
+		r.Handle(recorder, req, New().createContext(recorder, req))
+	}
+	expect(t, result, "id:42 name:bob id:42 file:2024:a/b.txt tag:ac ")
+
+	expect(t, id.Constraints()["id"], `\d+`)
+	expect(t, len(id.Constraints()), 1)
+	expect(t, id.URLWith([]string{"7"}), "/users/7")
+	expect(t, urlWith(`/files/:year([0-9]{4})/:path([^#?]+)`, []string{"2024", "a/b"}), "/files/2024/a/b")
+
+	params := routeParams(`/a/:x([(])/:y(a\)b)/:z/(foo)`)
+	expect(t, len(params), 3)
+	expect(t, params[0].constraint, "[(]")
+	expect(t, params[1].constraint, `a\)b`)
+	expect(t, params[2].name, "z")
+	expect(t, params[2].constraint, "")
+}
+
+func Test_MethodNotAllowed(t *testing.T) {
+	r := NewRouter()
+	r.Get("/users/:id", func() {})
+	r.Delete("/users/:id", func() {})
+	r.Post("/users", func() {})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "http://localhost:3000/users/5", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusMethodNotAllowed)
+	expect(t, recorder.Header().Get("Allow"), "DELETE, GET, HEAD")
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "http://localhost:3000/posts", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusNotFound)
+
+	r.MethodNotAllowed(func(res http.ResponseWriter) (int, string) {
+		return http.StatusMethodNotAllowed, "only " + res.Header().Get("Allow")
+	})
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "http://localhost:3000/users", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusMethodNotAllowed)
+	expect(t, recorder.Body.String(), "only POST")
+}
+
+func Test_AutoOptions(t *testing.T) {
+	r := NewRouter()
+	r.Get("/users/:id", func() {})
+	r.Put("/users/:id", func() {})
+	r.Options("/posts", func(res http.ResponseWriter) {
+		res.Header().Set("Allow", "custom")
+	})
+	r.Get("/posts", func() {})
+
+	options := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "http://localhost:3000"+path, nil)
+		r.Handle(recorder, req, New().createContext(recorder, req))
+		return recorder
+	}
+
+	expect(t, options("/users/5").Code, http.StatusMethodNotAllowed)
+
+	r.AutoOptions(true)
+	res := options("/users/5")
+	expect(t, res.Code, http.StatusOK)
+	expect(t, res.Header().Get("Allow"), "GET, HEAD, OPTIONS, PUT")
+	expect(t, res.Body.Len(), 0)
+	expect(t, options("/posts").Header().Get("Allow"), "custom")
+	expect(t, options("/comments").Code, http.StatusNotFound)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://localhost:3000/users/5", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusMethodNotAllowed)
+	expect(t, recorder.Header().Get("Allow"), "GET, HEAD, OPTIONS, PUT")
+}
+
+func Test_Mount(t *testing.T) {
+	users := NewRouter()
+	users.Get("/", func() string { return "list" })
+	users.Get("/:id", func(params Params) string { return "user " + params["id"] }).Name("user")
+	users.Group("/admin", func(r Router) {
+		r.NotFound(func() (int, string) { return http.StatusNotFound, "no such admin page" })
+	})
+	users.NotFound(func() (int, string) { return http.StatusNotFound, "no such user page" })
+
+	r := NewRouter()
+	r.Get("/", func() string { return "home" })
+	r.Mount("/users/", users, func(res http.ResponseWriter) {
+		res.Header().Set("X-Mounted", "true")
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+path, nil)
+		m := New()
+		m.Action(r.Handle)
+		m.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	expect(t, get("/users").Body.String(), "list")
+	expect(t, get("/users/").Body.String(), "list")
+	res := get("/users/5")
+	expect(t, res.Body.String(), "user 5")
+	expect(t, res.Header().Get("X-Mounted"), "true")
+	expect(t, get("/").Body.String(), "home")
+	expect(t, r.URLFor("user", 5), "/users/5")
+
+	res = get("/users/5/posts")
+	expect(t, res.Code, http.StatusNotFound)
+	expect(t, res.Body.String(), "no such user page")
+	expect(t, get("/users/admin/x/y").Body.String(), "no such admin page")
+	refute(t, get("/posts").Body.String(), "no such user page")
+}
+
+func Test_Host(t *testing.T) {
+	r := NewRouter()
+	r.Host("api.example.com", func(r Router) {
+		r.Get("/users", func() string { return "api users" })
+		r.NotFound(func() (int, string) { return http.StatusNotFound, "no such endpoint" })
+	})
+	r.Host("*.example.com", func(r Router) {
+		r.Get("/", func(params Params) string { return "tenant " + params["subdomain"] })
+	}, func(res http.ResponseWriter) {
+		res.Header().Set("X-Tenant", "true")
+	})
+	r.Host(":lang.docs.example.com", func(r Router) {
+		r.Get("/:page", func(params Params) string { return params["lang"] + " " + params["page"] })
+	})
+	r.Get("/users", func() string { return "users" })
+
+	get := func(host string, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://"+host+path, nil)
+		r.Handle(recorder, req, New().createContext(recorder, req))
+		return recorder
+	}
+
+	expect(t, get("api.example.com", "/users").Body.String(), "api users")
+	expect(t, get("API.example.com:8080", "/users").Body.String(), "api users")
+	expect(t, get("www.example.com", "/users").Body.String(), "users")
+	res := get("acme.example.com", "/")
+	expect(t, res.Body.String(), "tenant acme")
+	expect(t, res.Header().Get("X-Tenant"), "true")
+	expect(t, get("de.docs.example.com", "/intro").Body.String(), "de intro")
+	expect(t, get("example.com", "/").Code, http.StatusNotFound)
+
+	expect(t, get("api.example.com", "/posts").Body.String(), "no such endpoint")
+	refute(t, get("www.example.com", "/posts").Body.String(), "no such endpoint")
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://api.example.com/users", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusMethodNotAllowed)
+	expect(t, recorder.Header().Get("Allow"), "GET, HEAD")
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "http://other.org/", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusNotFound)
+}
+
+func Test_RouteBuilder(t *testing.T) {
+	r := NewRouter()
+	result := ""
+	var user Route
+	r.Group("/users", func(r Router) {
+		user = r.Get("/:id", func(params Params) {
+			result += "user:" + params["id"] + " "
+		}).Name("user").Constraint("id", `\d+`).Use(func() {
+			result += "auth "
+		}).Meta("scope", "admin")
+	}, func() {
+		result += "group "
+	})
+	r.Get("/users/:name", func(params Params) {
+		result += "name:" + params["name"] + " "
+	})
+
+	for _, path := range []string{"/users/42", "/users/bob"} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+path, nil)
+		r.Handle(recorder, req, New().createContext(recorder, req))
+	}
+	expect(t, result, "group auth user:42 name:bob ")
+	expect(t, r.URLFor("user", 7), "/users/7")
+	expect(t, user.Constraints()["id"], `\d+`)
+	expect(t, user.(*route).meta["scope"], "admin")
+
+	defer func() {
+		refute(t, recover(), nil)
+	}()
+	user.Constraint("missing", `\d+`)
+}
+
+func Test_CaseInsensitive(t *testing.T) {
+	r := NewRouter()
+	r.Get("/users/:id", func(params Params) string { return "user " + params["id"] })
+	r.Get("/About", func() string { return "about" })
+	r.Get(`/Files/:name(\w+)\.txt`, func(params Params) string { return "file " + params["name"] })
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+path, nil)
+		r.Handle(recorder, req, New().createContext(recorder, req))
+		return recorder
+	}
+
+	expect(t, get("/Users/Bob").Code, http.StatusNotFound)
+	r.CaseInsensitive(true)
+	expect(t, get("/Users/Bob").Body.String(), "user Bob")
+	expect(t, get("/about/").Body.String(), "about")
+	expect(t, get("/files/Notes.TXT").Body.String(), "file Notes")
+	expect(t, strings.Join(r.MethodsFor("/ABOUT"), ","), "GET")
+
+	r.RedirectCase(true)
+	res := get("/USERS/Bob?tab=posts")
+	expect(t, res.Code, http.StatusMovedPermanently)
+	expect(t, res.Header().Get("Location"), "/users/Bob?tab=posts")
+	expect(t, get("/about").Header().Get("Location"), "/About")
+	expect(t, get("/users/Bob").Body.String(), "user Bob")
+}
+
+func Test_ScopedServices(t *testing.T) {
+	type config struct{ name string }
+
+	billing := NewRouter()
+	billing.Provide(&config{"billing"})
+	billing.Get("/", func(c *config) string { return c.name })
+	billing.NotFound(func(c *config) (int, string) { return http.StatusNotFound, c.name + " not found" })
+	shipping := NewRouter()
+	shipping.Provide(&config{"shipping"})
+	shipping.ProvideAs(bytes.NewBufferString("scoped"), (*io.Reader)(nil))
+	shipping.Get("/", func(c *config, r io.Reader) string {
+		b, _ := ioutil.ReadAll(r)
+		return c.name + " " + string(b)
+	})
+
+	m := Classic()
+	m.Map(&config{"global"})
+	m.Mount("/billing", billing)
+	m.Mount("/shipping", shipping)
+	m.Get("/", func(c *config) string { return c.name })
+	expect(t, m.Validate(), nil)
+
+	get := func(path string) string {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+path, nil)
+		m.ServeHTTP(recorder, req)
+		return recorder.Body.String()
+	}
+	expect(t, get("/billing"), "billing")
+	expect(t, get("/billing/nope"), "billing not found")
+	expect(t, get("/shipping"), "shipping scoped")
+	expect(t, get("/"), "global")
+}
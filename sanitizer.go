@@ -0,0 +1,192 @@
+package martini
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// SanitizePolicy lists the HTML a Sanitizer keeps.
+type SanitizePolicy struct {
+	// Tags maps the allowed tags to their allowed attributes.
+	Tags map[string][]string
+	// Nofollow adds rel="nofollow noopener" to links.
+	Nofollow bool
+}
+
+// StrictPolicy keeps no HTML at all, only text.
+var StrictPolicy = SanitizePolicy{}
+
+// UGCPolicy keeps the formatting HTML common in user generated content.
+var UGCPolicy = SanitizePolicy{
+	Tags: map[string][]string{
+		"a": {"href", "title"}, "img": {"src", "alt", "title", "width", "height"},
+		"p": nil, "br": nil, "hr": nil, "blockquote": nil, "pre": nil, "code": nil,
+		"strong": nil, "b": nil, "em": nil, "i": nil, "u": nil, "s": nil, "del": nil, "sub": nil, "sup": nil,
+		"ul": nil, "ol": nil, "li": nil, "h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+		"table": nil, "thead": nil, "tbody": nil, "tr": nil, "th": nil, "td": nil,
+	},
+	Nofollow: true,
+}
+
+// Sanitizer cleans untrusted HTML, keeping only the tags and attributes allowed by its policy. The
+// content of disallowed tags is kept as text, except for script and style, URLs are restricted to http,
+// https and mailto and tags left open are closed.
+type Sanitizer struct {
+	policy SanitizePolicy
+}
+
+// NewSanitizer creates a Sanitizer with the policy.
+func NewSanitizer(policy SanitizePolicy) *Sanitizer {
+	return &Sanitizer{policy: policy}
+}
+
+var (
+	sanitizeAttr = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	voidTags     = map[string]bool{"br": true, "hr": true, "img": true}
+	rawTextTags  = map[string]bool{"script": true, "style": true}
+	urlAttrs     = map[string]bool{"href": true, "src": true}
+)
+
+// Sanitize returns the cleaned HTML.
+func (s *Sanitizer) Sanitize(src string) string {
+	var (
+		out  strings.Builder
+		open []string
+	)
+	for len(src) > 0 {
+		i := strings.IndexByte(src, '<')
+		if i < 0 {
+			out.WriteString(html.EscapeString(html.UnescapeString(src)))
+			break
+		}
+		out.WriteString(html.EscapeString(html.UnescapeString(src[:i])))
+		src = src[i:]
+
+		if strings.HasPrefix(src, "<!--") {
+			end := strings.Index(src, "-->")
+			if end < 0 {
+				break
+			}
+			src = src[end+3:]
+			continue
+		}
+		end := tagEnd(src)
+		name, closing := tagName(src)
+		if end < 0 || name == "" {
+			out.WriteString("&lt;")
+			src = src[1:]
+			continue
+		}
+		tag := src[:end+1]
+		src = src[end+1:]
+
+		if rawTextTags[name] && !closing {
+			if j := strings.Index(strings.ToLower(src), "</"+name); j >= 0 {
+				src = src[j:]
+				if k := strings.IndexByte(src, '>'); k >= 0 {
+					src = src[k+1:]
+				}
+			} else {
+				src = ""
+			}
+			continue
+		}
+		attrs, ok := s.policy.Tags[name]
+		if !ok {
+			continue
+		}
+		if closing {
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j] == name {
+					for k := len(open) - 1; k >= j; k-- {
+						out.WriteString("</" + open[k] + ">")
+					}
+					open = open[:j]
+					break
+				}
+			}
+			continue
+		}
+		out.WriteString("<" + name + s.attributes(name, tag, attrs) + ">")
+		if !voidTags[name] {
+			open = append(open, name)
+		}
+	}
+	for j := len(open) - 1; j >= 0; j-- {
+		out.WriteString("</" + open[j] + ">")
+	}
+	return out.String()
+}
+
+// attributes returns the allowed attributes of the tag, escaped.
+func (s *Sanitizer) attributes(name string, tag string, allowed []string) string {
+	var out strings.Builder
+	rest := tag[1+len(name) : len(tag)-1]
+	for _, m := range sanitizeAttr.FindAllStringSubmatch(rest, -1) {
+		attr := strings.ToLower(m[1])
+		if !containsString(allowed, attr) || (name == "a" && attr == "rel" && s.policy.Nofollow) {
+			continue
+		}
+		value := html.UnescapeString(m[2] + m[3] + m[4])
+		if urlAttrs[attr] && !safeURL(strings.TrimSpace(value)) {
+			continue
+		}
+		out.WriteString(" " + attr + `="` + html.EscapeString(value) + `"`)
+	}
+	if name == "a" && s.policy.Nofollow {
+		out.WriteString(` rel="nofollow noopener"`)
+	}
+	return out.String()
+}
+
+// tagEnd returns the index of the > ending the tag at the start of s, skipping quoted attribute values.
+func tagEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// tagName returns the lowercased name of the tag at the start of s and whether it is a closing tag.
+func tagName(s string) (string, bool) {
+	s = s[1:]
+	closing := strings.HasPrefix(s, "/")
+	if closing {
+		s = s[1:]
+	}
+	i := 0
+	for i < len(s) && (s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || i > 0 && s[i] >= '0' && s[i] <= '9') {
+		i++
+	}
+	return strings.ToLower(s[:i]), closing
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// FuncMap returns the sanitize template function, which outputs the cleaned HTML unescaped.
+func (s *Sanitizer) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"sanitize": func(src string) template.HTML {
+			return template.HTML(s.Sanitize(src))
+		},
+	}
+}
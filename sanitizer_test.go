@@ -0,0 +1,53 @@
+package martini
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+)
+
+func Test_Sanitizer(t *testing.T) {
+	s := NewSanitizer(UGCPolicy)
+	tests := []struct{ in, out string }{
+		{`<p>Hello <b>world</b></p>`, `<p>Hello <b>world</b></p>`},
+		{`<script>alert(1)</script>text`, `text`},
+		{`<STYLE>body{}</style><P onclick="x()">hi</p>`, `<p>hi</p>`},
+		{`<a href="https://example.com?a=1&amp;b=2" onmouseover="x()" rel="me">link</a>`, `<a href="https://example.com?a=1&amp;b=2" rel="nofollow noopener">link</a>`},
+		{`<a href=" javascript:alert(1)">x</a>`, `<a rel="nofollow noopener">x</a>`},
+		{`<img src='/a.png' alt="a > b">`, `<img src="/a.png" alt="a &gt; b">`},
+		{`<div><span>kept</span></div>`, `kept`},
+		{`<!-- comment -->a < b & c`, `a &lt; b &amp; c`},
+		{`<b><i>unclosed`, `<b><i>unclosed</i></b>`},
+		{`<b>x</i></b></b>`, `<b>x</b>`},
+		{`<iframe src="x"></iframe>`, ``},
+	}
+	for _, test := range tests {
+		expect(t, s.Sanitize(test.in), test.out)
+	}
+
+	expect(t, NewSanitizer(StrictPolicy).Sanitize(`<p>plain <b>text</b></p>`), `plain text`)
+}
+
+func Test_Sanitizer_Templates(t *testing.T) {
+	s := NewSanitizer(UGCPolicy)
+	tmpl := template.Must(template.New("").Funcs(s.FuncMap()).Parse(`<div>{{sanitize .}}</div>`))
+	var buf bytes.Buffer
+	expect(t, tmpl.Execute(&buf, `<em>hi</em><script>x</script>`), nil)
+	expect(t, buf.String(), `<div><em>hi</em></div>`)
+}
+
+type rawMarkdown struct{}
+
+func (rawMarkdown) Render(src []byte) ([]byte, error) {
+	return src, nil
+}
+
+func Test_Markdown_Sanitizer(t *testing.T) {
+	md := NewMarkdown(rawMarkdown{})
+	out, _ := md.HTML(`<p onclick="x()">hi</p>`)
+	expect(t, out, template.HTML(`<p onclick="x()">hi</p>`))
+
+	md.SetSanitizer(NewSanitizer(UGCPolicy))
+	out, _ = md.HTML(`<p onclick="x()">hi</p>`)
+	expect(t, out, template.HTML(`<p>hi</p>`))
+}
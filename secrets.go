@@ -0,0 +1,151 @@
+package martini
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrSecretNotFound is returned by Secrets providers for unknown secrets.
+var ErrSecretNotFound = errors.New("martini: secret not found")
+
+// Secret is a secret value, like a signing or encryption key, along with the metadata needed to rotate it.
+type Secret struct {
+	// Value is the current value, used to sign or encrypt.
+	Value []byte
+	// Version identifies the current value, e.g. as the key id stored along a signature. Optional.
+	Version string
+	// Previous are retired values that are still accepted to verify or decrypt, newest first.
+	Previous [][]byte
+}
+
+// Secrets is the interface of secret providers. The signing keys of LocalStorage, ImageOptions and
+// WebhookEndpoint are looked up in one with a SecretRef:
+//
+//	secrets := martini.ChainSecrets(martini.FileSecrets("/run/secrets"), martini.EnvSecrets("APP_"))
+//	storage := &martini.LocalStorage{Dir: "uploads", URL: "/files", SecretRef: martini.SecretRef{secrets, "storage_key"}}
+type Secrets interface {
+	// Get returns the secret with the given name, or ErrSecretNotFound.
+	Get(name string) (Secret, error)
+}
+
+// SecretRef refers to a secret of a Secrets provider by name. The secret is looked up on every use, so
+// it can be rotated without a restart: the current value signs, the previous values still verify what
+// was signed before the rotation.
+type SecretRef struct {
+	Secrets Secrets
+	Name    string
+}
+
+// Current returns the current value of the secret, e.g. to sign image URLs with SignImageURL. An empty
+// value is reported as ErrSecretNotFound.
+func (r SecretRef) Current() ([]byte, error) {
+	keys, err := r.keys(nil)
+	if err != nil {
+		return nil, err
+	}
+	return keys[0], nil
+}
+
+// keys returns the key to sign with first, followed by the previous keys still accepted to verify. It is
+// raw unless the ref names a secret. Empty keys are left out and ErrSecretNotFound is returned without a
+// key to sign with, so nothing is signed with a key anyone can compute.
+func (r SecretRef) keys(raw []byte) ([][]byte, error) {
+	s := Secret{Value: raw}
+	if r.Secrets != nil {
+		var err error
+		if s, err = r.Secrets.Get(r.Name); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.Value) == 0 {
+		return nil, ErrSecretNotFound
+	}
+	keys := [][]byte{s.Value}
+	for _, prev := range s.Previous {
+		if len(prev) > 0 {
+			keys = append(keys, prev)
+		}
+	}
+	return keys, nil
+}
+
+// EnvSecrets returns a provider reading secrets from environment variables. The secret "session_key" is
+// read from PREFIX_SESSION_KEY, its version from PREFIX_SESSION_KEY_VERSION and its comma separated
+// previous values from PREFIX_SESSION_KEY_PREVIOUS.
+func EnvSecrets(prefix string) Secrets {
+	return envSecrets(prefix)
+}
+
+type envSecrets string
+
+func (p envSecrets) Get(name string) (Secret, error) {
+	key := string(p) + strings.ToUpper(name)
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return Secret{}, ErrSecretNotFound
+	}
+	s := Secret{Value: []byte(value), Version: os.Getenv(key + "_VERSION")}
+	for _, prev := range strings.Split(os.Getenv(key+"_PREVIOUS"), ",") {
+		if prev != "" {
+			s.Previous = append(s.Previous, []byte(prev))
+		}
+	}
+	return s, nil
+}
+
+// FileSecrets returns a provider reading secrets from files in a directory, like the secrets mounted by
+// Docker or Kubernetes. The secret "session_key" is read from dir/session_key, its version from
+// dir/session_key.version and its previous values, one per line, from dir/session_key.previous.
+// Trailing newlines are trimmed.
+func FileSecrets(dir string) Secrets {
+	return fileSecrets(dir)
+}
+
+type fileSecrets string
+
+func (p fileSecrets) Get(name string) (Secret, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return Secret{}, ErrSecretNotFound
+	}
+	path := filepath.Join(string(p), name)
+	value, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Secret{}, ErrSecretNotFound
+	} else if err != nil {
+		return Secret{}, err
+	}
+
+	s := Secret{Value: []byte(strings.TrimRight(string(value), "\r\n"))}
+	if version, err := ioutil.ReadFile(path + ".version"); err == nil {
+		s.Version = strings.TrimSpace(string(version))
+	}
+	if previous, err := ioutil.ReadFile(path + ".previous"); err == nil {
+		for _, prev := range strings.Split(string(previous), "\n") {
+			if prev = strings.TrimRight(prev, "\r"); prev != "" {
+				s.Previous = append(s.Previous, []byte(prev))
+			}
+		}
+	}
+	return s, nil
+}
+
+// ChainSecrets returns a provider asking the given providers in order, e.g. a vault client before
+// falling back to the environment.
+func ChainSecrets(providers ...Secrets) Secrets {
+	return chainSecrets(providers)
+}
+
+type chainSecrets []Secrets
+
+func (c chainSecrets) Get(name string) (Secret, error) {
+	for _, p := range c {
+		s, err := p.Get(name)
+		if err != ErrSecretNotFound {
+			return s, err
+		}
+	}
+	return Secret{}, ErrSecretNotFound
+}
@@ -0,0 +1,138 @@
+package martini
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Secrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-secrets")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "session_key"), []byte("file-key\n"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "session_key.version"), []byte("v3\n"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "session_key.previous"), []byte("old-2\nold-1\n"), 0600)
+
+	os.Setenv("TESTAPP_JWT_KEY", "env-key")
+	os.Setenv("TESTAPP_JWT_KEY_PREVIOUS", "old")
+	defer os.Unsetenv("TESTAPP_JWT_KEY")
+	defer os.Unsetenv("TESTAPP_JWT_KEY_PREVIOUS")
+
+	secrets := ChainSecrets(FileSecrets(dir), EnvSecrets("TESTAPP_"))
+
+	s, err := secrets.Get("session_key")
+	expect(t, err, nil)
+	expect(t, string(s.Value), "file-key")
+	expect(t, s.Version, "v3")
+	expect(t, len(s.Previous), 2)
+	expect(t, string(s.Previous[0]), "old-2")
+
+	s, err = secrets.Get("jwt_key")
+	expect(t, err, nil)
+	expect(t, string(s.Value), "env-key")
+	expect(t, s.Version, "")
+	expect(t, string(s.Previous[0]), "old")
+
+	_, err = secrets.Get("missing")
+	expect(t, err, ErrSecretNotFound)
+	_, err = secrets.Get("../etc/passwd")
+	expect(t, err, ErrSecretNotFound)
+}
+
+// staticSecrets is a Secrets provider for tests.
+type staticSecrets map[string]Secret
+
+func (s staticSecrets) Get(name string) (Secret, error) {
+	if secret, ok := s[name]; ok {
+		return secret, nil
+	}
+	return Secret{}, ErrSecretNotFound
+}
+
+func Test_SecretRef(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-secrets")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	secrets := staticSecrets{"storage_key": {Value: []byte("old")}, "image_key": {Value: []byte("old")}}
+	storage := &LocalStorage{Dir: dir, URL: "/files", SecretRef: SecretRef{secrets, "storage_key"}}
+	storage.Put("a.txt", strings.NewReader("a"))
+	m := Classic()
+	m.Get("/files/**", storage.Handler())
+	m.Get("/images/**", Images(storage, ImageOptions{SecretRef: SecretRef{secrets, "image_key"}}))
+
+	signed, err := storage.SignedURL("a.txt", time.Minute)
+	expect(t, err, nil)
+	current, err := SecretRef{secrets, "image_key"}.Current()
+	expect(t, err, nil)
+	image := SignImageURL(current, "/images", "a.txt", url.Values{"w": {"10"}})
+	get := func(url string) int {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, httptest.NewRequest("GET", url, nil))
+		return res.Code
+	}
+	expect(t, get(signed), http.StatusOK)
+
+	// URLs signed before the rotation stay valid while the old key is a previous value
+	secrets["storage_key"] = Secret{Value: []byte("new"), Previous: [][]byte{[]byte("old")}}
+	secrets["image_key"] = Secret{Value: []byte("new"), Previous: [][]byte{[]byte("old")}}
+	expect(t, get(signed), http.StatusOK)
+	// the image isn't an image, but the signature was accepted
+	expect(t, get(image), http.StatusUnsupportedMediaType)
+	resigned, _ := storage.SignedURL("a.txt", time.Minute)
+	refute(t, resigned, signed)
+
+	secrets["storage_key"] = Secret{Value: []byte("new")}
+	secrets["image_key"] = Secret{Value: []byte("new")}
+	expect(t, get(signed), http.StatusForbidden)
+	expect(t, get(image), http.StatusForbidden)
+	expect(t, get(resigned), http.StatusOK)
+
+	_, err = (&LocalStorage{SecretRef: SecretRef{secrets, "nope"}}).SignedURL("a.txt", time.Minute)
+	expect(t, err, ErrSecretNotFound)
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		signature = req.Header.Get("Webhook-Signature")
+	}))
+	defer server.Close()
+	w := NewWebhooks(NewMemoryWebhookStore(), 1)
+	defer w.Close()
+	d := &WebhookDelivery{ID: "d", Payload: []byte("{}")}
+	_, err = w.send(WebhookEndpoint{URL: server.URL, SecretRef: SecretRef{secrets, "storage_key"}}, d)
+	expect(t, err, nil)
+	parts := strings.SplitN(signature, ",", 2)
+	expect(t, parts[1], "v1="+signWebhook([]byte("new"), parts[0][2:], d.Payload))
+	_, err = w.send(WebhookEndpoint{URL: server.URL, SecretRef: SecretRef{secrets, "nope"}}, d)
+	expect(t, err, ErrSecretNotFound)
+}
+
+func Test_SecretRef_Empty(t *testing.T) {
+	secrets := staticSecrets{"empty": {Previous: [][]byte{[]byte("old")}}, "key": {Value: []byte("new"), Previous: [][]byte{nil}}}
+
+	_, err := SecretRef{}.keys(nil)
+	expect(t, err, ErrSecretNotFound)
+	_, err = SecretRef{}.keys([]byte{})
+	expect(t, err, ErrSecretNotFound)
+	_, err = SecretRef{secrets, "empty"}.keys([]byte("raw"))
+	expect(t, err, ErrSecretNotFound)
+	_, err = SecretRef{}.Current()
+	expect(t, err, ErrSecretNotFound)
+
+	keys, err := SecretRef{secrets, "key"}.keys(nil)
+	expect(t, err, nil)
+	expect(t, len(keys), 1)
+	keys, err = SecretRef{}.keys([]byte("raw"))
+	expect(t, err, nil)
+	expect(t, string(keys[0]), "raw")
+
+	w := NewWebhooks(NewMemoryWebhookStore(), 1)
+	defer w.Close()
+	_, err = w.send(WebhookEndpoint{URL: "http://localhost"}, &WebhookDelivery{ID: "d"})
+	expect(t, err, ErrSecretNotFound)
+}
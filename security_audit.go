@@ -0,0 +1,98 @@
+package martini
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// SecurityEventType is the type of a security relevant event.
+type SecurityEventType string
+
+// Security event types recorded by martini, applications can record their own types as well.
+const (
+	SecurityLoginSuccess     SecurityEventType = "login_success"
+	SecurityLoginFailure     SecurityEventType = "login_failure"
+	SecurityPermissionDenied SecurityEventType = "permission_denied"
+	SecurityCSRFFailure      SecurityEventType = "csrf_failure"
+	SecurityRateLimited      SecurityEventType = "rate_limited"
+	SecurityCaptchaFailure   SecurityEventType = "captcha_failure"
+)
+
+// SecurityEvent is an entry of the security audit trail.
+type SecurityEvent struct {
+	Type      SecurityEventType `json:"type"`
+	Time      time.Time         `json:"time"`
+	Actor     string            `json:"actor,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	Method    string            `json:"method,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// SecuritySink receives the security events, e.g. to write them to append-only storage.
+type SecuritySink interface {
+	Record(e SecurityEvent) error
+}
+
+// NewJSONSecuritySink returns a SecuritySink writing every event as a line of JSON.
+func NewJSONSecuritySink(w io.Writer) SecuritySink {
+	return &jsonSecuritySink{w: w}
+}
+
+type jsonSecuritySink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *jsonSecuritySink) Record(e SecurityEvent) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// SecurityLog is mapped by SecurityAudit for every request to record security events along with the
+// request's context. LoginThrottle records logins and rate limit trips through it when it is mapped.
+type SecurityLog struct {
+	sink SecuritySink
+	c    Context
+	req  *http.Request
+}
+
+// SecurityAudit returns a middleware handler mapping a *SecurityLog recording to the sink. The IP of the
+// events is the RemoteAddr of the request, use the Forwarded middleware behind a proxy to record the
+// client's instead of the proxy's.
+func SecurityAudit(sink SecuritySink) Handler {
+	return func(c Context, req *http.Request) {
+		c.Map(&SecurityLog{sink, c, req})
+	}
+}
+
+// Record records an event of the given type for the actor, e.g. the user or account the event is about.
+func (l *SecurityLog) Record(typ SecurityEventType, actor string, details map[string]string) error {
+	e := SecurityEvent{Type: typ, Time: time.Now().UTC(), Actor: actor, Details: details}
+	if l.req != nil {
+		e.IP, e.Method, e.Path = clientIP(l.req), l.req.Method, l.req.URL.Path
+	}
+	if v := l.c.Get(reflect.TypeOf((*RequestLogger)(nil))); v.IsValid() {
+		e.RequestID = v.Interface().(*RequestLogger).Field("request_id")
+	}
+	return l.sink.Record(e)
+}
+
+// securityLog returns the *SecurityLog mapped on the context, or nil.
+func securityLog(c Context) *SecurityLog {
+	if v := c.Get(reflect.TypeOf((*SecurityLog)(nil))); v.IsValid() {
+		return v.Interface().(*SecurityLog)
+	}
+	return nil
+}
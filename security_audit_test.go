@@ -0,0 +1,48 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_SecurityAudit(t *testing.T) {
+	buff := bytes.NewBuffer(nil)
+	m := Classic()
+	m.Use(SecurityAudit(NewJSONSecuritySink(buff)))
+	throttle := &LoginThrottle{Store: NewMemoryThrottleStore(), Backoff: time.Hour}
+	m.Post("/login", throttle.Handler(func(req *http.Request) string { return "jane" }), func(a *LoginAttempt) {
+		a.Fail()
+	})
+	m.Get("/admin", func(l *SecurityLog) (int, string) {
+		l.Record(SecurityPermissionDenied, "joe", map[string]string{"permission": "admin"})
+		return http.StatusForbidden, "forbidden"
+	})
+
+	for _, r := range []struct{ method, path string }{{"POST", "/login"}, {"POST", "/login"}, {"GET", "/admin"}} {
+		req, _ := http.NewRequest(r.method, r.path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Request-Id", "req-1")
+		m.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var events []SecurityEvent
+	dec := json.NewDecoder(buff)
+	for dec.More() {
+		var e SecurityEvent
+		expect(t, dec.Decode(&e), nil)
+		events = append(events, e)
+	}
+	expect(t, len(events), 3)
+	expect(t, events[0].Type, SecurityLoginFailure)
+	expect(t, events[0].Actor, "jane")
+	expect(t, events[0].IP, "10.0.0.1")
+	expect(t, events[0].RequestID, "req-1")
+	expect(t, events[1].Type, SecurityRateLimited)
+	expect(t, events[2].Type, SecurityPermissionDenied)
+	expect(t, events[2].Path, "/admin")
+	expect(t, events[2].Details["permission"], "admin")
+}
@@ -0,0 +1,330 @@
+package martini
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionData is the stored form of a session.
+type sessionData struct {
+	ID      string            `json:"id"`
+	UserID  string            `json:"user_id,omitempty"`
+	Values  map[string]string `json:"values"`
+	Expires time.Time         `json:"expires"`
+}
+
+func (d sessionData) session() *Session {
+	if d.Values == nil {
+		d.Values = make(map[string]string)
+	}
+	return &Session{ID: d.ID, UserID: d.UserID, Values: d.Values, Expires: d.Expires}
+}
+
+// data copies the stored fields of the session.
+func (s *Session) data() sessionData {
+	return sessionData{s.ID, s.UserID, s.Values, s.Expires}.copy()
+}
+
+// copy returns a copy of the data that doesn't share the values.
+func (d sessionData) copy() sessionData {
+	values := make(map[string]string, len(d.Values))
+	for k, v := range d.Values {
+		values[k] = v
+	}
+	d.Values = values
+	return d
+}
+
+// NewMemorySessionStore creates a SessionStore keeping sessions in memory, for development and tests.
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{sessions: make(map[string]sessionData)}
+}
+
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]sessionData
+}
+
+func (m *memorySessionStore) Get(id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.sessions[id]
+	if !ok || time.Now().After(d.Expires) {
+		delete(m.sessions, id)
+		return nil, nil
+	}
+	return d.copy().session(), nil
+}
+
+func (m *memorySessionStore) Save(s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.ID] = s.data()
+	return nil
+}
+
+func (m *memorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memorySessionStore) DeleteUser(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, d := range m.sessions {
+		if d.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+// CachedSessionStore wraps a SessionStore, e.g. a SQLSessionStore, keeping the sessions it loads and saves
+// in the cache for up to ttl, so requests of the same session don't all hit the store. Concurrent loads of
+// a session are made once. Sessions deleted from the store directly, bypassing the wrapper, may be
+// returned until they drop out of the cache.
+func CachedSessionStore(store SessionStore, cache *Cache, ttl time.Duration) SessionStore {
+	return &cachedSessionStore{store, cache, ttl}
+}
+
+type cachedSessionStore struct {
+	store SessionStore
+	cache *Cache
+	ttl   time.Duration
+}
+
+func (c *cachedSessionStore) key(id string) string {
+	return "session:" + id
+}
+
+func (c *cachedSessionStore) Get(id string) (*Session, error) {
+	v, err := c.cache.GetOrLoadWithTTL(c.key(id), c.ttl, func() (interface{}, error) {
+		s, err := c.store.Get(id)
+		if err != nil || s == nil {
+			return nil, err
+		}
+		return s.data(), nil
+	})
+	if err != nil || v == nil {
+		return nil, err
+	}
+	d := v.(sessionData)
+	if time.Now().After(d.Expires) {
+		c.cache.Delete(c.key(id))
+		return nil, nil
+	}
+	return d.copy().session(), nil
+}
+
+func (c *cachedSessionStore) Save(s *Session) error {
+	if err := c.store.Save(s); err != nil {
+		c.cache.Delete(c.key(s.ID))
+		return err
+	}
+	c.cache.SetWithTTL(c.key(s.ID), s.data(), c.ttl)
+	return nil
+}
+
+func (c *cachedSessionStore) Delete(id string) error {
+	c.cache.Delete(c.key(id))
+	return c.store.Delete(id)
+}
+
+func (c *cachedSessionStore) DeleteUser(userID string) error {
+	c.cache.DeleteFunc(func(key string, value interface{}) bool {
+		d, ok := value.(sessionData)
+		return ok && strings.HasPrefix(key, "session:") && d.UserID == userID
+	})
+	return c.store.DeleteUser(userID)
+}
+
+// SQLSessionStore is a SessionStore keeping sessions in a database table with this schema:
+//
+//	CREATE TABLE sessions (
+//	  id      VARCHAR(64) PRIMARY KEY,
+//	  user_id VARCHAR(255) NOT NULL,
+//	  data    TEXT NOT NULL,
+//	  expires TIMESTAMP NOT NULL
+//	);
+//	CREATE INDEX sessions_user_id ON sessions (user_id);
+//
+// Expired rows are never returned, delete them periodically with DeleteExpired.
+type SQLSessionStore struct {
+	DB *sql.DB
+	// Table is the name of the table, "sessions" by default.
+	Table string
+	// Dollar uses $1 style placeholders, as required by PostgreSQL, instead of ?.
+	Dollar bool
+}
+
+func (s *SQLSessionStore) query(q string) string {
+	table := s.Table
+	if table == "" {
+		table = "sessions"
+	}
+	q = fmt.Sprintf(q, table)
+	if !s.Dollar {
+		return q
+	}
+	var out []byte
+	n := 0
+	for i := 0; i < len(q); i++ {
+		if q[i] == '?' {
+			n++
+			out = append(out, fmt.Sprintf("$%d", n)...)
+		} else {
+			out = append(out, q[i])
+		}
+	}
+	return string(out)
+}
+
+func (s *SQLSessionStore) Get(id string) (*Session, error) {
+	var (
+		d       sessionData
+		data    string
+		expires time.Time
+	)
+	err := s.DB.QueryRow(s.query("SELECT user_id, data, expires FROM %s WHERE id = ?"), id).Scan(&d.UserID, &data, &expires)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if time.Now().After(expires) {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(data), &d.Values); err != nil {
+		return nil, err
+	}
+	d.ID, d.Expires = id, expires
+	return d.session(), nil
+}
+
+func (s *SQLSessionStore) Save(session *Session) error {
+	d := session.data()
+	data, err := json.Marshal(d.Values)
+	if err != nil {
+		return err
+	}
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.query("DELETE FROM %s WHERE id = ?"), d.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(s.query("INSERT INTO %s (id, user_id, data, expires) VALUES (?, ?, ?, ?)"), d.ID, d.UserID, string(data), d.Expires.UTC()); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLSessionStore) Delete(id string) error {
+	_, err := s.DB.Exec(s.query("DELETE FROM %s WHERE id = ?"), id)
+	return err
+}
+
+func (s *SQLSessionStore) DeleteUser(userID string) error {
+	_, err := s.DB.Exec(s.query("DELETE FROM %s WHERE user_id = ?"), userID)
+	return err
+}
+
+// DeleteExpired deletes the expired sessions.
+func (s *SQLSessionStore) DeleteExpired() error {
+	_, err := s.DB.Exec(s.query("DELETE FROM %s WHERE expires < ?"), time.Now().UTC())
+	return err
+}
+
+// RedisClient is the subset of Redis commands used by RedisSessionStore. Adapt the client library of
+// your choice to it. Get returns an empty string without error for missing keys.
+type RedisClient interface {
+	Get(key string) (string, error)
+	Set(key string, value string, ttl time.Duration) error
+	Del(keys ...string) error
+	SAdd(key string, member string) error
+	SMembers(key string) ([]string, error)
+	Expire(key string, ttl time.Duration) error
+}
+
+// RedisSessionStore is a SessionStore keeping sessions in Redis, expiring them with the key TTL. The IDs of
+// the sessions of each user are kept in a set for DeleteUser.
+type RedisSessionStore struct {
+	Client RedisClient
+	// Prefix is prepended to all keys, "session:" by default.
+	Prefix string
+}
+
+func (s *RedisSessionStore) key(parts ...string) string {
+	key := s.Prefix
+	if key == "" {
+		key = "session:"
+	}
+	for i, p := range parts {
+		if i > 0 {
+			key += ":"
+		}
+		key += p
+	}
+	return key
+}
+
+func (s *RedisSessionStore) Get(id string) (*Session, error) {
+	data, err := s.Client.Get(s.key(id))
+	if err != nil || data == "" {
+		return nil, err
+	}
+	var d sessionData
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		return nil, err
+	}
+	if time.Now().After(d.Expires) {
+		return nil, nil
+	}
+	return d.session(), nil
+}
+
+func (s *RedisSessionStore) Save(session *Session) error {
+	d := session.data()
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(d.Expires)
+	if err := s.Client.Set(s.key(d.ID), string(data), ttl); err != nil {
+		return err
+	}
+	if d.UserID == "" {
+		return nil
+	}
+	userKey := s.key("user", d.UserID)
+	if err := s.Client.SAdd(userKey, d.ID); err != nil {
+		return err
+	}
+	return s.Client.Expire(userKey, ttl)
+}
+
+func (s *RedisSessionStore) Delete(id string) error {
+	return s.Client.Del(s.key(id))
+}
+
+func (s *RedisSessionStore) DeleteUser(userID string) error {
+	userKey := s.key("user", userID)
+	ids, err := s.Client.SMembers(userKey)
+	if err != nil {
+		return err
+	}
+	keys := []string{userKey}
+	for _, id := range ids {
+		keys = append(keys, s.key(id))
+	}
+	return s.Client.Del(keys...)
+}
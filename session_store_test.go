@@ -0,0 +1,77 @@
+package martini
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeRedis struct {
+	values map[string]string
+	sets   map[string][]string
+}
+
+func (r *fakeRedis) Get(key string) (string, error) { return r.values[key], nil }
+func (r *fakeRedis) Set(key string, value string, ttl time.Duration) error {
+	r.values[key] = value
+	return nil
+}
+func (r *fakeRedis) Del(keys ...string) error {
+	for _, k := range keys {
+		delete(r.values, k)
+		delete(r.sets, k)
+	}
+	return nil
+}
+func (r *fakeRedis) SAdd(key string, member string) error {
+	r.sets[key] = append(r.sets[key], member)
+	return nil
+}
+func (r *fakeRedis) SMembers(key string) ([]string, error)      { return r.sets[key], nil }
+func (r *fakeRedis) Expire(key string, ttl time.Duration) error { return nil }
+
+func testSessionStore(t *testing.T, store SessionStore) {
+	expires := time.Now().Add(time.Hour)
+	a := &Session{ID: "a", UserID: "jane", Values: map[string]string{"k": "v"}, Expires: expires}
+	b := &Session{ID: "b", UserID: "jane", Values: map[string]string{}, Expires: expires}
+	c := &Session{ID: "c", Values: map[string]string{}, Expires: expires}
+	old := &Session{ID: "old", Values: map[string]string{}, Expires: time.Now().Add(-time.Second)}
+	for _, s := range []*Session{a, b, c, old} {
+		expect(t, store.Save(s), nil)
+	}
+
+	s, err := store.Get("a")
+	expect(t, err, nil)
+	expect(t, s.UserID, "jane")
+	expect(t, s.Get("k"), "v")
+	// stored sessions are copies
+	a.Set("k", "changed")
+	s, _ = store.Get("a")
+	expect(t, s.Get("k"), "v")
+
+	s, _ = store.Get("old")
+	expect(t, s == nil, true)
+
+	expect(t, store.DeleteUser("jane"), nil)
+	for id, exists := range map[string]bool{"a": false, "b": false, "c": true} {
+		s, _ := store.Get(id)
+		expect(t, s != nil, exists)
+	}
+	expect(t, store.Delete("c"), nil)
+	s, _ = store.Get("c")
+	expect(t, s == nil, true)
+}
+
+func Test_MemorySessionStore(t *testing.T) {
+	testSessionStore(t, NewMemorySessionStore())
+}
+
+func Test_RedisSessionStore(t *testing.T) {
+	testSessionStore(t, &RedisSessionStore{Client: &fakeRedis{make(map[string]string), make(map[string][]string)}})
+}
+
+func Test_SQLSessionStore_Query(t *testing.T) {
+	s := &SQLSessionStore{Table: "web_sessions", Dollar: true}
+	expect(t, s.query("DELETE FROM %s WHERE id = ? AND user_id = ?"), "DELETE FROM web_sessions WHERE id = $1 AND user_id = $2")
+	s = &SQLSessionStore{}
+	expect(t, s.query("DELETE FROM %s WHERE id = ?"), "DELETE FROM sessions WHERE id = ?")
+}
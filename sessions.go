@@ -0,0 +1,198 @@
+package martini
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Session is the server side session of a client, identified by the ID stored in a cookie. Sessions maps
+// a *Session for every request.
+type Session struct {
+	// ID identifies the session, it is changed on Login and Regenerate.
+	ID string
+	// UserID is the user the session is logged in as, empty for anonymous sessions.
+	UserID string
+	// Values are the values stored in the session.
+	Values map[string]string
+	// Expires is when the session expires.
+	Expires time.Time
+
+	mu       sync.Mutex
+	oldID    string
+	changed  bool
+	fresh    bool
+	destroy  bool
+	finished bool
+}
+
+// Get returns the session value of the key.
+func (s *Session) Get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Values[key]
+}
+
+// Set sets the session value of the key.
+func (s *Session) Set(key string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Values[key] = value
+	s.changed = true
+}
+
+// Delete deletes the session value of the key.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Values, key)
+	s.changed = true
+}
+
+// Login logs the session in as the user. The session gets a new ID, so an ID planted by an attacker
+// before the login can't be used to hijack it.
+func (s *Session) Login(userID string) {
+	s.Regenerate()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.UserID = userID
+}
+
+// Regenerate gives the session a new ID, keeping its values.
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.oldID == "" && !s.fresh {
+		s.oldID = s.ID
+	}
+	s.ID = randomToken(32)
+	s.changed = true
+}
+
+// Destroy deletes the session from the store and expires its cookie.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.destroy = true
+}
+
+// SessionStore stores sessions on the server. Expired sessions must not be returned.
+type SessionStore interface {
+	// Get returns the session with the given ID, or nil if it doesn't exist or has expired.
+	Get(id string) (*Session, error)
+	// Save stores the session until it expires.
+	Save(s *Session) error
+	// Delete deletes the session with the given ID.
+	Delete(id string) error
+	// DeleteUser deletes all sessions logged in as the user, e.g. after a password change.
+	DeleteUser(userID string) error
+}
+
+// SessionOptions configures the Sessions middleware.
+type SessionOptions struct {
+	// CookieName is the name of the session cookie. Defaults to "martini_session".
+	CookieName string
+	// Path is the path of the session cookie. Defaults to "/".
+	Path string
+	// Domain is the domain of the session cookie.
+	Domain string
+	// Secure restricts the session cookie to HTTPS.
+	Secure bool
+	// TTL is how long sessions live. Defaults to 24 hours.
+	TTL time.Duration
+	// Sliding extends the lifetime of a session by TTL on every request, instead of only when it changes.
+	Sliding bool
+}
+
+// Sessions returns a middleware handler that maps the *Session of the request, loaded from the store by
+// the ID in the session cookie. New sessions are only stored and sent to the client once they have values
+// or are logged in.
+func Sessions(store SessionStore, opts SessionOptions) Handler {
+	if opts.CookieName == "" {
+		opts.CookieName = "martini_session"
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.TTL == 0 {
+		opts.TTL = 24 * time.Hour
+	}
+
+	return func(c Context, res http.ResponseWriter, req *http.Request) {
+		var s *Session
+		if cookie, err := req.Cookie(opts.CookieName); err == nil && cookie.Value != "" {
+			if s, err = store.Get(cookie.Value); err != nil {
+				panic(err)
+			}
+		}
+		if s == nil {
+			s = &Session{ID: randomToken(32), Values: make(map[string]string), fresh: true}
+		}
+		c.Map(s)
+
+		rw := res.(ResponseWriter)
+		rw.Before(func(ResponseWriter) {
+			finishSession(s, store, opts, rw)
+		})
+		c.Next()
+		if !rw.Written() {
+			finishSession(s, store, opts, rw)
+		} else if s.changed && !s.destroy {
+			// values set after the response was written still reach the store
+			s.mu.Lock()
+			err := store.Save(s)
+			s.mu.Unlock()
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// finishSession stores or deletes the session and sets the cookie, once per request.
+func finishSession(s *Session, store SessionStore, opts SessionOptions, res http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return
+	}
+	s.finished = true
+
+	cookie := &http.Cookie{Name: opts.CookieName, Path: opts.Path, Domain: opts.Domain, Secure: opts.Secure, HttpOnly: true, SameSite: http.SameSiteLaxMode}
+	if s.oldID != "" {
+		if err := store.Delete(s.oldID); err != nil {
+			panic(err)
+		}
+	}
+	switch {
+	case s.destroy:
+		if !s.fresh {
+			if err := store.Delete(s.ID); err != nil {
+				panic(err)
+			}
+		}
+		cookie.MaxAge = -1
+	case s.changed || (opts.Sliding && !s.fresh):
+		s.Expires = time.Now().Add(opts.TTL)
+		if err := store.Save(s); err != nil {
+			panic(err)
+		}
+		s.changed = false
+		cookie.Value = s.ID
+		cookie.Expires = s.Expires
+	default:
+		return
+	}
+	http.SetCookie(res, cookie)
+}
+
+// randomToken returns n random bytes encoded as hex.
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,83 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func sessionRequest(m http.Handler, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	m.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func sessionCookie(recorder *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range recorder.Result().Cookies() {
+		if c.Name == "martini_session" {
+			return c
+		}
+	}
+	return nil
+}
+
+func Test_Sessions(t *testing.T) {
+	store := NewMemorySessionStore()
+	m := Classic()
+	m.Use(Sessions(store, SessionOptions{TTL: time.Hour}))
+	m.Get("/anonymous", func(s *Session) string { return s.Get("cart") })
+	m.Get("/cart", func(s *Session) string {
+		s.Set("cart", "3 items")
+		return "ok"
+	})
+	m.Get("/login", func(s *Session) string {
+		s.Login("jane")
+		return "ok"
+	})
+	m.Get("/logout", func(s *Session) { s.Destroy() })
+
+	// untouched sessions are not stored
+	expect(t, sessionCookie(sessionRequest(m, "/anonymous", nil)) == nil, true)
+
+	cookie := sessionCookie(sessionRequest(m, "/cart", nil))
+	expect(t, cookie.HttpOnly, true)
+	expect(t, sessionRequest(m, "/anonymous", cookie).Body.String(), "3 items")
+	// without sliding expiration the cookie is only sent when the session changes
+	expect(t, sessionCookie(sessionRequest(m, "/anonymous", cookie)) == nil, true)
+
+	// logging in changes the ID and keeps the values
+	loggedIn := sessionCookie(sessionRequest(m, "/login", cookie))
+	refute(t, loggedIn.Value, cookie.Value)
+	expect(t, sessionRequest(m, "/anonymous", loggedIn).Body.String(), "3 items")
+	expect(t, sessionRequest(m, "/anonymous", cookie).Body.String(), "")
+
+	s, _ := store.Get(loggedIn.Value)
+	expect(t, s.UserID, "jane")
+
+	expect(t, sessionCookie(sessionRequest(m, "/logout", loggedIn)).MaxAge, -1)
+	s, _ = store.Get(loggedIn.Value)
+	expect(t, s == nil, true)
+}
+
+func Test_Sessions_Sliding(t *testing.T) {
+	store := NewMemorySessionStore()
+	m := Classic()
+	m.Use(Sessions(store, SessionOptions{TTL: time.Hour, Sliding: true}))
+	m.Get("/", func(s *Session) { s.Set("seen", "yes") })
+	m.Get("/read", func(s *Session) {})
+
+	cookie := sessionCookie(sessionRequest(m, "/", nil))
+	s, _ := store.Get(cookie.Value)
+	s.Expires = time.Now().Add(time.Minute)
+	store.Save(s)
+
+	renewed := sessionCookie(sessionRequest(m, "/read", cookie))
+	expect(t, renewed.Value, cookie.Value)
+	s, _ = store.Get(cookie.Value)
+	expect(t, s.Expires.After(time.Now().Add(59*time.Minute)), true)
+}
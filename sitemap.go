@@ -0,0 +1,110 @@
+package martini
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const sitemapXmlns = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// SitemapURL is a single entry of a sitemap. Either Loc or Route must be set. When Route is set, the
+// location is built with Routes.URLFor using the route name and Params.
+type SitemapURL struct {
+	// Loc is the absolute or root relative location of the page.
+	Loc string
+	// Route is the name of a named route used to build the location.
+	Route string
+	// Params are passed to URLFor to fulfill the named parameters of Route.
+	Params []interface{}
+	// LastMod is the date of last modification of the page. Omitted when zero.
+	LastMod time.Time
+	// ChangeFreq is how frequently the page is likely to change (always, hourly, daily...).
+	ChangeFreq string
+	// Priority is the priority of this URL relative to other URLs on the site, between 0.0 and 1.0.
+	Priority float64
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name         `xml:"urlset"`
+	Xmlns   string           `xml:"xmlns,attr"`
+	URLs    []sitemapURLNode `xml:"url"`
+}
+
+type sitemapURLNode struct {
+	Loc        string  `xml:"loc"`
+	LastMod    string  `xml:"lastmod,omitempty"`
+	ChangeFreq string  `xml:"changefreq,omitempty"`
+	Priority   float64 `xml:"priority,omitempty"`
+}
+
+// Sitemap returns a handler that renders a sitemap.xml document. It lists the GET routes annotated with
+// the "sitemap" meta key, whose "changefreq" and "priority" meta values fill in the entries. Routes with
+// parameters, a host or a pattern matching more than one path are left out, as are routes annotated with
+// false.
+//
+// The optional entries func adds dynamic URLs, e.g. one per post. It is called on every request so the
+// sitemap can reflect content that changes at runtime. Relative locations are made absolute using the
+// given base URL (e.g. "http://example.com").
+//
+//	m.Get("/", home).Meta("sitemap", true).Meta("changefreq", "daily").Meta("priority", 1.0)
+//	m.Get("/about", about).Meta("sitemap", true)
+//	m.Get("/posts/:id", showPost).Name("post")
+//	m.Get("/sitemap.xml", martini.Sitemap("http://example.com", func() []martini.SitemapURL {
+//	  return []martini.SitemapURL{{Route: "post", Params: []interface{}{42}}}
+//	}))
+func Sitemap(base string, entries func() []SitemapURL) Handler {
+	base = strings.TrimRight(base, "/")
+	return func(res http.ResponseWriter, routes Routes) {
+		set := sitemapURLSet{Xmlns: sitemapXmlns}
+		urls := annotatedSitemapURLs(routes)
+		if entries != nil {
+			urls = append(urls, entries()...)
+		}
+		for _, e := range urls {
+			loc := e.Loc
+			if e.Route != "" {
+				loc = routes.URLFor(e.Route, e.Params...)
+			}
+			if strings.HasPrefix(loc, "/") {
+				loc = base + loc
+			}
+
+			node := sitemapURLNode{Loc: loc, ChangeFreq: e.ChangeFreq, Priority: e.Priority}
+			if !e.LastMod.IsZero() {
+				node.LastMod = e.LastMod.UTC().Format(time.RFC3339)
+			}
+			set.URLs = append(set.URLs, node)
+		}
+		writeXML(res, "application/xml; charset=utf-8", set)
+	}
+}
+
+// annotatedSitemapURLs returns the entries of the routes annotated with the "sitemap" meta key.
+func annotatedSitemapURLs(routes Routes) []SitemapURL {
+	var urls []SitemapURL
+	for _, info := range routes.All() {
+		if include, _ := info.Meta["sitemap"].(bool); !include || info.Method != "GET" || info.Host != "" || !staticPattern(info.Pattern) {
+			continue
+		}
+		e := SitemapURL{Loc: info.Pattern, Route: info.Name}
+		e.ChangeFreq, _ = info.Meta["changefreq"].(string)
+		e.Priority, _ = info.Meta["priority"].(float64)
+		urls = append(urls, e)
+	}
+	return urls
+}
+
+// writeXML writes v as an XML document with the given content type.
+func writeXML(res http.ResponseWriter, contentType string, v interface{}) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", contentType)
+	res.WriteHeader(http.StatusOK)
+	res.Write([]byte(xml.Header))
+	res.Write(body)
+}
@@ -0,0 +1,55 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Sitemap(t *testing.T) {
+	m := Classic()
+	m.Get("/", func() {}).Meta("sitemap", true).Meta("changefreq", "daily").Meta("priority", 1.0)
+	m.Get("/about", func() {}).Meta("sitemap", true)
+	m.Get("/private", func() {}).Meta("sitemap", false)
+	m.Post("/contact", func() {}).Meta("sitemap", true)
+	m.Get("/users/:id", func() {}).Meta("sitemap", true)
+	m.Get("/posts/:id", func() {}).Name("post")
+	m.Get("/sitemap.xml", Sitemap("http://example.com/", func() []SitemapURL {
+		return []SitemapURL{
+			{Route: "post", Params: []interface{}{42}, LastMod: time.Date(2014, 4, 1, 0, 0, 0, 0, time.UTC)},
+			{Loc: "http://other.example.com/about"},
+		}
+	}))
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:3000/sitemap.xml", nil)
+	m.ServeHTTP(recorder, req)
+
+	body := recorder.Body.String()
+	expect(t, recorder.Code, http.StatusOK)
+	expect(t, recorder.Header().Get("Content-Type"), "application/xml; charset=utf-8")
+	expect(t, strings.Contains(body, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`), true)
+	expect(t, strings.Contains(body, "<loc>http://example.com/</loc><changefreq>daily</changefreq><priority>1</priority>"), true)
+	expect(t, strings.Contains(body, "<loc>http://example.com/posts/42</loc><lastmod>2014-04-01T00:00:00Z</lastmod>"), true)
+	expect(t, strings.Contains(body, "<loc>http://other.example.com/about</loc>"), true)
+	expect(t, strings.Contains(body, "<loc>http://example.com/about</loc>"), true)
+	expect(t, strings.Count(body, "<url>"), 4)
+}
+
+func Test_Sitemap_Routes(t *testing.T) {
+	m := Classic()
+	m.Group("/docs", func(r Router) {
+		r.Get("/intro", func() {}).Meta("sitemap", true).Meta("changefreq", "monthly")
+	})
+	m.Get("/sitemap.xml", Sitemap("http://example.com", nil))
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:3000/sitemap.xml", nil)
+	m.ServeHTTP(recorder, req)
+
+	expect(t, recorder.Code, http.StatusOK)
+	expect(t, strings.Contains(recorder.Body.String(), "<url><loc>http://example.com/docs/intro</loc><changefreq>monthly</changefreq></url>"), true)
+	expect(t, strings.Count(recorder.Body.String(), "<url>"), 1)
+}
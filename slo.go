@@ -0,0 +1,153 @@
+package martini
+
+import (
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sloMeta is the Route.Meta key routes are annotated with their *SLO under.
+const sloMeta = "slo"
+
+// sloBuckets is the number of buckets the window of an SLO is split into.
+const sloBuckets = 60
+
+// SLO is a service level objective for the routes annotated with it. A request is bad if it takes longer
+// than Latency or ends with a 5xx status. SLO counts good and bad requests, logs each bad one, and
+// reports how fast the error budget is being consumed.
+//
+// Annotate routes with the "slo" meta key, RouteStats records their requests against the objective and
+// reports its burn rate with the route's statistics:
+//
+//	checkout := &martini.SLO{Name: "checkout", Latency: 300 * time.Millisecond, ErrorBudget: 0.001}
+//	m.Use(stats.Handler())
+//	m.Post("/checkout", checkoutHandler).Meta("slo", checkout)
+type SLO struct {
+	// Name identifies the objective in log messages.
+	Name string
+	// Latency is the target latency. Zero disables the latency objective.
+	Latency time.Duration
+	// ErrorBudget is the fraction of requests allowed to be bad, e.g. 0.001 for 99.9%.
+	ErrorBudget float64
+	// SampleRate is the fraction of requests that are recorded. Zero records every request.
+	SampleRate float64
+	// Window is the period BurnRate is computed over. Defaults to an hour.
+	Window time.Duration
+
+	total uint64
+	bad   uint64
+
+	mu      sync.Mutex
+	buckets [sloBuckets]sloBucket
+}
+
+// sloBucket counts the requests of a slice of the window starting at start.
+type sloBucket struct {
+	start time.Time
+	total uint64
+	bad   uint64
+}
+
+// Handler returns the handler recording requests against the objective, for routes not recorded by
+// RouteStats. Add it in front of a route's handlers or to a group.
+func (s *SLO) Handler() Handler {
+	return func(c Context, res http.ResponseWriter, req *http.Request, log *log.Logger) {
+		start := time.Now()
+		rw, ok := res.(ResponseWriter)
+		if !ok {
+			rw = NewResponseWriter(res)
+			c.MapTo(rw, (*http.ResponseWriter)(nil))
+		}
+		c.Next()
+		s.observe(req, time.Since(start), rw.Status(), log)
+	}
+}
+
+// observe records a request answered with the status after d and logs it if it was bad.
+func (s *SLO) observe(req *http.Request, d time.Duration, status int, log *log.Logger) {
+	if s.SampleRate > 0 && rand.Float64() >= s.SampleRate {
+		return
+	}
+
+	slow := s.Latency > 0 && d > s.Latency
+	failed := status >= 500
+	s.record(time.Now(), slow || failed)
+	if (!slow && !failed) || log == nil {
+		return
+	}
+
+	if slow {
+		log.Printf("[SLO] %s: %s %s took %v, objective is %v (burn rate %.2f)", s.Name, req.Method, req.URL.Path, d, s.Latency, s.BurnRate())
+	} else {
+		log.Printf("[SLO] %s: %s %s failed with %v (burn rate %.2f)", s.Name, req.Method, req.URL.Path, status, s.BurnRate())
+	}
+}
+
+func (s *SLO) window() time.Duration {
+	if s.Window <= 0 {
+		return time.Hour
+	}
+	return s.Window
+}
+
+// bucket returns the bucket counting requests at now, reset if it held an earlier slice of the window.
+// The caller holds the mutex.
+func (s *SLO) bucket(now time.Time) *sloBucket {
+	width := s.window() / sloBuckets
+	start := now.Truncate(width)
+	b := &s.buckets[int(start.UnixNano()/int64(width))%sloBuckets]
+	if !b.start.Equal(start) {
+		*b = sloBucket{start: start}
+	}
+	return b
+}
+
+func (s *SLO) record(now time.Time, bad bool) {
+	atomic.AddUint64(&s.total, 1)
+	if bad {
+		atomic.AddUint64(&s.bad, 1)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.bucket(now)
+	b.total++
+	if bad {
+		b.bad++
+	}
+}
+
+// Counts returns the number of recorded and bad requests since the objective was created.
+func (s *SLO) Counts() (total uint64, bad uint64) {
+	return atomic.LoadUint64(&s.total), atomic.LoadUint64(&s.bad)
+}
+
+// BurnRate returns the ratio of bad requests within the window to the error budget. A burn rate above 1
+// means the budget will be exhausted before the end of the window.
+func (s *SLO) BurnRate() float64 {
+	return s.burnRate(time.Now())
+}
+
+func (s *SLO) burnRate(now time.Time) float64 {
+	if s.ErrorBudget <= 0 {
+		return 0
+	}
+	window := s.window()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total, bad uint64
+	for _, b := range s.buckets {
+		if age := now.Sub(b.start); age >= 0 && age < window {
+			total += b.total
+			bad += b.bad
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(bad) / float64(total) / s.ErrorBudget
+}
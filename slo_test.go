@@ -0,0 +1,84 @@
+package martini
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_SLO(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	slo := &SLO{Name: "api", Latency: 5 * time.Millisecond, ErrorBudget: 0.5}
+
+	stats := NewRouteStats()
+	m := Classic()
+	m.Map(log.New(buff, "[martini] ", 0))
+	m.Use(stats.Handler())
+	m.Get("/api/fast", func() string { return "ok" }).Meta("slo", slo)
+	m.Get("/api/slow", func() string {
+		time.Sleep(10 * time.Millisecond)
+		return "ok"
+	}).Meta("slo", slo)
+	m.Get("/api/fail", func() (int, string) { return http.StatusBadGateway, "nope" }).Meta("slo", slo)
+	m.Get("/other", func() (int, string) { return http.StatusBadGateway, "nope" })
+
+	for _, path := range []string{"/api/fast", "/api/fast", "/api/slow", "/api/fail", "/other"} {
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	total, bad := slo.Counts()
+	expect(t, total, uint64(4))
+	expect(t, bad, uint64(2))
+	expect(t, slo.BurnRate(), 1.0)
+	expect(t, strings.Contains(buff.String(), "[SLO] api: GET /api/slow took"), true)
+	expect(t, strings.Contains(buff.String(), "[SLO] api: GET /api/fail failed with 502"), true)
+
+	stat, _ := stats.Get("GET /api/fail")
+	expect(t, stat.BurnRate, 1.0)
+	stat, _ = stats.Get("GET /other")
+	expect(t, stat.BurnRate, 0.0)
+}
+
+func Test_SLO_Window(t *testing.T) {
+	slo := &SLO{ErrorBudget: 0.1, Window: time.Hour}
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		slo.record(now.Add(-2*time.Hour), false)
+	}
+	expect(t, slo.burnRate(now), 0.0)
+
+	slo.record(now.Add(-time.Minute), true)
+	slo.record(now, false)
+	expect(t, slo.burnRate(now), 5.0)
+
+	// the bad request leaves the window
+	expect(t, slo.burnRate(now.Add(time.Hour)), 0.0)
+	total, bad := slo.Counts()
+	expect(t, total, uint64(1002))
+	expect(t, bad, uint64(1))
+}
+
+func Test_SLO_Head(t *testing.T) {
+	slo := &SLO{Name: "things", ErrorBudget: 0.1}
+	r := NewRouter()
+	r.Resource("/things/:id", Methods{
+		"GET": {slo.Handler(), func(params Params) string {
+			return "thing " + params["id"]
+		}},
+	})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("HEAD", "http://localhost:3000/things/42", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusOK)
+	expect(t, recorder.Body.Len(), 0)
+
+	total, bad := slo.Counts()
+	expect(t, total, uint64(1))
+	expect(t, bad, uint64(0))
+}
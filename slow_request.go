@@ -0,0 +1,69 @@
+package martini
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// SlowRequests returns a middleware handler that logs requests taking longer than the threshold. With
+// sampleStack set, the stack of the goroutine serving the request is captured once the threshold passes
+// and logged along with the request, showing where the handler was stuck.
+func SlowRequests(threshold time.Duration, sampleStack bool) Handler {
+	return func(c Context, req *http.Request, log *log.Logger) {
+		var (
+			mu     sync.Mutex
+			sample []byte
+		)
+		if sampleStack {
+			id := goroutineID()
+			timer := time.AfterFunc(threshold, func() {
+				s := goroutineStack(id)
+				mu.Lock()
+				sample = s
+				mu.Unlock()
+			})
+			defer timer.Stop()
+		}
+
+		start := time.Now()
+		c.Next()
+		elapsed := time.Since(start)
+		if elapsed <= threshold {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if sample != nil {
+			log.Printf("[slow] %s %s took %v, stack after %v:\n%s", req.Method, req.URL.Path, elapsed, threshold, sample)
+		} else {
+			log.Printf("[slow] %s %s took %v", req.Method, req.URL.Path, elapsed)
+		}
+	}
+}
+
+// goroutineStack returns the stack of the goroutine with the given id, or nil if it has exited.
+func goroutineStack(id uint64) []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	header := []byte(fmt.Sprintf("goroutine %d [", id))
+	for _, g := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(g, header) {
+			return g
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package martini
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func stuckHandler() {
+	time.Sleep(50 * time.Millisecond)
+}
+
+func Test_SlowRequests(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	m := New()
+	m.Map(log.New(buff, "", 0))
+	m.Use(SlowRequests(10*time.Millisecond, true))
+	m.Use(func(req *http.Request) {
+		if req.URL.Path == "/slow" {
+			stuckHandler()
+		}
+	})
+
+	req, _ := http.NewRequest("GET", "/fast", nil)
+	m.ServeHTTP(httptest.NewRecorder(), req)
+	expect(t, buff.String(), "")
+
+	req, _ = http.NewRequest("GET", "/slow", nil)
+	m.ServeHTTP(httptest.NewRecorder(), req)
+	expect(t, strings.HasPrefix(buff.String(), "[slow] GET /slow took "), true)
+	expect(t, strings.Contains(buff.String(), "stuckHandler"), true)
+}
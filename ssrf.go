@@ -0,0 +1,106 @@
+package martini
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrBlockedRequest is returned for outbound requests an SSRFGuard doesn't allow.
+var ErrBlockedRequest = errors.New("martini: outbound request blocked")
+
+// blockedNetworks are the ranges besides private, loopback, link-local and multicast addresses that
+// aren't reachable on the public internet.
+var blockedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// SSRFGuard protects endpoints that fetch user supplied URLs from being abused to reach internal
+// services. Set it as ClientOptions.Guard to map a guarded client for those routes:
+//
+//	m.Post("/import", martini.HTTPClient(martini.ClientOptions{Guard: &martini.SSRFGuard{}}), importFeed)
+//
+// Every request and redirect is checked against Hosts, and the addresses connected to are checked after
+// DNS resolution, so a host resolving to an internal address is blocked as well.
+type SSRFGuard struct {
+	// Hosts are the hosts requests may go to, "*.example.com" allows the subdomains of example.com.
+	// Empty allows any host.
+	Hosts []string
+	// AllowPrivate allows private, loopback and link-local addresses.
+	AllowPrivate bool
+}
+
+// CheckURL returns an error wrapping ErrBlockedRequest if the URL isn't an http or https URL of an
+// allowed host, or its host is an IP address that isn't allowed.
+func (g *SSRFGuard) CheckURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrBlockedRequest, u.Scheme)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if len(g.Hosts) > 0 && !g.allowedHost(host) {
+		return fmt.Errorf("%w: host %q not allowed", ErrBlockedRequest, host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return g.CheckIP(ip)
+	}
+	return nil
+}
+
+func (g *SSRFGuard) allowedHost(host string) bool {
+	for _, allowed := range g.Hosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckIP returns an error wrapping ErrBlockedRequest if the address isn't a public unicast address,
+// unless AllowPrivate is set.
+func (g *SSRFGuard) CheckIP(ip net.IP) error {
+	if g.AllowPrivate {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	blocked := ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+	for _, n := range blockedNetworks {
+		blocked = blocked || n.Contains(ip)
+	}
+	if blocked {
+		return fmt.Errorf("%w: address %s not allowed", ErrBlockedRequest, ip)
+	}
+	return nil
+}
+
+// Control checks the address a connection is made to, it is meant for net.Dialer.Control.
+func (g *SSRFGuard) Control(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: unresolved address %s", ErrBlockedRequest, address)
+	}
+	return g.CheckIP(ip)
+}
@@ -0,0 +1,66 @@
+package martini
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_SSRFGuard_Check(t *testing.T) {
+	g := &SSRFGuard{}
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "224.0.0.1"} {
+		if err := g.CheckIP(net.ParseIP(ip)); !errors.Is(err, ErrBlockedRequest) {
+			t.Errorf("%s not blocked", ip)
+		}
+	}
+	for _, ip := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"} {
+		expect(t, g.CheckIP(net.ParseIP(ip)), nil)
+	}
+
+	check := func(g *SSRFGuard, raw string) error {
+		u, _ := url.Parse(raw)
+		return g.CheckURL(u)
+	}
+	expect(t, check(g, "https://example.com/feed"), nil)
+	expect(t, errors.Is(check(g, "http://127.0.0.1:8080/admin"), ErrBlockedRequest), true)
+	expect(t, errors.Is(check(g, "http://[::1]/"), ErrBlockedRequest), true)
+	expect(t, errors.Is(check(g, "file:///etc/passwd"), ErrBlockedRequest), true)
+
+	allow := &SSRFGuard{Hosts: []string{"example.com", "*.cdn.example.org"}}
+	expect(t, check(allow, "https://EXAMPLE.com./"), nil)
+	expect(t, check(allow, "https://img.cdn.example.org/a.png"), nil)
+	expect(t, errors.Is(check(allow, "https://cdn.example.org.evil.com/"), ErrBlockedRequest), true)
+	expect(t, errors.Is(check(allow, "https://other.com/"), ErrBlockedRequest), true)
+}
+
+func Test_SSRFGuard_Client(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte("secret"))
+	}))
+	defer internal.Close()
+	// localhost resolves to a loopback address, which the dialer has to catch
+	target := strings.Replace(internal.URL, "127.0.0.1", "localhost", 1)
+
+	fetch := func(guard *SSRFGuard) error {
+		var err error
+		r := NewRouter()
+		r.Get("/", HTTPClient(ClientOptions{Guard: guard}), func(client *http.Client) {
+			var res *http.Response
+			if res, err = client.Get(target); err == nil {
+				res.Body.Close()
+			}
+		})
+		m := New()
+		m.Action(r.Handle)
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		return err
+	}
+
+	expect(t, errors.Is(fetch(&SSRFGuard{}), ErrBlockedRequest), true)
+	expect(t, errors.Is(fetch(&SSRFGuard{AllowPrivate: true, Hosts: []string{"example.com"}}), ErrBlockedRequest), true)
+	expect(t, fetch(&SSRFGuard{AllowPrivate: true}), nil)
+}
@@ -47,7 +47,7 @@ func Static(directory string, staticOpt ...StaticOptions) Handler {
 	dir := http.Dir(directory)
 	opt := prepareStaticOptions(staticOpt)
 
-	return func(res http.ResponseWriter, req *http.Request, log *log.Logger) {
+	return func(res http.ResponseWriter, req *http.Request, log *log.Logger, base BasePath) {
 		if req.Method != "GET" && req.Method != "HEAD" {
 			return
 		}
@@ -78,7 +78,7 @@ func Static(directory string, staticOpt ...StaticOptions) Handler {
 		if fi.IsDir() {
 			// redirect if missing trailing slash
 			if !strings.HasSuffix(req.URL.Path, "/") {
-				http.Redirect(res, req, req.URL.Path+"/", http.StatusFound)
+				http.Redirect(res, req, base.URL(req.URL.Path+"/"), http.StatusFound)
 				return
 			}
 
@@ -0,0 +1,265 @@
+package martini
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidKey is returned by storages for keys that aren't relative slash separated paths.
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage stores files by key, a slash separated relative path. Get returns an error satisfying
+// errors.Is(err, os.ErrNotExist) for missing keys. Adapters for object stores like S3 or GCS implement
+// SignedURL with the presigned URLs of the service.
+type Storage interface {
+	Put(key string, r io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	// SignedURL returns a URL granting access to the file for the ttl.
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// AppendStorage is a Storage that can append to files. Tus uses it when available, otherwise every
+// chunk rewrites the whole file.
+type AppendStorage interface {
+	Storage
+	// Append appends to the file with the key, creating it if needed. It returns the number of bytes
+	// appended, also when it fails halfway.
+	Append(key string, r io.Reader) (int64, error)
+}
+
+// ListStorage is a Storage that can list its files. Tus.Sweep needs it to find expired uploads.
+type ListStorage interface {
+	Storage
+	// List returns the keys of the files starting with the prefix.
+	List(prefix string) ([]string, error)
+}
+
+// LocalStorage is a Storage keeping files in a directory on disk. Its signed URLs point to the route
+// serving Handler:
+//
+//	storage := &martini.LocalStorage{Dir: "uploads", URL: "/files", Secret: secret}
+//	m.Get("/files/**", storage.Handler())
+type LocalStorage struct {
+	Dir string
+	// URL is the URL of the route serving Handler.
+	URL string
+	// Secret signs the URLs. Without a Secret or SecretRef no URL is signed or accepted.
+	Secret []byte
+	// SecretRef names the secret signing the URLs instead of Secret, so it can be rotated.
+	SecretRef SecretRef
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file to a temporary file first, so readers never see a partial file.
+func (s *LocalStorage) Put(key string, r io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (s *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *LocalStorage) Append(key string, r io.Reader) (int64, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) List(prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(s.Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Dir, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (s *LocalStorage) SignedURL(key string, ttl time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	keys, err := s.SecretRef.keys(s.Secret)
+	if err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	q := url.Values{"expires": {expires}, "signature": {signStorage(keys[0], key, expires)}}
+	return strings.TrimSuffix(s.URL, "/") + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+func signStorage(secret []byte, key string, expires string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the query carries a valid, unexpired signature for the key.
+func (s *LocalStorage) Verify(key string, query url.Values) bool {
+	expires := query.Get("expires")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	keys, err := s.SecretRef.keys(s.Secret)
+	if err != nil {
+		return false
+	}
+	for _, secret := range keys {
+		if hmac.Equal([]byte(query.Get("signature")), []byte(signStorage(secret, key, expires))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler returns a handler serving the files of signed URLs, for a route ending in **.
+func (s *LocalStorage) Handler() Handler {
+	return func(params Params, res http.ResponseWriter, req *http.Request) {
+		key := params["_1"]
+		if !s.Verify(key, req.URL.Query()) {
+			http.Error(res, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		ServeObject(res, req, s, key, "")
+	}
+}
+
+// inlineTypes are the content types ServeObject lets browsers display. Other files are downloaded, so
+// uploaded HTML or SVG can't run scripts on the app's origin.
+var inlineTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// ServeObject serves the file with the key from the storage, answering 404 for missing keys. A non empty
+// name makes the browser download the file under that name. Files of types outside of a small set of
+// images, PDF and plain text are always downloaded.
+func ServeObject(res http.ResponseWriter, req *http.Request, s Storage, key string, name string) {
+	r, err := s.Get(key)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrInvalidKey) {
+		http.NotFound(res, req)
+		return
+	} else if err != nil {
+		panic(err)
+	}
+	defer r.Close()
+
+	ctype := mime.TypeByExtension(path.Ext(key))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	if mediaType, _, _ := mime.ParseMediaType(ctype); name == "" && !inlineTypes[mediaType] {
+		name = path.Base(key)
+	}
+	if name != "" {
+		res.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+	res.Header().Set("Content-Type", ctype)
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	if rs, ok := r.(io.ReadSeeker); ok {
+		http.ServeContent(res, req, path.Base(key), time.Time{}, rs)
+		return
+	}
+	io.Copy(res, r)
+}
+
+// SaveUpload stores the file uploaded in the multipart form field under the key.
+func SaveUpload(s Storage, req *http.Request, field string, key string) (*multipart.FileHeader, error) {
+	f, header, err := req.FormFile(field)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := s.Put(key, f); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
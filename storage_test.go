@@ -0,0 +1,147 @@
+package martini
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_LocalStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-storage")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	s := &LocalStorage{Dir: dir, URL: "/files", Secret: []byte("secret")}
+
+	expect(t, s.Put("avatars/1.txt", strings.NewReader("hello")), nil)
+	r, err := s.Get("avatars/1.txt")
+	expect(t, err, nil)
+	body, _ := ioutil.ReadAll(r)
+	r.Close()
+	expect(t, string(body), "hello")
+
+	for _, key := range []string{"", "/etc/passwd", "../x", "a/../../x", "a//b"} {
+		expect(t, s.Put(key, strings.NewReader("x")), ErrInvalidKey)
+	}
+
+	expect(t, s.Delete("avatars/1.txt"), nil)
+	_, err = s.Get("avatars/1.txt")
+	expect(t, os.IsNotExist(err), true)
+	expect(t, s.Delete("avatars/1.txt"), nil)
+}
+
+func Test_LocalStorage_SignedURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-storage")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	s := &LocalStorage{Dir: dir, URL: "/files/", Secret: []byte("secret")}
+	s.Put("docs/a b.txt", strings.NewReader("content"))
+
+	m := Classic()
+	m.Get("/files/**", s.Handler())
+
+	signed, err := s.SignedURL("docs/a b.txt", time.Minute)
+	expect(t, err, nil)
+	expect(t, strings.HasPrefix(signed, "/files/docs/a%20b.txt?expires="), true)
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", signed, nil))
+	expect(t, res.Code, http.StatusOK)
+	expect(t, res.Body.String(), "content")
+	expect(t, res.Header().Get("Content-Type"), "text/plain; charset=utf-8")
+	expect(t, res.Header().Get("X-Content-Type-Options"), "nosniff")
+	expect(t, res.Header().Get("Content-Disposition"), "")
+
+	u, _ := url.Parse(signed)
+	q := u.Query()
+	q.Set("expires", "9999999999")
+	u.RawQuery = q.Encode()
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", u.String(), nil))
+	expect(t, res.Code, http.StatusForbidden)
+
+	expired, _ := s.SignedURL("docs/a b.txt", -time.Minute)
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", expired, nil))
+	expect(t, res.Code, http.StatusForbidden)
+
+	// uploaded markup is downloaded rather than rendered on the app's origin
+	for key, ctype := range map[string]string{"page.html": "text/html; charset=utf-8", "logo.svg": "image/svg+xml", "blob": "application/octet-stream"} {
+		s.Put(key, strings.NewReader("<script>alert(1)</script>"))
+		signed, _ = s.SignedURL(key, time.Minute)
+		res = httptest.NewRecorder()
+		m.ServeHTTP(res, httptest.NewRequest("GET", signed, nil))
+		expect(t, res.Code, http.StatusOK)
+		expect(t, res.Header().Get("Content-Type"), ctype)
+		expect(t, res.Header().Get("Content-Disposition"), "attachment; filename="+key)
+		expect(t, res.Header().Get("X-Content-Type-Options"), "nosniff")
+	}
+}
+
+func Test_LocalStorage_NoSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-storage")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	s := &LocalStorage{Dir: dir, URL: "/files"}
+	s.Put("a.txt", strings.NewReader("secret content"))
+
+	m := Classic()
+	m.Get("/files/**", s.Handler())
+
+	_, err = s.SignedURL("a.txt", time.Minute)
+	expect(t, err, ErrSecretNotFound)
+
+	// a signature anyone can compute with an empty key
+	expires := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	q := url.Values{"expires": {expires}, "signature": {signStorage(nil, "a.txt", expires)}}
+	expect(t, s.Verify("a.txt", q), false)
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/files/a.txt?"+q.Encode(), nil))
+	expect(t, res.Code, http.StatusForbidden)
+}
+
+func Test_SaveUpload_ServeObject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-storage")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	s := &LocalStorage{Dir: dir}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fw, _ := w.CreateFormFile("file", "report.csv")
+	fw.Write([]byte("a,b\n"))
+	w.Close()
+
+	m := Classic()
+	m.Post("/upload", func(req *http.Request) string {
+		header, err := SaveUpload(s, req, "file", "reports/1.csv")
+		expect(t, err, nil)
+		return header.Filename
+	})
+	m.Get("/download/:id", func(params Params, res http.ResponseWriter, req *http.Request) {
+		ServeObject(res, req, s, "reports/"+params["id"]+".csv", "report.csv")
+	})
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	m.ServeHTTP(res, req)
+	expect(t, res.Body.String(), "report.csv")
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/download/1", nil))
+	expect(t, res.Code, http.StatusOK)
+	expect(t, res.Body.String(), "a,b\n")
+	expect(t, res.Header().Get("Content-Disposition"), `attachment; filename=report.csv`)
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/download/2", nil))
+	expect(t, res.Code, http.StatusNotFound)
+}
@@ -0,0 +1,236 @@
+package martini
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// contentBlock is the block a layout renders the page into.
+const contentBlock = "content"
+
+// TemplateOptions configures Templates.
+type TemplateOptions struct {
+	// Dir is the directory of the templates, "templates" by default. Layouts are kept in its "layouts" and
+	// partials in its "partials" subdirectory, every other template is a page.
+	Dir string
+	// Extension is the extension of template files, ".tmpl" by default.
+	Extension string
+	// Layout is the layout pages are rendered in unless a render call selects another one. Without it
+	// pages are rendered on their own.
+	Layout string
+	// Funcs are the functions available to the templates.
+	Funcs template.FuncMap
+}
+
+// RenderOptions select how a single render call renders the page.
+type RenderOptions struct {
+	// Layout overrides the default layout.
+	Layout string
+	// NoLayout renders the page without any layout.
+	NoLayout bool
+	// Block renders only the named block of the page, e.g. a fragment for htmx or Turbo, without layout.
+	Block string
+}
+
+// Templates renders pages with layout inheritance: a layout declares blocks with default content, like
+// {{block "content" .}}{{end}}, and the page fills them with {{define "content"}}...{{end}}. Partials are
+// available to all layouts and pages with {{template "name" .}}, named by their file name without the
+// extension. In development the templates are parsed again on every render, so changes show up on reload.
+//
+// The csp_nonce function returns the nonce of the CSP middleware for the request, for script and style
+// tags: <script nonce="{{csp_nonce}}">.
+type Templates struct {
+	opts   TemplateOptions
+	reload bool
+	// nonceMarker is output by csp_nonce and replaced with the nonce of the request after rendering, as
+	// the functions of parsed templates can't be changed per request.
+	nonceMarker string
+
+	mu    sync.Mutex
+	pages map[string]*page
+}
+
+// page is the template set of a page.
+type page struct {
+	set *template.Template
+	// content is whether the page defines the content block itself.
+	content bool
+}
+
+// NewTemplates parses the templates.
+func NewTemplates(opts TemplateOptions) (*Templates, error) {
+	if opts.Dir == "" {
+		opts.Dir = "templates"
+	}
+	if opts.Extension == "" {
+		opts.Extension = ".tmpl"
+	}
+	t := &Templates{opts: opts, reload: Env == Dev, nonceMarker: randomToken(16)}
+	pages, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	t.pages = pages
+	return t, nil
+}
+
+// parse parses every page together with the layouts and partials, so the pages can't override each
+// other's blocks.
+func (t *Templates) parse() (map[string]*page, error) {
+	base := template.New("").Funcs(t.funcs())
+	var pages []string
+	err := filepath.Walk(t.opts.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, t.opts.Extension) {
+			return err
+		}
+		rel, err := filepath.Rel(t.opts.Dir, path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.ToSlash(rel), t.opts.Extension)
+		switch {
+		case strings.HasPrefix(name, "layouts/"):
+			name = strings.TrimPrefix(name, "layouts/")
+		case strings.HasPrefix(name, "partials/"):
+			name = strings.TrimPrefix(name, "partials/")
+		default:
+			pages = append(pages, path)
+			return nil
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = base.New(name).Parse(string(src))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := make(map[string]*page, len(pages))
+	for _, path := range pages {
+		rel, _ := filepath.Rel(t.opts.Dir, path)
+		name := strings.TrimSuffix(filepath.ToSlash(rel), t.opts.Extension)
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		set, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := set.New(name).Parse(string(src))
+		if err != nil {
+			return nil, err
+		}
+		own, _ := template.New(name).Funcs(t.funcs()).Parse(string(src))
+		parsed[name] = &page{set: tmpl, content: own.Lookup(contentBlock) != nil}
+	}
+	return parsed, nil
+}
+
+func (t *Templates) funcs() template.FuncMap {
+	funcs := template.FuncMap{
+		"csp_nonce": func() string { return t.nonceMarker },
+	}
+	for name, f := range t.opts.Funcs {
+		funcs[name] = f
+	}
+	return funcs
+}
+
+// Render renders the page with the data into a buffer, so errors don't leave half a page behind.
+func (t *Templates) Render(name string, data interface{}, opts ...RenderOptions) ([]byte, error) {
+	return t.render(name, data, "", opts...)
+}
+
+// hasBlock returns whether the page defines the block.
+func (t *Templates) hasBlock(name string, block string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pages[name]
+	return ok && p.set.Lookup(block) != nil
+}
+
+// render renders the page, replacing the output of csp_nonce with the nonce.
+func (t *Templates) render(name string, data interface{}, nonce string, opts ...RenderOptions) ([]byte, error) {
+	var o RenderOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	t.mu.Lock()
+	if t.reload {
+		pages, err := t.parse()
+		if err != nil {
+			t.mu.Unlock()
+			return nil, err
+		}
+		t.pages = pages
+	}
+	p, ok := t.pages[name]
+	t.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("martini: template %q not found", name)
+	}
+
+	layout := o.Layout
+	if layout == "" {
+		layout = t.opts.Layout
+	}
+	target := name
+	switch {
+	case o.Block != "":
+		target = o.Block
+	case layout != "" && !o.NoLayout:
+		target = layout
+	case p.content:
+		target = contentBlock
+	}
+
+	var buf bytes.Buffer
+	if err := p.set.ExecuteTemplate(&buf, target, data); err != nil {
+		return nil, err
+	}
+	return bytes.Replace(buf.Bytes(), []byte(t.nonceMarker), []byte(nonce), -1), nil
+}
+
+// Handler returns a middleware handler mapping a *Render for the request.
+func (t *Templates) Handler() Handler {
+	return func(c Context, res http.ResponseWriter, req *http.Request) {
+		c.Map(&Render{Templates: t, c: c, res: res, req: req})
+	}
+}
+
+// Render renders templates as the response of a request.
+type Render struct {
+	Templates *Templates
+	c         Context
+	res       http.ResponseWriter
+	req       *http.Request
+}
+
+// HTML renders the page as the response with the status.
+func (r *Render) HTML(status int, name string, data interface{}, opts ...RenderOptions) error {
+	var nonce string
+	if v := r.c.Get(reflect.TypeOf(CSPNonce(""))); v.IsValid() {
+		nonce = v.String()
+	}
+	out, err := r.Templates.render(name, data, nonce, opts...)
+	if err != nil {
+		return err
+	}
+	r.res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	r.res.WriteHeader(status)
+	_, err = r.res.Write(out)
+	return err
+}
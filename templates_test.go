@@ -0,0 +1,103 @@
+package martini
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplates(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "martini-templates")
+	expect(t, err, nil)
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		expect(t, ioutil.WriteFile(path, []byte(src), 0644), nil)
+	}
+	return dir
+}
+
+func Test_Templates(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"layouts/main.tmpl":   `<main>{{block "content" .}}default{{end}}</main>`,
+		"layouts/admin.tmpl":  `<admin>{{block "content" .}}{{end}}</admin>`,
+		"partials/item.tmpl":  `<li>{{.}}</li>`,
+		"users/list.tmpl":     `{{define "content"}}<ul>{{block "items" .}}{{range .}}{{template "item" .}}{{end}}{{end}}</ul>{{end}}`,
+		"users/empty.tmpl":    ``,
+		"plain.tmpl":          `plain {{upper .}}`,
+		"users/show.tmpl":     `{{define "content"}}{{.}}{{end}}`,
+		"layouts/ignore.txt":  `not a template`,
+		"partials/footer.txt": `not a template`,
+	})
+	defer os.RemoveAll(dir)
+
+	tmpl, err := NewTemplates(TemplateOptions{Dir: dir, Layout: "main", Funcs: map[string]interface{}{"upper": strings.ToUpper}})
+	expect(t, err, nil)
+
+	users := []string{"<a>", "b"}
+	out, err := tmpl.Render("users/list", users)
+	expect(t, err, nil)
+	expect(t, string(out), "<main><ul><li>&lt;a&gt;</li><li>b</li></ul></main>")
+
+	out, err = tmpl.Render("users/list", users, RenderOptions{Layout: "admin"})
+	expect(t, err, nil)
+	expect(t, string(out), "<admin><ul><li>&lt;a&gt;</li><li>b</li></ul></admin>")
+
+	out, err = tmpl.Render("users/list", users, RenderOptions{Block: "items"})
+	expect(t, err, nil)
+	expect(t, string(out), "<li>&lt;a&gt;</li><li>b</li>")
+
+	out, err = tmpl.Render("users/list", users, RenderOptions{NoLayout: true})
+	expect(t, err, nil)
+	expect(t, string(out), "<ul><li>&lt;a&gt;</li><li>b</li></ul>")
+
+	// pages don't see each other's blocks
+	out, err = tmpl.Render("users/empty", nil)
+	expect(t, err, nil)
+	expect(t, string(out), "<main>default</main>")
+
+	out, err = tmpl.Render("plain", "x", RenderOptions{NoLayout: true})
+	expect(t, err, nil)
+	expect(t, string(out), "plain X")
+
+	_, err = tmpl.Render("missing", nil)
+	refute(t, err, nil)
+}
+
+func Test_Render(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"hello.tmpl": `Hello {{.}}`,
+	})
+	defer os.RemoveAll(dir)
+	tmpl, err := NewTemplates(TemplateOptions{Dir: dir})
+	expect(t, err, nil)
+
+	m := Classic()
+	m.Use(tmpl.Handler())
+	m.Get("/", func(r *Render) error {
+		return r.HTML(http.StatusAccepted, "hello", "world")
+	})
+	m.Get("/missing", func(r *Render) error {
+		return r.HTML(http.StatusOK, "missing", nil)
+	})
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+	expect(t, res.Code, http.StatusAccepted)
+	expect(t, res.Body.String(), "Hello world")
+	expect(t, res.Header().Get("Content-Type"), "text/html; charset=utf-8")
+
+	// changes show up in development
+	ioutil.WriteFile(filepath.Join(dir, "hello.tmpl"), []byte(`Hi {{.}}`), 0644)
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+	expect(t, res.Body.String(), "Hi world")
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/missing", nil))
+	expect(t, res.Code, http.StatusInternalServerError)
+}
@@ -0,0 +1,35 @@
+package martini
+
+import (
+	gocontext "context"
+	"net/http"
+	"time"
+)
+
+// Timeout returns a middleware handler that sets a deadline on the request's context. The *http.Request
+// is re-mapped with the new context so handlers and Context.CheckDeadline observe the deadline. If the
+// deadline passes before the response has been written, a 503 Service Unavailable is sent.
+func Timeout(d time.Duration) Handler {
+	return func(c Context, req *http.Request, res http.ResponseWriter) {
+		withTimeout(c, req, res, d)
+	}
+}
+
+// withTimeout runs the rest of the handler chain with a deadline on the request's context.
+func withTimeout(c Context, req *http.Request, res http.ResponseWriter, d time.Duration) {
+	ctx, cancel := gocontext.WithTimeout(req.Context(), d)
+	defer cancel()
+
+	rw, ok := res.(ResponseWriter)
+	if !ok {
+		// e.g. the body discarding writer of HEAD requests routed to GET handlers
+		rw = NewResponseWriter(res)
+		c.MapTo(rw, (*http.ResponseWriter)(nil))
+	}
+	c.Map(req.WithContext(ctx))
+	c.Next()
+
+	if ctx.Err() == gocontext.DeadlineExceeded && !rw.Written() {
+		http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}
+}
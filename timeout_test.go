@@ -0,0 +1,56 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Timeout(t *testing.T) {
+	recorder := httptest.NewRecorder()
+
+	m := New()
+	m.Use(Timeout(time.Millisecond))
+	m.Use(func(c Context, req *http.Request) {
+		expect(t, c.CheckDeadline(), nil)
+		_, ok := req.Context().Deadline()
+		expect(t, ok, true)
+
+		<-req.Context().Done()
+		refute(t, c.CheckDeadline(), nil)
+	})
+
+	req, _ := http.NewRequest("GET", "http://localhost:3000/slow", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Code, http.StatusServiceUnavailable)
+}
+
+func Test_Timeout_Written(t *testing.T) {
+	recorder := httptest.NewRecorder()
+
+	m := New()
+	m.Use(Timeout(time.Second))
+	m.Use(func(res http.ResponseWriter) {
+		res.WriteHeader(http.StatusAccepted)
+	})
+
+	req, _ := http.NewRequest("GET", "http://localhost:3000/fast", nil)
+	m.ServeHTTP(recorder, req)
+	expect(t, recorder.Code, http.StatusAccepted)
+}
+
+func Test_Timeout_Head(t *testing.T) {
+	r := NewRouter()
+	r.Resource("/things/:id", Methods{
+		"GET": {WithOptions(RouteOptions{Timeout: time.Second}), func(params Params) string {
+			return "thing " + params["id"]
+		}},
+	})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("HEAD", "http://localhost:3000/things/42", nil)
+	r.Handle(recorder, req, New().createContext(recorder, req))
+	expect(t, recorder.Code, http.StatusOK)
+	expect(t, recorder.Body.Len(), 0)
+}
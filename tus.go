@@ -0,0 +1,367 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tusVersion is the version of the tus protocol implemented by Tus.
+const tusVersion = "1.0.0"
+
+// TusOptions configures Tus.
+type TusOptions struct {
+	// URL is the URL the handler is mounted at, used for the Location of new uploads.
+	URL string
+	// Prefix is prepended to the storage keys, "tus/" by default.
+	Prefix string
+	// MaxSize is the maximum size of an upload in bytes, 0 for no limit.
+	MaxSize int64
+	// Expiration is how long an upload can be continued after its creation. Defaults to 24 hours.
+	Expiration time.Duration
+	// OnComplete is called when the last byte of an upload has been received.
+	OnComplete func(u *TusUpload)
+}
+
+// TusUpload is the state of a resumable upload.
+type TusUpload struct {
+	ID       string            `json:"id"`
+	Length   int64             `json:"length"`
+	Offset   int64             `json:"offset"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Expires  time.Time         `json:"expires"`
+}
+
+// Key returns the storage key of the uploaded file.
+func (u *TusUpload) Key(t *Tus) string {
+	return t.opts.Prefix + u.ID
+}
+
+// Tus implements the tus.io resumable upload protocol with the creation, expiration and termination
+// extensions, storing uploads in a Storage. Clients create an upload with POST, learn how much has
+// arrived with HEAD and send the rest with PATCH after a broken connection.
+//
+//	tus := martini.NewTus(storage, martini.TusOptions{URL: "/uploads"})
+//	m.Any("/uploads", tus.Handler())
+//	m.Any("/uploads/**", tus.Handler())
+type Tus struct {
+	storage Storage
+	opts    TusOptions
+	mu      sync.Mutex
+	locks   map[string]*tusLock
+}
+
+// tusLock serializes the requests for an upload, it is removed once it has no holders left.
+type tusLock struct {
+	sync.Mutex
+	holders int
+}
+
+// NewTus creates a Tus storing uploads in the storage.
+func NewTus(storage Storage, opts TusOptions) *Tus {
+	if opts.Prefix == "" {
+		opts.Prefix = "tus/"
+	}
+	if opts.Expiration == 0 {
+		opts.Expiration = 24 * time.Hour
+	}
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+	return &Tus{storage: storage, opts: opts, locks: make(map[string]*tusLock)}
+}
+
+// lock serializes the requests for an upload.
+func (t *Tus) lock(id string) func() {
+	t.mu.Lock()
+	l, ok := t.locks[id]
+	if !ok {
+		l = new(tusLock)
+		t.locks[id] = l
+	}
+	l.holders++
+	t.mu.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		t.mu.Lock()
+		l.holders--
+		if l.holders == 0 {
+			delete(t.locks, id)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// Upload returns the upload with the id, or an error satisfying errors.Is(err, os.ErrNotExist).
+func (t *Tus) Upload(id string) (*TusUpload, error) {
+	r, err := t.storage.Get(t.opts.Prefix + id + ".info")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var u TusUpload
+	if err := json.NewDecoder(r).Decode(&u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (t *Tus) save(u *TusUpload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return t.storage.Put(t.opts.Prefix+u.ID+".info", bytes.NewReader(data))
+}
+
+// Delete deletes the upload and its file.
+func (t *Tus) Delete(id string) error {
+	if err := t.storage.Delete(t.opts.Prefix + id); err != nil {
+		return err
+	}
+	return t.storage.Delete(t.opts.Prefix + id + ".info")
+}
+
+// Sweep deletes the expired uploads that haven't been completed and returns their number. Expired uploads
+// are deleted when they are requested, call Sweep periodically to delete the abandoned ones as well. The
+// storage has to be a ListStorage.
+func (t *Tus) Sweep() (int, error) {
+	ls, ok := t.storage.(ListStorage)
+	if !ok {
+		return 0, errors.New("martini: sweeping uploads needs a ListStorage")
+	}
+	keys, err := ls.List(t.opts.Prefix)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".info") {
+			continue
+		}
+		id := strings.TrimSuffix(key[len(t.opts.Prefix):], ".info")
+		unlock := t.lock(id)
+		u, err := t.Upload(id)
+		if err == nil && time.Now().After(u.Expires) && u.Offset < u.Length {
+			err = t.Delete(id)
+			if err == nil {
+				n++
+			}
+		}
+		unlock()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Handler returns the handler for the routes of the upload URL and the URLs of the uploads below it.
+func (t *Tus) Handler() Handler {
+	return func(params Params, res http.ResponseWriter, req *http.Request) {
+		h := res.Header()
+		h.Set("Tus-Resumable", tusVersion)
+		if req.Method == "OPTIONS" {
+			h.Set("Tus-Version", tusVersion)
+			h.Set("Tus-Extension", "creation,expiration,termination")
+			if t.opts.MaxSize > 0 {
+				h.Set("Tus-Max-Size", strconv.FormatInt(t.opts.MaxSize, 10))
+			}
+			res.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if req.Header.Get("Tus-Resumable") != tusVersion {
+			h.Set("Tus-Version", tusVersion)
+			res.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		id := strings.Trim(params["_1"], "/")
+		if id == "" {
+			if req.Method != "POST" {
+				res.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			t.create(res, req)
+			return
+		}
+		if strings.ContainsAny(id, "/.") {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		// only lock uploads that exist, the upload is read again once locked
+		if _, err := t.Upload(id); errors.Is(err, os.ErrNotExist) {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+		defer t.lock(id)()
+		u, err := t.Upload(id)
+		if errors.Is(err, os.ErrNotExist) {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		} else if err != nil {
+			panic(err)
+		}
+		if time.Now().After(u.Expires) && u.Offset < u.Length {
+			t.Delete(id)
+			res.WriteHeader(http.StatusGone)
+			return
+		}
+
+		switch req.Method {
+		case "HEAD":
+			h.Set("Cache-Control", "no-store")
+			h.Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+			h.Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+			if len(u.Metadata) > 0 {
+				h.Set("Upload-Metadata", encodeTusMetadata(u.Metadata))
+			}
+			res.WriteHeader(http.StatusOK)
+		case "PATCH":
+			t.patch(u, res, req)
+		case "DELETE":
+			if err := t.Delete(id); err != nil {
+				panic(err)
+			}
+			res.WriteHeader(http.StatusNoContent)
+		default:
+			res.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (t *Tus) create(res http.ResponseWriter, req *http.Request) {
+	length, err := strconv.ParseInt(req.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(res, "invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if t.opts.MaxSize > 0 && length > t.opts.MaxSize {
+		res.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := decodeTusMetadata(req.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(res, "invalid Upload-Metadata", http.StatusBadRequest)
+		return
+	}
+	u := &TusUpload{ID: randomToken(16), Length: length, Metadata: metadata, Expires: time.Now().Add(t.opts.Expiration)}
+	if err := t.storage.Put(u.Key(t), strings.NewReader("")); err != nil {
+		panic(err)
+	}
+	if err := t.save(u); err != nil {
+		panic(err)
+	}
+	res.Header().Set("Location", t.opts.URL+"/"+u.ID)
+	res.Header().Set("Upload-Expires", u.Expires.UTC().Format(http.TimeFormat))
+	res.WriteHeader(http.StatusCreated)
+	if length == 0 && t.opts.OnComplete != nil {
+		t.opts.OnComplete(u)
+	}
+}
+
+func (t *Tus) patch(u *TusUpload, res http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		res.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(req.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		http.Error(res, "invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+	if offset != u.Offset {
+		res.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	body := io.LimitReader(req.Body, u.Length-u.Offset)
+	var n int64
+	if as, ok := t.storage.(AppendStorage); ok {
+		n, err = as.Append(u.Key(t), body)
+	} else {
+		n, err = t.rewrite(u.Key(t), body)
+	}
+	// keep what arrived before a broken connection, the client continues from there
+	u.Offset += n
+	if serr := t.save(u); serr != nil {
+		panic(serr)
+	}
+	if err != nil {
+		return
+	}
+
+	res.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	res.Header().Set("Upload-Expires", u.Expires.UTC().Format(http.TimeFormat))
+	res.WriteHeader(http.StatusNoContent)
+	if n > 0 && u.Offset == u.Length && t.opts.OnComplete != nil {
+		t.opts.OnComplete(u)
+	}
+}
+
+// rewrite appends to the file in storages without AppendStorage, by writing it again with the chunk.
+func (t *Tus) rewrite(key string, chunk io.Reader) (int64, error) {
+	r, err := t.storage.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	existing, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return 0, err
+	}
+	data, err := ioutil.ReadAll(chunk)
+	if len(data) == 0 {
+		return 0, err
+	}
+	if perr := t.storage.Put(key, io.MultiReader(bytes.NewReader(existing), bytes.NewReader(data))); perr != nil {
+		return 0, perr
+	}
+	return int64(len(data)), err
+}
+
+// decodeTusMetadata decodes the Upload-Metadata header, comma separated keys with base64 values.
+func decodeTusMetadata(header string) (map[string]string, error) {
+	if header == "" {
+		return nil, nil
+	}
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, errors.New("invalid metadata")
+		}
+		var value []byte
+		if len(fields) == 2 {
+			var err error
+			if value, err = base64.StdEncoding.DecodeString(fields[1]); err != nil {
+				return nil, err
+			}
+		}
+		metadata[fields[0]] = string(value)
+	}
+	return metadata, nil
+}
+
+func encodeTusMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for k, v := range metadata {
+		if v == "" {
+			pairs = append(pairs, k)
+		} else {
+			pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(v)))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
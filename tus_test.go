@@ -0,0 +1,145 @@
+package martini
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rewriteStorage hides the Append method of a LocalStorage.
+type rewriteStorage struct {
+	Storage
+}
+
+func Test_Tus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-tus")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	for _, storage := range []Storage{&LocalStorage{Dir: dir}, rewriteStorage{&LocalStorage{Dir: dir}}} {
+		var completed *TusUpload
+		tus := NewTus(storage, TusOptions{URL: "/uploads/", MaxSize: 100, OnComplete: func(u *TusUpload) { completed = u }})
+		m := Classic()
+		m.Any("/uploads", tus.Handler())
+		m.Any("/uploads/**", tus.Handler())
+
+		do := func(method string, url string, body string, headers ...string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, url, strings.NewReader(body))
+			req.Header.Set("Tus-Resumable", "1.0.0")
+			for i := 0; i < len(headers); i += 2 {
+				req.Header.Set(headers[i], headers[i+1])
+			}
+			res := httptest.NewRecorder()
+			m.ServeHTTP(res, req)
+			return res
+		}
+
+		res := do("OPTIONS", "/uploads", "")
+		expect(t, res.Code, http.StatusNoContent)
+		expect(t, res.Header().Get("Tus-Extension"), "creation,expiration,termination")
+		expect(t, res.Header().Get("Tus-Max-Size"), "100")
+
+		expect(t, do("POST", "/uploads", "", "Upload-Length", "101").Code, http.StatusRequestEntityTooLarge)
+		res = do("POST", "/uploads", "", "Upload-Length", "11", "Upload-Metadata", "filename aGVsbG8udHh0,private")
+		expect(t, res.Code, http.StatusCreated)
+		location := res.Header().Get("Location")
+		expect(t, strings.HasPrefix(location, "/uploads/"), true)
+		id := location[len("/uploads/"):]
+
+		octets := "application/offset+octet-stream"
+		expect(t, do("PATCH", location, "hello", "Content-Type", octets, "Upload-Offset", "0").Code, http.StatusNoContent)
+		expect(t, do("PATCH", location, "xxx", "Content-Type", octets, "Upload-Offset", "0").Code, http.StatusConflict)
+		expect(t, do("PATCH", location, "xxx", "Content-Type", "text/plain", "Upload-Offset", "5").Code, http.StatusUnsupportedMediaType)
+
+		res = do("HEAD", location, "")
+		expect(t, res.Header().Get("Upload-Offset"), "5")
+		expect(t, res.Header().Get("Upload-Length"), "11")
+		expect(t, res.Header().Get("Upload-Metadata"), "filename aGVsbG8udHh0,private")
+		expect(t, res.Header().Get("Cache-Control"), "no-store")
+		expect(t, completed == nil, true)
+
+		res = do("PATCH", location, " world and more", "Content-Type", octets, "Upload-Offset", "5")
+		expect(t, res.Header().Get("Upload-Offset"), "11")
+		refute(t, completed, nil)
+		expect(t, completed.Metadata["filename"], "hello.txt")
+
+		r, err := storage.Get(completed.Key(tus))
+		expect(t, err, nil)
+		content, _ := ioutil.ReadAll(r)
+		r.Close()
+		expect(t, string(content), "hello world")
+
+		req := httptest.NewRequest("HEAD", location, nil)
+		res = httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		expect(t, res.Code, http.StatusPreconditionFailed)
+
+		expect(t, do("DELETE", location, "").Code, http.StatusNoContent)
+		expect(t, do("HEAD", location, "").Code, http.StatusNotFound)
+		_, err = tus.Upload(id)
+		expect(t, os.IsNotExist(err), true)
+	}
+}
+
+func Test_Tus_Expiration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-tus")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	tus := NewTus(&LocalStorage{Dir: dir}, TusOptions{URL: "/uploads", Expiration: -time.Second})
+	m := Classic()
+	m.Any("/uploads/**", tus.Handler())
+
+	req := httptest.NewRequest("POST", "/uploads/", nil)
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Upload-Length", "5")
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	expect(t, res.Code, http.StatusCreated)
+
+	req = httptest.NewRequest("HEAD", res.Header().Get("Location"), nil)
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	expect(t, res.Code, http.StatusGone)
+}
+
+
+func Test_Tus_Sweep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini-tus")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	storage := &LocalStorage{Dir: dir}
+	tus := NewTus(storage, TusOptions{URL: "/uploads", Expiration: time.Hour})
+	m := Classic()
+	m.Any("/uploads/**", tus.Handler())
+
+	for _, u := range []*TusUpload{
+		{ID: "abandoned", Length: 5, Expires: time.Now().Add(-time.Minute)},
+		{ID: "completed", Length: 5, Offset: 5, Expires: time.Now().Add(-time.Minute)},
+		{ID: "pending", Length: 5, Expires: time.Now().Add(time.Minute)},
+	} {
+		expect(t, tus.save(u), nil)
+	}
+	n, err := tus.Sweep()
+	expect(t, err, nil)
+	expect(t, n, 1)
+	_, err = tus.Upload("abandoned")
+	expect(t, os.IsNotExist(err), true)
+	_, err = tus.Upload("pending")
+	expect(t, err, nil)
+
+	// requests for unknown uploads don't leave locks behind
+	for _, id := range []string{"nope", "pending"} {
+		req := httptest.NewRequest("HEAD", "/uploads/"+id, nil)
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		m.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	expect(t, len(tus.locks), 0)
+
+	_, err = NewTus(rewriteStorage{storage}, TusOptions{}).Sweep()
+	refute(t, err, nil)
+}
@@ -0,0 +1,142 @@
+package martini
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// VirusScanner scans uploads with an external scanner, e.g. by streaming them to clamd.
+type VirusScanner interface {
+	// Scan returns whether the content is clean. An error means it couldn't be scanned.
+	Scan(r io.Reader) (clean bool, err error)
+}
+
+// UploadInspection checks the content of uploaded files, regardless of the type and name the client
+// claims for them.
+type UploadInspection struct {
+	// Types are the allowed types as detected from the magic bytes, e.g. "image/png" or "image/*".
+	// Empty allows all.
+	Types []string
+	// MaxWidth and MaxHeight limit the dimensions of images, 0 for no limit.
+	MaxWidth  int
+	MaxHeight int
+	// Scanner scans the files for malware. Optional.
+	Scanner VirusScanner
+}
+
+// Inspect checks the file, rewinding it before every check. Rejected files are reported as HTTPError
+// with status 415 Unsupported Media Type or 422 Unprocessable Entity, a failing scanner with 503
+// Service Unavailable.
+func (u *UploadInspection) Inspect(f io.ReadSeeker) error {
+	var head [512]byte
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	n, err := io.ReadFull(f, head[:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	detected := http.DetectContentType(head[:n])
+	if !allowedType(detected, u.Types) {
+		return HTTPError{Status: http.StatusUnsupportedMediaType, Message: fmt.Sprintf("file type %s not allowed", strings.SplitN(detected, ";", 2)[0])}
+	}
+
+	if strings.HasPrefix(detected, "image/") && (u.MaxWidth > 0 || u.MaxHeight > 0) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		config, _, err := image.DecodeConfig(f)
+		if err != nil {
+			return HTTPError{Status: http.StatusUnprocessableEntity, Message: "invalid image"}
+		}
+		if (u.MaxWidth > 0 && config.Width > u.MaxWidth) || (u.MaxHeight > 0 && config.Height > u.MaxHeight) {
+			return HTTPError{Status: http.StatusUnprocessableEntity, Message: fmt.Sprintf("image larger than %dx%d", u.MaxWidth, u.MaxHeight)}
+		}
+	}
+
+	if u.Scanner != nil {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		clean, err := u.Scanner.Scan(f)
+		if err != nil {
+			return HTTPError{Status: http.StatusServiceUnavailable, Message: "file could not be scanned"}
+		}
+		if !clean {
+			return HTTPError{Status: http.StatusUnprocessableEntity, Message: "file rejected by scanner"}
+		}
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+// InspectUploads returns a middleware handler parsing multipart forms and inspecting all uploaded
+// files, so rejected files never reach the handlers. Files over maxMemory bytes in total are kept
+// in temporary files, as with http.Request.ParseMultipartForm.
+func InspectUploads(u *UploadInspection, maxMemory int64) Handler {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+			return
+		}
+		if err := req.ParseMultipartForm(maxMemory); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, headers := range req.MultipartForm.File {
+			for _, h := range headers {
+				if err := inspectFileHeader(u, h); err != nil {
+					if e, ok := asHTTPError(err); ok {
+						writeHTTPError(res, req, e)
+						return
+					}
+					panic(err)
+				}
+			}
+		}
+	}
+}
+
+func inspectFileHeader(u *UploadInspection, h *multipart.FileHeader) error {
+	f, err := h.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return u.Inspect(f)
+}
+
+// inspectPart spools the part to a temporary file and inspects it. The returned file is positioned at
+// the start and removed on close.
+func inspectPart(u *UploadInspection, r io.Reader) (io.ReadCloser, error) {
+	f, err := ioutil.TempFile("", "martini-upload-")
+	if err != nil {
+		return nil, err
+	}
+	spooled := &tempFile{f}
+	if _, err := io.Copy(f, r); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	if err := u.Inspect(f); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	return spooled, nil
+}
+
+// tempFile is a temporary file removed on close.
+type tempFile struct {
+	*os.File
+}
+
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	os.Remove(t.File.Name())
+	return err
+}
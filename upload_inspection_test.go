@@ -0,0 +1,96 @@
+package martini
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeScanner struct {
+	err error
+}
+
+func (s fakeScanner) Scan(r io.Reader) (bool, error) {
+	b, _ := ioutil.ReadAll(r)
+	return !bytes.Contains(b, []byte("EICAR")), s.err
+}
+
+func pngBytes(w, h int) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, w, h)))
+	return buf.Bytes()
+}
+
+func Test_UploadInspection(t *testing.T) {
+	u := &UploadInspection{Types: []string{"image/*"}, MaxWidth: 100, MaxHeight: 100, Scanner: fakeScanner{}}
+	status := func(content []byte) int {
+		err := u.Inspect(bytes.NewReader(content))
+		if err == nil {
+			return 0
+		}
+		e, _ := asHTTPError(err)
+		return e.Status
+	}
+
+	expect(t, status(pngBytes(100, 50)), 0)
+	expect(t, status(pngBytes(101, 50)), http.StatusUnprocessableEntity)
+	expect(t, status([]byte("%PDF-1.4 not an image")), http.StatusUnsupportedMediaType)
+	expect(t, status(append(pngBytes(10, 10), "EICAR"...)), http.StatusUnprocessableEntity)
+
+	u.Scanner = fakeScanner{err: errors.New("clamd down")}
+	expect(t, status(pngBytes(10, 10)), http.StatusServiceUnavailable)
+}
+
+func Test_InspectUploads(t *testing.T) {
+	m := Classic()
+	m.Use(InspectUploads(&UploadInspection{Types: []string{"image/png"}}, 1<<20))
+	m.Post("/upload", func() string {
+		return "stored"
+	})
+
+	post := func(content []byte) *httptest.ResponseRecorder {
+		body, ctype := multipartBody(t, nil, map[string][2]string{"avatar": {"image/png", string(content)}})
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", ctype)
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		return res
+	}
+	expect(t, post(pngBytes(1, 1)).Body.String(), "stored")
+	res := post([]byte("<html>claims to be a png</html>"))
+	expect(t, res.Code, http.StatusUnsupportedMediaType)
+}
+
+func Test_MultipartStream_Inspection(t *testing.T) {
+	var received []byte
+	stream := NewMultipartStream().Handle("avatar", PartRule{MaxSize: 1 << 20, Inspection: &UploadInspection{Types: []string{"image/png"}}},
+		func(p *multipart.Part, r io.Reader) error {
+			received, _ = ioutil.ReadAll(r)
+			return nil
+		})
+
+	parse := func(content []byte) error {
+		body, ctype := multipartBody(t, nil, map[string][2]string{"avatar": {"image/png", string(content)}})
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", ctype)
+		_, err := stream.Parse(req)
+		return err
+	}
+
+	img := pngBytes(2, 2)
+	expect(t, parse(img), nil)
+	expect(t, bytes.Equal(received, img), true)
+
+	received = nil
+	err := parse([]byte(strings.Repeat("text", 10)))
+	refute(t, err, nil)
+	expect(t, len(received), 0)
+}
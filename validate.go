@@ -0,0 +1,135 @@
+package martini
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/codegangsta/inject"
+)
+
+// ValidationError lists the problems found by Validate.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "martini: invalid setup:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+// contextTypes are the services mapped by Martini on every request context.
+var contextTypes = []reflect.Type{
+	reflect.TypeOf((*http.Request)(nil)),
+	inject.InterfaceOf((*http.ResponseWriter)(nil)),
+	inject.InterfaceOf((*Context)(nil)),
+	reflect.TypeOf(BasePath("")),
+}
+
+// routeTypes are the services additionally mapped by the router for a matched route.
+var routeTypes = []reflect.Type{
+	reflect.TypeOf(Params(nil)),
+	reflect.TypeOf(Locale("")),
+	reflect.TypeOf(RouteInfo{}),
+	reflect.TypeOf((*Route)(nil)).Elem(),
+}
+
+// Validate checks that every argument of the middleware stack and the action can be injected, either
+// from the global injector or from the services Martini maps on each request. Services that middleware
+// maps at request time have to be declared with requestTypes, passing a value of the type or, for
+// interfaces, a pointer to it like MapTo: m.Validate((*Session)(nil)).
+// A *ValidationError listing every missing binding is returned, so problems show up before serving traffic.
+func (m *Martini) Validate(requestTypes ...interface{}) error {
+	v := m.validator(requestTypes)
+	v.check("middleware", m.handlers)
+	v.problems = append(v.problems, missingMiddleware(m.middleware)...)
+	v.check("action", []Handler{m.action})
+	return v.err()
+}
+
+// Validate is like Martini.Validate but also checks the handlers of every route and the NotFound handlers.
+// Route patterns are compiled as well, so invalid patterns are reported instead of failing on the first request.
+func (m *ClassicMartini) Validate(requestTypes ...interface{}) error {
+	v := m.validator(requestTypes)
+	v.check("middleware", m.handlers)
+	v.problems = append(v.problems, missingMiddleware(m.middleware)...)
+	if r, ok := m.Router.(*router); ok {
+		v.known = append(v.known, routeTypes...)
+		v.known = append(v.known, r.providedTypes()...)
+		for _, err := range r.compile() {
+			v.problems = append(v.problems, err.Error())
+		}
+		for _, c := range r.CheckConflicts() {
+			if c.Shadowed {
+				v.problems = append(v.problems, c.String())
+			}
+		}
+		for _, route := range r.index().routes {
+			v.check(route.String(), route.handlers)
+			v.check(route.String()+" after", route.after)
+		}
+		v.check("after", r.after)
+		v.check("NotFound", r.notFounds)
+		for _, nf := range r.groupNotFounds {
+			v.check("NotFound "+nf.pattern, nf.handlers)
+		}
+	}
+	return v.err()
+}
+
+type validator struct {
+	injector inject.Injector
+	registry *handlerRegistry
+	known    []reflect.Type
+	problems []string
+}
+
+func (m *Martini) validator(types []interface{}) *validator {
+	v := &validator{injector: m.Injector, registry: m.registry}
+	v.known = append(v.known, contextTypes...)
+	for _, t := range types {
+		typ := reflect.TypeOf(t)
+		if typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Interface {
+			typ = typ.Elem()
+		}
+		v.known = append(v.known, typ)
+	}
+	return v
+}
+
+func (v *validator) check(where string, handlers []Handler) {
+	for i, h := range handlers {
+		t := reflect.TypeOf(h)
+		for j := 0; j < t.NumIn(); j++ {
+			arg := t.In(j)
+			if !v.satisfied(arg) {
+				v.problems = append(v.problems, fmt.Sprintf("%s: handler %d (%s) needs %v, which is not mapped", where, i+1, v.registry.describe(h), arg))
+			}
+		}
+	}
+}
+
+func (v *validator) satisfied(t reflect.Type) bool {
+	for _, k := range v.known {
+		if k == t || (t.Kind() == reflect.Interface && k.Implements(t)) {
+			return true
+		}
+	}
+	return v.injector.Get(t).IsValid()
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{v.problems}
+}
+
+// handlerName returns the name of the function backing the handler.
+func handlerName(h Handler) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(h).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return "???"
+}
@@ -0,0 +1,41 @@
+package martini
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type validateService interface {
+	Foo()
+}
+
+func Test_Validate(t *testing.T) {
+	m := New()
+	m.Use(func(c Context, req *http.Request, res http.ResponseWriter) {})
+	m.Action(func(s string) {})
+	expect(t, m.Validate("request level string"), nil)
+
+	err := m.Validate()
+	refute(t, err, nil)
+	expect(t, len(err.(*ValidationError).Problems), 1)
+	expect(t, strings.Contains(err.Error(), "action: handler 1 (github.com/go-martini/martini.Test_Validate.func2) needs string, which is not mapped"), true)
+}
+
+func Test_ClassicMartini_Validate(t *testing.T) {
+	m := Classic()
+	m.Get("/foo/:id", func(params Params, routes Routes) {})
+	m.Post("/bar", func(c Context) {}, func(s validateService) {})
+	m.NotFound(func(n int) {})
+
+	err := m.Validate()
+	refute(t, err, nil)
+	problems := err.(*ValidationError).Problems
+	expect(t, len(problems), 2)
+	expect(t, strings.HasPrefix(problems[0], "POST /bar: handler 2"), true)
+	expect(t, strings.HasSuffix(problems[0], "needs martini.validateService, which is not mapped"), true)
+	expect(t, strings.HasPrefix(problems[1], "NotFound: handler 1"), true)
+
+	m.Map(42)
+	expect(t, m.Validate((*validateService)(nil)), nil)
+}
@@ -0,0 +1,33 @@
+package martini
+
+import (
+	gocontext "context"
+	"log"
+	"net/http"
+	"reflect"
+)
+
+// Warmup adds requests that Start, and so Run before listening, serves through ServeHTTP, e.g. to compile
+// templates and fill caches so the first real requests aren't slow. Responses are discarded, those with a
+// server error status are logged.
+//
+//	req, _ := http.NewRequest("GET", "/", nil)
+//	m.Warmup(req)
+func (m *Martini) Warmup(requests ...*http.Request) {
+	m.warmups = append(m.warmups, requests...)
+}
+
+// warmup serves the warmup requests in order.
+func (m *Martini) warmup(ctx gocontext.Context) {
+	logger := m.Injector.Get(reflect.TypeOf(m.logger)).Interface().(*log.Logger)
+	for _, req := range m.warmups {
+		if ctx.Err() != nil {
+			return
+		}
+		rec := &batchRecorder{header: make(http.Header)}
+		m.ServeHTTP(rec, req.WithContext(ctx))
+		if rec.status >= http.StatusInternalServerError {
+			logger.Printf("warmup %s %s failed with status %d\n", req.Method, req.URL.Path, rec.status)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package martini
+
+import (
+	"bytes"
+	gocontext "context"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_Warmup(t *testing.T) {
+	m := Classic()
+	buf := new(bytes.Buffer)
+	m.Map(log.New(buf, "", 0))
+
+	var served []string
+	started := false
+	m.OnStart(func(ctx gocontext.Context) error {
+		started = true
+		return nil
+	})
+	m.Get("/", func(req *http.Request) {
+		// warmup requests are served after the start hooks
+		expect(t, started, true)
+		served = append(served, req.URL.Path)
+	})
+	m.Get("/broken", func(res http.ResponseWriter) {
+		served = append(served, "/broken")
+		res.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	home, _ := http.NewRequest("GET", "/", nil)
+	broken, _ := http.NewRequest("GET", "/broken", nil)
+	m.Warmup(home, broken)
+	expect(t, len(served), 0)
+
+	expect(t, m.Start(gocontext.Background()), nil)
+	expect(t, strings.Join(served, ","), "/,/broken")
+	expect(t, strings.Contains(buf.String(), "warmup GET /broken failed with status 503\n"), true)
+	refute(t, strings.Contains(buf.String(), "warmup GET / "), true)
+}
@@ -0,0 +1,130 @@
+package martini
+
+import (
+	gocontext "context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// Watchdog samples the runtime stats and reports when the number of goroutines or the heap size crosses
+// a threshold: it logs a message, publishes an EventWatchdog and, with DumpDir set, writes a pprof profile
+// of the goroutines or the heap to disk. It reports again once the value dropped below the threshold and
+// crossed it anew.
+//
+//	m.Watch(&martini.Watchdog{MaxGoroutines: 10000, MaxHeap: 2 << 30, DumpDir: "/var/tmp/app"})
+type Watchdog struct {
+	// Interval is the time between samples. Defaults to 10s.
+	Interval time.Duration
+	// MaxGoroutines is the goroutine threshold. Zero disables it.
+	MaxGoroutines int
+	// MaxHeap is the threshold of allocated heap bytes. Zero disables it.
+	MaxHeap uint64
+	// DumpDir is the directory profiles are written to, e.g. "goroutine-20060102T150405.pprof". Empty
+	// disables profiles.
+	DumpDir string
+	// Logger logs the reports. Defaults to the logger mapped on the Martini instance with Watch.
+	Logger *log.Logger
+	// Events receives an EventWatchdog for each report. Defaults to the bus of the Martini instance with Watch.
+	Events *Events
+
+	mu    sync.Mutex
+	above map[string]bool
+}
+
+// Watch runs the watchdog while the Martini instance runs, between the start and the stop hooks.
+func (m *Martini) Watch(w *Watchdog) {
+	m.background(func(ctx gocontext.Context) {
+		w.Run(ctx)
+	}, func() {
+		if w.Logger == nil {
+			w.Logger = m.Injector.Get(reflect.TypeOf(m.logger)).Interface().(*log.Logger)
+		}
+		if w.Events == nil {
+			w.Events = m.events
+		}
+	})
+}
+
+// Run samples the runtime stats every Interval until ctx is done.
+func (w *Watchdog) Run(ctx gocontext.Context) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Check()
+		}
+	}
+}
+
+// Check samples the runtime stats once and reports the thresholds crossed since the last sample.
+func (w *Watchdog) Check() {
+	if w.MaxGoroutines > 0 {
+		w.check("goroutine", uint64(runtime.NumGoroutine()), uint64(w.MaxGoroutines))
+	}
+	if w.MaxHeap > 0 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		w.check("heap", stats.HeapAlloc, w.MaxHeap)
+	}
+}
+
+// check reports the value of the stat if it crossed the threshold.
+func (w *Watchdog) check(stat string, value uint64, threshold uint64) {
+	w.mu.Lock()
+	if w.above == nil {
+		w.above = make(map[string]bool)
+	}
+	crossed := value > threshold && !w.above[stat]
+	w.above[stat] = value > threshold
+	w.mu.Unlock()
+	if !crossed {
+		return
+	}
+
+	message := fmt.Sprintf("[watchdog] %s at %d, threshold is %d", stat, value, threshold)
+	if w.DumpDir != "" {
+		path, err := w.dump(stat)
+		if err != nil {
+			message += fmt.Sprintf(", writing profile failed: %v", err)
+		} else {
+			message += ", profile written to " + path
+		}
+	}
+	if w.Logger != nil {
+		w.Logger.Println(message)
+	}
+	if w.Events != nil {
+		w.Events.Publish(Event{Kind: EventWatchdog, Subject: stat, Value: value})
+	}
+}
+
+// dump writes the pprof profile of the stat to DumpDir and returns its path.
+func (w *Watchdog) dump(stat string) (string, error) {
+	if err := os.MkdirAll(w.DumpDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(w.DumpDir, fmt.Sprintf("%s-%s.pprof", stat, time.Now().Format("20060102T150405.000")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	err = pprof.Lookup(stat).WriteTo(f, 0)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return path, err
+}
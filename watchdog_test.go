@@ -0,0 +1,72 @@
+package martini
+
+import (
+	"bytes"
+	gocontext "context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Watchdog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchdog")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	buf := new(bytes.Buffer)
+	events := NewEvents()
+	var reported []Event
+	events.Subscribe(EventWatchdog, func(e Event) {
+		reported = append(reported, e)
+	})
+	w := &Watchdog{MaxGoroutines: 1, MaxHeap: 1, DumpDir: dir, Logger: log.New(buf, "", 0), Events: events}
+
+	w.Check()
+	expect(t, len(reported), 2)
+	expect(t, reported[0].Subject, "goroutine")
+	expect(t, reported[0].Value > 1, true)
+	expect(t, reported[1].Subject, "heap")
+	expect(t, strings.Contains(buf.String(), "[watchdog] goroutine at "), true)
+	expect(t, strings.Contains(buf.String(), ", threshold is 1, profile written to "+dir), true)
+	goroutines, _ := filepath.Glob(filepath.Join(dir, "goroutine-*.pprof"))
+	heaps, _ := filepath.Glob(filepath.Join(dir, "heap-*.pprof"))
+	expect(t, len(goroutines), 1)
+	expect(t, len(heaps), 1)
+
+	// still above the thresholds, nothing new to report
+	w.Check()
+	expect(t, len(reported), 2)
+
+	// below the threshold again, the next crossing is reported
+	w.MaxGoroutines = 1 << 20
+	w.Check()
+	w.MaxGoroutines = 1
+	w.Check()
+	expect(t, len(reported), 3)
+	expect(t, reported[2].Subject, "goroutine")
+}
+
+func Test_WatchdogLifecycle(t *testing.T) {
+	m := New()
+	buf := new(bytes.Buffer)
+	m.Map(log.New(buf, "", 0))
+	reported := make(chan Event, 1)
+	m.Events().Subscribe(EventWatchdog, func(e Event) {
+		reported <- e
+	})
+	m.Watch(&Watchdog{Interval: time.Millisecond, MaxGoroutines: 1})
+
+	expect(t, m.Start(gocontext.Background()), nil)
+	select {
+	case e := <-reported:
+		expect(t, e.Subject, "goroutine")
+	case <-time.After(time.Second):
+		t.Fatal("no watchdog event")
+	}
+	expect(t, m.Stop(gocontext.Background()), nil)
+	expect(t, strings.Contains(buf.String(), "[watchdog] goroutine at "), true)
+}
@@ -0,0 +1,369 @@
+package martini
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrDeliveryNotFound is returned for unknown webhook delivery IDs.
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+// WebhookEndpoint is a URL receiving webhooks.
+type WebhookEndpoint struct {
+	ID  string
+	URL string
+	// Secret signs the payloads. Deliveries fail without a Secret or SecretRef.
+	Secret []byte
+	// SecretRef names the secret signing the payloads instead of Secret, so it can be rotated.
+	SecretRef SecretRef
+	// Events are the events sent to the endpoint, all if empty.
+	Events []string
+}
+
+// WebhookDelivery is the delivery of an event to an endpoint.
+type WebhookDelivery struct {
+	ID         string `json:"id"`
+	EndpointID string `json:"endpoint_id"`
+	Event      string `json:"event"`
+	Payload    []byte `json:"payload"`
+	Attempts   int    `json:"attempts"`
+	Status     int    `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Delivered  bool   `json:"delivered"`
+	// Dead is set when all attempts failed. Dead deliveries can be sent again with Redeliver.
+	Dead        bool      `json:"dead"`
+	Created     time.Time `json:"created"`
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+}
+
+// WebhookFilter selects deliveries from the delivery log. Empty fields match all deliveries.
+type WebhookFilter struct {
+	EndpointID string
+	Event      string
+	// State is "pending", "delivered" or "dead".
+	State string
+}
+
+func (f WebhookFilter) match(d *WebhookDelivery) bool {
+	state := "pending"
+	if d.Delivered {
+		state = "delivered"
+	} else if d.Dead {
+		state = "dead"
+	}
+	return (f.EndpointID == "" || f.EndpointID == d.EndpointID) && (f.Event == "" || f.Event == d.Event) && (f.State == "" || f.State == state)
+}
+
+// WebhookStore is the delivery log.
+type WebhookStore interface {
+	Save(d *WebhookDelivery) error
+	// Get returns the delivery with the ID or ErrDeliveryNotFound.
+	Get(id string) (*WebhookDelivery, error)
+	// Find returns the matching deliveries, newest first.
+	Find(f WebhookFilter) ([]*WebhookDelivery, error)
+}
+
+// Webhooks sends events to the registered endpoints in the background. Payloads are signed with the
+// secret of the endpoint in the Webhook-Signature header as t=<unix time>,v1=<hex HMAC-SHA256 of the
+// time, a dot and the body>. Failed deliveries are retried with exponential backoff and end up dead
+// after MaxAttempts, all deliveries are kept in the store for inspection.
+type Webhooks struct {
+	// Client sends the webhooks. Defaults to a client with a 10 second timeout.
+	Client *http.Client
+	// MaxAttempts is the number of attempts before a delivery is dead. Defaults to 5.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled for every further one. Defaults to 10 seconds.
+	Backoff time.Duration
+	// Retry replaces MaxAttempts and Backoff with its policy if set, e.g. so endpoints answering 4xx
+	// aren't retried. Its metrics count the deliveries.
+	Retry *Retryer
+
+	store     WebhookStore
+	mu        sync.Mutex
+	endpoints map[string]WebhookEndpoint
+	queue     chan string
+	timers    map[*time.Timer]bool
+	closed    bool
+	pending   sync.WaitGroup
+	workers   sync.WaitGroup
+}
+
+// NewWebhooks creates Webhooks logging deliveries to the store and sending them with the number of
+// workers.
+func NewWebhooks(store WebhookStore, workers int) *Webhooks {
+	w := &Webhooks{
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 5,
+		Backoff:     10 * time.Second,
+		store:       store,
+		endpoints:   make(map[string]WebhookEndpoint),
+		queue:       make(chan string, 1024),
+		timers:      make(map[*time.Timer]bool),
+	}
+	for i := 0; i < workers; i++ {
+		w.workers.Add(1)
+		go w.work()
+	}
+	return w
+}
+
+// Register adds the endpoint, replacing the one with the same ID.
+func (w *Webhooks) Register(e WebhookEndpoint) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.endpoints[e.ID] = e
+}
+
+// Unregister removes the endpoint with the ID. Its pending deliveries are dropped.
+func (w *Webhooks) Unregister(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.endpoints, id)
+}
+
+// Emit sends the event with the payload encoded as JSON to the endpoints subscribed to it.
+func (w *Webhooks) Emit(event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	var endpoints []WebhookEndpoint
+	for _, e := range w.endpoints {
+		if len(e.Events) == 0 || containsString(e.Events, event) {
+			endpoints = append(endpoints, e)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, e := range endpoints {
+		d := &WebhookDelivery{ID: randomToken(16), EndpointID: e.ID, Event: event, Payload: data, Created: time.Now()}
+		if err := w.store.Save(d); err != nil {
+			return err
+		}
+		w.enqueue(d.ID, 0)
+	}
+	return nil
+}
+
+// Redeliver sends a dead delivery again, with a fresh set of attempts.
+func (w *Webhooks) Redeliver(id string) error {
+	d, err := w.store.Get(id)
+	if err != nil {
+		return err
+	}
+	d.Dead, d.Attempts = false, 0
+	if err := w.store.Save(d); err != nil {
+		return err
+	}
+	w.enqueue(d.ID, 0)
+	return nil
+}
+
+// enqueue queues the delivery after the delay.
+func (w *Webhooks) enqueue(id string, delay time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.pending.Add(1)
+	if delay == 0 {
+		go func() { w.queue <- id }()
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		w.mu.Lock()
+		delete(w.timers, timer)
+		w.mu.Unlock()
+		w.queue <- id
+	})
+	w.timers[timer] = true
+}
+
+func (w *Webhooks) work() {
+	defer w.workers.Done()
+	for id := range w.queue {
+		w.deliver(id)
+		w.pending.Done()
+	}
+}
+
+func (w *Webhooks) deliver(id string) {
+	d, err := w.store.Get(id)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	e, ok := w.endpoints[d.EndpointID]
+	closed := w.closed
+	w.mu.Unlock()
+	if !ok || d.Delivered {
+		return
+	}
+
+	d.Attempts++
+	d.LastAttempt = time.Now()
+	d.Status, err = w.send(e, d)
+	delay := w.Backoff << uint(d.Attempts-1)
+	switch {
+	case err == nil:
+		d.Delivered, d.Error = true, ""
+	case w.Retry != nil:
+		d.Error = err.Error()
+		d.Dead = !w.Retry.Retry(d.Attempts, err)
+		delay = w.Retry.Delay(d.Attempts)
+	default:
+		d.Error = err.Error()
+		d.Dead = d.Attempts >= w.MaxAttempts
+	}
+	if w.Retry != nil {
+		w.Retry.count(d.Attempts, d.Dead)
+	}
+	w.store.Save(d)
+	if !d.Delivered && !d.Dead && !closed {
+		w.enqueue(d.ID, delay)
+	}
+}
+
+// send posts the delivery to the endpoint. Any status other than 2xx is a failure.
+func (w *Webhooks) send(e WebhookEndpoint, d *WebhookDelivery) (int, error) {
+	req, err := http.NewRequest("POST", e.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	keys, err := e.SecretRef.keys(e.Secret)
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Webhook-Id", d.ID)
+	req.Header.Set("Webhook-Event", d.Event)
+	req.Header.Set("Webhook-Signature", "t="+timestamp+",v1="+signWebhook(keys[0], timestamp, d.Payload))
+	res, err := w.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, webhookStatusError(res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// webhookStatusError is the failure of a delivery answered with a status other than 2xx.
+type webhookStatusError int
+
+func (e webhookStatusError) Error() string {
+	return fmt.Sprintf("endpoint answered %d", int(e))
+}
+
+func (e webhookStatusError) StatusCode() int {
+	return int(e)
+}
+
+func signWebhook(secret []byte, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliveries queries the delivery log.
+func (w *Webhooks) Deliveries(f WebhookFilter) ([]*WebhookDelivery, error) {
+	return w.store.Find(f)
+}
+
+// Handler returns a handler answering with the deliveries matching the endpoint, event and state
+// query params as JSON, for an admin API.
+func (w *Webhooks) Handler() Handler {
+	return func(res http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		deliveries, err := w.Deliveries(WebhookFilter{EndpointID: q.Get("endpoint"), Event: q.Get("event"), State: q.Get("state")})
+		if err != nil {
+			panic(err)
+		}
+		if deliveries == nil {
+			deliveries = []*WebhookDelivery{}
+		}
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(deliveries)
+	}
+}
+
+// Wait waits until all queued deliveries, including retries, have been attempted.
+func (w *Webhooks) Wait() {
+	w.pending.Wait()
+}
+
+// Close stops the workers after the queued deliveries have been attempted. Retries scheduled for later
+// are dropped, they stay pending in the store.
+func (w *Webhooks) Close() {
+	w.mu.Lock()
+	w.closed = true
+	for timer := range w.timers {
+		if timer.Stop() {
+			w.pending.Done()
+		}
+	}
+	w.timers = nil
+	w.mu.Unlock()
+	w.pending.Wait()
+	close(w.queue)
+	w.workers.Wait()
+}
+
+// NewMemoryWebhookStore creates a WebhookStore keeping the deliveries in memory.
+func NewMemoryWebhookStore() WebhookStore {
+	return &memoryWebhookStore{deliveries: make(map[string]WebhookDelivery)}
+}
+
+type memoryWebhookStore struct {
+	mu         sync.Mutex
+	deliveries map[string]WebhookDelivery
+}
+
+func (m *memoryWebhookStore) Save(d *WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[d.ID] = *d
+	return nil
+}
+
+func (m *memoryWebhookStore) Get(id string) (*WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	return &d, nil
+}
+
+func (m *memoryWebhookStore) Find(f WebhookFilter) ([]*WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var found []*WebhookDelivery
+	for _, d := range m.deliveries {
+		if d := d; f.match(&d) {
+			found = append(found, &d)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Created.After(found[j].Created) })
+	return found, nil
+}
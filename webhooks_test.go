@@ -0,0 +1,140 @@
+package martini
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Webhooks(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+		fails    = 2
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		sig := req.Header.Get("Webhook-Signature")
+		parts := strings.SplitN(sig, ",", 2)
+		expect(t, parts[1], "v1="+signWebhook([]byte("secret"), parts[0][2:], body))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if req.URL.Path == "/flaky" && fails > 0 {
+			fails--
+			res.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if req.URL.Path == "/down" {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		received = append(received, req.URL.Path+" "+req.Header.Get("Webhook-Event")+" "+string(body))
+	}))
+	defer server.Close()
+
+	w := NewWebhooks(NewMemoryWebhookStore(), 2)
+	w.Backoff = time.Millisecond
+	w.MaxAttempts = 3
+	w.Register(WebhookEndpoint{ID: "all", URL: server.URL + "/all", Secret: []byte("secret")})
+	w.Register(WebhookEndpoint{ID: "flaky", URL: server.URL + "/flaky", Secret: []byte("secret"), Events: []string{"order.paid"}})
+	w.Register(WebhookEndpoint{ID: "down", URL: server.URL + "/down", Secret: []byte("secret"), Events: []string{"order.paid"}})
+
+	expect(t, w.Emit("order.created", map[string]int{"id": 1}), nil)
+	expect(t, w.Emit("order.paid", map[string]int{"id": 1}), nil)
+	w.Wait()
+
+	mu.Lock()
+	expect(t, len(received), 3)
+	mu.Unlock()
+
+	flaky, _ := w.Deliveries(WebhookFilter{EndpointID: "flaky"})
+	expect(t, len(flaky), 1)
+	expect(t, flaky[0].Attempts, 3)
+	expect(t, flaky[0].Delivered, true)
+
+	dead, _ := w.Deliveries(WebhookFilter{State: "dead"})
+	expect(t, len(dead), 1)
+	expect(t, dead[0].EndpointID, "down")
+	expect(t, dead[0].Status, http.StatusInternalServerError)
+	expect(t, dead[0].Error, "endpoint answered 500")
+
+	m := Classic()
+	m.Get("/webhooks/deliveries", w.Handler())
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/webhooks/deliveries?state=delivered&event=order.paid", nil))
+	var deliveries []WebhookDelivery
+	expect(t, json.Unmarshal(res.Body.Bytes(), &deliveries), nil)
+	expect(t, len(deliveries), 2)
+	for _, d := range deliveries {
+		expect(t, d.Event, "order.paid")
+		expect(t, d.Delivered, true)
+	}
+
+	w.Register(WebhookEndpoint{ID: "down", URL: server.URL + "/all", Secret: []byte("secret")})
+	expect(t, w.Redeliver(dead[0].ID), nil)
+	w.Wait()
+	redelivered, _ := w.Deliveries(WebhookFilter{EndpointID: "down"})
+	expect(t, redelivered[0].Delivered, true)
+
+	w.Close()
+	expect(t, w.Redeliver("missing"), ErrDeliveryNotFound)
+}
+
+func Test_Webhooks_Close(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	w := NewWebhooks(NewMemoryWebhookStore(), 1)
+	w.Backoff = time.Hour
+	w.Register(WebhookEndpoint{ID: "a", URL: server.URL, Secret: []byte("secret")})
+	w.Emit("ping", nil)
+
+	done := make(chan struct{})
+	go func() {
+		w.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close waited for a scheduled retry")
+	}
+	pending, _ := w.Deliveries(WebhookFilter{State: "pending"})
+	expect(t, len(pending), 1)
+	expect(t, pending[0].Attempts, 1)
+}
+
+func Test_Webhooks_Retryer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/gone" {
+			res.WriteHeader(http.StatusGone)
+			return
+		}
+		res.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	w := NewWebhooks(NewMemoryWebhookStore(), 1)
+	w.Retry = &Retryer{MaxAttempts: 2, Backoff: time.Millisecond}
+	w.Register(WebhookEndpoint{ID: "gone", URL: server.URL + "/gone", Secret: []byte("secret")})
+	w.Register(WebhookEndpoint{ID: "flaky", URL: server.URL + "/flaky", Secret: []byte("secret")})
+	w.Emit("ping", nil)
+	w.Wait()
+	w.Close()
+
+	gone, _ := w.Deliveries(WebhookFilter{EndpointID: "gone"})
+	expect(t, gone[0].Dead, true)
+	expect(t, gone[0].Attempts, 1)
+	flaky, _ := w.Deliveries(WebhookFilter{EndpointID: "flaky"})
+	expect(t, flaky[0].Dead, true)
+	expect(t, flaky[0].Attempts, 2)
+	expect(t, w.Retry.Metrics(), RetryMetrics{Calls: 2, Retries: 1, Failures: 2})
+}